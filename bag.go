@@ -0,0 +1,184 @@
+package suk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+)
+
+// Bag is a session made of named fields, which handlers change one at a time
+// with SetField and DeleteField. Only the fields changed since the bag was
+// retrieved are written by SaveBag, over the ones stored meanwhile, so
+// handlers changing different fields of the same session don't undo each
+// other. Bags are not safe for concurrent use.
+//
+// Bags are kept as they are in the in-memory storage, and serialized by the
+// codec given to WithCodec, if any. For every other backend, such as Redis,
+// they are serialized to JSON, so their fields must be supported by
+// encoding/json, and numbers come back as float64.
+type Bag struct {
+	fields map[string]any
+
+	// changed holds the fields changed since the bag was retrieved or saved,
+	// which are true when set and false when deleted.
+	changed map[string]bool
+}
+
+// NewBag returns an empty bag.
+func NewBag() *Bag {
+	return &Bag{fields: make(map[string]any), changed: make(map[string]bool)}
+}
+
+// GetField returns the field with the given name, if there's any.
+func (b *Bag) GetField(name string) (any, bool) {
+	v, ok := b.fields[name]
+	return v, ok
+}
+
+// SetField sets the field with the given name to v.
+func (b *Bag) SetField(name string, v any) {
+	b.fields[name] = v
+	b.changed[name] = true
+}
+
+// DeleteField deletes the field with the given name.
+func (b *Bag) DeleteField(name string) {
+	delete(b.fields, name)
+	b.changed[name] = false
+}
+
+// Fields returns a copy of the fields of the bag.
+func (b *Bag) Fields() map[string]any {
+	return maps.Clone(b.fields)
+}
+
+// Dirty tells whether any field was changed since the bag was retrieved or
+// saved.
+func (b *Bag) Dirty() bool {
+	return len(b.changed) > 0
+}
+
+// apply applies the fields changed in the bag to fields.
+func (b *Bag) apply(fields map[string]any) {
+	for name, set := range b.changed {
+		if set {
+			fields[name] = b.fields[name]
+		} else {
+			delete(fields, name)
+		}
+	}
+}
+
+// encodeBag returns the fields as stored in the backend.
+func (ss *SessionStorage) encodeBag(fields map[string]any) (any, error) {
+	if ss.keepsValues() {
+		return fields, nil
+	}
+
+	return json.Marshal(fields)
+}
+
+// decodeBag returns the fields of the bag stored as data, which are copied, so
+// they can be changed without changing the stored ones.
+func decodeBag(data any) (map[string]any, error) {
+	var raw []byte
+	switch d := data.(type) {
+	case map[string]any:
+		return maps.Clone(d), nil
+	case string:
+		raw = []byte(d)
+	case []byte:
+		raw = d
+	default:
+		return nil, fmt.Errorf("%w: got %T", ErrUnexpectedSessionType, data)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnexpectedSessionType, err)
+	}
+
+	if fields == nil {
+		fields = make(map[string]any)
+	}
+
+	return fields, nil
+}
+
+// SetBag assigns the bag as a session and returns a key for it, as Set does.
+func (ss *SessionStorage) SetBag(b *Bag, opts ...SetOption) (string, error) {
+	return ss.SetBagCtx(ss.ctx, b, opts...)
+}
+
+// SetBagCtx is like SetBag, but the storage operations run on the given
+// context.
+func (ss *SessionStorage) SetBagCtx(ctx context.Context, b *Bag, opts ...SetOption) (string, error) {
+	data, err := ss.encodeBag(maps.Clone(b.fields))
+	if err != nil {
+		return "", err
+	}
+
+	key, err := ss.SetCtx(ctx, data, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	clear(b.changed)
+	return key, nil
+}
+
+// GetBag retrieves the bag stored under key and generates a new key for it, as
+// Get does. It fails with ErrUnexpectedSessionType when the session is not a
+// bag.
+func (ss *SessionStorage) GetBag(key string) (*Bag, string, error) {
+	return ss.GetBagCtx(ss.ctx, key)
+}
+
+// GetBagCtx is like GetBag, but the storage operations run on the given
+// context.
+func (ss *SessionStorage) GetBagCtx(ctx context.Context, key string) (*Bag, string, error) {
+	data, newKey, err := ss.GetCtx(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fields, err := decodeBag(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &Bag{fields: fields, changed: make(map[string]bool)}, newKey, nil
+}
+
+// SaveBag writes the fields of the bag changed since it was retrieved or saved
+// to the bag stored under key, keeping the key, as Update does. Fields changed
+// meanwhile by others are kept, unless the bag changed them too. Bags without
+// changes are not written.
+func (ss *SessionStorage) SaveBag(key string, b *Bag) error {
+	return ss.SaveBagCtx(ss.ctx, key, b)
+}
+
+// SaveBagCtx is like SaveBag, but the storage operations run on the given
+// context.
+func (ss *SessionStorage) SaveBagCtx(ctx context.Context, key string, b *Bag) error {
+	if !b.Dirty() {
+		return nil
+	}
+
+	err := ss.update(ctx, key, func(old any) (any, error) {
+		fields, err := decodeBag(old)
+		if err != nil {
+			return nil, err
+		}
+
+		b.apply(fields)
+		return ss.encodeBag(fields)
+	})
+	if err != nil {
+		return err
+	}
+
+	clear(b.changed)
+	return nil
+}
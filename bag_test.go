@@ -0,0 +1,135 @@
+package suk
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBag(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Sync map": syncMapStorage,
+		"Redis":    redisStorage,
+		"SQLite":   sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" fields survive the backend", func(t *testing.T) {
+			b := NewBag()
+			b.SetField("user", "ed")
+
+			key, err := ss.SetBag(b)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			got, _, err := ss.GetBag(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if v, ok := got.GetField("user"); !ok || v != "ed" {
+				t.Errorf("got %v expected %q", v, "ed")
+			}
+		})
+
+		t.Run(name+" changed fields are saved", func(t *testing.T) {
+			b := NewBag()
+			b.SetField("user", "ed")
+			b.SetField("theme", "dark")
+			b.SetField("cart", "empty")
+			key, _ := ss.SetBag(b)
+
+			b, key, _ = ss.GetBag(key)
+			b.SetField("theme", "light")
+			b.DeleteField("cart")
+			if err := ss.SaveBag(key, b); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if b.Dirty() {
+				t.Errorf("got %v expected %v", b.Dirty(), false)
+			}
+
+			fields, _ := decodeBag(mustPeek(t, ss, key))
+			if fields["user"] != "ed" || fields["theme"] != "light" || len(fields) != 2 {
+				t.Errorf("got %v expected the user and the light theme", fields)
+			}
+		})
+	}
+
+	t.Run("Concurrent changes of different fields are kept", func(t *testing.T) {
+		ss, _ := New()
+		b := NewBag()
+		b.SetField("theme", "dark")
+		b.SetField("cart", "empty")
+		key, _ := ss.SetBag(b)
+
+		first, key, _ := ss.GetBag(key)
+		second, _ := decodeBag(mustPeek(t, ss, key))
+		secondBag := &Bag{fields: second, changed: make(map[string]bool)}
+
+		first.SetField("theme", "light")
+		secondBag.DeleteField("cart")
+		ss.SaveBag(key, first)
+		ss.SaveBag(key, secondBag)
+
+		got, _, _ := ss.GetBag(key)
+		if v, _ := got.GetField("theme"); v != "light" {
+			t.Errorf("got %v expected %q", v, "light")
+		}
+
+		if _, ok := got.GetField("cart"); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+	})
+
+	t.Run("Changes are not seen before being saved", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.SetBag(NewBag())
+
+		b, key, _ := ss.GetBag(key)
+		b.SetField("user", "ed")
+
+		if got, _ := ss.Peek(key); len(got.(map[string]any)) != 0 {
+			t.Errorf("got %v expected no fields", got)
+		}
+	})
+
+	t.Run("Clean bags are not written", func(t *testing.T) {
+		ss, _ := New()
+
+		if err := ss.SaveBag("unknown", NewBag()); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Sessions that are not bags", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		if _, _, err := ss.GetBag(key); !errors.Is(err, ErrUnexpectedSessionType) {
+			t.Errorf("got %v expected %v", err, ErrUnexpectedSessionType)
+		}
+	})
+}
+
+func mustPeek(t *testing.T, ss *SessionStorage, key string) any {
+	t.Helper()
+
+	data, err := ss.Peek(key)
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+
+	return data
+}
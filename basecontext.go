@@ -0,0 +1,44 @@
+package suk
+
+import (
+	"context"
+)
+
+// bind returns a context derived from ctx that is also canceled once the base
+// context given to WithBaseContext is, along with the function releasing it.
+func (ss *SessionStorage) bind(ctx context.Context) (context.Context, func()) {
+	base := ss.config.baseCtx
+	if base == nil || ctx == base {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(base, func() {
+		cancel(context.Cause(base))
+	})
+
+	return ctx, func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// stopBackground stops every background goroutine, such as the scheduled jobs
+// and the workers of the backend. It is safe to call more than once.
+func (ss *SessionStorage) stopBackground() {
+	ss.stopOnce.Do(func() {
+		for _, stop := range ss.stops {
+			stop()
+		}
+
+		ss.policyMu.Lock()
+		if ss.stopAutoClear != nil {
+			ss.stopAutoClear()
+		}
+		ss.policyMu.Unlock()
+
+		if ss.stopChannel != nil {
+			close(ss.stopChannel)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBaseContext(t *testing.T) {
+	t.Run("Nil base context", func(t *testing.T) {
+		_, err := New(WithBaseContext(nil))
+
+		if !errors.Is(err, ErrNilBaseContext) {
+			t.Errorf("got %v expected %v", err, ErrNilBaseContext)
+		}
+	})
+
+	t.Run("Background jobs stop with the base context", func(t *testing.T) {
+		base, cancel := context.WithCancel(context.Background())
+		sched := new(ManualScheduler)
+		ss, _ := New(WithBaseContext(base), WithScheduler(sched), WithAutoClearExpiredKeys())
+		defer Destroy(ss)
+
+		cancel()
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			sched.mu.Lock()
+			jobs := len(sched.jobs)
+			sched.mu.Unlock()
+
+			if jobs == 0 {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatalf("got %d expected %d", jobs, 0)
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	t.Run("Operations fail once the base context is canceled", func(t *testing.T) {
+		errShutdown := errors.New("shutting down")
+		base, cancel := context.WithCancelCause(context.Background())
+		ss, _ := New(WithBaseContext(base))
+
+		key, _ := ss.Set("session")
+		cancel(errShutdown)
+
+		if _, err := ss.Set("session"); !errors.Is(err, errShutdown) {
+			t.Errorf("got %v expected %v", err, errShutdown)
+		}
+
+		if _, _, err := ss.GetCtx(context.Background(), key); !errors.Is(err, errShutdown) {
+			t.Errorf("got %v expected %v", err, errShutdown)
+		}
+	})
+
+	t.Run("Operations in flight are canceled along with the base context", func(t *testing.T) {
+		base, cancel := context.WithCancel(context.Background())
+		ss, _ := New(WithBaseContext(base))
+
+		ctx, release := ss.bind(context.Background())
+		defer release()
+
+		cancel()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Errorf("got %v expected %v", nil, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,592 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// batchStorage is implemented by storages that can set and remove many entries
+// at once, such as with a single lock acquisition per shard in memory or a
+// single pipeline on Redis. Both report the error of every key, in order.
+// Storages not implementing it have their entries set and removed one at a
+// time.
+type batchStorage interface {
+	setMany(ctx context.Context, keys []string, entries []Entry) []error
+	removeMany(ctx context.Context, keys []string) ([]Entry, []error)
+}
+
+// byShard groups the indexes of the keys by the shard holding them.
+func (m *shardedMap) byShard(keys []string) map[*shard][]int {
+	shards := make(map[*shard][]int)
+	for i, key := range keys {
+		s := m.shardOf(key)
+		shards[s] = append(shards[s], i)
+	}
+
+	return shards
+}
+
+func (m *shardedMap) setMany(_ context.Context, keys []string, entries []Entry) []error {
+	errs := make([]error, len(keys))
+	for s, indexes := range m.byShard(keys) {
+		s.mu.Lock()
+		for _, i := range indexes {
+			if _, ok := s.entries[keys[i]]; ok {
+				errs[i] = ErrKeyExists
+				continue
+			}
+
+			e := entries[i]
+			if e.scratch == nil {
+				e.scratch = new(sync.Map)
+			}
+			s.put(keys[i], e)
+		}
+		s.mu.Unlock()
+	}
+
+	return errs
+}
+
+func (m *shardedMap) removeMany(_ context.Context, keys []string) ([]Entry, []error) {
+	entries := make([]Entry, len(keys))
+	errs := make([]error, len(keys))
+	for s, indexes := range m.byShard(keys) {
+		s.mu.Lock()
+		for _, i := range indexes {
+			e, ok := s.entries[keys[i]]
+			if !ok {
+				errs[i] = ErrNoKeyFound
+				continue
+			}
+
+			delete(s.entries, keys[i])
+			entries[i] = e
+		}
+		s.mu.Unlock()
+	}
+
+	return entries, errs
+}
+
+// setMany pipelines a SETNX for every entry. Errors are read from each
+// command, so the error of the pipeline itself is left out.
+func (r *redisDB) setMany(ctx context.Context, keys []string, entries []Entry) []error {
+	errs := make([]error, len(keys))
+	cmds := make([]*redis.Cmd, len(keys))
+
+	// Scripts are not loaded within pipelines, so they are sent whole.
+	r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			redisKeys, args, err := r.setArgs(key, entries[i])
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+
+			cmds[i] = setScript.Eval(ctx, pipe, redisKeys, args...)
+		}
+		return nil
+	})
+
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+
+		if ok, err := cmd.Bool(); err != nil {
+			errs[i] = err
+		} else if !ok {
+			errs[i] = ErrKeyExists
+		}
+	}
+
+	return errs
+}
+
+func (r *redisDB) removeMany(ctx context.Context, keys []string) ([]Entry, []error) {
+	p := r.prefixes.Load()
+
+	entries, errs := r.removeManyPrefixed(ctx, r.epochPrefix(p.current), keys)
+	if p.migrating {
+		retryMissing(keys, entries, errs, func(keys []string) ([]Entry, []error) {
+			return r.removeManyPrefixed(ctx, r.epochPrefix(p.previous), keys)
+		})
+	}
+
+	return entries, errs
+}
+
+// removeManyPrefixed removes the keys with the given prefix in a single
+// transaction.
+func (r *redisDB) removeManyPrefixed(ctx context.Context, prefix string, keys []string) ([]Entry, []error) {
+	gets := make([]*redis.StringCmd, len(keys))
+	pttls := make([]*redis.DurationCmd, len(keys))
+
+	r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			stored := prefix + key
+			pttls[i] = pipe.PTTL(ctx, stored)
+			gets[i] = pipe.GetDel(ctx, stored)
+		}
+		return nil
+	})
+
+	entries := make([]Entry, len(keys))
+	errs := make([]error, len(keys))
+	for i := range keys {
+		entries[i], errs[i] = r.entry(gets[i], pttls[i], gets[i].Err())
+	}
+
+	return entries, errs
+}
+
+func (b *bufferedStorage) setMany(ctx context.Context, keys []string, entries []Entry) []error {
+	errs := b.redisDB.setMany(ctx, keys, entries)
+	for i, err := range errs {
+		if isOutage(err) && b.local.len() < b.maxSize {
+			errs[i] = b.local.Set(ctx, keys[i], entries[i])
+		}
+	}
+
+	return errs
+}
+
+func (b *bufferedStorage) removeMany(ctx context.Context, keys []string) ([]Entry, []error) {
+	entries, errs := b.local.removeMany(ctx, keys)
+	retryMissing(keys, entries, errs, func(keys []string) ([]Entry, []error) {
+		return b.redisDB.removeMany(ctx, keys)
+	})
+
+	return entries, errs
+}
+
+// retryMissing removes the keys that were not found again with remove, filling
+// their entries and errors in.
+func retryMissing(keys []string, entries []Entry, errs []error, remove func([]string) ([]Entry, []error)) {
+	var missing []int
+	for i, err := range errs {
+		if err == ErrNoKeyFound {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	retried := make([]string, len(missing))
+	for j, i := range missing {
+		retried[j] = keys[i]
+	}
+
+	retriedEntries, retriedErrs := remove(retried)
+	for j, i := range missing {
+		entries[i], errs[i] = retriedEntries[j], retriedErrs[j]
+	}
+}
+
+// setMany stores every entry under its key, in a single batch when the storage
+// supports it.
+func (ss *SessionStorage) setMany(ctx context.Context, keys []string, entries []Entry) []error {
+	stored := ss.storedKeys(keys)
+	if b, ok := ss.storage.(batchStorage); ok {
+		return b.setMany(ctx, stored, entries)
+	}
+
+	errs := make([]error, len(keys))
+	for i, key := range stored {
+		errs[i] = ss.storage.Set(ctx, key, entries[i])
+	}
+
+	return errs
+}
+
+// removeMany removes the entries stored under every key, in a single batch
+// when the storage supports it.
+func (ss *SessionStorage) removeMany(ctx context.Context, keys []string) ([]Entry, []error) {
+	stored := ss.storedKeys(keys)
+	if b, ok := ss.storage.(batchStorage); ok {
+		return b.removeMany(ctx, stored)
+	}
+
+	entries := make([]Entry, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range stored {
+		entries[i], errs[i] = ss.storage.Remove(ctx, key)
+	}
+
+	return entries, errs
+}
+
+// insertMany stores every entry under a newly generated key, generating
+// another one whenever the key is already in use, and reports the error of
+// every entry.
+func (ss *SessionStorage) insertMany(ctx context.Context, entries []Entry) ([]string, []error) {
+	p := ss.currentPolicy()
+	keys := make([]string, len(entries))
+	errs := make([]error, len(entries))
+
+	pending := make([]int, len(entries))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for len(pending) > 0 {
+		var (
+			batch        []int
+			batchKeys    []string
+			batchEntries []Entry
+		)
+
+		for _, i := range pending {
+			key, err := p.rkg(p.keyLengthFor(entries[i].Data))
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+
+			batch = append(batch, i)
+			batchKeys = append(batchKeys, key)
+			batchEntries = append(batchEntries, entries[i])
+		}
+
+		pending = pending[:0]
+		for j, err := range ss.setMany(ctx, batchKeys, batchEntries) {
+			i := batch[j]
+			switch err {
+			case nil:
+				keys[i] = batchKeys[j]
+			case ErrKeyExists:
+				pending = append(pending, i)
+			default:
+				errs[i] = err
+			}
+		}
+	}
+
+	return keys, errs
+}
+
+// SetMany assigns every session and returns their keys, in the same order, in
+// a single round trip where the backend allows it, such as in memory and on
+// Redis. Options apply to every session. Either every session is set or,
+// failing that, none is.
+func (ss *SessionStorage) SetMany(sessions []any, opts ...SetOption) ([]string, error) {
+	return ss.SetManyCtx(ss.ctx, sessions, opts...)
+}
+
+// SetManyCtx is like SetMany, but the storage operations run on the given
+// context, so per-request deadlines and cancellation propagate to the backend.
+func (ss *SessionStorage) SetManyCtx(ctx context.Context, sessions []any, opts ...SetOption) ([]string, error) {
+	if ss.config.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	for _, session := range sessions {
+		if session == nil {
+			return nil, ErrNilSession
+		}
+	}
+
+	sc, err := newSetConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	limiter := ss.currentPolicy().limiter
+	if source, ok := sourceFrom(ctx); ok && limiter != nil {
+		for range sessions {
+			if !limiter.allow(source, time.Now()) {
+				return nil, ErrIssueRateLimited
+			}
+		}
+	}
+
+	if err := ss.begin(true); err != nil {
+		return nil, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	entries := make([]Entry, len(sessions))
+	owners := make(map[string]int)
+	for i, session := range sessions {
+		if entries[i], err = ss.newEntry(session, sc); err != nil {
+			return nil, err
+		}
+		owners[entries[i].Owner]++
+	}
+
+	for owner, n := range owners {
+		if err := ss.enforceSessionLimit(ctx, owner, n); err != nil {
+			return nil, err
+		}
+	}
+
+	// No lock is needed, as nobody else knows the keys before they are
+	// returned.
+	keys, errs := ss.insertMany(ctx, entries)
+	if err := errors.Join(errs...); err != nil {
+		var inserted []string
+		for _, key := range keys {
+			if key != "" {
+				inserted = append(inserted, key)
+			}
+		}
+
+		ss.removeMany(ctx, inserted)
+		return nil, err
+	}
+
+	for i, key := range keys {
+		if err := ss.issued(ctx, key, entries[i]); err != nil {
+			ss.removeBatch(ctx, keys)
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// GetResult is the outcome of retrieving one of the sessions given to GetMany.
+type GetResult struct {
+	Session any
+
+	// Key is the key the session was rotated to.
+	Key string
+	Err error
+}
+
+// GetMany retrieves every session and generates new keys for them, as Get
+// does, in a single round trip where the backend allows it, such as in memory
+// and on Redis. The outcome of each key is reported in the results, in the
+// same order, so a single unknown key doesn't fail the others.
+func (ss *SessionStorage) GetMany(keys []string) ([]GetResult, error) {
+	return ss.GetManyCtx(ss.ctx, keys)
+}
+
+// GetManyCtx is like GetMany, but the storage operations run on the given
+// context, so per-request deadlines and cancellation propagate to the backend.
+func (ss *SessionStorage) GetManyCtx(ctx context.Context, keys []string) ([]GetResult, error) {
+	results := make([]GetResult, len(keys))
+
+	// Keys aren't rotated in batches by storages that must rotate them on
+	// their own, nor when they aren't rotated at all. Nor are they when the
+	// failed lookups of the source are limited, so each one counts before the
+	// next is looked up.
+	_, rotates := ss.storage.(rotator)
+	_, limited := sourceFrom(ctx)
+	limited = limited && ss.lookups != nil
+	if rotates || limited || ss.config.readOnly || ss.config.withoutKeyRotation {
+		for i, key := range keys {
+			r := &results[i]
+			r.Session, r.Key, r.Err = ss.GetCtx(ctx, key)
+		}
+
+		return results, nil
+	}
+
+	if err := ss.begin(false); err != nil {
+		return nil, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	p := ss.currentPolicy()
+	var batch []int
+	for i, key := range keys {
+		if !p.accepts(key) {
+			results[i].Err = ErrForeignKey
+		} else if err := ss.checkLockdown(ctx, key); err != nil {
+			results[i].Err = err
+		} else {
+			batch = append(batch, i)
+		}
+	}
+
+	for _, i := range ss.rotateMany(ctx, keys, batch, results) {
+		r := &results[i]
+		r.Session, r.Key, r.Err = ss.consumed(ctx, keys[i])
+	}
+
+	return results, nil
+}
+
+// rotateMany rotates the keys at the given indexes, filling their results in,
+// and returns the indexes of the keys that were not found, which may have been
+// consumed already.
+func (ss *SessionStorage) rotateMany(ctx context.Context, keys []string, batch []int, results []GetResult) []int {
+	batchKeys := make([]string, len(batch))
+	for j, i := range batch {
+		batchKeys[j] = keys[i]
+	}
+
+	defer ss.locks.lockMany(batchKeys)()
+	ss.journal.retry(context.WithoutCancel(ctx), ss.storage)
+
+	var (
+		missing []int
+		renewed []int
+		olds    []Entry
+		entries []Entry
+	)
+
+	removed, errs := ss.removeMany(ctx, batchKeys)
+	for j, i := range batch {
+		old, err := removed[j], errs[j]
+		if err == ErrNoKeyFound {
+			if e, ok := ss.journal.take(ss.stored(keys[i])); ok {
+				old, err = e, nil
+			}
+		}
+
+		if err == ErrNoKeyFound {
+			missing = append(missing, i)
+			continue
+		} else if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		if err := checkClient(ctx, old); err != nil {
+			ss.restore(ctx, keys[i], old)
+			results[i].Err = err
+			continue
+		}
+
+		e, err := ss.renew(old)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		renewed = append(renewed, i)
+		olds = append(olds, old)
+		entries = append(entries, e)
+	}
+
+	newKeys, errs := ss.insertMany(ctx, entries)
+	for j, i := range renewed {
+		key, newKey, e := keys[i], newKeys[j], entries[j]
+		if err := errs[j]; err != nil {
+			ss.restore(ctx, key, olds[j])
+			results[i].Err = err
+			continue
+		}
+
+		if sc, ok := ss.storage.(scratchStorage); ok {
+			if err := sc.scratchMove(ctx, ss.stored(key), ss.stored(newKey)); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+
+		if err := ss.rotated(ctx, key, newKey, e); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i] = GetResult{Session: e.Data, Key: newKey}
+	}
+
+	return missing
+}
+
+// RemoveMany deletes every key and its associated value, in a single round
+// trip where the backend allows it, such as in memory and on Redis. As with
+// Remove, unknown keys are skipped.
+func (ss *SessionStorage) RemoveMany(keys []string) error {
+	return ss.RemoveManyCtx(ss.ctx, keys)
+}
+
+// RemoveManyCtx is like RemoveMany, but the storage operations run on the
+// given context, so per-request deadlines and cancellation propagate to the
+// backend.
+func (ss *SessionStorage) RemoveManyCtx(ctx context.Context, keys []string) error {
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := ss.begin(false); err != nil {
+		return err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	// Foreign keys can't be stored, so there's nothing to remove, as with keys
+	// not found.
+	p := ss.currentPolicy()
+	var (
+		batch   []string
+		unbound error
+	)
+	for _, key := range keys {
+		if !p.accepts(key) {
+			continue
+		}
+
+		if err := ss.checkBound(ctx, key); err != nil {
+			unbound = errors.Join(unbound, err)
+			continue
+		}
+
+		batch = append(batch, key)
+	}
+
+	missing, err := ss.removeBatch(ctx, batch)
+	err = errors.Join(unbound, err)
+
+	// Logging out with keys consumed during their grace period ends the
+	// sessions they were rotated to.
+	for _, key := range missing {
+		if _, newKey, ok := ss.graced(ctx, key); ok {
+			if _, rerr := ss.remove(ctx, newKey); rerr != nil && rerr != ErrNoKeyFound {
+				err = errors.Join(err, rerr)
+			}
+		}
+	}
+
+	return err
+}
+
+// removeBatch removes the sessions stored under the keys, along with their
+// scratch space, and returns the keys that were not found.
+func (ss *SessionStorage) removeBatch(ctx context.Context, keys []string) ([]string, error) {
+	defer ss.locks.lockMany(keys)()
+
+	var (
+		missing []string
+		failed  error
+	)
+
+	entries, errs := ss.removeMany(ctx, keys)
+	for i, key := range keys {
+		if err := errs[i]; err == ErrNoKeyFound {
+			missing = append(missing, key)
+		} else if err != nil {
+			failed = errors.Join(failed, err)
+			continue
+		}
+
+		if err := ss.removed(ctx, key, entries[i]); err != nil {
+			failed = errors.Join(failed, err)
+		}
+
+		if errs[i] == nil {
+			ss.emit(EventRemoved, key, "", entries[i])
+		}
+	}
+
+	return missing, failed
+}
@@ -0,0 +1,160 @@
+package suk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBatch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage, "SQLite": sqliteStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" sessions set in a batch are retrieved in a batch", func(t *testing.T) {
+			keys, err := ss.SetMany([]any{"a", "b", "c"})
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if len(keys) != 3 {
+				t.Fatalf("got %d keys expected %d", len(keys), 3)
+			}
+
+			results, err := ss.GetMany(append(keys, "unknown"))
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			for i, expected := range []any{"a", "b", "c"} {
+				if r := results[i]; r.Err != nil || r.Session != expected || r.Key == keys[i] {
+					t.Errorf("got %v expected %v under a new key", r, expected)
+				}
+
+				// The keys were consumed.
+				if _, _, err := ss.Get(keys[i]); err != ErrNoKeyFound {
+					t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+				}
+			}
+
+			if err := results[3].Err; err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		})
+
+		t.Run(name+" sessions are removed in a batch", func(t *testing.T) {
+			keys, _ := ss.SetMany([]any{"a", "b"})
+			kept, _ := ss.Set("c")
+
+			if err := ss.RemoveMany(append(keys, "unknown")); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			for _, key := range keys {
+				if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+					t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+				}
+			}
+
+			if _, _, err := ss.Get(kept); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+	}
+
+	t.Run("Nil sessions are refused", func(t *testing.T) {
+		ss, _ := New()
+
+		if _, err := ss.SetMany([]any{"a", nil}); err != ErrNilSession {
+			t.Errorf("got %v expected %v", err, ErrNilSession)
+		}
+	})
+
+	t.Run("Options apply to every session", func(t *testing.T) {
+		ss, _ := New()
+		keys, _ := ss.SetMany([]any{"a", "b"}, WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		results, _ := ss.GetMany(keys)
+		for _, r := range results {
+			if r.Err != ErrKeyWasExpired {
+				t.Errorf("got %v expected %v", r.Err, ErrKeyWasExpired)
+			}
+		}
+	})
+
+	t.Run("Session limits count the whole batch", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionsPerOwner(2, RejectNew))
+
+		if _, err := ss.SetMany([]any{"a", "b", "c"}, WithOwner("user-42")); err != ErrTooManySessions {
+			t.Errorf("got %v expected %v", err, ErrTooManySessions)
+		}
+
+		sessions, _ := ss.SessionsForOwner("user-42")
+		if got := len(sessions); got != 0 {
+			t.Errorf("got %d sessions expected %d", got, 0)
+		}
+	})
+
+	t.Run("Batches are indexed", func(t *testing.T) {
+		ss, _ := New()
+		keys, _ := ss.SetMany([]any{"a", "b"}, WithOwner("user-42"))
+
+		results, _ := ss.GetMany(keys)
+		sessions, _ := ss.SessionsForOwner("user-42")
+		if got := len(sessions); got != 2 {
+			t.Errorf("got %d sessions expected %d", got, 2)
+		}
+
+		ss.RemoveMany([]string{results[0].Key, results[1].Key})
+		sessions, _ = ss.SessionsForOwner("user-42")
+		if got := len(sessions); got != 0 {
+			t.Errorf("got %d sessions expected %d", got, 0)
+		}
+	})
+
+	t.Run("Graced keys are resolved", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+		key, _ := ss.Set("a")
+		_, newKey, _ := ss.Get(key)
+
+		results, _ := ss.GetMany([]string{key})
+		if r := results[0]; r.Err != nil || r.Key != newKey {
+			t.Errorf("got %v expected %q", r, newKey)
+		}
+	})
+
+	t.Run("Multi-region sessions are handled one at a time", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ss, _ := newTestRegion(t, mrA, mrB)
+
+		keys, _ := ss.SetMany([]any{"a", "b"})
+
+		results, _ := ss.GetMany(keys)
+		for i, expected := range []any{"a", "b"} {
+			if r := results[i]; r.Err != nil || r.Session != expected {
+				t.Errorf("got %v expected %v", r, expected)
+			}
+		}
+	})
+
+	t.Run("Read-only storage", func(t *testing.T) {
+		ss, _ := NewReadOnly(WithStorage(newShardedMap(1)))
+
+		if _, err := ss.SetMany([]any{"a"}); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+
+		if err := ss.RemoveMany([]string{"key"}); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+	})
+}
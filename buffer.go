@@ -0,0 +1,104 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// bufferedStorage wraps the Redis storage, keeping the sessions set while Redis
+// is unreachable in a bounded local map until they can be flushed back. While
+// buffered, a session is only visible to this instance.
+type bufferedStorage struct {
+	*redisDB
+
+	local   *shardedMap
+	maxSize int
+}
+
+// isOutage reports whether err was caused by the backend, rather than by the
+// entry being set.
+func isOutage(err error) bool {
+	return err != nil && !errors.Is(err, ErrKeyExists) && !errors.Is(err, ErrPastExpiration)
+}
+
+func (b *bufferedStorage) Set(ctx context.Context, key string, e Entry) error {
+	err := b.redisDB.Set(ctx, key, e)
+	if !isOutage(err) || b.local.len() >= b.maxSize {
+		return err
+	}
+
+	return b.local.Set(ctx, key, e)
+}
+
+func (b *bufferedStorage) Get(ctx context.Context, key string) (Entry, error) {
+	e, err := b.local.Get(ctx, key)
+	if err != ErrNoKeyFound {
+		return e, err
+	}
+
+	return b.redisDB.Get(ctx, key)
+}
+
+func (b *bufferedStorage) Remove(ctx context.Context, key string) (Entry, error) {
+	e, err := b.local.Remove(ctx, key)
+	if err != ErrNoKeyFound {
+		return e, err
+	}
+
+	return b.redisDB.Remove(ctx, key)
+}
+
+func (b *bufferedStorage) ClearExpired(ctx context.Context) error {
+	return b.local.ClearExpired(ctx)
+}
+
+// scratchMove skips sessions rotated into the buffer, as they can't have
+// scratch space in Redis.
+func (b *bufferedStorage) scratchMove(ctx context.Context, oldKey, newKey string) error {
+	if _, err := b.local.Get(ctx, newKey); err == nil {
+		return nil
+	}
+
+	return b.redisDB.scratchMove(ctx, oldKey, newKey)
+}
+
+// flush writes the buffered sessions to Redis, stopping at the first failure
+// as Redis is probably still unreachable.
+func (b *bufferedStorage) flush(ctx context.Context) error {
+	for key, e := range b.local.snapshot() {
+		// The sessions of past epochs would be stored under the segment of the
+		// current one, so they must not reach Redis.
+		if time.Until(e.ExpiresAt) <= 0 || e.Epoch != 0 && e.Epoch <= b.epoch.Load() {
+			b.local.delete(key)
+			continue
+		}
+
+		err := b.redisDB.Set(ctx, key, e)
+		if err == ErrKeyExists {
+			// Someone else got the key while Redis was unreachable from here, so
+			// the session can only live on locally.
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		b.local.delete(key)
+	}
+
+	return nil
+}
+
+// flushOutageBuffer writes the sessions buffered during a Redis outage back to
+// Redis.
+func (ss *SessionStorage) flushOutageBuffer(ctx context.Context) error {
+	b, ok := ss.storage.(*bufferedStorage)
+	if !ok {
+		return nil
+	}
+
+	// Sessions taken while being flushed would live on in Redis, so every
+	// operation waits for the flush.
+	defer ss.locks.lockAll()()
+	return b.flush(ctx)
+}
@@ -0,0 +1,61 @@
+package suk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestOutageBuffer(t *testing.T) {
+	t.Run("Outage buffer requires Redis", func(t *testing.T) {
+		_, err := New(WithOutageBuffer(10, time.Second))
+
+		if err != ErrOutageBufferWithoutRedis {
+			t.Errorf("got %v expected %v", err, ErrOutageBufferWithoutRedis)
+		}
+	})
+
+	t.Run("Sets are buffered during outages and flushed afterwards", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+		ss, _ := New(WithRedis(client, nil), WithOutageBuffer(1, time.Hour))
+		defer Destroy(ss)
+
+		mr.SetError("connection lost")
+
+		key, err := ss.Set("session")
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if _, err := ss.Set("another session"); err == nil {
+			t.Error("expected error with the outage buffer full")
+		}
+
+		got, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if got != "session" {
+			t.Errorf("got %v expected %v", got, "session")
+		}
+
+		mr.SetError("")
+
+		if err := ss.flushOutageBuffer(context.Background()); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if got := storedData(t, mr, newKey); got != "session" {
+			t.Errorf("got %q expected %q", got, "session")
+		}
+
+		if mr.TTL(newKey) <= 0 {
+			t.Error("expected flushed key to have a TTL")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package suk
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+)
+
+var ErrChannelMismatch = errors.New("The session was bound to another TLS channel.")
+
+type channelContextKey struct{}
+
+// ChannelContext returns a copy of ctx carrying the binding of the TLS channel
+// the request came over, such as the hash of the client certificate or the
+// TLS exporter value of the connection. Sessions set with it are bound to the
+// channel, so retrieving them with a context carrying another binding fails
+// with ErrChannelMismatch, which keeps keys from being used outside of the
+// channel they were issued over. The session is left as it is.
+//
+// Unlike fingerprints, an empty binding is a channel of its own, so requests
+// without a client certificate can't use the sessions bound to one. Contexts
+// without a binding, such as the ones of background jobs, are not checked.
+// The binding is kept by every backend, Redis included, across rotations.
+func ChannelContext(ctx context.Context, binding []byte) context.Context {
+	return context.WithValue(ctx, channelContextKey{}, binding)
+}
+
+// channelOf returns the hash of the channel binding carried by ctx, and
+// whether it carries one.
+func channelOf(ctx context.Context) (string, bool) {
+	binding, ok := ctx.Value(channelContextKey{}).([]byte)
+	if !ok {
+		return "", false
+	}
+
+	if len(binding) == 0 {
+		return "", true
+	}
+
+	sum := sha256.Sum256(binding)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// checkChannel fails with ErrChannelMismatch when the entry is bound to a
+// channel other than the one carried by ctx.
+func checkChannel(ctx context.Context, e Entry) error {
+	if e.Channel == "" {
+		return nil
+	}
+
+	channel, ok := channelOf(ctx)
+	if !ok || subtle.ConstantTimeCompare([]byte(channel), []byte(e.Channel)) == 1 {
+		return nil
+	}
+
+	return ErrChannelMismatch
+}
+
+// checkClient fails when the entry is bound to another client, as told by the
+// fingerprint or the channel binding carried by ctx.
+func checkClient(ctx context.Context, e Entry) error {
+	if err := checkFingerprint(ctx, e); err != nil {
+		return err
+	}
+
+	return checkChannel(ctx, e)
+}
+
+// bound reports whether ctx carries a fingerprint or a channel binding, so the
+// sessions used with it must be checked.
+func bound(ctx context.Context) bool {
+	_, fingerprinted := fingerprintOf(ctx)
+	_, channeled := channelOf(ctx)
+	return fingerprinted || channeled
+}
@@ -0,0 +1,76 @@
+package suk
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestChannel(t *testing.T) {
+	syncMapStorage, _ := New()
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}), nil))
+	multiRegionStorage, _ := New(WithMultiRegionRedis(
+		redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		nil,
+	))
+
+	storages := map[string]*SessionStorage{
+		"Sync map":           syncMapStorage,
+		"SQLite":             sqliteStorage,
+		"Redis":              redisStorage,
+		"Multi-region Redis": multiRegionStorage,
+	}
+
+	alice := ChannelContext(context.Background(), []byte("alice"))
+	mallory := ChannelContext(context.Background(), []byte("mallory"))
+	plain := ChannelContext(context.Background(), nil)
+
+	for name, ss := range storages {
+		t.Run(name+" sessions are bound to their channel", func(t *testing.T) {
+			key, _ := ss.SetCtx(alice, "session")
+
+			for _, ctx := range []context.Context{mallory, plain} {
+				if _, _, err := ss.GetCtx(ctx, key); err != ErrChannelMismatch {
+					t.Errorf("got %v expected %v", err, ErrChannelMismatch)
+				}
+			}
+
+			got, key, err := ss.GetCtx(alice, key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got != "session" {
+				t.Errorf("got %v expected %q", got, "session")
+			}
+
+			if _, _, err := ss.GetCtx(mallory, key); err != ErrChannelMismatch {
+				t.Errorf("got %v expected %v", err, ErrChannelMismatch)
+			}
+		})
+	}
+
+	t.Run("Sessions set without a binding are not bound", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.SetCtx(plain, "session")
+		if _, _, err := ss.GetCtx(mallory, key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Contexts without a binding are not checked", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.SetCtx(alice, "session")
+		if _, err := ss.Peek(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+}
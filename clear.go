@@ -0,0 +1,191 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrClearUnsupported = errors.New("The session storage backend can't remove every session at once.")
+
+// Clearer may be implemented by storages able to remove every entry at once,
+// so they support Clear.
+type Clearer interface {
+	Clear(ctx context.Context) error
+}
+
+func (m *shardedMap) Clear(_ context.Context) error {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		s.entries = make(map[string]Entry)
+		s.expirations = nil
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Clear removes every key under the prefix, scratch spaces and the keys of past
+// epochs included, but the watermarks. While the prefix is being migrated, the
+// keys under the previous one are removed too.
+func (r *redisDB) Clear(ctx context.Context) error {
+	p := r.prefixes.Load()
+
+	if err := unlinkMatching(ctx, r.client, escapePattern(p.current)+"*", p.current+watermarkKey("")); err != nil {
+		return err
+	}
+
+	if p.migrating {
+		return unlinkMatching(ctx, r.client, escapePattern(p.previous)+"*", p.previous+watermarkKey(""))
+	}
+
+	return nil
+}
+
+// unlinkMatching removes every key matching the pattern, a SCAN batch at a
+// time, except the ones prefixed with kept.
+func unlinkMatching(ctx context.Context, client *redis.Client, pattern, kept string) error {
+	var cursor uint64
+	for {
+		scanned, next, err := client.Scan(ctx, cursor, pattern, migrationBatchSize).Result()
+		if err != nil {
+			return err
+		}
+
+		var keys []string
+		for _, key := range scanned {
+			if !strings.HasPrefix(key, kept) {
+				keys = append(keys, key)
+			}
+		}
+
+		if len(keys) > 0 {
+			if err := client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (b *bufferedStorage) Clear(ctx context.Context) error {
+	if err := b.local.Clear(ctx); err != nil {
+		return err
+	}
+
+	return b.redisDB.Clear(ctx)
+}
+
+// Clear removes every key of both regions, tombstones included, but the
+// watermarks. Writes still waiting to be replicated may be applied to the
+// other region afterwards.
+func (m *multiRegionRedis) Clear(ctx context.Context) error {
+	for _, client := range []*redis.Client{m.nearest, m.other} {
+		if err := unlinkMatching(ctx, client, "*", watermarkKey("")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteDB) Clear(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM suk_sessions`)
+	return err
+}
+
+// Clear removes every session, such as after a security incident or when
+// tearing tests down. On Redis, only the keys under the prefix set with
+// WithRedisPrefix are removed, so without one, the whole database is wiped.
+// Keys being rotated or removed meanwhile wait for it to finish, while sessions
+// set meanwhile may or may not be removed. Storages not implementing Clearer
+// fail with ErrClearUnsupported.
+func (ss *SessionStorage) Clear() error {
+	return ss.ClearCtx(ss.ctx)
+}
+
+// ClearCtx is like Clear, but the storage operations run on the given context.
+func (ss *SessionStorage) ClearCtx(ctx context.Context) error {
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	c, ok := ss.storage.(Clearer)
+	if !ok {
+		return ErrClearUnsupported
+	}
+
+	if err := ss.begin(false); err != nil {
+		return err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	defer ss.locks.lockAll()()
+	if err := c.Clear(ctx); err != nil {
+		return err
+	}
+
+	ss.journal.reset()
+	ss.owners.reset()
+	ss.families.reset()
+	if ss.consumedKeys != nil {
+		ss.consumedKeys.reset()
+	}
+	if ss.usage != nil {
+		ss.usage.reset()
+	}
+
+	return nil
+}
+
+// ClearReport tells what Clear would remove, as returned by ClearDryRun.
+type ClearReport struct {
+	// Count is how many live sessions would be removed.
+	Count int
+
+	// Sample describes some of them, up to the size asked for.
+	Sample []SessionInfo
+}
+
+// ClearDryRun reports what Clear would remove without removing anything, such
+// as before clearing a production storage: how many live sessions it holds,
+// along with a sample of up to size of them. It goes through every session, as
+// Range does, so it works on read-only storages too. Storages not implementing
+// Clearer fail with ErrClearUnsupported, and the ones not implementing Ranger
+// with ErrRangeUnsupported.
+func (ss *SessionStorage) ClearDryRun(size int) (ClearReport, error) {
+	return ss.ClearDryRunCtx(ss.ctx, size)
+}
+
+// ClearDryRunCtx is like ClearDryRun, but the storage operations run on the
+// given context.
+func (ss *SessionStorage) ClearDryRunCtx(ctx context.Context, size int) (ClearReport, error) {
+	if _, ok := ss.storage.(Clearer); !ok {
+		return ClearReport{}, ErrClearUnsupported
+	}
+
+	var report ClearReport
+	err := ss.RangeCtx(ctx, func(_ string, info SessionInfo) bool {
+		if len(report.Sample) < size {
+			report.Sample = append(report.Sample, info)
+		}
+
+		report.Count++
+		return true
+	})
+	if err != nil {
+		return ClearReport{}, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,137 @@
+package suk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClear(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithRedisPrefix("sessions:"))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Sync map": syncMapStorage,
+		"Redis":    redisStorage,
+		"SQLite":   sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" every session is removed", func(t *testing.T) {
+			keys, _ := ss.SetMany([]any{"a", "b", "c"}, WithOwner("user-42"))
+
+			if err := ss.Clear(); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			for _, key := range keys {
+				if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+					t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+				}
+			}
+
+			sessions, _ := ss.SessionsForOwner("user-42")
+			if got := len(sessions); got != 0 {
+				t.Errorf("got %d sessions expected %d", got, 0)
+			}
+
+			// The storage is still usable.
+			key, _ := ss.Set("d")
+			if _, _, err := ss.Get(key); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" dry runs remove nothing", func(t *testing.T) {
+			ss.Clear()
+			keys, _ := ss.SetMany([]any{"a", "b", "c"}, WithOwner("user-42"))
+
+			report, err := ss.ClearDryRun(2)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if report.Count != 3 {
+				t.Errorf("got %d expected %d", report.Count, 3)
+			}
+
+			if len(report.Sample) != 2 || report.Sample[0].Owner != "user-42" {
+				t.Errorf("got %v expected %d sessions of %s", report.Sample, 2, "user-42")
+			}
+
+			for _, key := range keys {
+				if ok, _ := ss.Exists(key); !ok {
+					t.Errorf("got %v expected %v", ok, true)
+				}
+			}
+		})
+	}
+
+	t.Run("Only the Redis namespace is removed", func(t *testing.T) {
+		mr.Set("other", "data")
+
+		key, _ := redisStorage.Set("a")
+		redisStorage.Scratch(key).Set("step", 2)
+		redisStorage.Clear()
+
+		if keys := mr.Keys(); len(keys) != 1 || keys[0] != "other" {
+			t.Errorf("got %v expected %v", keys, []string{"other"})
+		}
+	})
+
+	t.Run("Consumed keys are forgotten", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+		key, _ := ss.Set("a")
+		ss.Get(key)
+		ss.Clear()
+
+		if ok, _ := ss.Exists(key); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+	})
+
+	t.Run("Multi-region sessions are removed from both regions", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ss, region := newTestRegion(t, mrA, mrB)
+
+		ss.Set("a")
+		drain(region)
+		ss.Clear()
+
+		if len(mrA.Keys()) != 0 || len(mrB.Keys()) != 0 {
+			t.Errorf("got %v and %v expected no keys", mrA.Keys(), mrB.Keys())
+		}
+	})
+
+	t.Run("Read-only storage", func(t *testing.T) {
+		ss, _ := NewReadOnly(WithStorage(newShardedMap(1)))
+
+		if err := ss.Clear(); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+
+		if _, err := ss.ClearDryRun(1); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Backends that can't remove every session", func(t *testing.T) {
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}))
+
+		if err := ss.Clear(); err != ErrClearUnsupported {
+			t.Errorf("got %v expected %v", err, ErrClearUnsupported)
+		}
+
+		if _, err := ss.ClearDryRun(1); err != ErrClearUnsupported {
+			t.Errorf("got %v expected %v", err, ErrClearUnsupported)
+		}
+	})
+}
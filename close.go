@@ -0,0 +1,56 @@
+package suk
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrClosed = errors.New("The session storage is closed.")
+
+// Close shuts the session storage down gracefully. New operations are refused
+// with ErrClosed, and once the ones in flight finish, or the context is done,
+// the background jobs are stopped and the pending state is flushed: sessions
+// buffered during a Redis outage are written back, sessions consumed by
+// failed rotations are restored, and storages opened by the session storage
+// itself, such as with WithSQLite, are closed, as is the channel returned by
+// Events.
+//
+// Every step is taken even if an earlier one fails, and their errors are
+// joined. Calling Close more than once returns ErrClosed.
+func (ss *SessionStorage) Close(ctx context.Context) error {
+	ss.opsMu.Lock()
+	if ss.closed {
+		ss.opsMu.Unlock()
+		return ErrClosed
+	}
+	ss.closed = true
+
+	var errs []error
+	if err := ss.waitIdle(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	ss.stopBackground()
+
+	if !ss.config.readOnly {
+		if err := ss.flushOutageBuffer(ctx); err != nil {
+			errs = append(errs, err)
+		}
+
+		if err := ss.journal.flush(ctx, ss.storage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ss.ownedStorage != nil {
+		if err := ss.ownedStorage.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ss.events != nil {
+		ss.events.close()
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Operations are refused once closed", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		if err := ss.Close(ctx); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := ss.Set(10); !errors.Is(err, ErrClosed) {
+			t.Errorf("got %v expected %v", err, ErrClosed)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, ErrClosed) {
+			t.Errorf("got %v expected %v", err, ErrClosed)
+		}
+
+		if err := ss.ClearExpired(); !errors.Is(err, ErrClosed) {
+			t.Errorf("got %v expected %v", err, ErrClosed)
+		}
+
+		if err := ss.Close(ctx); !errors.Is(err, ErrClosed) {
+			t.Errorf("got %v expected %v", err, ErrClosed)
+		}
+	})
+
+	t.Run("Owned storages are closed", func(t *testing.T) {
+		ss, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+		ss.Close(ctx)
+
+		if err := ss.storage.(*sqliteDB).db.Ping(); err == nil {
+			t.Error("got no error expected the database to be closed")
+		}
+	})
+
+	t.Run("Journaled sessions are restored", func(t *testing.T) {
+		fs := &flakyStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(fs), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+		key, _ := ss.Set(10)
+
+		fs.failSets = 2
+		ss.Get(key)
+
+		if err := ss.Close(ctx); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := fs.Get(ctx, key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Unrestored sessions are reported", func(t *testing.T) {
+		fs := &flakyStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(fs), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+		key, _ := ss.Set(10)
+
+		fs.failSets = 3
+		ss.Get(key)
+
+		if err := ss.Close(ctx); !errors.Is(err, ErrPendingRestores) {
+			t.Errorf("got %v expected %v", err, ErrPendingRestores)
+		}
+	})
+
+	t.Run("Operations in flight are waited for", func(t *testing.T) {
+		ss, _ := New()
+		ss.begin(false)
+
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+
+		if err := ss.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v expected %v", err, context.DeadlineExceeded)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+// Command sukbench drives a suk session storage with a number of virtual users
+// performing a mix of operations, reporting the throughput and the latency
+// percentiles for each of them. It is meant to help sizing the backend before
+// launch.
+//
+// Each virtual user logs in (Set), then keeps accessing its session (Get,
+// which rotates the key) and eventually logs out (Remove), logging in again
+// afterwards. The mix of operations is given as weights:
+//
+//	sukbench -users 100 -duration 30s -mix get=90,set=5,remove=5
+//	sukbench -backend redis -redis-addr localhost:6379
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"github.com/redis/go-redis/v9"
+)
+
+// operations lists the benchmarked operations, in the order they are reported.
+var operations = []string{"set", "get", "remove"}
+
+// result holds the latencies measured by a virtual user for each operation.
+type result struct {
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func newResult() *result {
+	return &result{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+}
+
+func (r *result) merge(other *result) {
+	for op, l := range other.latencies {
+		r.latencies[op] = append(r.latencies[op], l...)
+	}
+
+	for op, n := range other.errors {
+		r.errors[op] += n
+	}
+}
+
+// parseMix parses a comma separated list of op=weight pairs.
+func parseMix(s string) (map[string]int, error) {
+	mix := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !slices.Contains(operations, op) {
+			return nil, fmt.Errorf("invalid mix entry %q", pair)
+		}
+
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight for %q", op)
+		}
+
+		mix[op] = w
+	}
+
+	if mix["get"]+mix["set"]+mix["remove"] == 0 {
+		return nil, fmt.Errorf("mix has no positive weights")
+	}
+
+	return mix, nil
+}
+
+// pick chooses an operation at random, following the weights of the mix.
+func pick(mix map[string]int) string {
+	total := 0
+	for _, op := range operations {
+		total += mix[op]
+	}
+
+	n := rand.IntN(total)
+	for _, op := range operations {
+		if n < mix[op] {
+			return op
+		}
+		n -= mix[op]
+	}
+
+	return operations[len(operations)-1]
+}
+
+// virtualUser performs operations until the deadline, holding a single
+// session at a time.
+func virtualUser(ss *suk.SessionStorage, mix map[string]int, payload string, deadline time.Time) *result {
+	r := newResult()
+	key := ""
+
+	for time.Now().Before(deadline) {
+		op := pick(mix)
+		if key == "" {
+			op = "set"
+		}
+
+		start := time.Now()
+		var err error
+
+		switch op {
+		case "set":
+			key, err = ss.Set(payload)
+		case "get":
+			_, key, err = ss.Get(key)
+		case "remove":
+			err = ss.Remove(key)
+			key = ""
+		}
+
+		r.latencies[op] = append(r.latencies[op], time.Since(start))
+		if err != nil {
+			r.errors[op]++
+			key = ""
+		}
+	}
+
+	return r
+}
+
+// percentile returns the p-th percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+func report(r *result, elapsed time.Duration) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "op\tcount\terrors\tops/s\tp50\tp90\tp99\tmax\t")
+
+	total := 0
+	for _, op := range operations {
+		l := r.latencies[op]
+		if len(l) == 0 {
+			continue
+		}
+
+		slices.Sort(l)
+		total += len(l)
+
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%d\t%.0f\t%s\t%s\t%s\t%s\t\n",
+			op,
+			len(l),
+			r.errors[op],
+			float64(len(l))/elapsed.Seconds(),
+			percentile(l, 0.50),
+			percentile(l, 0.90),
+			percentile(l, 0.99),
+			l[len(l)-1],
+		)
+	}
+
+	fmt.Fprintf(w, "total\t%d\t\t%.0f\t\t\t\t\t\n", total, float64(total)/elapsed.Seconds())
+	w.Flush()
+}
+
+func main() {
+	var (
+		users       = flag.Int("users", 50, "number of virtual users")
+		duration    = flag.Duration("duration", 10*time.Second, "how long to run the benchmark")
+		mixFlag     = flag.String("mix", "get=80,set=10,remove=10", "weights of each operation")
+		backend     = flag.String("backend", "memory", "backend to benchmark (memory or redis)")
+		redisAddr   = flag.String("redis-addr", "localhost:6379", "address of the Redis server")
+		keyLength   = flag.Uint64("key-length", 32, "length of the generated keys")
+		payloadSize = flag.Int("payload-size", 64, "size in bytes of each session")
+	)
+	flag.Parse()
+
+	mix, err := parseMix(*mixFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sukbench: %s\n", err.Error())
+		os.Exit(2)
+	}
+
+	opts := []suk.Option{suk.WithKeyLength(*keyLength)}
+	switch *backend {
+	case "memory":
+		opts = append(opts, suk.WithAutoClearExpiredKeys())
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: *redisAddr})
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "sukbench: could not reach Redis: %s\n", err.Error())
+			os.Exit(1)
+		}
+		defer client.Close()
+
+		opts = append(opts, suk.WithRedis(client, nil))
+	default:
+		fmt.Fprintf(os.Stderr, "sukbench: unknown backend %q\n", *backend)
+		os.Exit(2)
+	}
+
+	ss, err := suk.New(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sukbench: %s\n", err.Error())
+		os.Exit(1)
+	}
+	defer ss.Close(context.Background())
+
+	payload := strings.Repeat("x", *payloadSize)
+	fmt.Printf("running %d virtual users against %s for %s\n\n", *users, *backend, *duration)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total = newResult()
+	)
+
+	start := time.Now()
+	deadline := start.Add(*duration)
+
+	for range *users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := virtualUser(ss, mix, payload, deadline)
+
+			mu.Lock()
+			total.merge(r)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	report(total, time.Since(start))
+}
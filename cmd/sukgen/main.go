@@ -0,0 +1,125 @@
+// Command sukgen generates companion code for applications using suk, so the
+// rules the server enforces live in a single place. Running it from a
+// go:generate directive, next to where the session storage is configured,
+// keeps the generated code in sync with the Go config.
+//
+// The js subcommand emits a client helper, sukFetch, wrapping fetch to handle
+// key rotation over the configured transport, either a cookie or a header:
+//
+//	//go:generate sukgen js -cookie access-token -o static/suk.js
+//	//go:generate sukgen js -header X-Session-Key -grace 5s -typescript -o web/suk.ts
+//
+// Since every Get rotates the key, requests are sent one at a time, unless the
+// server accepts the previous key for a grace period. Requests failing with the
+// status the server reports ErrNoKeyFound with, after the key was rotated by
+// another request in the meantime, are retried once with the new key.
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+)
+
+//go:embed client.js.tmpl
+var clientTemplate string
+
+// transport describes how keys travel between the client and the server.
+type transport struct {
+	Cookie     string
+	Header     string
+	Grace      time.Duration
+	Status     int
+	TypeScript bool
+}
+
+// GraceMillis returns the grace period in milliseconds, as used by the client.
+func (t transport) GraceMillis() int64 {
+	return t.Grace.Milliseconds()
+}
+
+func (t transport) validate() error {
+	if (t.Cookie == "") == (t.Header == "") {
+		return errors.New("exactly one of -cookie and -header must be given")
+	}
+
+	if t.Grace < 0 {
+		return errors.New("the grace period can't be negative")
+	}
+
+	if http.StatusText(t.Status) == "" {
+		return fmt.Errorf("invalid status %d", t.Status)
+	}
+
+	return nil
+}
+
+// generateJS writes the client helper for the transport to w.
+func generateJS(w io.Writer, t transport) error {
+	tmpl, err := template.New("client").Parse(clientTemplate)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, t)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sukgen js [flags]")
+	os.Exit(2)
+}
+
+func runJS(args []string) error {
+	var t transport
+
+	fs := flag.NewFlagSet("js", flag.ExitOnError)
+	fs.StringVar(&t.Cookie, "cookie", "", "name of the cookie carrying the key")
+	fs.StringVar(&t.Header, "header", "", "name of the header carrying the key, both ways")
+	fs.DurationVar(&t.Grace, "grace", 0, "how long the server accepts the previous key after a rotation")
+	fs.IntVar(&t.Status, "status", http.StatusUnauthorized, "status the server reports ErrNoKeyFound with")
+	fs.BoolVar(&t.TypeScript, "typescript", false, "emit TypeScript instead of JavaScript")
+	out := fs.String("o", "", "file to write to, instead of the standard output")
+	fs.Parse(args)
+
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return generateJS(os.Stdout, t)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+
+	if err := generateJS(f, t); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "js":
+		if err := runJS(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "sukgen: %s\n", err.Error())
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,301 @@
+package suk
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var ErrNotProtoMessage = errors.New("The session is not a protocol buffer message.")
+
+// Codec serializes the sessions stored in Redis, as set with WithCodec.
+// Unmarshal is given a pointer to an empty interface, so the codec chooses the
+// type sessions are decoded to.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec serializes sessions with encoding/json. Sessions are decoded to
+// the generic types of encoding/json, so structs come back as map[string]any
+// and numbers as float64. Typed storages convert them back to their type.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec serializes sessions with encoding/gob, along with their type, so
+// they are decoded to the type they were stored with. Custom types must be
+// registered with gob.Register.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// MsgpackCodec serializes sessions with MessagePack, which is smaller and
+// faster than JSON, making it a better fit for large sessions. Like JSONCodec,
+// sessions are decoded to generic types, so structs come back as
+// map[string]any.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// ProtoCodec serializes sessions that are protocol buffer messages. Messages
+// are wrapped in an anypb.Any along with their type, so they are decoded to
+// the type they were stored with, which must be linked into the binary.
+// Sessions of any other type fail with ErrNotProtoMessage.
+type ProtoCodec struct{}
+
+func (ProtoCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: got %T", ErrNotProtoMessage, v)
+	}
+
+	a, err := anypb.New(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(a)
+}
+
+// Unmarshal decodes the message into v, which is either a pointer to an empty
+// interface, given a message of the stored type, or a message itself.
+func (ProtoCodec) Unmarshal(data []byte, v any) error {
+	var a anypb.Any
+	if err := proto.Unmarshal(data, &a); err != nil {
+		return err
+	}
+
+	switch dst := v.(type) {
+	case proto.Message:
+		return a.UnmarshalTo(dst)
+	case *any:
+		m, err := a.UnmarshalNew()
+		if err != nil {
+			return err
+		}
+
+		*dst = m
+		return nil
+	default:
+		return fmt.Errorf("%w: got %T", ErrNotProtoMessage, v)
+	}
+}
+
+// metadataPrefix starts the Redis sessions stored along with their metadata,
+// such as their ID, TTL and absolute deadline, so they can be told apart from
+// the ones stored as their data alone.
+const metadataPrefix = "\x00suk+"
+
+// redisPayload serializes the sessions stored in Redis with the codec, if
+// any, encrypting them with the sealer, if any.
+type redisPayload struct {
+	codec  Codec
+	sealer *sealer
+}
+
+// encode returns the data as stored in Redis, which is given to the client as
+// is without a codec nor a sealer.
+func (p redisPayload) encode(data any) (any, error) {
+	if p.codec == nil && p.sealer == nil {
+		return data, nil
+	}
+
+	var raw []byte
+	if p.codec != nil {
+		var err error
+		if raw, err = p.codec.Marshal(data); err != nil {
+			return nil, err
+		}
+	} else {
+		switch d := data.(type) {
+		case string:
+			raw = []byte(d)
+		case []byte:
+			raw = d
+		default:
+			return nil, ErrUnencryptableSession
+		}
+	}
+
+	if p.sealer == nil {
+		return raw, nil
+	}
+
+	return p.sealer.seal(raw)
+}
+
+// encodeEntry returns the entry as stored in Redis. Entries with metadata are
+// stored as metadataPrefix followed by their encoded data, as given to
+// encodeEntryData, while the others are stored as their data, as encode does.
+func (p redisPayload) encodeEntry(e Entry) (any, error) {
+	data, err := p.encode(e.Data)
+	if err != nil || !hasMetadata(e) {
+		return data, err
+	}
+
+	if e.Data, err = redisArg(data); err != nil {
+		return nil, err
+	}
+
+	raw, err := encodeEntryData(e, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(metadataPrefix), raw...), nil
+}
+
+// decodeEntry returns the entry stored in Redis as raw, without expiration, as
+// encodeEntry stores it.
+func (p redisPayload) decodeEntry(raw string) (Entry, error) {
+	if !strings.HasPrefix(raw, metadataPrefix) {
+		data, stale, err := p.decode(raw)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		return Entry{Data: data, stale: stale}, nil
+	}
+
+	e, err := decodeEntryData([]byte(raw[len(metadataPrefix):]), nil)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	data, ok := e.Data.([]byte)
+	if !ok {
+		return Entry{}, ErrCorruptedEntry
+	}
+
+	if e.Data, e.stale, err = p.decode(string(data)); err != nil {
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+// redisArg formats the data the way the Redis client does, so it reads back
+// the same whether it was stored with metadata or not.
+func redisArg(data any) ([]byte, error) {
+	switch v := data.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case uint:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(nil, v, 10), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 64), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case time.Time:
+		return v.AppendFormat(nil, time.RFC3339Nano), nil
+	case time.Duration:
+		return strconv.AppendInt(nil, v.Nanoseconds(), 10), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	case net.IP:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("redis: can't marshal %T (implement encoding.BinaryMarshaler)", v)
+	}
+}
+
+// decode returns the data stored in Redis as raw, decrypting it when it was
+// encrypted, and whether it is stale, as sealer.open does.
+func (p redisPayload) decode(raw string) (any, bool, error) {
+	var stale bool
+	if isSealed([]byte(raw)) {
+		plaintext, s, err := p.sealer.open([]byte(raw))
+		if err != nil {
+			return nil, false, err
+		}
+
+		raw, stale = string(plaintext), s
+	}
+
+	if p.codec == nil {
+		return raw, stale, nil
+	}
+
+	var data any
+	if err := p.codec.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, false, err
+	}
+
+	return data, stale, nil
+}
+
+// keepsValues reports whether the backend keeps sessions as they are, or
+// serializes them with a codec or in stateless tokens, so they need no
+// serialization of their own.
+func (ss *SessionStorage) keepsValues() bool {
+	_, inMemory := ss.storage.(*shardedMap)
+	return inMemory || ss.genericValues()
+}
+
+// genericValues reports whether sessions may come back as generic values, such
+// as the map[string]any of JSONCodec and stateless tokens.
+func (ss *SessionStorage) genericValues() bool {
+	return ss.config.codec != nil || ss.config.tokens != nil
+}
@@ -0,0 +1,250 @@
+package suk
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// storedData returns the data of the session stored in Redis under key.
+func storedData(t *testing.T, mr *miniredis.Miniredis, key string) any {
+	t.Helper()
+
+	raw, err := mr.Get(key)
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+
+	e, err := redisPayload{}.decodeEntry(raw)
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+
+	return e.Data
+}
+
+func TestCodec(t *testing.T) {
+	gob.Register(user{})
+
+	codecs := map[string]Codec{
+		"JSON":    JSONCodec{},
+		"Gob":     GobCodec{},
+		"Msgpack": MsgpackCodec{},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name+" structs survive Redis", func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			ss, err := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithCodec(codec))
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			typed := NewTyped[user](ss)
+			expected := user{ID: 42, Name: "Ana", Roles: []string{"admin"}}
+
+			key, err := typed.Set(expected)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			got, _, err := typed.Get(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got.ID != expected.ID || got.Name != expected.Name || len(got.Roles) != 1 {
+				t.Errorf("got %v expected %v", got, expected)
+			}
+		})
+
+		t.Run(name+" updates are serialized", func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithCodec(codec))
+
+			key, _ := ss.Set(user{ID: 1})
+			err := ss.Update(key, func(old any) any {
+				return user{ID: 2}
+			})
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			got, _, err := NewTyped[user](ss).Get(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got.ID != 2 {
+				t.Errorf("got %d expected %d", got.ID, 2)
+			}
+		})
+	}
+
+	t.Run("Gob keeps the type of sessions", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithCodec(GobCodec{}))
+
+		key, _ := ss.Set(user{ID: 42})
+		got, _, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if u, ok := got.(user); !ok || u.ID != 42 {
+			t.Errorf("got %v expected %v", got, user{ID: 42})
+		}
+	})
+
+	t.Run("Protocol buffer messages keep their type", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithCodec(ProtoCodec{}))
+		typed := NewTyped[*wrapperspb.StringValue](ss)
+
+		key, err := typed.Set(wrapperspb.String("session"))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		got, _, err := typed.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got.GetValue() != "session" {
+			t.Errorf("got %q expected %q", got.GetValue(), "session")
+		}
+	})
+
+	t.Run("Protocol buffers only take messages", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithCodec(ProtoCodec{}))
+
+		if _, err := ss.Set("session"); !errors.Is(err, ErrNotProtoMessage) {
+			t.Errorf("got %v expected %v", err, ErrNotProtoMessage)
+		}
+	})
+
+	t.Run("Multi-region Redis", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ss, err := New(WithMultiRegionRedis(
+			redis.NewClient(&redis.Options{Addr: mrA.Addr()}),
+			redis.NewClient(&redis.Options{Addr: mrB.Addr()}),
+			nil,
+		), WithCodec(GobCodec{}))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer Destroy(ss)
+
+		key, _ := ss.Set(user{ID: 42})
+		got, _, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if u, ok := got.(user); !ok || u.ID != 42 {
+			t.Errorf("got %v expected %v", got, user{ID: 42})
+		}
+
+		_, entries, _, err := ss.storage.(Ranger).RangePage(ss.ctx, "", rangePageSize)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("got %d entries expected %d", len(entries), 1)
+		}
+
+		if u, ok := entries[0].Data.(user); !ok || u.ID != 42 {
+			t.Errorf("got %v expected %v", entries[0].Data, user{ID: 42})
+		}
+	})
+
+	t.Run("Metadata is kept in Redis", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+
+		key, _ := ss.Set(42, WithOwner("user-42"), WithTTL(time.Hour))
+		e, err := ss.storage.Get(ss.ctx, key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		// Without a codec, sessions read back the way Redis stores them.
+		if e.Data != "42" || e.ID == "" || e.Owner != "user-42" || e.TTL != time.Hour || e.CreatedAt.IsZero() {
+			t.Errorf("got %+v expected the data and metadata set", e)
+		}
+	})
+
+	t.Run("Sessions stored without metadata are read", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+		mr.Set("key", "session")
+
+		e, err := ss.storage.Get(ss.ctx, "key")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if e.Data != "session" || e.ID != "" {
+			t.Errorf("got %+v expected %v without metadata", e, "session")
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+		if _, err := New(WithRedis(client, nil), WithCodec(nil)); !errors.Is(err, ErrNilCodec) {
+			t.Errorf("got %v expected %v", err, ErrNilCodec)
+		}
+
+		if _, err := New(WithRedis(client, nil), WithCodec(JSONCodec{}), WithCodec(GobCodec{})); !errors.Is(err, ErrCodecAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrCodecAlreadySet)
+		}
+
+		if _, err := New(WithCodec(JSONCodec{})); !errors.Is(err, ErrCodecWithoutRedis) {
+			t.Errorf("got %v expected %v", err, ErrCodecWithoutRedis)
+		}
+	})
+}
+
+func BenchmarkCodecs(b *testing.B) {
+	gob.Register(map[string]any{})
+
+	// Sessions of about 8KB, as kept by larger applications.
+	session := map[string]any{}
+	for i := range 128 {
+		session[fmt.Sprintf("field-%03d", i)] = strings.Repeat("x", 48)
+	}
+
+	codecs := map[string]Codec{
+		"JSON":    JSONCodec{},
+		"Gob":     GobCodec{},
+		"Msgpack": MsgpackCodec{},
+	}
+
+	for name, codec := range codecs {
+		b.Run(name, func(b *testing.B) {
+			for range b.N {
+				raw, err := codec.Marshal(session)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var got any
+				if err := codec.Unmarshal(raw, &got); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package suk
 
 import (
+	"bytes"
 	"context"
+	"crypto/rsa"
 	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -14,32 +18,237 @@ var (
 	ErrNilRedisClient        = errors.New("The given Redis client is nil.")
 	ErrRedisClientAlreadySet = errors.New("A Redis client was already registered for this session storage.")
 
+	// WithStorage Errors
+
+	ErrNilStorage        = errors.New("The given storage is nil.")
+	ErrStorageAlreadySet = errors.New("A storage was already registered for this session storage.")
+
+	// WithSQLite Errors
+
+	ErrEmptySQLitePath = errors.New("The given SQLite database path is empty.")
+
 	// WithKeyLength Errors
 
 	ErrZeroKeyLength = errors.New("The given key length must be at least 1.")
 
+	// WithElevatedKeyLength Errors
+
+	ErrNilElevationFunc          = errors.New("The given elevation function is nil.")
+	ErrElevatedKeyLengthTooShort = errors.New("The given elevated key length must be greater than the key length.")
+
 	// WithKeyDuration Errors
 
 	ErrNonPositiveKeyDuration = errors.New("The given key duration must be positive.")
 
+	// WithKeyDurationUntil Errors
+
+	ErrNilKeyExpirationFunc = errors.New("The given key expiration function is nil.")
+
+	// WithAutoClearInterval Errors
+
+	ErrNonPositiveAutoClearInterval = errors.New("The given auto clear interval must be positive.")
+
 	// WithCustomRandomKeyGenerator Errors
 
 	ErrNilRandomKeyGenerator = errors.New("The given random key generator function is nil.")
 
+	// WithKeyVersion Errors
+
+	ErrInvalidKeyVersion = errors.New("The given key version must not be empty nor contain dots.")
+
+	// WithIdentityFunc Errors
+
+	ErrNilIdentityFunc = errors.New("The given identity function is nil.")
+
+	// WithAbsoluteExpiration Errors
+
+	ErrNonPositiveAbsoluteExpiration = errors.New("The given absolute expiration must be positive.")
+
+	// WithRotationGracePeriod Errors
+
+	ErrNonPositiveRotationGracePeriod = errors.New("The given rotation grace period must be positive.")
+
+	// WithReplayDetection Errors
+
+	ErrNonPositiveReplayWindow = errors.New("The given replay detection window must be positive.")
+
+	// WithMaxSessionsPerOwner Errors
+
+	ErrNonPositiveMaxSessions    = errors.New("The given maximum of sessions per owner must be positive.")
+	ErrUnknownSessionLimitPolicy = errors.New("The given session limit policy is unknown.")
+
+	// WithKeyAudit Errors
+
+	ErrZeroKeyAuditRate = errors.New("The given key audit sampling rate must be at least 1.")
+
+	// WithUsageSampling Errors
+
+	ErrZeroUsageSamplingRate = errors.New("The given usage sampling rate must be at least 1.")
+
+	// WithOutageBuffer Errors
+
+	ErrNonPositiveOutageBufferSize = errors.New("The given outage buffer size must be positive.")
+	ErrNonPositiveRetryInterval    = errors.New("The given retry interval must be positive.")
+	ErrOutageBufferWithoutRedis    = errors.New("The outage buffer can only be used along with WithRedis.")
+
+	// WithIssueRateLimit Errors
+
+	ErrNonPositiveIssueRate  = errors.New("The given issue rate must be positive.")
+	ErrNonPositiveIssueBurst = errors.New("The given issue burst must be positive.")
+
+	// WithKeyEscrow Errors
+
+	ErrNilEscrowKey  = errors.New("The given escrow public key is nil.")
+	ErrNilEscrowSink = errors.New("The given escrow sink is nil.")
+
+	// WithBaseContext Errors
+
+	ErrNilBaseContext = errors.New("The given base context is nil.")
+
+	// WithShards Errors
+
+	ErrNonPositiveShards = errors.New("The given shard count must be positive.")
+
+	// WithLogger Errors
+
+	ErrNilLogger = errors.New("The given logger is nil.")
+
+	// WithScheduler Errors
+
+	ErrNilScheduler = errors.New("The given scheduler is nil.")
+
+	// WithCodec Errors
+
+	ErrNilCodec          = errors.New("The given codec is nil.")
+	ErrCodecWithoutRedis = errors.New("The codec can only be used along with WithRedis or WithMultiRegionRedis.")
+
+	// WithEncryption Errors
+
+	ErrInvalidEncryptionKey = errors.New("The given encryption key must be 16, 24 or 32 bytes long.")
+
+	// WithKeySigning Errors
+
+	ErrEmptyKeySigningSecret = errors.New("The given key signing secret is empty.")
+
+	// WithLookupRateLimit Errors
+
+	ErrNonPositiveLookupLimit  = errors.New("The given failed lookup limit must be positive.")
+	ErrNonPositiveLookupWindow = errors.New("The given failed lookup window must be positive.")
+
+	// WithAuditLogger Errors
+
+	ErrNilAuditLogger = errors.New("The given audit logger is nil.")
+
+	// WithHooks Errors
+
+	ErrNoHooks              = errors.New("No hook was given.")
+	ErrRedactWithoutPayload = errors.New("A redaction function was given without asking for the payload.")
+
+	// WithEventBuffer Errors
+
+	ErrNonPositiveEventBuffer = errors.New("The given event buffer size must be positive.")
+
+	// WithStatelessTokens Errors
+
+	ErrInvalidStatelessTokenKey = errors.New("The given stateless token key must be 32 bytes long.")
+
+	// WithMaxSessionSize Errors
+
+	ErrNonPositiveMaxSessionSize = errors.New("The given maximum session size must be positive.")
+
 	// Option Already Set Errors
 
 	ErrCustomKeyLengthAlreadySet      = errors.New("A custom key length was already registered for this session storage.")
+	ErrElevatedKeyLengthAlreadySet    = errors.New("An elevated key length was already registered for this session storage.")
 	ErrCustomKeyDurationAlreadySet    = errors.New("A custom key duration was already registered for this session storage.")
+	ErrExpirationModeAlreadySet       = errors.New("An expiration mode was already set for this session storage.")
+	ErrRotationGracePeriodAlreadySet  = errors.New("A rotation grace period was already set for this session storage.")
+	ErrReplayDetectionAlreadySet      = errors.New("Replay detection was already set for this session storage.")
 	ErrAutoClearExpiredKeysAlreadySet = errors.New("Auto clear for expired keys was already set for this session storage.")
+	ErrAutoClearIntervalAlreadySet    = errors.New("An auto clear interval was already set for this session storage.")
+	ErrKeyVersionAlreadySet           = errors.New("A key version was already set for this session storage.")
+	ErrIdentityFuncAlreadySet         = errors.New("An identity function was already registered for this session storage.")
+	ErrMaxSessionsPerOwnerAlreadySet  = errors.New("A maximum of sessions per owner was already set for this session storage.")
+	ErrKeyAuditAlreadySet             = errors.New("Key audit was already set for this session storage.")
+	ErrUsageSamplingAlreadySet        = errors.New("Usage sampling was already set for this session storage.")
+	ErrShardsAlreadySet               = errors.New("A shard count was already set for this session storage.")
+	ErrLoggerAlreadySet               = errors.New("A logger was already registered for this session storage.")
+	ErrOutageBufferAlreadySet         = errors.New("An outage buffer was already set for this session storage.")
+	ErrSchedulerAlreadySet            = errors.New("A scheduler was already registered for this session storage.")
+	ErrRedisPrefixAlreadySet          = errors.New("A Redis prefix was already registered for this session storage.")
+	ErrIssueRateLimitAlreadySet       = errors.New("An issue rate limit was already set for this session storage.")
+	ErrKeyEscrowAlreadySet            = errors.New("Key escrow was already set for this session storage.")
+	ErrBaseContextAlreadySet          = errors.New("A base context was already set for this session storage.")
+	ErrCodecAlreadySet                = errors.New("A codec was already registered for this session storage.")
+	ErrMaxSessionSizeAlreadySet       = errors.New("A maximum session size was already set for this session storage.")
+	ErrEncryptionAlreadySet           = errors.New("Encryption was already set for this session storage.")
+	ErrKeySigningAlreadySet           = errors.New("Key signing was already set for this session storage.")
+	ErrStatelessTokensAlreadySet      = errors.New("Stateless tokens were already set for this session storage.")
+	ErrLookupRateLimitAlreadySet      = errors.New("A failed lookup rate limit was already set for this session storage.")
+	ErrAuditLoggerAlreadySet          = errors.New("An audit logger was already registered for this session storage.")
+	ErrHooksAlreadySet                = errors.New("Hooks were already registered for this session storage.")
+	ErrEventBufferAlreadySet          = errors.New("An event buffer was already set for this session storage.")
 )
 
 type config struct {
-	autoClearExpiredKeys     bool
-	customKeyLength          *uint64
-	customKeyDuration        *time.Duration
-	customRandomKeyGenerator func(uint64) (string, error)
-	redisCtx                 context.Context
-	redisClient              *redis.Client
+	autoClearExpiredKeys      bool
+	autoClearInterval         *time.Duration
+	customKeyLength           *uint64
+	elevatedKeyLength         uint64
+	isElevated                func(session any) bool
+	customKeyDuration         *time.Duration
+	customKeyExpiration       func(time.Time) time.Time
+	expirationModeSet         bool
+	absoluteExpiration        time.Duration
+	rotationGracePeriod       time.Duration
+	replayWindow              time.Duration
+	onReplay                  func(oldKey string)
+	customRandomKeyGenerator  func(uint64) (string, error)
+	keyVersion                string
+	acceptedKeyVersions       []string
+	keyAuditRate              uint64
+	usageSampleRate           uint64
+	identity                  func(session any) string
+	maxSessionsPerOwner       int
+	sessionLimitPolicy        SessionLimitPolicy
+	issueRate                 float64
+	issueBurst                int
+	lookupLimit               int
+	lookupWindow              time.Duration
+	onLookupLimit             func(source string)
+	auditLogger               func(Event)
+	hooks                     *Hooks
+	eventBuffer               int
+	escrowKey                 *rsa.PublicKey
+	escrowSink                EscrowSink
+	readOnly                  bool
+	withoutKeyRotation        bool
+	shards                    int
+	logger                    *slog.Logger
+	outageBufferSize          int
+	outageRetryInterval       time.Duration
+	scheduler                 Scheduler
+	redisCtx                  context.Context
+	baseCtx                   context.Context
+	redisClient               *redis.Client
+	nearestRedisClient        *redis.Client
+	otherRedisClient          *redis.Client
+	storage                   Storage
+	redisPrefix               string
+	codec                     Codec
+	maxSessionSize            int
+	sealer                    *sealer
+	hashKeys                  bool
+	keySigningSecret          []byte
+	previousKeySigningSecrets [][]byte
+	tokens                    *tokenSealer
+	sqlitePath                string
+}
+
+// storageSet reports whether a storage other than Redis was already chosen.
+func (c *config) storageSet() bool {
+	return c.storage != nil ||
+		c.sqlitePath != ""
 }
 
 type Option interface {
@@ -53,11 +262,16 @@ func (o option) apply(c *config) error {
 }
 
 // WithRedis uses the given Redis client to store the sessions, instead of using
-// an in-memory storage. It also may receive a custom context to work on, but by
-// default it uses context.Background().
+// an in-memory storage. It also may receive a custom context for Set, Get and
+// Remove to work on, but by default it uses context.Background(). Use SetCtx,
+// GetCtx and RemoveCtx to give each call its own context instead.
 func WithRedis(client *redis.Client, ctx context.Context) Option {
 	return option(func(c *config) error {
-		if c.redisClient != nil || c.redisCtx != nil {
+		if c.storageSet() {
+			return ErrStorageAlreadySet
+		}
+
+		if c.redisClient != nil || c.nearestRedisClient != nil {
 			return ErrRedisClientAlreadySet
 		}
 
@@ -76,6 +290,101 @@ func WithRedis(client *redis.Client, ctx context.Context) Option {
 	})
 }
 
+// WithRedisPrefix prefixes every key stored in Redis, so suk's keyspace can be
+// told apart from other data in the same Redis database. Use MigratePrefix to
+// change the prefix of a live keyspace.
+func WithRedisPrefix(prefix string) Option {
+	return option(func(c *config) error {
+		if c.redisPrefix != "" {
+			return ErrRedisPrefixAlreadySet
+		}
+
+		c.redisPrefix = prefix
+		return nil
+	})
+}
+
+// WithStorage uses the given storage to hold the sessions, instead of using an
+// in-memory storage, allowing backends not shipped with suk to be plugged in.
+// It can't be used along with WithRedis, WithMultiRegionRedis or WithSQLite.
+func WithStorage(storage Storage) Option {
+	return option(func(c *config) error {
+		if c.storageSet() || c.redisClient != nil || c.nearestRedisClient != nil {
+			return ErrStorageAlreadySet
+		}
+
+		if storage == nil {
+			return ErrNilStorage
+		}
+
+		c.storage = storage
+		return nil
+	})
+}
+
+// WithSQLite stores the sessions in the SQLite database at path, creating it
+// when needed, so sessions survive restarts without an external service. The
+// database is closed on Close.
+//
+// No SQLite driver is bundled, so one must be registered with database/sql,
+// such as modernc.org/sqlite or github.com/mattn/go-sqlite3:
+//
+//	import _ "modernc.org/sqlite"
+//
+// Sessions are encoded with encoding/gob, so custom types must be registered
+// with gob.Register. Expired sessions are only removed by ClearExpired, so
+// consider using WithAutoClearExpiredKeys as well.
+func WithSQLite(path string) Option {
+	return option(func(c *config) error {
+		if c.storageSet() || c.redisClient != nil || c.nearestRedisClient != nil {
+			return ErrStorageAlreadySet
+		}
+
+		if path == "" {
+			return ErrEmptySQLitePath
+		}
+
+		c.sqlitePath = path
+		return nil
+	})
+}
+
+// WithMultiRegionRedis stores the sessions in two regional Redis deployments,
+// for globally distributed applications. Gets are served by the nearest
+// deployment, falling back to the other one for keys that weren't replicated
+// yet, while writes are applied to the nearest deployment and asynchronously
+// replicated to the other one, so no operation waits on cross-region latency.
+//
+// When the same key is rotated concurrently in both regions, the newest
+// rotation wins and the key issued by the older one is invalidated in both
+// regions. It also may receive a custom context to work on, but by default it
+// uses context.Background().
+func WithMultiRegionRedis(nearest, other *redis.Client, ctx context.Context) Option {
+	return option(func(c *config) error {
+		if c.storageSet() {
+			return ErrStorageAlreadySet
+		}
+
+		if c.redisClient != nil || c.nearestRedisClient != nil {
+			return ErrRedisClientAlreadySet
+		}
+
+		if nearest == nil || other == nil {
+			return ErrNilRedisClient
+		}
+
+		if ctx == nil {
+			c.redisCtx = context.Background()
+		} else {
+			c.redisCtx = ctx
+		}
+
+		c.nearestRedisClient = nearest
+		c.otherRedisClient = other
+		return nil
+	})
+}
+
 // WithKeyLength sets a custom key length for generated keys. The default
 // is 32, which gives an entropy of 192 for each key, which should be fine for
 // most applications.
@@ -97,11 +406,34 @@ func WithKeyLength(keyLength uint64) Option {
 	})
 }
 
+// WithElevatedKeyLength issues longer keys, of the given length, for sessions
+// flagged as high-privilege by isElevated, such as admin sessions, so they
+// carry more entropy without changing the key length of every other session.
+// Sessions are checked whenever a key is issued for them, so a session
+// elevated after being set gets longer keys from its next rotation on.
+//
+// The elevated key length must be greater than the key length.
+func WithElevatedKeyLength(keyLength uint64, isElevated func(session any) bool) Option {
+	return option(func(c *config) error {
+		if c.isElevated != nil {
+			return ErrElevatedKeyLengthAlreadySet
+		}
+
+		if isElevated == nil {
+			return ErrNilElevationFunc
+		}
+
+		c.elevatedKeyLength = keyLength
+		c.isElevated = isElevated
+		return nil
+	})
+}
+
 // WithKeyDuration sets a custom duration for generated keys. The default is
 // 10 minutes.
 func WithKeyDuration(duration time.Duration) Option {
 	return option(func(c *config) error {
-		if c.customKeyDuration != nil {
+		if c.customKeyDuration != nil || c.customKeyExpiration != nil {
 			return ErrCustomKeyDurationAlreadySet
 		}
 
@@ -114,10 +446,136 @@ func WithKeyDuration(duration time.Duration) Option {
 	})
 }
 
+// WithKeyDurationUntil sets a function that computes the expiration of
+// generated keys from the time they are issued, allowing calendar-aware
+// durations, such as expiring every session at midnight local time:
+//
+//	suk.WithKeyDurationUntil(func(now time.Time) time.Time {
+//		y, m, d := now.Date()
+//		return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+//	})
+//
+// Keys whose computed expiration is not after the time they are issued are
+// refused with ErrPastExpiration. It can't be used along with WithKeyDuration.
+// When auto clearing expired keys, the clearing still happens every 10
+// minutes.
+func WithKeyDurationUntil(until func(now time.Time) time.Time) Option {
+	return option(func(c *config) error {
+		if c.customKeyDuration != nil || c.customKeyExpiration != nil {
+			return ErrCustomKeyDurationAlreadySet
+		}
+
+		if until == nil {
+			return ErrNilKeyExpirationFunc
+		}
+
+		c.customKeyExpiration = until
+		return nil
+	})
+}
+
+// WithSlidingExpiration makes every rotation give the session a new key
+// duration, so sessions live as long as they keep being used. This is the
+// default, and it can't be used along with WithAbsoluteExpiration.
+func WithSlidingExpiration() Option {
+	return option(func(c *config) error {
+		if c.expirationModeSet {
+			return ErrExpirationModeAlreadySet
+		}
+
+		c.expirationModeSet = true
+		return nil
+	})
+}
+
+// WithAbsoluteExpiration caps the lifetime of every session to max from the
+// moment it is set, so activity can't keep it alive forever. Keys still expire
+// after the key duration without being used, but are never renewed past the
+// cap. Set the key duration to max for sessions expiring at a fixed time
+// whatever the activity. The cap is kept across rotations by every backend,
+// Redis included.
+func WithAbsoluteExpiration(max time.Duration) Option {
+	return option(func(c *config) error {
+		if c.expirationModeSet {
+			return ErrExpirationModeAlreadySet
+		}
+
+		if max <= 0 {
+			return ErrNonPositiveAbsoluteExpiration
+		}
+
+		c.expirationModeSet = true
+		c.absoluteExpiration = max
+		return nil
+	})
+}
+
+// WithRotationGracePeriod keeps consumed keys usable for the given period after
+// being rotated, so parallel requests from the same client, such as a browser
+// loading many resources at once, don't log the user out. Within the period,
+// Get returns the session along with the key it was rotated to, without
+// rotating it again, and Remove ends the session it was rotated to.
+//
+// The keys are remembered by the process rotating them, so with backends shared
+// by many instances, such as Redis, concurrent requests should reach the same
+// instance. Keep the period short, such as a few seconds, as it weakens the
+// single-use guarantee.
+func WithRotationGracePeriod(d time.Duration) Option {
+	return option(func(c *config) error {
+		if c.rotationGracePeriod != 0 {
+			return ErrRotationGracePeriodAlreadySet
+		}
+
+		if d <= 0 {
+			return ErrNonPositiveRotationGracePeriod
+		}
+
+		c.rotationGracePeriod = d
+		return nil
+	})
+}
+
+// WithReplayDetection remembers the keys consumed by rotations for the given
+// window. Presenting one of them again, past the grace period set with
+// WithRotationGracePeriod, if any, is a strong sign the key was stolen, as
+// either the client or the thief is holding a stale copy. The whole session is
+// then revoked, whatever key it is under now, onReplay is called with the
+// replayed key, if not nil, and Get fails with ErrKeyReplayed.
+//
+// As with WithRotationGracePeriod, the keys are remembered by the process
+// rotating them.
+func WithReplayDetection(window time.Duration, onReplay func(oldKey string)) Option {
+	return option(func(c *config) error {
+		if c.replayWindow != 0 {
+			return ErrReplayDetectionAlreadySet
+		}
+
+		if window <= 0 {
+			return ErrNonPositiveReplayWindow
+		}
+
+		c.replayWindow = window
+		c.onReplay = onReplay
+		return nil
+	})
+}
+
+// WithoutKeyRotation turns off the single-use semantics, so Get returns the
+// session along with the same key, which stays valid until it expires. It
+// suits plain server-side sessions, where rewriting the cookie on every request
+// is not wanted, at the cost of stolen keys being usable until they expire.
+func WithoutKeyRotation() Option {
+	return option(func(c *config) error {
+		c.withoutKeyRotation = true
+		return nil
+	})
+}
+
 // WithAutoClearExpiredKeys automatically clears expired keys at intervals
 // based on the set key expiration time. By default, the clearing process occurs
 // every 10 minutes, but this can be adjusted by setting a different key
-// expiration time using WithTokenDuration.
+// expiration time using WithTokenDuration, or a separate interval using
+// WithAutoClearInterval.
 func WithAutoClearExpiredKeys() Option {
 	return option(func(c *config) error {
 		c.autoClearExpiredKeys = true
@@ -125,8 +583,27 @@ func WithAutoClearExpiredKeys() Option {
 	})
 }
 
+// WithAutoClearInterval automatically clears expired keys every interval,
+// regardless of the key duration, so long-lived keys don't linger for as long
+// once expired. It implies WithAutoClearExpiredKeys.
+func WithAutoClearInterval(interval time.Duration) Option {
+	return option(func(c *config) error {
+		if c.autoClearInterval != nil {
+			return ErrAutoClearIntervalAlreadySet
+		}
+
+		if interval <= 0 {
+			return ErrNonPositiveAutoClearInterval
+		}
+
+		c.autoClearExpiredKeys = true
+		c.autoClearInterval = &interval
+		return nil
+	})
+}
+
 // WithCustomRandomKeyGenerator sets a custom function to generate the keys.
-func WithCustomRandomKeyGenerator(rkg func (uint64) (string, error)) Option {
+func WithCustomRandomKeyGenerator(rkg func(uint64) (string, error)) Option {
 	return option(func(c *config) error {
 		if rkg == nil {
 			return ErrNonPositiveKeyDuration
@@ -136,3 +613,535 @@ func WithCustomRandomKeyGenerator(rkg func (uint64) (string, error)) Option {
 		return nil
 	})
 }
+
+// WithKeyVersion prefixes every key issued with the version code and a dot,
+// such as "s1.", and rejects keys carrying any other version with
+// ErrForeignKey before looking them up. Tokens meant for another system, or
+// another session storage, are then turned away without touching the backend,
+// and KeyVersion can be used to route keys between session storages.
+//
+// Keys issued with the accepted versions are still looked up, so the key
+// format can be changed without logging everyone out. An empty accepted
+// version stands for the keys issued before WithKeyVersion was used.
+func WithKeyVersion(version string, accepted ...string) Option {
+	return option(func(c *config) error {
+		if c.keyVersion != "" {
+			return ErrKeyVersionAlreadySet
+		}
+
+		if version == "" || strings.Contains(version, keyVersionSeparator) {
+			return ErrInvalidKeyVersion
+		}
+
+		for _, v := range accepted {
+			if strings.Contains(v, keyVersionSeparator) {
+				return ErrInvalidKeyVersion
+			}
+		}
+
+		c.keyVersion = version
+		c.acceptedKeyVersions = accepted
+		return nil
+	})
+}
+
+// WithIdentityFunc tells the owner of each session, such as the ID of the user
+// it was issued to, so the sessions are indexed by owner as they are issued,
+// rotated and removed, without callers maintaining the mapping themselves.
+// The owner is also recorded along with escrowed keys and their log entries.
+// Sessions whose owner is an empty string are not indexed.
+//
+// The owner is stored along with each session, and the backends implementing
+// OwnerFinder, such as Redis and SQLite, index the sessions by owner, so they
+// are found whichever instance issued them.
+func WithIdentityFunc(identity func(session any) (ownerID string)) Option {
+	return option(func(c *config) error {
+		if c.identity != nil {
+			return ErrIdentityFuncAlreadySet
+		}
+
+		if identity == nil {
+			return ErrNilIdentityFunc
+		}
+
+		c.identity = identity
+		return nil
+	})
+}
+
+// WithMaxSessionsPerOwner limits the live sessions each owner, as given with
+// WithOwner or told by the function given to WithIdentityFunc, may hold at
+// once to n. Once an owner holds n sessions, setting another one either revokes
+// the ones created the earliest, with EvictOldest, or fails with
+// ErrTooManySessions, with RejectNew.
+//
+// Sessions are counted as listed by SessionsForOwner, so the limit holds
+// across every instance sharing the backend. It fails with
+// ErrOwnersUnsupported when the storage can't find the sessions of an owner,
+// such as a custom one implementing neither OwnerFinder nor Ranger.
+func WithMaxSessionsPerOwner(n int, policy SessionLimitPolicy) Option {
+	return option(func(c *config) error {
+		if c.maxSessionsPerOwner != 0 {
+			return ErrMaxSessionsPerOwnerAlreadySet
+		}
+
+		if n <= 0 {
+			return ErrNonPositiveMaxSessions
+		}
+
+		if policy != EvictOldest && policy != RejectNew {
+			return ErrUnknownSessionLimitPolicy
+		}
+
+		c.maxSessionsPerOwner = n
+		c.sessionLimitPolicy = policy
+		return nil
+	})
+}
+
+// WithKeyAudit samples one of every rate generated keys, counting how their
+// characters are distributed over the key alphabet. The results, including a
+// chi-squared bias statistic, are reported by Stats, giving evidence that the
+// key generator is unbiased.
+func WithKeyAudit(rate uint64) Option {
+	return option(func(c *config) error {
+		if c.keyAuditRate != 0 {
+			return ErrKeyAuditAlreadySet
+		}
+
+		if rate == 0 {
+			return ErrZeroKeyAuditRate
+		}
+
+		c.keyAuditRate = rate
+		return nil
+	})
+}
+
+// WithUsageSampling samples one of every rate issued sessions, recording the
+// size of their payloads, how often they are rotated and how much of their
+// lifetime keys use up before being rotated. The distributions are reported by
+// Stats, so capacity planning can be based on the measured workload. No key nor
+// session is kept, only the measures.
+func WithUsageSampling(rate uint64) Option {
+	return option(func(c *config) error {
+		if c.usageSampleRate != 0 {
+			return ErrUsageSamplingAlreadySet
+		}
+
+		if rate == 0 {
+			return ErrZeroUsageSamplingRate
+		}
+
+		c.usageSampleRate = rate
+		return nil
+	})
+}
+
+// WithShards splits the in-memory storage into n shards, each with its own
+// lock, so operations on keys of different shards don't wait on each other.
+// Operations on the same key are always serialized, whatever the storage, so
+// it also sets how many locks are used for that. The default is 32, and more
+// shards only pay off on machines with many cores under heavy load.
+func WithShards(n int) Option {
+	return option(func(c *config) error {
+		if c.shards != 0 {
+			return ErrShardsAlreadySet
+		}
+
+		if n <= 0 {
+			return ErrNonPositiveShards
+		}
+
+		c.shards = n
+		return nil
+	})
+}
+
+// WithLogger sets the logger used to report problems that can't be returned
+// to the caller, such as a panic in a background worker. The default is
+// slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return option(func(c *config) error {
+		if c.logger != nil {
+			return ErrLoggerAlreadySet
+		}
+
+		if logger == nil {
+			return ErrNilLogger
+		}
+
+		c.logger = logger
+		return nil
+	})
+}
+
+// WithOutageBuffer keeps login working through brief Redis outages. Sessions
+// set while Redis is unreachable are buffered locally, up to maxSize of them,
+// and flushed back to Redis every retryInterval once it is reachable again.
+//
+// Until flushed, buffered sessions are only visible to this session storage,
+// so other instances sharing the same Redis won't find them. It can only be
+// used along with WithRedis.
+func WithOutageBuffer(maxSize int, retryInterval time.Duration) Option {
+	return option(func(c *config) error {
+		if c.outageBufferSize != 0 {
+			return ErrOutageBufferAlreadySet
+		}
+
+		if maxSize <= 0 {
+			return ErrNonPositiveOutageBufferSize
+		}
+
+		if retryInterval <= 0 {
+			return ErrNonPositiveRetryInterval
+		}
+
+		c.outageBufferSize = maxSize
+		c.outageRetryInterval = retryInterval
+		return nil
+	})
+}
+
+// WithCodec serializes the sessions stored in Redis with the given codec, such
+// as JSONCodec, GobCodec, MsgpackCodec or ProtoCodec, so sessions of any type
+// survive it. Without a codec, Redis only keeps the types its client can
+// write, such as strings, numbers and byte slices, and sessions come back as
+// strings. It can only be used along with WithRedis or WithMultiRegionRedis.
+func WithCodec(codec Codec) Option {
+	return option(func(c *config) error {
+		if c.codec != nil {
+			return ErrCodecAlreadySet
+		}
+
+		if codec == nil {
+			return ErrNilCodec
+		}
+
+		c.codec = codec
+		return nil
+	})
+}
+
+// WithMaxSessionSize limits how many bytes a session may take once serialized,
+// so a single handler can't fill the backend with huge sessions. Sessions are
+// measured as serialized by the codec given to WithCodec, if any, and as
+// encoded with encoding/gob otherwise, while strings and byte slices are
+// measured as they are. Set, SetMany and Update fail with a
+// SessionTooLargeError, matching ErrSessionTooLarge, for larger sessions.
+func WithMaxSessionSize(bytes int) Option {
+	return option(func(c *config) error {
+		if c.maxSessionSize != 0 {
+			return ErrMaxSessionSizeAlreadySet
+		}
+
+		if bytes <= 0 {
+			return ErrNonPositiveMaxSessionSize
+		}
+
+		c.maxSessionSize = bytes
+		return nil
+	})
+}
+
+// WithEncryption encrypts the sessions with AES-GCM before they reach the
+// backend, such as Redis, SQLite or a storage set with WithStorage, and
+// decrypts them once read. Keys must be 16, 24 or 32 bytes long, selecting
+// AES-128, AES-192 or AES-256. Each session is prefixed with an ID of the key
+// it was encrypted with, and sessions stored before encryption was enabled are
+// still read as they are.
+//
+// Sessions are encrypted with key, and decrypted with it or any of the
+// previous keys, so they stay readable once key is rotated. Sessions
+// encrypted with a previous key are encrypted again with key as they are
+// retrieved with Get, so previous keys can be dropped once every session
+// outlived them, or right away after encrypting every session again with
+// ReencryptAll.
+//
+// Sessions kept in memory are not encrypted. Storages set with WithStorage are
+// given entries holding the encrypted data, which they must store as any other
+// data, such as with encoding/gob. In Redis, sessions must be strings or byte
+// slices unless a codec is set with WithCodec, failing with
+// ErrUnencryptableSession otherwise.
+func WithEncryption(key []byte, previous ...[]byte) Option {
+	return option(func(c *config) error {
+		if c.sealer != nil {
+			return ErrEncryptionAlreadySet
+		}
+
+		s, err := newSealer(key, previous...)
+		if err != nil {
+			return err
+		}
+
+		c.sealer = s
+		return nil
+	})
+}
+
+// WithHashedKeys stores the SHA-256 hash of each key in the backend instead of
+// the key itself, so a leaked Redis dump or database backup doesn't yield
+// usable keys. As lookups compare hashes, their timing reveals nothing about
+// the stored keys either. Keys are hashed before they reach any backend, so
+// storages set with WithStorage, such as sqlstore, are only given the hashes
+// too. The in-memory storage always stores hashed keys.
+//
+// Like the in-memory storage, hashed storages can't tell the keys of the
+// sessions they list, so Range gives empty keys for the sessions not issued
+// or rotated by this process. Keys stored before the option was set are no
+// longer found.
+func WithHashedKeys() Option {
+	return option(func(c *config) error {
+		c.hashKeys = true
+		return nil
+	})
+}
+
+// WithKeySigning appends an HMAC-SHA256 tag of each key issued, made with the
+// secret, to the key itself, and rejects keys without a valid tag with
+// ErrForeignKey before looking them up. Forged and garbled keys, such as the
+// ones sent while credential stuffing, are then turned away without touching
+// the backend. The tag takes 22 more characters after the key.
+//
+// Keys signed with any of the previous secrets are still accepted, so the
+// secret can be rotated without logging everyone out, while keys issued
+// before WithKeySigning was used no longer are.
+func WithKeySigning(secret []byte, previous ...[]byte) Option {
+	return option(func(c *config) error {
+		if c.keySigningSecret != nil {
+			return ErrKeySigningAlreadySet
+		}
+
+		for _, s := range append([][]byte{secret}, previous...) {
+			if len(s) == 0 {
+				return ErrEmptyKeySigningSecret
+			}
+		}
+
+		c.keySigningSecret = bytes.Clone(secret)
+		for _, s := range previous {
+			c.previousKeySigningSecrets = append(c.previousKeySigningSecrets, bytes.Clone(s))
+		}
+		return nil
+	})
+}
+
+// WithStatelessTokens makes the session storage issue self-contained PASETO
+// v4.local tokens instead of keys, embedding the session and its expiration
+// encrypted with the 32-byte key, so Set, Get and Peek never reach the
+// backend. It suits read-heavy endpoints, where looking sessions up is the
+// bottleneck.
+//
+// Sessions are serialized to JSON, so they come back as the generic types of
+// encoding/json, which Typed converts back to their type. Tokens are not
+// rotated, so Get returns the given token back, and stay valid until they
+// expire, as the session storage can't forget them: Remove, Pop and Update
+// fail with ErrStatelessToken, and only InvalidateAll ends them early. As
+// tokens carry the whole session, they must be kept small enough for a
+// cookie.
+func WithStatelessTokens(key []byte) Option {
+	return option(func(c *config) error {
+		if c.tokens != nil {
+			return ErrStatelessTokensAlreadySet
+		}
+
+		if len(key) != tokenKeySize {
+			return ErrInvalidStatelessTokenKey
+		}
+
+		c.tokens = &tokenSealer{key: [tokenKeySize]byte(key)}
+		return nil
+	})
+}
+
+// WithIssueRateLimit limits how many sessions SetCtx issues to each source,
+// protecting login endpoints against session-minting floods before they hit
+// the storage. Each source is issued up to burst sessions at once, refilled at
+// perSecond sessions per second, and further sessions fail with
+// ErrIssueRateLimited. The source is given with SourceContext, and sessions
+// set without one are not limited.
+func WithIssueRateLimit(perSecond float64, burst int) Option {
+	return option(func(c *config) error {
+		if c.issueRate != 0 {
+			return ErrIssueRateLimitAlreadySet
+		}
+
+		if perSecond <= 0 {
+			return ErrNonPositiveIssueRate
+		}
+
+		if burst <= 0 {
+			return ErrNonPositiveIssueBurst
+		}
+
+		c.issueRate = perSecond
+		c.issueBurst = burst
+		return nil
+	})
+}
+
+// WithLookupRateLimit counts the lookups of each source failing because the
+// key was not found, or not accepted, over a sliding window, which is what key
+// guessing looks like from the storage. Once a source failed limit lookups
+// within the window, its lookups fail with ErrLookupRateLimited, without
+// reaching the backend, until the oldest failures slide out of it, and
+// onExceeded is called with the source, if not nil, so it can be blocked or
+// reported further up. Every lookup by key counts, such as with GetCtx,
+// PeekCtx, ExistsCtx, UpdateCtx or ScratchCtx. The source is given with
+// SourceContext, and lookups without one are not limited.
+func WithLookupRateLimit(limit int, window time.Duration, onExceeded func(source string)) Option {
+	return option(func(c *config) error {
+		if c.lookupLimit != 0 {
+			return ErrLookupRateLimitAlreadySet
+		}
+
+		if limit <= 0 {
+			return ErrNonPositiveLookupLimit
+		}
+
+		if window <= 0 {
+			return ErrNonPositiveLookupWindow
+		}
+
+		c.lookupLimit = limit
+		c.lookupWindow = window
+		c.onLookupLimit = onExceeded
+		return nil
+	})
+}
+
+// WithAuditLogger hands fn an event every time a session is created, is
+// retrieved, with its key rotated or not, expires, is removed or has one of its
+// consumed keys replayed, such as for feeding a SIEM, without wrapping every
+// call. Events carry the hash of the keys, never the keys themselves, along
+// with the owner of the session and when it happened.
+//
+// fn is called synchronously, while the operation is in flight, so it must be
+// quick and must not use the session storage. Expirations are only seen when
+// keys are rotated or, for the in-memory storage, when expired keys are
+// cleared, as the other backends drop expired sessions on their own.
+func WithAuditLogger(fn func(Event)) Option {
+	return option(func(c *config) error {
+		if c.auditLogger != nil {
+			return ErrAuditLoggerAlreadySet
+		}
+
+		if fn == nil {
+			return ErrNilAuditLogger
+		}
+
+		c.auditLogger = fn
+		return nil
+	})
+}
+
+// WithHooks registers the hooks called on each transition in the lifecycle of
+// the sessions, such as for warming caches, recording metrics or cleaning up
+// what belongs to the sessions that are gone. They can be replaced at runtime
+// with Reconfigure.
+func WithHooks(hooks Hooks) Option {
+	return option(func(c *config) error {
+		if c.hooks != nil {
+			return ErrHooksAlreadySet
+		}
+
+		if hooks.OnSet == nil && hooks.OnGet == nil && hooks.OnExpire == nil && hooks.OnRemove == nil {
+			return ErrNoHooks
+		}
+
+		if hooks.Redact != nil && !hooks.Payload {
+			return ErrRedactWithoutPayload
+		}
+
+		c.hooks = &hooks
+		return nil
+	})
+}
+
+// WithEventBuffer sends the events through the channel returned by Events,
+// buffering up to size of them, so they can be handled without holding up the
+// operations. Events arriving while the buffer is full are dropped.
+func WithEventBuffer(size int) Option {
+	return option(func(c *config) error {
+		if c.eventBuffer != 0 {
+			return ErrEventBufferAlreadySet
+		}
+
+		if size <= 0 {
+			return ErrNonPositiveEventBuffer
+		}
+
+		c.eventBuffer = size
+		return nil
+	})
+}
+
+// WithKeyEscrow delivers every key issued, either by Set or by rotating a key
+// on Get, to the sink, encrypted to the escrow public key. It is meant for
+// regulated deployments required to allow lawful session takeover, and must
+// never be enabled otherwise.
+//
+// Keys are only handed out once escrowed: when the sink fails, the key is
+// revoked and the error is returned, even if that loses the session being
+// rotated. Every escrowed key, and every failure, is recorded in the log.
+func WithKeyEscrow(publicKey *rsa.PublicKey, sink EscrowSink) Option {
+	return option(func(c *config) error {
+		if c.escrowSink != nil {
+			return ErrKeyEscrowAlreadySet
+		}
+
+		if publicKey == nil {
+			return ErrNilEscrowKey
+		}
+
+		if sink == nil {
+			return ErrNilEscrowSink
+		}
+
+		c.escrowKey = publicKey
+		c.escrowSink = sink
+		return nil
+	})
+}
+
+// WithBaseContext ties the session storage to the given context, such as the
+// root context of the server, given to http.Server through BaseContext. Once
+// it is canceled, every background goroutine stops, the operations in flight
+// are canceled and new ones fail with the cause of the cancellation, so suk
+// cooperates with existing graceful-shutdown orchestration. Close is still
+// needed to release the storage, such as closing a SQLite database.
+//
+// It is also the context Set, Get and Remove run on, unless WithRedis or
+// WithMultiRegionRedis was given one.
+func WithBaseContext(ctx context.Context) Option {
+	return option(func(c *config) error {
+		if c.baseCtx != nil {
+			return ErrBaseContextAlreadySet
+		}
+
+		if ctx == nil {
+			return ErrNilBaseContext
+		}
+
+		c.baseCtx = ctx
+		return nil
+	})
+}
+
+// WithScheduler sets the scheduler that drives the periodic background jobs,
+// such as the auto clear of expired keys. By default, each job runs in its own
+// goroutine using a ticker.
+func WithScheduler(scheduler Scheduler) Option {
+	return option(func(c *config) error {
+		if c.scheduler != nil {
+			return ErrSchedulerAlreadySet
+		}
+
+		if scheduler == nil {
+			return ErrNilScheduler
+		}
+
+		c.scheduler = scheduler
+		return nil
+	})
+}
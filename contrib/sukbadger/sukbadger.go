@@ -0,0 +1,308 @@
+// Package sukbadger provides a Badger backend for suk, relying on its native
+// TTL to expire the sessions, so ClearExpired has nothing to do, as with Redis:
+//
+//	db, _ := badger.Open(badger.DefaultOptions("/var/lib/app/sessions"))
+//	ss, _ := suk.New(sukbadger.WithBadger(db))
+//
+// The database is not closed along with the session storage. Sessions are
+// encoded with encoding/gob, so custom types must be registered with
+// gob.Register.
+package sukbadger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ed-henrique/suk"
+)
+
+// The prefixes of the keys of the sessions, of the indexes of the sessions by
+// ID and by owner and of the watermarks, so Clear leaves the watermarks alone.
+var (
+	sessionPrefix   = []byte("session:")
+	familyPrefix    = []byte("family:")
+	ownerPrefix     = []byte("owner:")
+	watermarkPrefix = []byte("watermark:")
+)
+
+// Storage stores the sessions in a Badger database, each expiring along with
+// its session.
+type Storage struct {
+	db *badger.DB
+}
+
+// New creates a storage keeping the sessions in the given database.
+func New(db *badger.DB) *Storage {
+	return &Storage{db}
+}
+
+// WithBadger stores the sessions in the given database. It is a shorthand for
+// suk.WithStorage(sukbadger.New(db)).
+func WithBadger(db *badger.DB) suk.Option {
+	return suk.WithStorage(New(db))
+}
+
+// sessionKey returns the Badger key of the session under key.
+func sessionKey(key string) []byte {
+	return append(bytes.Clone(sessionPrefix), key...)
+}
+
+func (s *Storage) Set(_ context.Context, key string, e suk.Entry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(sessionKey(key))
+		if err == nil {
+			return suk.ErrKeyExists
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		// Badger expirations have a precision of seconds, so it is rounded
+		// up, while the exact one is kept along with the session. The index
+		// entries of the session ID expire along with it.
+		expiresAt := uint64(e.ExpiresAt.Add(time.Second - 1).Unix())
+
+		entry := badger.NewEntry(sessionKey(key), buf.Bytes())
+		entry.ExpiresAt = expiresAt
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+
+		if e.ID == "" {
+			return nil
+		}
+
+		entry = badger.NewEntry(append(bytes.Clone(familyPrefix), e.ID...), []byte(key))
+		entry.ExpiresAt = expiresAt
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+
+		if e.Owner == "" {
+			return nil
+		}
+
+		entry = badger.NewEntry(ownerKey(e.Owner, e.ID), nil)
+		entry.ExpiresAt = expiresAt
+		return txn.SetEntry(entry)
+	})
+}
+
+// ownerKey returns the Badger key indexing the session with the given ID under
+// its owner.
+func ownerKey(owner, id string) []byte {
+	return append(bytes.Clone(ownerPrefix), owner+"\x00"+id...)
+}
+
+func (s *Storage) Get(_ context.Context, key string) (suk.Entry, error) {
+	var e suk.Entry
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		e, err = get(txn, key)
+		return err
+	})
+
+	return e, err
+}
+
+func (s *Storage) Remove(_ context.Context, key string) (suk.Entry, error) {
+	var e suk.Entry
+	err := s.db.Update(func(txn *badger.Txn) error {
+		var err error
+		e, err = get(txn, key)
+		if err != nil {
+			return err
+		}
+
+		return txn.Delete(sessionKey(key))
+	})
+
+	return e, err
+}
+
+// ClearExpired does nothing, as Badger drops the sessions once their TTL is
+// over.
+func (s *Storage) ClearExpired(_ context.Context) error {
+	return nil
+}
+
+func (s *Storage) Clear(_ context.Context) error {
+	return s.db.DropPrefix(sessionPrefix, familyPrefix, ownerPrefix)
+}
+
+// FindSession reads the index entry of the session ID, which may outlive the
+// session when it is removed, until it expires.
+func (s *Storage) FindSession(_ context.Context, id string) (string, error) {
+	var key string
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(append(bytes.Clone(familyPrefix), id...))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return suk.ErrNoKeyFound
+		} else if err != nil {
+			return err
+		}
+
+		stored, err := item.ValueCopy(nil)
+		key = string(stored)
+		return err
+	})
+
+	return key, err
+}
+
+// FindOwnedSessions reads the index entries of the sessions of owner, which may
+// outlive the sessions when they are removed, until they expire.
+func (s *Storage) FindOwnedSessions(_ context.Context, owner string) ([]string, error) {
+	var ids []string
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = ownerKey(owner, "")
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			ids = append(ids, string(it.Item().Key()[len(opts.Prefix):]))
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+// Count goes through the keys Badger hasn't expired yet, which, as its
+// expirations are rounded up to the second, may include sessions expired
+// within the last second.
+func (s *Storage) Count(_ context.Context) (int64, error) {
+	var n int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = sessionPrefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			n++
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+func (s *Storage) RangePage(_ context.Context, cursor string, limit int) ([]string, []suk.Entry, string, error) {
+	var (
+		keys    []string
+		entries []suk.Entry
+		next    string
+	)
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = sessionPrefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		it.Seek(sessionKey(cursor))
+		if it.Valid() && bytes.Equal(it.Item().Key(), sessionKey(cursor)) {
+			it.Next()
+		}
+
+		for ; it.Valid() && len(entries) < limit; it.Next() {
+			item := it.Item()
+
+			var e suk.Entry
+			err := item.Value(func(value []byte) error {
+				var err error
+				e, err = decode(value)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			key := string(bytes.TrimPrefix(item.Key(), sessionPrefix))
+			keys = append(keys, key)
+			entries = append(entries, e)
+			next = key
+		}
+
+		if !it.Valid() {
+			next = ""
+		}
+
+		return nil
+	})
+
+	return keys, entries, next, err
+}
+
+func get(txn *badger.Txn, key string) (suk.Entry, error) {
+	item, err := txn.Get(sessionKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return suk.Entry{}, suk.ErrNoKeyFound
+	} else if err != nil {
+		return suk.Entry{}, err
+	}
+
+	var e suk.Entry
+	err = item.Value(func(value []byte) error {
+		var err error
+		e, err = decode(value)
+		return err
+	})
+
+	return e, err
+}
+
+func decode(value []byte) (suk.Entry, error) {
+	var e suk.Entry
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&e); err != nil {
+		return suk.Entry{}, err
+	}
+
+	return e, nil
+}
+
+// RaiseWatermark tries again when another transaction raised the watermark
+// meanwhile.
+func (s *Storage) RaiseWatermark(_ context.Context, name string, value int64) (int64, error) {
+	key := append(bytes.Clone(watermarkPrefix), name...)
+
+	raise := func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == nil {
+			err = item.Value(func(stored []byte) error {
+				if len(stored) == 8 {
+					value = max(value, int64(binary.BigEndian.Uint64(stored)))
+				}
+				return nil
+			})
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		return txn.Set(key, binary.BigEndian.AppendUint64(nil, uint64(value)))
+	}
+
+	for {
+		err := s.db.Update(raise)
+		if !errors.Is(err, badger.ErrConflict) {
+			return value, err
+		}
+	}
+}
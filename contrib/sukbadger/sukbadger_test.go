@@ -0,0 +1,181 @@
+package sukbadger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/suktest"
+)
+
+func newTestBadger(t *testing.T) *badger.DB {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestStorage(t *testing.T) {
+	t.Run("Sessions are stored in Badger", func(t *testing.T) {
+		ss, _ := suk.New(WithBadger(newTestBadger(t)))
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		session, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if err := ss.Remove(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Sessions expire in Badger itself", func(t *testing.T) {
+		s := New(newTestBadger(t))
+		ctx := context.Background()
+
+		s.Set(ctx, "key", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Millisecond)})
+
+		if _, err := s.Get(ctx, "key"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		time.Sleep(time.Second + 100*time.Millisecond)
+
+		if _, err := s.Get(ctx, "key"); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if err := s.Set(ctx, "key", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if err := s.Set(ctx, "key", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}); !errors.Is(err, suk.ErrKeyExists) {
+			t.Errorf("got %v expected %v", err, suk.ErrKeyExists)
+		}
+	})
+
+	t.Run("Invalidations are kept in Badger", func(t *testing.T) {
+		db := newTestBadger(t)
+		ss, _ := suk.New(suk.WithStorage(New(db)))
+
+		key, _ := ss.Set(42)
+		if err := ss.InvalidateAll(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		suk.Destroy(ss)
+
+		s := New(db)
+		ss, _ = suk.New(suk.WithStorage(s))
+		defer suk.Destroy(ss)
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		ss.Clear()
+
+		if got, err := s.RaiseWatermark(context.Background(), "epoch", 0); err != nil || got != 1 {
+			t.Errorf("got %d and error %v expected %d", got, err, 1)
+		}
+	})
+
+	t.Run("Sessions of an owner are found whichever instance issued them", func(t *testing.T) {
+		s := New(newTestBadger(t))
+		a, _ := suk.New(suk.WithStorage(s))
+		b, _ := suk.New(suk.WithStorage(s))
+
+		key, _ := a.Set(42, suk.WithOwner("user-42"))
+		_, key, _ = b.Get(key)
+		b.Set(43, suk.WithOwner("user-42"))
+
+		if got, err := a.SessionsForOwner("user-42"); err != nil || len(got) != 2 {
+			t.Fatalf("got %v and %v expected 2 sessions", got, err)
+		}
+
+		if n, err := a.RevokeAllForOwner(context.Background(), "user-42", nil); err != nil || n != 2 {
+			t.Errorf("got %d and %v expected %d", n, err, 2)
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions are revoked whichever instance rotated them", func(t *testing.T) {
+		s := New(newTestBadger(t))
+		a, _ := suk.New(suk.WithStorage(s))
+		b, _ := suk.New(suk.WithStorage(s))
+
+		key, _ := a.Set(42)
+		id, _ := a.SessionID(key)
+		_, key, _ = b.Get(key)
+
+		if err := a.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions are listed, counted and cleared", func(t *testing.T) {
+		ss, _ := suk.New(suk.WithStorage(New(newTestBadger(t))))
+		ss.SetMany([]any{"a", "b", "c"})
+
+		listed := 0
+		ss.Range(func(string, suk.SessionInfo) bool {
+			listed++
+			return true
+		})
+
+		if listed != 3 {
+			t.Errorf("got %d expected %d", listed, 3)
+		}
+
+		if n, err := ss.Count(); err != nil || n != 3 {
+			t.Errorf("got %d and error %v expected %d", n, err, 3)
+		}
+
+		if err := ss.Clear(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if n, _ := ss.Count(); n != 0 {
+			t.Errorf("got %d expected %d", n, 0)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	suktest.Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+		ss, err := suk.New(append(opts, suk.WithStorage(New(newTestBadger(t))))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ss.Close(context.Background()) })
+
+		return ss
+	})
+}
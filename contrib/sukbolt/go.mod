@@ -0,0 +1,21 @@
+module github.com/ed-henrique/suk/contrib/sukbolt
+
+go 1.22.5
+
+require (
+	github.com/ed-henrique/suk v0.0.0-20261016132105-a50f13911910
+	go.etcd.io/bbolt v1.3.10
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/redis/go-redis/v9 v9.6.1 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/crypto v0.27.0 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
+)
+
+replace github.com/ed-henrique/suk => ../..
@@ -0,0 +1,466 @@
+// Package sukbolt provides a bbolt backend for suk, so sessions survive
+// restarts without an external service nor cgo:
+//
+//	store, _ := sukbolt.Open("sessions.db")
+//	defer store.Close()
+//	ss, _ := suk.New(suk.WithStorage(store), suk.WithHashedKeys())
+//
+// Apps already holding the database pass it to WithBolt instead, and keep
+// closing it themselves.
+//
+// With suk.WithHashedKeys, only the hashes of the keys reach the database file,
+// so a copy of it doesn't yield usable keys. Opening the database fails after a
+// second if another process holds it. Expired sessions are only removed by
+// ClearExpired, so consider using suk.WithAutoClearExpiredKeys as well.
+//
+// Sessions are encoded with encoding/gob, so custom types must be registered
+// with gob.Register.
+package sukbolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrEmptyPath = errors.New("The given bbolt database path is empty.")
+
+var (
+	bucket           = []byte("suk_sessions")
+	watermarksBucket = []byte("suk_watermarks")
+	familiesBucket   = []byte("suk_families")
+	ownersBucket     = []byte("suk_owners")
+)
+
+// Storage stores the sessions in a bbolt database, each as its expiration in
+// Unix nanoseconds followed by the gob-encoded entry, so expired sessions are
+// cleared without decoding them.
+type Storage struct {
+	db *bolt.DB
+
+	// once creates the buckets on the first use of a database not opened by
+	// Open, and err holds its outcome.
+	once sync.Once
+	err  error
+}
+
+// Open opens the bbolt database at path, creating it when needed. It must be
+// closed with Close once the session storage is closed.
+func Open(path string) (*Storage, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := New(db)
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// New creates a storage keeping the sessions in the given database, creating
+// its buckets on first use.
+func New(db *bolt.DB) *Storage {
+	return &Storage{db: db}
+}
+
+// WithBolt stores the sessions in the given database. It is a shorthand for
+// suk.WithStorage(sukbolt.New(db)).
+func WithBolt(db *bolt.DB) suk.Option {
+	return suk.WithStorage(New(db))
+}
+
+// init creates the buckets of the storage, once.
+func (s *Storage) init() error {
+	s.once.Do(func() {
+		s.err = s.db.Update(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{bucket, watermarksBucket, familiesBucket, ownersBucket} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+
+	return s.err
+}
+
+// Close closes the database.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func encode(e suk.Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 8))
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return nil, err
+	}
+
+	value := buf.Bytes()
+	binary.BigEndian.PutUint64(value, uint64(e.ExpiresAt.UnixNano()))
+	return value, nil
+}
+
+// expiration decodes only the expiration of an entry encoded by encode.
+func expiration(value []byte) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, suk.ErrCorruptedEntry
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value))), nil
+}
+
+func decode(value []byte) (suk.Entry, error) {
+	if len(value) < 8 {
+		return suk.Entry{}, suk.ErrCorruptedEntry
+	}
+
+	var e suk.Entry
+	if err := gob.NewDecoder(bytes.NewReader(value[8:])).Decode(&e); err != nil {
+		return suk.Entry{}, err
+	}
+
+	return e, nil
+}
+
+func (s *Storage) Set(_ context.Context, key string, e suk.Entry) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+
+	value, err := encode(e)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+
+		// Expired sessions not cleared yet don't hold on to their keys.
+		if old := b.Get([]byte(key)); old != nil {
+			expiresAt, err := expiration(old)
+			if err == nil && time.Now().Before(expiresAt) {
+				return suk.ErrKeyExists
+			}
+		}
+
+		if err := b.Put([]byte(key), value); err != nil {
+			return err
+		}
+
+		if e.ID == "" {
+			return nil
+		}
+
+		if err := tx.Bucket(familiesBucket).Put([]byte(e.ID), []byte(key)); err != nil {
+			return err
+		}
+
+		if e.Owner == "" {
+			return nil
+		}
+
+		return tx.Bucket(ownersBucket).Put(ownerKey(e.Owner, e.ID), nil)
+	})
+}
+
+// ownerKey returns the key, in the owners bucket, indexing the session with
+// the given ID under its owner.
+func ownerKey(owner, id string) []byte {
+	return []byte(owner + "\x00" + id)
+}
+
+// dropFamily drops the index entries of the session removed from under key,
+// unless it was rotated to another key since.
+func dropFamily(tx *bolt.Tx, e suk.Entry, key []byte) error {
+	if e.ID == "" {
+		return nil
+	}
+
+	b := tx.Bucket(familiesBucket)
+	if !bytes.Equal(b.Get([]byte(e.ID)), key) {
+		return nil
+	}
+
+	if err := b.Delete([]byte(e.ID)); err != nil {
+		return err
+	}
+
+	if e.Owner == "" {
+		return nil
+	}
+
+	return tx.Bucket(ownersBucket).Delete(ownerKey(e.Owner, e.ID))
+}
+
+func (s *Storage) Get(_ context.Context, key string) (suk.Entry, error) {
+	if err := s.init(); err != nil {
+		return suk.Entry{}, err
+	}
+
+	var e suk.Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(bucket).Get([]byte(key))
+		if value == nil {
+			return suk.ErrNoKeyFound
+		}
+
+		var err error
+		e, err = decode(value)
+		return err
+	})
+
+	return e, err
+}
+
+func (s *Storage) Remove(_ context.Context, key string) (suk.Entry, error) {
+	if err := s.init(); err != nil {
+		return suk.Entry{}, err
+	}
+
+	var e suk.Entry
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+
+		value := b.Get([]byte(key))
+		if value == nil {
+			return suk.ErrNoKeyFound
+		}
+
+		var err error
+		e, err = decode(value)
+		if err != nil {
+			return err
+		}
+
+		if err := dropFamily(tx, e, []byte(key)); err != nil {
+			return err
+		}
+
+		return b.Delete([]byte(key))
+	})
+
+	return e, err
+}
+
+func (s *Storage) ClearExpired(ctx context.Context) error {
+	_, err := s.Sweep(ctx, "", 0, time.Time{})
+	return err
+}
+
+// Sweep clears the expired sessions in a single transaction, stopping once
+// maxKeys keys were checked or the deadline passed. Only the expired sessions
+// are decoded, to drop their index entries.
+func (s *Storage) Sweep(_ context.Context, cursor string, maxKeys int, deadline time.Time) (string, error) {
+	if err := s.init(); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	next := ""
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		c := b.Cursor()
+
+		key, value := c.Seek([]byte(cursor))
+		if key != nil && string(key) == cursor {
+			key, value = c.Next()
+		}
+
+		var expired [][]byte
+		for n := 0; key != nil; n++ {
+			if n > 0 && (maxKeys > 0 && n >= maxKeys || !deadline.IsZero() && !time.Now().Before(deadline)) {
+				break
+			}
+
+			expiresAt, err := expiration(value)
+			if err == nil && !now.Before(expiresAt) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+
+			next = string(key)
+			key, value = c.Next()
+		}
+
+		if key == nil {
+			next = ""
+		}
+
+		for _, key := range expired {
+			if e, err := decode(b.Get(key)); err == nil {
+				if err := dropFamily(tx, e, key); err != nil {
+					return err
+				}
+			}
+
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return next, err
+}
+
+func (s *Storage) Clear(_ context.Context) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucket, familiesBucket, ownersBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) Count(_ context.Context) (int64, error) {
+	if err := s.init(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	var n int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, value []byte) error {
+			expiresAt, err := expiration(value)
+			if err == nil && now.Before(expiresAt) {
+				n++
+			}
+			return nil
+		})
+	})
+
+	return n, err
+}
+
+func (s *Storage) RangePage(_ context.Context, cursor string, limit int) ([]string, []suk.Entry, string, error) {
+	if err := s.init(); err != nil {
+		return nil, nil, "", err
+	}
+
+	var (
+		keys    []string
+		entries []suk.Entry
+		next    string
+	)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+
+		key, value := c.Seek([]byte(cursor))
+		if key != nil && string(key) == cursor {
+			key, value = c.Next()
+		}
+
+		for ; key != nil && len(entries) < limit; key, value = c.Next() {
+			e, err := decode(value)
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, string(key))
+			entries = append(entries, e)
+			next = string(key)
+		}
+
+		if key == nil {
+			next = ""
+		}
+
+		return nil
+	})
+
+	return keys, entries, next, err
+}
+
+// FindSession reads the index entry of the session ID, kept in its own bucket
+// along with the session.
+func (s *Storage) FindSession(_ context.Context, id string) (string, error) {
+	if err := s.init(); err != nil {
+		return "", err
+	}
+
+	var key string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(familiesBucket).Get([]byte(id))
+		if stored == nil {
+			return suk.ErrNoKeyFound
+		}
+
+		key = string(stored)
+		return nil
+	})
+
+	return key, err
+}
+
+// FindOwnedSessions reads the index entries of the sessions of owner, kept in
+// their own bucket along with the sessions.
+func (s *Storage) FindOwnedSessions(_ context.Context, owner string) ([]string, error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := ownerKey(owner, "")
+		c := tx.Bucket(ownersBucket).Cursor()
+		for key, _ := c.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = c.Next() {
+			ids = append(ids, string(key[len(prefix):]))
+		}
+
+		return nil
+	})
+
+	return ids, err
+}
+
+// RaiseWatermark keeps the watermarks in their own bucket, so Clear leaves
+// them alone.
+func (s *Storage) RaiseWatermark(_ context.Context, name string, value int64) (int64, error) {
+	if err := s.init(); err != nil {
+		return 0, err
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(watermarksBucket)
+
+		if stored := b.Get([]byte(name)); len(stored) == 8 {
+			value = max(value, int64(binary.BigEndian.Uint64(stored)))
+		}
+
+		return b.Put([]byte(name), binary.BigEndian.AppendUint64(nil, uint64(value)))
+	})
+
+	return value, err
+}
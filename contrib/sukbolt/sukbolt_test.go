@@ -0,0 +1,303 @@
+package sukbolt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/suktest"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestStorage(t *testing.T, path string) *Storage {
+	t.Helper()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+
+	return s
+}
+
+func TestStorage(t *testing.T) {
+	t.Run("Empty path", func(t *testing.T) {
+		if _, err := Open(""); !errors.Is(err, ErrEmptyPath) {
+			t.Errorf("got %v expected %v", err, ErrEmptyPath)
+		}
+	})
+
+	t.Run("Databases already open are used", func(t *testing.T) {
+		db, err := bolt.Open(filepath.Join(t.TempDir(), "sessions.db"), 0o600, nil)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer db.Close()
+
+		ss, err := suk.New(WithBolt(db))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer ss.Close(context.Background())
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session, _, err := ss.Get(key); err != nil || session != 42 {
+			t.Errorf("got %v and error %v expected %v", session, err, 42)
+		}
+	})
+
+	t.Run("Sessions survive restarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.db")
+
+		s := openTestStorage(t, path)
+		ss, _ := suk.New(suk.WithStorage(s), suk.WithHashedKeys())
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		suk.Destroy(ss)
+		s.Close()
+
+		s = openTestStorage(t, path)
+		defer s.Close()
+		ss, _ = suk.New(suk.WithStorage(s), suk.WithHashedKeys())
+		defer suk.Destroy(ss)
+
+		session, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if err := ss.Remove(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Invalidations survive restarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.db")
+
+		s := openTestStorage(t, path)
+		ss, _ := suk.New(suk.WithStorage(s))
+
+		key, _ := ss.Set(42)
+		if err := ss.InvalidateAll(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		suk.Destroy(ss)
+		s.Close()
+
+		s = openTestStorage(t, path)
+		defer s.Close()
+		ss, _ = suk.New(suk.WithStorage(s))
+		defer suk.Destroy(ss)
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		ss.Clear()
+
+		if got, err := s.RaiseWatermark(context.Background(), "epoch", 0); err != nil || got != 1 {
+			t.Errorf("got %d and error %v expected %d", got, err, 1)
+		}
+	})
+
+	t.Run("Sessions of an owner are found whichever instance issued them", func(t *testing.T) {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer s.Close()
+
+		a, _ := suk.New(suk.WithStorage(s), suk.WithHashedKeys())
+		b, _ := suk.New(suk.WithStorage(s), suk.WithHashedKeys())
+
+		key, _ := a.Set(42, suk.WithOwner("user-42"))
+		_, key, _ = b.Get(key)
+		b.Set(43, suk.WithOwner("user-42"))
+
+		if got, err := a.SessionsForOwner("user-42"); err != nil || len(got) != 2 {
+			t.Fatalf("got %v and %v expected 2 sessions", got, err)
+		}
+
+		if n, err := a.RevokeAllForOwner(context.Background(), "user-42", nil); err != nil || n != 2 {
+			t.Errorf("got %d and %v expected %d", n, err, 2)
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions are revoked whichever instance rotated them", func(t *testing.T) {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer s.Close()
+
+		a, _ := suk.New(suk.WithStorage(s), suk.WithHashedKeys())
+		b, _ := suk.New(suk.WithStorage(s), suk.WithHashedKeys())
+
+		key, _ := a.Set(42)
+		id, _ := a.SessionID(key)
+		_, key, _ = b.Get(key)
+
+		if err := a.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Index entries go away with their sessions", func(t *testing.T) {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer s.Close()
+
+		ctx := context.Background()
+		s.Set(ctx, "removed", suk.Entry{Data: "session", ID: "a", ExpiresAt: time.Now().Add(time.Hour)})
+		s.Set(ctx, "expired", suk.Entry{Data: "session", ID: "b", ExpiresAt: time.Now().Add(-time.Hour)})
+
+		if key, err := s.FindSession(ctx, "a"); err != nil || key != "removed" {
+			t.Errorf("got %q and error %v expected %q", key, err, "removed")
+		}
+
+		s.Remove(ctx, "removed")
+		s.ClearExpired(ctx)
+
+		for _, id := range []string{"a", "b"} {
+			if _, err := s.FindSession(ctx, id); !errors.Is(err, suk.ErrNoKeyFound) {
+				t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+			}
+		}
+	})
+
+	t.Run("Only expired sessions are cleared", func(t *testing.T) {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer s.Close()
+
+		ctx := context.Background()
+		for i, expiresAt := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour), time.Now().Add(-time.Minute)} {
+			s.Set(ctx, string(rune('a'+i)), suk.Entry{Data: "session", ExpiresAt: expiresAt})
+		}
+
+		if err := s.ClearExpired(ctx); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		for key, expected := range map[string]error{"a": suk.ErrNoKeyFound, "b": nil, "c": suk.ErrNoKeyFound} {
+			if _, err := s.Get(ctx, key); !errors.Is(err, expected) {
+				t.Errorf("got %v expected %v", err, expected)
+			}
+		}
+	})
+
+	t.Run("Incremental sweeps clear every expired key", func(t *testing.T) {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer s.Close()
+
+		ctx := context.Background()
+		for i := range 10 {
+			expiresAt := time.Now().Add(time.Hour)
+			if i%2 == 0 {
+				expiresAt = time.Now().Add(-time.Hour)
+			}
+			s.Set(ctx, string(rune('a'+i)), suk.Entry{Data: "session", ExpiresAt: expiresAt})
+		}
+
+		cursor, sweeps := "", 0
+		for {
+			var err error
+			if cursor, err = s.Sweep(ctx, cursor, 3, time.Time{}); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			sweeps++
+			if cursor == "" {
+				break
+			}
+		}
+
+		if sweeps != 4 {
+			t.Errorf("got %d expected %d", sweeps, 4)
+		}
+
+		if n, _ := s.Count(ctx); n != 5 {
+			t.Errorf("got %d expected %d", n, 5)
+		}
+	})
+
+	t.Run("Colliding keys are reported unless expired", func(t *testing.T) {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer s.Close()
+
+		ctx := context.Background()
+		s.Set(ctx, "live", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)})
+		s.Set(ctx, "expired", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(-time.Hour)})
+
+		if err := s.Set(ctx, "live", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}); !errors.Is(err, suk.ErrKeyExists) {
+			t.Errorf("got %v expected %v", err, suk.ErrKeyExists)
+		}
+
+		if err := s.Set(ctx, "expired", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Sessions are listed, counted and cleared", func(t *testing.T) {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer s.Close()
+
+		ss, _ := suk.New(suk.WithStorage(s))
+		ss.SetMany([]any{"a", "b", "c"})
+
+		listed := 0
+		ss.Range(func(string, suk.SessionInfo) bool {
+			listed++
+			return true
+		})
+
+		if listed != 3 {
+			t.Errorf("got %d expected %d", listed, 3)
+		}
+
+		if n, err := ss.Count(); err != nil || n != 3 {
+			t.Errorf("got %d and error %v expected %d", n, err, 3)
+		}
+
+		if err := ss.Clear(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if n, _ := ss.Count(); n != 0 {
+			t.Errorf("got %d expected %d", n, 0)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	suktest.Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+		s := openTestStorage(t, filepath.Join(t.TempDir(), "sessions.db"))
+		t.Cleanup(func() { s.Close() })
+
+		ss, err := suk.New(append(opts, suk.WithStorage(s))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ss.Close(context.Background()) })
+
+		return ss
+	})
+}
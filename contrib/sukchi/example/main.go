@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/contrib/sukchi"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/go-chi/chi/v5"
+)
+
+// This is an example of using suk along with chi, protecting only some route
+// groups. Most error checking is skipped for brevity, but you should check in
+// your application.
+//
+// The routes are:
+// - /login, which is public, and logs the user in;
+// - /dashboard, an HTML page, redirecting to /login without a session;
+// - /api/me, a JSON endpoint, answering 401 Unauthorized without a session.
+
+func login(ss *suk.SessionStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// In an actual application, you would check the user credentials first.
+		s, _ := ss.SetSession("ed")
+		sukhttp.Issue(r, s)
+		fmt.Fprint(w, "Logged in")
+	}
+}
+
+func dashboard(w http.ResponseWriter, r *http.Request) {
+	s, _ := sukhttp.FromContext(r.Context())
+	fmt.Fprintf(w, "<h1>Hello, %s!</h1>", s.Data)
+}
+
+func me(w http.ResponseWriter, r *http.Request) {
+	s, _ := sukhttp.FromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"user": s.Data})
+}
+
+func main() {
+	ss, err := suk.New(suk.WithKeyDuration(15*time.Minute), suk.WithAutoClearExpiredKeys())
+	if err != nil {
+		panic(err)
+	}
+	defer ss.Close(context.Background())
+
+	r := chi.NewRouter()
+
+	// The middleware retrieves the session of every request, rotating its key,
+	// while the route groups decide whether they require one.
+	r.Use(sukhttp.Middleware(ss))
+
+	r.Get("/login", login(ss))
+
+	r.Group(func(r chi.Router) {
+		r.Use(sukchi.RequireHTML("/login"))
+		r.Get("/dashboard", dashboard)
+	})
+
+	r.Route("/api", func(r chi.Router) {
+		r.Use(sukchi.RequireAPI())
+		r.Get("/me", me)
+	})
+
+	if err := http.ListenAndServe(":8080", r); err != nil {
+		fmt.Printf("Server could not start. err=%s\n", err.Error())
+	}
+}
@@ -0,0 +1,82 @@
+// Package sukchi provides middlewares for chi route groups, requiring the
+// requests to carry a valid key, as retrieved by the sukhttp middleware, which
+// chi takes as it is:
+//
+//	r := chi.NewRouter()
+//	r.Use(sukhttp.Middleware(ss))
+//
+//	r.Route("/api", func(r chi.Router) {
+//		r.Use(sukchi.RequireAPI())
+//		...
+//	})
+//
+//	r.Group(func(r chi.Router) {
+//		r.Use(sukchi.RequireHTML("/login"))
+//		...
+//	})
+//
+// API routes answer the requests without a session with a 401 Unauthorized
+// JSON body, while HTML routes redirect them to the login page.
+package sukchi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ed-henrique/suk/sukhttp"
+)
+
+// Require returns a middleware serving only the requests carrying a valid key,
+// as sukhttp.RequireSession does, so it can be scoped to a route group. It
+// panics if an option is invalid.
+func Require(opts ...sukhttp.RequireOption) func(http.Handler) http.Handler {
+	// The options are checked once, when the middleware is set up.
+	sukhttp.RequireSession(http.NotFoundHandler(), opts...)
+
+	return func(next http.Handler) http.Handler {
+		return sukhttp.RequireSession(next, opts...)
+	}
+}
+
+// RequireAPI is like Require, but the requests rejected are answered with a
+// JSON body, 401 Unauthorized when they have no valid key, and 500 Internal
+// Server Error when their session couldn't be retrieved. The options given
+// override these answers.
+func RequireAPI(opts ...sukhttp.RequireOption) func(http.Handler) http.Handler {
+	unauthorized := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSONError(w, http.StatusUnauthorized)
+	})
+
+	defaults := []sukhttp.RequireOption{
+		sukhttp.WithMissingKeyHandler(unauthorized),
+		sukhttp.WithExpiredKeyHandler(unauthorized),
+		sukhttp.WithUnknownKeyHandler(unauthorized),
+		sukhttp.WithErrorHandler(func(w http.ResponseWriter, _ *http.Request, _ error) {
+			writeJSONError(w, http.StatusInternalServerError)
+		}),
+	}
+
+	return Require(append(defaults, opts...)...)
+}
+
+// RequireHTML is like Require, but the requests without a valid key are
+// redirected to the login page. The options given override the redirects.
+func RequireHTML(loginURL string, opts ...sukhttp.RequireOption) func(http.Handler) http.Handler {
+	login := http.RedirectHandler(loginURL, http.StatusSeeOther)
+
+	defaults := []sukhttp.RequireOption{
+		sukhttp.WithMissingKeyHandler(login),
+		sukhttp.WithExpiredKeyHandler(login),
+		sukhttp.WithUnknownKeyHandler(login),
+	}
+
+	return Require(append(defaults, opts...)...)
+}
+
+// writeJSONError answers with the status code, telling its text in a JSON
+// body.
+func writeJSONError(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": http.StatusText(code)})
+}
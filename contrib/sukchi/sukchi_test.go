@@ -0,0 +1,122 @@
+package sukchi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/go-chi/chi/v5"
+)
+
+func newRouter(ss *suk.SessionStorage) http.Handler {
+	ok := func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	r := chi.NewRouter()
+	r.Use(sukhttp.Middleware(ss))
+	r.Get("/public", ok)
+
+	r.Group(func(r chi.Router) {
+		r.Use(RequireHTML("/login"))
+		r.Get("/dashboard", ok)
+	})
+
+	r.Route("/api", func(r chi.Router) {
+		r.Use(RequireAPI())
+		r.Get("/me", ok)
+	})
+
+	return r
+}
+
+func TestRequire(t *testing.T) {
+	ss, _ := suk.New()
+	router := newRouter(ss)
+
+	serve := func(path, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			r.AddCookie(&http.Cookie{Name: sukhttp.DefaultCookieName, Value: key})
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("Routes outside the groups are not protected", func(t *testing.T) {
+		if got := serve("/public", "").Code; got != http.StatusNoContent {
+			t.Errorf("got %d expected %d", got, http.StatusNoContent)
+		}
+	})
+
+	t.Run("API routes answer with JSON", func(t *testing.T) {
+		w := serve("/api/me", "")
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", w.Code, http.StatusUnauthorized)
+		}
+
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("got %q expected %q", got, "application/json")
+		}
+
+		if got := w.Body.String(); !strings.Contains(got, `"error":"Unauthorized"`) {
+			t.Errorf("got %q expected a JSON error", got)
+		}
+	})
+
+	t.Run("HTML routes redirect to the login page", func(t *testing.T) {
+		w := serve("/dashboard", "unknown")
+
+		if w.Code != http.StatusSeeOther {
+			t.Errorf("got %d expected %d", w.Code, http.StatusSeeOther)
+		}
+
+		if got := w.Header().Get("Location"); got != "/login" {
+			t.Errorf("got %q expected %q", got, "/login")
+		}
+	})
+
+	t.Run("Requests with a session are served", func(t *testing.T) {
+		key, _ := ss.Set("a")
+		w := serve("/api/me", key)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("got %d expected %d", w.Code, http.StatusNoContent)
+		}
+
+		// The key was consumed, so it must be the rotated one next time.
+		newKey := w.Result().Cookies()[0].Value
+		if got := serve("/dashboard", newKey).Code; got != http.StatusNoContent {
+			t.Errorf("got %d expected %d", got, http.StatusNoContent)
+		}
+	})
+
+	t.Run("Given options override the answers", func(t *testing.T) {
+		r := chi.NewRouter()
+		r.Use(sukhttp.Middleware(ss))
+		r.With(RequireAPI(sukhttp.WithMissingKeyHandler(http.NotFoundHandler()))).Get("/", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("got %d expected %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Invalid options panic", func(t *testing.T) {
+		defer func() {
+			if got := recover(); got == nil {
+				t.Errorf("got no panic expected one")
+			}
+		}()
+
+		Require(sukhttp.WithMissingKeyHandler(nil))
+	})
+}
@@ -0,0 +1,131 @@
+// Package sukdocker starts throwaway Redis and PostgreSQL containers with
+// dockertest, so storage backends can be validated against the real thing
+// instead of just the in-memory storage:
+//
+//	func TestRedis(t *testing.T) {
+//		client := sukdocker.Redis(t)
+//		ss, _ := suk.New(suk.WithRedis(client, nil))
+//		...
+//	}
+//
+// The containers are purged once the test and its subtests complete. Tests are
+// skipped when no Docker daemon is reachable, so the helpers are safe to use
+// in environments without Docker. Setting SUKDOCKER_REQUIRED makes them fail
+// instead, which keeps CI from silently skipping them.
+package sukdocker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/ory/dockertest/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+// Images started by the helpers. They may be changed before the containers are
+// started, to test against other versions.
+var (
+	RedisImage    = "redis:7-alpine"
+	PostgresImage = "postgres:16-alpine"
+)
+
+// MaxWait is how long the helpers wait for a container to accept connections.
+var MaxWait = time.Minute
+
+// pool connects to the Docker daemon, skipping the test if it's unreachable.
+func pool(t testing.TB) *dockertest.Pool {
+	t.Helper()
+
+	p, err := dockertest.NewPool("")
+	if err == nil {
+		err = p.Client.Ping()
+	}
+
+	if err != nil {
+		if os.Getenv("SUKDOCKER_REQUIRED") != "" {
+			t.Fatalf("sukdocker: %s", err.Error())
+		}
+		t.Skipf("sukdocker: docker is unavailable: %s", err.Error())
+	}
+
+	p.MaxWait = MaxWait
+	return p
+}
+
+// run starts a container from image, purging it once the test completes.
+func run(t testing.TB, p *dockertest.Pool, image string, env ...string) *dockertest.Resource {
+	t.Helper()
+
+	repository, tag := image, "latest"
+	for i := len(image) - 1; i >= 0 && image[i] != '/'; i-- {
+		if image[i] == ':' {
+			repository, tag = image[:i], image[i+1:]
+			break
+		}
+	}
+
+	resource, err := p.RunWithOptions(&dockertest.RunOptions{
+		Repository: repository,
+		Tag:        tag,
+		Env:        env,
+	})
+	if err != nil {
+		t.Fatalf("sukdocker: %s", err.Error())
+	}
+
+	t.Cleanup(func() {
+		if err := p.Purge(resource); err != nil {
+			t.Logf("sukdocker: %s", err.Error())
+		}
+	})
+
+	// Containers outliving a crashed test are removed by the daemon eventually.
+	resource.Expire(uint(10 * MaxWait / time.Second))
+	return resource
+}
+
+// Redis starts a Redis container and returns a client connected to it.
+func Redis(t testing.TB) *redis.Client {
+	t.Helper()
+
+	p := pool(t)
+	resource := run(t, p, RedisImage)
+
+	client := redis.NewClient(&redis.Options{Addr: resource.GetHostPort("6379/tcp")})
+	t.Cleanup(func() { client.Close() })
+
+	if err := p.Retry(func() error {
+		return client.Ping(context.Background()).Err()
+	}); err != nil {
+		t.Fatalf("sukdocker: %s", err.Error())
+	}
+
+	return client
+}
+
+// Postgres starts a PostgreSQL container and returns a database connected to
+// it through the lib/pq driver.
+func Postgres(t testing.TB) *sql.DB {
+	t.Helper()
+
+	p := pool(t)
+	resource := run(t, p, PostgresImage, "POSTGRES_PASSWORD=suk", "POSTGRES_DB=suk")
+
+	dsn := fmt.Sprintf("postgres://postgres:suk@%s/suk?sslmode=disable", resource.GetHostPort("5432/tcp"))
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sukdocker: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := p.Retry(db.Ping); err != nil {
+		t.Fatalf("sukdocker: %s", err.Error())
+	}
+
+	return db
+}
@@ -0,0 +1,53 @@
+package sukdocker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sqlstore"
+	"github.com/ed-henrique/suk/suktest"
+)
+
+func TestRedis(t *testing.T) {
+	client := Redis(t)
+
+	suktest.Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+		if err := client.FlushDB(context.Background()).Err(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		ss, err := suk.New(append(opts, suk.WithRedis(client, nil))...)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		t.Cleanup(func() { ss.Close(context.Background()) })
+
+		return ss
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	db := Postgres(t)
+
+	suktest.Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+		ctx := context.Background()
+
+		store, err := sqlstore.New(ctx, db, sqlstore.Postgres, "")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := db.ExecContext(ctx, "DELETE FROM "+sqlstore.DefaultTable); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		ss, err := suk.New(append(opts, suk.WithStorage(store))...)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		t.Cleanup(func() { ss.Close(context.Background()) })
+
+		return ss
+	})
+}
@@ -0,0 +1,252 @@
+// Package sukdynamodb provides a DynamoDB backend for suk, for serverless
+// deployments on AWS:
+//
+//	cfg, _ := config.LoadDefaultConfig(ctx)
+//	ss, _ := suk.New(sukdynamodb.WithDynamoDB(dynamodb.NewFromConfig(cfg), "sessions"))
+//
+// The table must have a string partition key named "key", and should have TTL
+// enabled on the "ttl" attribute, so DynamoDB deletes the expired sessions by
+// itself and ClearExpired has nothing to do, as with Redis. Keys are issued
+// with conditional puts, so concurrent instances never issue the same key
+// twice.
+//
+// The table is only ever read by key, so the sessions are not indexed by
+// owner. suk.WithMaxSessionsPerOwner is refused with suk.ErrOwnersUnsupported,
+// and SessionsForOwner and RevokeAllForOwner fail with it.
+//
+// Sessions are encoded with encoding/gob, so custom types must be registered
+// with gob.Register.
+package sukdynamodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ed-henrique/suk"
+)
+
+// Client is the subset of the DynamoDB API used to store the sessions,
+// implemented by *dynamodb.Client.
+type Client interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// The attributes of the items holding the sessions. The key is the partition
+// key of the table, and ttl is meant to be its TTL attribute, in Unix seconds,
+// while expires_at keeps the exact expiration, in Unix nanoseconds. The items
+// holding the watermarks keep them in value instead, and never expire, while
+// the ones indexing the sessions by ID keep the key of the session in session,
+// and expire along with it.
+const (
+	keyAttribute       = "key"
+	dataAttribute      = "data"
+	expiresAtAttribute = "expires_at"
+	ttlAttribute       = "ttl"
+	valueAttribute     = "value"
+	sessionAttribute   = "session"
+)
+
+// Storage stores the sessions in a DynamoDB table.
+type Storage struct {
+	client Client
+	table  string
+}
+
+// New creates a storage keeping the sessions in the given table.
+func New(client Client, table string) *Storage {
+	return &Storage{client: client, table: table}
+}
+
+// WithDynamoDB stores the sessions in the given table. It is a shorthand for
+// suk.WithStorage(sukdynamodb.New(client, table)).
+func WithDynamoDB(client Client, table string) suk.Option {
+	return suk.WithStorage(New(client, table))
+}
+
+func itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		keyAttribute: &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func number(n int64) *types.AttributeValueMemberN {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+func (s *Storage) Set(ctx context.Context, key string, e suk.Entry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return err
+	}
+
+	item := itemKey(key)
+	item[dataAttribute] = &types.AttributeValueMemberB{Value: buf.Bytes()}
+	item[expiresAtAttribute] = number(e.ExpiresAt.UnixNano())
+	item[ttlAttribute] = number(e.ExpiresAt.Add(time.Second - 1).Unix())
+
+	// DynamoDB may take a while to delete expired items, which must not hold
+	// on to their keys meanwhile.
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #expires_at <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#key":        keyAttribute,
+			"#expires_at": expiresAtAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": number(time.Now().UnixNano()),
+		},
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return suk.ErrKeyExists
+	} else if err != nil || e.ID == "" {
+		return err
+	}
+
+	index := itemKey(familyKey(e.ID))
+	index[sessionAttribute] = &types.AttributeValueMemberS{Value: key}
+	index[ttlAttribute] = item[ttlAttribute]
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      index,
+	})
+	return err
+}
+
+// familyKey returns the key of the item indexing the session with the given
+// ID.
+func familyKey(id string) string {
+	return "family:" + id
+}
+
+// FindSession reads the item indexing the session ID, written after the
+// session itself.
+func (s *Storage) FindSession(ctx context.Context, id string) (string, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            itemKey(familyKey(id)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(out.Item) == 0 {
+		return "", suk.ErrNoKeyFound
+	}
+
+	key, ok := out.Item[sessionAttribute].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", suk.ErrCorruptedEntry
+	}
+
+	return key.Value, nil
+}
+
+func (s *Storage) Get(ctx context.Context, key string) (suk.Entry, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            itemKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return suk.Entry{}, err
+	}
+
+	return decode(out.Item)
+}
+
+func (s *Storage) Remove(ctx context.Context, key string) (suk.Entry, error) {
+	out, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:    aws.String(s.table),
+		Key:          itemKey(key),
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return suk.Entry{}, err
+	}
+
+	return decode(out.Attributes)
+}
+
+// ClearExpired does nothing, as DynamoDB deletes the expired sessions through
+// the TTL attribute.
+func (s *Storage) ClearExpired(_ context.Context) error {
+	return nil
+}
+
+// RaiseWatermark keeps the watermarks in items of their own, raised with
+// conditional puts so concurrent raises are never lost.
+func (s *Storage) RaiseWatermark(ctx context.Context, name string, value int64) (int64, error) {
+	key := "watermark:" + name
+
+	item := itemKey(key)
+	item[valueAttribute] = number(value)
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #value < :value"),
+		ExpressionAttributeNames: map[string]string{
+			"#key":   keyAttribute,
+			"#value": valueAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": number(value),
+		},
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if !errors.As(err, &conditionFailed) {
+		return value, err
+	}
+
+	// The watermark is already as high, so it is read back.
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            itemKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	stored, ok := out.Item[valueAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, suk.ErrCorruptedEntry
+	}
+
+	return strconv.ParseInt(stored.Value, 10, 64)
+}
+
+// decode decodes the entry held by the item.
+func decode(item map[string]types.AttributeValue) (suk.Entry, error) {
+	if len(item) == 0 {
+		return suk.Entry{}, suk.ErrNoKeyFound
+	}
+
+	raw, ok := item[dataAttribute].(*types.AttributeValueMemberB)
+	if !ok {
+		return suk.Entry{}, suk.ErrCorruptedEntry
+	}
+
+	var e suk.Entry
+	if err := gob.NewDecoder(bytes.NewReader(raw.Value)).Decode(&e); err != nil {
+		return suk.Entry{}, err
+	}
+
+	return e, nil
+}
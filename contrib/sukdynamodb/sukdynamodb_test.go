@@ -0,0 +1,206 @@
+package sukdynamodb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/suktest"
+)
+
+// fakeDynamoDB is an in-memory DynamoDB table, supporting just the conditions
+// used by the storage, told apart by the values they are given.
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func fakeKey(key map[string]types.AttributeValue) string {
+	return key[keyAttribute].(*types.AttributeValueMemberS).Value
+}
+
+func fakeNumber(v types.AttributeValue) int64 {
+	n, _ := strconv.ParseInt(v.(*types.AttributeValueMemberN).Value, 10, 64)
+	return n
+}
+
+func (f *fakeDynamoDB) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := fakeKey(params.Item)
+	if old, ok := f.items[key]; ok && params.ConditionExpression != nil {
+		if value, ok := params.ExpressionAttributeValues[":value"]; ok {
+			if fakeNumber(old[valueAttribute]) >= fakeNumber(value) {
+				return nil, &types.ConditionalCheckFailedException{}
+			}
+		} else if fakeNumber(old[expiresAtAttribute]) > fakeNumber(params.ExpressionAttributeValues[":now"]) {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	}
+
+	f.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &dynamodb.GetItemOutput{Item: f.items[fakeKey(params.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := fakeKey(params.Key)
+	old := f.items[key]
+	delete(f.items, key)
+	return &dynamodb.DeleteItemOutput{Attributes: old}, nil
+}
+
+func TestStorage(t *testing.T) {
+	t.Run("Sessions are stored in DynamoDB", func(t *testing.T) {
+		ss, _ := suk.New(WithDynamoDB(newFakeDynamoDB(), "sessions"))
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		session, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if err := ss.Remove(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Items carry the TTL attribute", func(t *testing.T) {
+		fake := newFakeDynamoDB()
+		s := New(fake, "sessions")
+		expiresAt := time.Now().Add(time.Hour)
+
+		s.Set(context.Background(), "key", suk.Entry{Data: "session", ExpiresAt: expiresAt})
+
+		ttl := fakeNumber(fake.items["key"][ttlAttribute])
+		if ttl < expiresAt.Unix() || ttl > expiresAt.Unix()+1 {
+			t.Errorf("got %d expected %d", ttl, expiresAt.Unix())
+		}
+	})
+
+	t.Run("Colliding keys are reported unless expired", func(t *testing.T) {
+		s := New(newFakeDynamoDB(), "sessions")
+		ctx := context.Background()
+
+		s.Set(ctx, "live", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)})
+		s.Set(ctx, "expired", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(-time.Hour)})
+
+		if err := s.Set(ctx, "live", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}); !errors.Is(err, suk.ErrKeyExists) {
+			t.Errorf("got %v expected %v", err, suk.ErrKeyExists)
+		}
+
+		if err := s.Set(ctx, "expired", suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Invalidations are kept in DynamoDB", func(t *testing.T) {
+		fake := newFakeDynamoDB()
+		ss, _ := suk.New(suk.WithStorage(New(fake, "sessions")))
+
+		key, _ := ss.Set(42)
+		if err := ss.InvalidateAll(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		suk.Destroy(ss)
+
+		ss, _ = suk.New(suk.WithStorage(New(fake, "sessions")))
+		defer suk.Destroy(ss)
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions are not indexed by owner", func(t *testing.T) {
+		_, err := suk.New(suk.WithStorage(New(newFakeDynamoDB(), "sessions")), suk.WithMaxSessionsPerOwner(1, suk.RejectNew))
+		if !errors.Is(err, suk.ErrOwnersUnsupported) {
+			t.Errorf("got %v expected %v", err, suk.ErrOwnersUnsupported)
+		}
+	})
+
+	t.Run("Sessions are revoked whichever instance rotated them", func(t *testing.T) {
+		fake := newFakeDynamoDB()
+		a, _ := suk.New(suk.WithStorage(New(fake, "sessions")))
+		b, _ := suk.New(suk.WithStorage(New(fake, "sessions")))
+
+		key, _ := a.Set(42)
+		id, _ := a.SessionID(key)
+		_, key, _ = b.Get(key)
+
+		if err := a.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Watermarks only move forward", func(t *testing.T) {
+		s := New(newFakeDynamoDB(), "sessions")
+		ctx := context.Background()
+
+		s.RaiseWatermark(ctx, "epoch", 5)
+
+		if got, err := s.RaiseWatermark(ctx, "epoch", 3); err != nil || got != 5 {
+			t.Errorf("got %d and error %v expected %d", got, err, 5)
+		}
+
+		if got, err := s.RaiseWatermark(ctx, "epoch", 7); err != nil || got != 7 {
+			t.Errorf("got %d and error %v expected %d", got, err, 7)
+		}
+	})
+
+	t.Run("Sessions can't be listed", func(t *testing.T) {
+		ss, _ := suk.New(suk.WithStorage(New(newFakeDynamoDB(), "sessions")))
+
+		if _, err := ss.Count(); !errors.Is(err, suk.ErrCountUnsupported) {
+			t.Errorf("got %v expected %v", err, suk.ErrCountUnsupported)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	suktest.Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+		ss, err := suk.New(append(opts, suk.WithStorage(New(newFakeDynamoDB(), "sessions")))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ss.Close(context.Background()) })
+
+		return ss
+	})
+}
@@ -0,0 +1,42 @@
+// Package sukecho provides the sukhttp middleware for Echo, retrieving the
+// session of each request from the key it carries and handing the key it was
+// rotated to back to the client, just as with net/http:
+//
+//	e := echo.New()
+//	e.Use(sukecho.Middleware(ss))
+//
+//	e.GET("/", func(c echo.Context) error {
+//		s, ok := sukecho.Session(c)
+//		...
+//	})
+package sukecho
+
+import (
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware returns the sukhttp middleware as an Echo middleware, configured
+// with the sukhttp options. It panics if an option is invalid.
+func Middleware(ss *suk.SessionStorage, opts ...sukhttp.Option) echo.MiddlewareFunc {
+	return echo.WrapMiddleware(sukhttp.Middleware(ss, opts...))
+}
+
+// Session returns the session of the request, as retrieved by the middleware,
+// along with the data staged with SetData, if any.
+func Session(c echo.Context) (suk.Session, bool) {
+	return sukhttp.FromContext(c.Request().Context())
+}
+
+// SetData stages v as the new data of the session of the request, which the
+// middleware stores once the request is served, as with sukhttp.SetData.
+func SetData(c echo.Context, v any) error {
+	return sukhttp.SetData(c.Request().Context(), v)
+}
+
+// Issue makes the key of the session be handed back to the client along with
+// the response, as with sukhttp.Issue.
+func Issue(c echo.Context, s suk.Session) error {
+	return sukhttp.Issue(c.Request(), s)
+}
@@ -0,0 +1,110 @@
+package sukecho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/labstack/echo/v4"
+)
+
+// serve serves a request carrying the key, if any, through the middleware and
+// h, returning the response.
+func serve(ss *suk.SessionStorage, key string, h echo.HandlerFunc) *http.Response {
+	e := echo.New()
+	e.Use(Middleware(ss))
+	e.GET("/", h)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		r.AddCookie(&http.Cookie{Name: sukhttp.DefaultCookieName, Value: key})
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Sessions are retrieved and their keys rotated", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		var s suk.Session
+		resp := serve(ss, key, func(c echo.Context) error {
+			var ok bool
+			if s, ok = Session(c); !ok {
+				t.Fatalf("got %v expected %v", ok, true)
+			}
+
+			return c.String(http.StatusOK, s.Data.(string))
+		})
+
+		if s.Data != "a" || s.Key == key {
+			t.Errorf("got %v expected %q under a rotated key", s, "a")
+		}
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Value != s.Key {
+			t.Errorf("got %v expected the key %q", cookies, s.Key)
+		}
+	})
+
+	t.Run("Keys are handed back along with errors", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		resp := serve(ss, key, func(c echo.Context) error {
+			return echo.ErrForbidden
+		})
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusForbidden)
+		}
+
+		if len(resp.Cookies()) != 1 {
+			t.Errorf("got %d cookies expected %d", len(resp.Cookies()), 1)
+		}
+	})
+
+	t.Run("Staged data is stored", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set(1)
+
+		var newKey string
+		serve(ss, key, func(c echo.Context) error {
+			s, _ := Session(c)
+			newKey = s.Key
+			return SetData(c, 2)
+		})
+
+		if got, _ := ss.Peek(newKey); got != 2 {
+			t.Errorf("got %v expected %v", got, 2)
+		}
+	})
+
+	t.Run("Issued keys are handed back", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		var issued suk.Session
+		resp := serve(ss, "", func(c echo.Context) error {
+			if _, ok := Session(c); ok {
+				t.Errorf("got %v expected %v", ok, false)
+			}
+
+			issued, _ = ss.SetSession("a")
+			if err := Issue(c, issued); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			return c.NoContent(http.StatusNoContent)
+		})
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Value != issued.Key {
+			t.Errorf("got %v expected the key %q", cookies, issued.Key)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+// Package suketcd provides an etcd backend for suk, so sessions are shared
+// across a cluster of app instances. Each session is stored under its own
+// lease, matching the key duration, so etcd expires the sessions by itself:
+//
+//	client, _ := clientv3.New(clientv3.Config{Endpoints: []string{"localhost:2379"}})
+//	ss, _ := suk.New(suk.WithStorage(suketcd.New(client, "sessions/")))
+//
+// Sessions are encoded with encoding/gob, so custom types must be registered
+// with gob.Register.
+package suketcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Client is the subset of the etcd API used to store the sessions, implemented
+// by *clientv3.Client.
+type Client interface {
+	clientv3.KV
+	clientv3.Lease
+}
+
+// Storage stores the sessions in etcd, each under a lease expiring along with
+// it.
+type Storage struct {
+	client Client
+	prefix string
+}
+
+// New creates a storage keeping the sessions in etcd, under the given prefix.
+func New(client Client, prefix string) *Storage {
+	return &Storage{client: client, prefix: prefix}
+}
+
+func (s *Storage) key(key string) string {
+	return s.prefix + key
+}
+
+// leaseTTL returns the TTL, in seconds, of the lease for a session expiring at
+// expiresAt. Leases have a precision of seconds, so it is rounded up, while the
+// exact expiration is kept along with the session.
+func leaseTTL(expiresAt time.Time) int64 {
+	return max(1, int64((time.Until(expiresAt)+time.Second-1)/time.Second))
+}
+
+func (s *Storage) Set(ctx context.Context, key string, e suk.Entry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return err
+	}
+
+	lease, err := s.client.Grant(ctx, leaseTTL(e.ExpiresAt))
+	if err != nil {
+		return err
+	}
+
+	// The session is indexed by ID and by owner under the same lease, so the
+	// index entries go away along with it.
+	k := s.key(key)
+	ops := []clientv3.Op{clientv3.OpPut(k, buf.String(), clientv3.WithLease(lease.ID))}
+	if e.ID != "" {
+		ops = append(ops, clientv3.OpPut(s.key(familyKey(e.ID)), key, clientv3.WithLease(lease.ID)))
+		if e.Owner != "" {
+			ops = append(ops, clientv3.OpPut(s.key(ownerKey(e.Owner, e.ID)), "", clientv3.WithLease(lease.ID)))
+		}
+	}
+
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(k), "=", 0)).
+		Then(ops...).
+		Commit()
+	if err == nil && !resp.Succeeded {
+		err = suk.ErrKeyExists
+	}
+
+	if err != nil {
+		s.client.Revoke(ctx, lease.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) Get(ctx context.Context, key string) (suk.Entry, error) {
+	resp, err := s.client.Get(ctx, s.key(key))
+	if err != nil {
+		return suk.Entry{}, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return suk.Entry{}, suk.ErrNoKeyFound
+	}
+
+	return decode(resp.Kvs[0].Value)
+}
+
+func (s *Storage) Remove(ctx context.Context, key string) (suk.Entry, error) {
+	resp, err := s.client.Delete(ctx, s.key(key), clientv3.WithPrevKV())
+	if err != nil {
+		return suk.Entry{}, err
+	}
+
+	if len(resp.PrevKvs) == 0 {
+		return suk.Entry{}, suk.ErrNoKeyFound
+	}
+
+	// The lease would only go away once expired otherwise.
+	s.client.Revoke(ctx, clientv3.LeaseID(resp.PrevKvs[0].Lease))
+
+	return decode(resp.PrevKvs[0].Value)
+}
+
+// familyKey returns the key, relative to the prefix, indexing the session with
+// the given ID.
+func familyKey(id string) string {
+	return "family:" + id
+}
+
+func (s *Storage) FindSession(ctx context.Context, id string) (string, error) {
+	resp, err := s.client.Get(ctx, s.key(familyKey(id)))
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return "", suk.ErrNoKeyFound
+	}
+
+	return string(resp.Kvs[0].Value), nil
+}
+
+// ownerKey returns the key, relative to the prefix, indexing the session with
+// the given ID under its owner.
+func ownerKey(owner, id string) string {
+	return "owner:" + owner + "\x00" + id
+}
+
+// FindOwnedSessions lists the keys indexing the sessions of owner.
+func (s *Storage) FindOwnedSessions(ctx context.Context, owner string) ([]string, error) {
+	prefix := s.key(ownerKey(owner, ""))
+	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		ids[i] = string(kv.Key[len(prefix):])
+	}
+
+	return ids, nil
+}
+
+// ClearExpired does nothing, as etcd deletes the sessions once their leases
+// expire.
+func (s *Storage) ClearExpired(_ context.Context) error {
+	return nil
+}
+
+func decode(value []byte) (suk.Entry, error) {
+	var e suk.Entry
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&e); err != nil {
+		return suk.Entry{}, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,234 @@
+package suketcd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// fakeEtcd is an in-memory etcd, supporting just the calls made by the
+// storage. Puts are attached to the lease granted last.
+type fakeEtcd struct {
+	clientv3.KV
+	clientv3.Lease
+
+	mu        sync.Mutex
+	kvs       map[string]*mvccpb.KeyValue
+	leases    map[clientv3.LeaseID]int64
+	lastLease clientv3.LeaseID
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{
+		kvs:    make(map[string]*mvccpb.KeyValue),
+		leases: make(map[clientv3.LeaseID]int64),
+	}
+}
+
+func (f *fakeEtcd) Grant(_ context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastLease++
+	f.leases[f.lastLease] = ttl
+	return &clientv3.LeaseGrantResponse{ID: f.lastLease, TTL: ttl}, nil
+}
+
+func (f *fakeEtcd) Revoke(_ context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.leases, id)
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+func (f *fakeEtcd) Get(_ context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resp := &clientv3.GetResponse{}
+	if end := clientv3.OpGet(key, opts...).RangeBytes(); len(end) > 0 {
+		for k, kv := range f.kvs {
+			if k >= key && k < string(end) {
+				resp.Kvs = append(resp.Kvs, kv)
+			}
+		}
+	} else if kv, ok := f.kvs[key]; ok {
+		resp.Kvs = append(resp.Kvs, kv)
+	}
+
+	return resp, nil
+}
+
+func (f *fakeEtcd) Delete(_ context.Context, key string, _ ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resp := &clientv3.DeleteResponse{}
+	if kv, ok := f.kvs[key]; ok {
+		resp.PrevKvs = append(resp.PrevKvs, kv)
+		delete(f.kvs, key)
+	}
+
+	return resp, nil
+}
+
+func (f *fakeEtcd) Txn(_ context.Context) clientv3.Txn {
+	return &fakeTxn{etcd: f}
+}
+
+// fakeTxn only supports comparing whether keys were never created.
+type fakeTxn struct {
+	etcd *fakeEtcd
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+func (t *fakeTxn) If(cmps ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = cmps
+	return t
+}
+
+func (t *fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = ops
+	return t
+}
+
+func (t *fakeTxn) Else(_ ...clientv3.Op) clientv3.Txn {
+	return t
+}
+
+func (t *fakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	f := t.etcd
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, cmp := range t.cmps {
+		if _, ok := f.kvs[string(cmp.KeyBytes())]; ok {
+			return &clientv3.TxnResponse{Succeeded: false}, nil
+		}
+	}
+
+	for _, op := range t.ops {
+		f.kvs[string(op.KeyBytes())] = &mvccpb.KeyValue{
+			Key:   op.KeyBytes(),
+			Value: op.ValueBytes(),
+			Lease: int64(f.lastLease),
+		}
+	}
+
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
+func TestStorage(t *testing.T) {
+	t.Run("Sessions are stored in etcd", func(t *testing.T) {
+		ss, _ := suk.New(suk.WithStorage(New(newFakeEtcd(), "sessions/")))
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		session, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if err := ss.Remove(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Sessions are stored under leases matching the key duration", func(t *testing.T) {
+		etcd := newFakeEtcd()
+		ss, _ := suk.New(suk.WithStorage(New(etcd, "sessions/")), suk.WithKeyDuration(time.Minute))
+
+		key, _ := ss.Set("session")
+
+		kv, ok := etcd.kvs["sessions/"+key]
+		if !ok {
+			t.Fatalf("got %v expected %v", ok, true)
+		}
+
+		if ttl := etcd.leases[clientv3.LeaseID(kv.Lease)]; ttl != 60 {
+			t.Errorf("got %d expected %d", ttl, 60)
+		}
+
+		ss.Remove(key)
+
+		if len(etcd.leases) != 0 {
+			t.Errorf("got %d expected %d", len(etcd.leases), 0)
+		}
+	})
+
+	t.Run("Sessions of an owner are found whichever instance issued them", func(t *testing.T) {
+		etcd := newFakeEtcd()
+		a, _ := suk.New(suk.WithStorage(New(etcd, "sessions/")))
+		b, _ := suk.New(suk.WithStorage(New(etcd, "sessions/")))
+
+		key, _ := a.Set(42, suk.WithOwner("user-42"))
+		_, key, _ = b.Get(key)
+		b.Set(43, suk.WithOwner("user-42"))
+
+		if got, err := a.SessionsForOwner("user-42"); err != nil || len(got) != 2 {
+			t.Fatalf("got %v and %v expected 2 sessions", got, err)
+		}
+
+		if n, err := a.RevokeAllForOwner(context.Background(), "user-42", nil); err != nil || n != 2 {
+			t.Errorf("got %d and %v expected %d", n, err, 2)
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions are revoked whichever instance rotated them", func(t *testing.T) {
+		etcd := newFakeEtcd()
+		a, _ := suk.New(suk.WithStorage(New(etcd, "sessions/")))
+		b, _ := suk.New(suk.WithStorage(New(etcd, "sessions/")))
+
+		key, _ := a.Set(42)
+		id, _ := a.SessionID(key)
+		_, key, _ = b.Get(key)
+
+		if err := a.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Colliding keys are reported and their leases revoked", func(t *testing.T) {
+		etcd := newFakeEtcd()
+		s := New(etcd, "")
+		ctx := context.Background()
+		e := suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}
+
+		s.Set(ctx, "key", e)
+
+		if err := s.Set(ctx, "key", e); !errors.Is(err, suk.ErrKeyExists) {
+			t.Errorf("got %v expected %v", err, suk.ErrKeyExists)
+		}
+
+		if len(etcd.leases) != 1 {
+			t.Errorf("got %d expected %d", len(etcd.leases), 1)
+		}
+	})
+}
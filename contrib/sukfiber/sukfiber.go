@@ -0,0 +1,271 @@
+// Package sukfiber provides a Fiber middleware for suk, retrieving the session
+// of each request from the key it carries and handing the key it was rotated
+// to back to the client, as sukhttp does for net/http, which Fiber doesn't
+// use:
+//
+//	app := fiber.New()
+//	app.Use(sukfiber.Middleware(ss))
+//
+//	app.Get("/me", func(c *fiber.Ctx) error {
+//		s, ok := sukfiber.Session(c)
+//		if !ok {
+//			return fiber.ErrUnauthorized
+//		}
+//		...
+//	})
+//
+// Keys are read from a cookie by default, written from the same template as
+// sukhttp's, and from a header with WithHeader.
+package sukfiber
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Option customizes the middleware.
+type Option interface {
+	apply(*config) error
+}
+
+type option func(*config) error
+
+func (o option) apply(c *config) error {
+	return o(c)
+}
+
+type config struct {
+	cookies      sukhttp.CookieManager
+	header       string
+	onFlushError func(c *fiber.Ctx, err error)
+}
+
+// WithCookieName sets the cookie carrying the keys, sukhttp.DefaultCookieName
+// otherwise.
+func WithCookieName(name string) Option {
+	return option(func(c *config) error {
+		if name == "" {
+			return sukhttp.ErrEmptyCookieName
+		}
+
+		c.cookies.Name = name
+		return nil
+	})
+}
+
+// WithCookieManager sets the template of the cookies carrying the keys, as
+// for sukhttp, so both write the same cookies.
+func WithCookieManager(m *sukhttp.CookieManager) Option {
+	return option(func(c *config) error {
+		if m == nil {
+			return sukhttp.ErrNilCookieManager
+		}
+
+		c.cookies = *m
+		return nil
+	})
+}
+
+// WithHeader makes the keys be read from the given header before the cookie,
+// handing the rotated keys back in the same header of the response.
+func WithHeader(header string) Option {
+	return option(func(c *config) error {
+		if header == "" {
+			return sukhttp.ErrEmptyHeader
+		}
+
+		c.header = header
+		return nil
+	})
+}
+
+// WithFlushErrorHandler sets the function told about the failures to store
+// the data staged with SetData, which are logged otherwise.
+func WithFlushErrorHandler(fn func(c *fiber.Ctx, err error)) Option {
+	return option(func(c *config) error {
+		if fn == nil {
+			return sukhttp.ErrNilFlushHandler
+		}
+
+		c.onFlushError = fn
+		return nil
+	})
+}
+
+type stateKey struct{}
+
+// state is the session of a request, as retrieved by the middleware, along
+// with why it couldn't be, if it couldn't.
+type state struct {
+	config  *config
+	session suk.Session
+	err     error
+
+	// inHeader tells whether the key is handed back in the header, rather
+	// than in the cookie.
+	inHeader bool
+
+	// staged tells whether the data of the session was changed with SetData,
+	// so it must be stored once the request is served.
+	staged bool
+}
+
+// Middleware returns a middleware retrieving the session of each request from
+// the key it carries, and handing the key it was rotated to back to the
+// client, for the handlers to find the session with Session. Requests without
+// a valid key are served without a session. It panics if an option is
+// invalid, as middlewares are set up once, when the program starts.
+func Middleware(ss *suk.SessionStorage, opts ...Option) fiber.Handler {
+	c := config{
+		cookies: *sukhttp.NewCookieManager(ss),
+		onFlushError: func(c *fiber.Ctx, err error) {
+			slog.ErrorContext(c.UserContext(), "sukfiber: could not store the staged session data", "error", err)
+		},
+	}
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.apply(&c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		panic(err)
+	}
+
+	return func(fc *fiber.Ctx) error {
+		st := &state{config: &c, err: sukhttp.ErrNoKey, inHeader: c.header != ""}
+
+		key := ""
+		if c.header != "" {
+			key = fc.Get(c.header)
+		}
+		if key == "" {
+			key = fc.Cookies(c.cookies.Name)
+			st.inHeader = key == "" && c.header != ""
+		}
+
+		if key != "" {
+			st.session, st.err = ss.GetSessionCtx(fc.UserContext(), key)
+			if st.err == nil {
+				st.writeKey(fc)
+			}
+		}
+
+		fc.Locals(stateKey{}, st)
+		err := fc.Next()
+
+		if st.staged {
+			st.staged = false
+			data := st.session.Data
+			ferr := ss.UpdateCtx(fc.UserContext(), st.session.Key, func(any) any { return data })
+			if ferr != nil {
+				c.onFlushError(fc, ferr)
+			}
+		}
+
+		return err
+	}
+}
+
+// writeKey hands the key of the session back to the client, replacing any
+// key written before, as the response is only sent once the handlers return.
+func (st *state) writeKey(fc *fiber.Ctx) {
+	if st.inHeader {
+		fc.Set(st.config.header, st.session.Key)
+		return
+	}
+
+	m := &st.config.cookies
+	maxAge := m.MaxAge
+	if maxAge == 0 && !st.session.ExpiresAt.IsZero() {
+		maxAge = time.Until(st.session.ExpiresAt)
+	} else if maxAge == 0 && m.Sessions != nil {
+		maxAge = m.Sessions.KeyDuration()
+	}
+
+	fc.Cookie(&fiber.Cookie{
+		Name:     m.Name,
+		Value:    st.session.Key,
+		Path:     m.Path,
+		Domain:   m.Domain,
+		MaxAge:   max(int(maxAge.Seconds()), 1),
+		Expires:  time.Now().Add(maxAge),
+		Secure:   m.Secure,
+		HTTPOnly: m.HttpOnly,
+		SameSite: sameSite(m.SameSite),
+	})
+}
+
+// sameSite returns the Fiber name of the SameSite mode.
+func sameSite(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return fiber.CookieSameSiteLaxMode
+	case http.SameSiteStrictMode:
+		return fiber.CookieSameSiteStrictMode
+	case http.SameSiteNoneMode:
+		return fiber.CookieSameSiteNoneMode
+	default:
+		return fiber.CookieSameSiteDisabled
+	}
+}
+
+func stateFrom(c *fiber.Ctx) (*state, bool) {
+	st, ok := c.Locals(stateKey{}).(*state)
+	return st, ok
+}
+
+// Session returns the session of the request, as retrieved by the middleware,
+// along with the data staged with SetData, if any.
+func Session(c *fiber.Ctx) (suk.Session, bool) {
+	st, ok := stateFrom(c)
+	if !ok || st.err != nil {
+		return suk.Session{}, false
+	}
+
+	return st.session, true
+}
+
+// SetData stages v as the new data of the session of the request, which the
+// middleware stores once the request is served, keeping the key.
+func SetData(c *fiber.Ctx, v any) error {
+	if v == nil {
+		return suk.ErrNilSession
+	}
+
+	st, ok := stateFrom(c)
+	if !ok {
+		return sukhttp.ErrNoMiddleware
+	}
+
+	if st.err != nil {
+		return sukhttp.ErrNoSession
+	}
+
+	st.session.Data = v
+	st.staged = true
+	return nil
+}
+
+// Issue makes the key of the session be handed back to the client along with
+// the response, in place of the key the request carried, such as after logging
+// in. The session is also the one of the request from then on, dropping the
+// data staged with SetData.
+func Issue(c *fiber.Ctx, s suk.Session) error {
+	st, ok := stateFrom(c)
+	if !ok {
+		return sukhttp.ErrNoMiddleware
+	}
+
+	st.session, st.err, st.staged = s, nil, false
+	st.writeKey(c)
+	return nil
+}
@@ -0,0 +1,174 @@
+package sukfiber
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/gofiber/fiber/v2"
+)
+
+// serve serves the request through the middleware and h, returning the
+// response.
+func serve(t *testing.T, ss *suk.SessionStorage, r *http.Request, h fiber.Handler, opts ...Option) *http.Response {
+	t.Helper()
+
+	app := fiber.New()
+	app.Use(Middleware(ss, opts...))
+	app.Get("/", h)
+
+	resp, err := app.Test(r)
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+
+	return resp
+}
+
+func withKey(key string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: sukhttp.DefaultCookieName, Value: key})
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Sessions are retrieved and their keys rotated", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		var s suk.Session
+		resp := serve(t, ss, withKey(key), func(c *fiber.Ctx) error {
+			var ok bool
+			if s, ok = Session(c); !ok {
+				t.Fatalf("got %v expected %v", ok, true)
+			}
+
+			return c.SendString(s.Data.(string))
+		})
+
+		if s.Data != "a" || s.Key == key {
+			t.Errorf("got %v expected %q under a rotated key", s, "a")
+		}
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Value != s.Key || !cookies[0].HttpOnly || !cookies[0].Secure {
+			t.Errorf("got %v expected a secure cookie with the key %q", cookies, s.Key)
+		}
+	})
+
+	t.Run("Keys are handed back along with errors", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		resp := serve(t, ss, withKey(key), func(c *fiber.Ctx) error {
+			return fiber.ErrForbidden
+		})
+
+		if resp.StatusCode != http.StatusForbidden || len(resp.Cookies()) != 1 {
+			t.Errorf("got %d and %v expected %d with the key", resp.StatusCode, resp.Cookies(), http.StatusForbidden)
+		}
+	})
+
+	t.Run("Requests without a valid key have no session", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		for _, r := range []*http.Request{httptest.NewRequest(http.MethodGet, "/", nil), withKey("unknown")} {
+			resp := serve(t, ss, r, func(c *fiber.Ctx) error {
+				if _, ok := Session(c); ok {
+					t.Errorf("got %v expected %v", ok, false)
+				}
+
+				if err := SetData(c, 2); err != sukhttp.ErrNoSession {
+					t.Errorf("got %v expected %v", err, sukhttp.ErrNoSession)
+				}
+
+				return nil
+			})
+
+			if len(resp.Cookies()) != 0 {
+				t.Errorf("got %v expected no cookie", resp.Cookies())
+			}
+		}
+	})
+
+	t.Run("Keys are read from and written to the header", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Session", key)
+
+		var s suk.Session
+		resp := serve(t, ss, r, func(c *fiber.Ctx) error {
+			s, _ = Session(c)
+			return nil
+		}, WithHeader("X-Session"))
+
+		if got := resp.Header.Get("X-Session"); got == "" || got != s.Key {
+			t.Errorf("got %q expected %q", got, s.Key)
+		}
+	})
+
+	t.Run("Staged data is stored", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set(1)
+
+		var newKey string
+		serve(t, ss, withKey(key), func(c *fiber.Ctx) error {
+			s, _ := Session(c)
+			newKey = s.Key
+			return SetData(c, 2)
+		})
+
+		if got, _ := ss.Peek(newKey); got != 2 {
+			t.Errorf("got %v expected %v", got, 2)
+		}
+	})
+
+	t.Run("Failures to store the staged data are told", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set(1)
+
+		var got error
+		serve(t, ss, withKey(key), func(c *fiber.Ctx) error {
+			s, _ := Session(c)
+			ss.Remove(s.Key)
+			return SetData(c, 2)
+		}, WithFlushErrorHandler(func(_ *fiber.Ctx, err error) {
+			got = err
+		}))
+
+		if got != suk.ErrNoKeyFound {
+			t.Errorf("got %v expected %v", got, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Issued keys replace the rotated ones", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		var issued suk.Session
+		resp := serve(t, ss, withKey(key), func(c *fiber.Ctx) error {
+			issued, _ = ss.SetSession("b")
+			return Issue(c, issued)
+		}, WithCookieName(sukhttp.DefaultCookieName))
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Value != issued.Key {
+			t.Errorf("got %v expected the key %q", cookies, issued.Key)
+		}
+	})
+
+	t.Run("Invalid options panic", func(t *testing.T) {
+		defer func() {
+			if got := recover(); got == nil {
+				t.Errorf("got no panic expected one")
+			}
+		}()
+
+		ss, _ := suk.New()
+		Middleware(ss, WithCookieName(""))
+	})
+}
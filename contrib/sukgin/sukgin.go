@@ -0,0 +1,111 @@
+// Package sukgin provides the sukhttp middleware for Gin, retrieving the
+// session of each request from the key it carries and handing the key it was
+// rotated to back to the client, just as with net/http:
+//
+//	r := gin.Default()
+//	r.Use(sukgin.Middleware(ss))
+//
+//	r.GET("/me", func(c *gin.Context) {
+//		s, ok := sukgin.Session(c)
+//		if !ok {
+//			sukgin.AbortUnauthenticated(c)
+//			return
+//		}
+//		...
+//	})
+package sukgin
+
+import (
+	"net/http"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/gin-gonic/gin"
+)
+
+// responseWriter is the Gin writer of a request, writing through the sukhttp
+// one, so the key is handed back along with the header however the handlers
+// write it.
+type responseWriter struct {
+	gin.ResponseWriter
+
+	w http.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.w.WriteHeader(code)
+}
+
+// WriteHeaderNow writes the key along with the header, as Gin writes the
+// header right away when aborting.
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.w.WriteHeader(w.Status())
+	}
+
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	return w.w.Write(b)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.w.Write([]byte(s))
+}
+
+func (w *responseWriter) Flush() {
+	w.w.(http.Flusher).Flush()
+}
+
+// Middleware returns the sukhttp middleware as a Gin middleware, configured
+// with the sukhttp options. It panics if an option is invalid.
+func Middleware(ss *suk.SessionStorage, opts ...sukhttp.Option) gin.HandlerFunc {
+	mw := sukhttp.Middleware(ss, opts...)
+
+	return func(c *gin.Context) {
+		gw := c.Writer
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Request = r
+			c.Writer = &responseWriter{ResponseWriter: gw, w: w}
+			c.Next()
+		})).ServeHTTP(gw, c.Request)
+		c.Writer = gw
+	}
+}
+
+// Session returns the session of the request, as retrieved by the middleware,
+// along with the data staged with SetData, if any.
+func Session(c *gin.Context) (suk.Session, bool) {
+	return sukhttp.FromContext(c.Request.Context())
+}
+
+// MustSession is like Session, but panics with sukhttp.ErrNoSession when the
+// request has no session, as gin.Context.MustGet does, for the routes only
+// reached with one.
+func MustSession(c *gin.Context) suk.Session {
+	s, ok := Session(c)
+	if !ok {
+		panic(sukhttp.ErrNoSession)
+	}
+
+	return s
+}
+
+// AbortUnauthenticated aborts the request with 401 Unauthorized, such as when
+// it has no session.
+func AbortUnauthenticated(c *gin.Context) {
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
+
+// SetData stages v as the new data of the session of the request, which the
+// middleware stores once the request is served, as with sukhttp.SetData.
+func SetData(c *gin.Context, v any) error {
+	return sukhttp.SetData(c.Request.Context(), v)
+}
+
+// Issue makes the key of the session be handed back to the client along with
+// the response, as with sukhttp.Issue.
+func Issue(c *gin.Context, s suk.Session) error {
+	return sukhttp.Issue(c.Request, s)
+}
@@ -0,0 +1,136 @@
+package sukgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serve serves a request carrying the key, if any, through the middleware and
+// h, returning the response.
+func serve(ss *suk.SessionStorage, key string, h gin.HandlerFunc) *http.Response {
+	e := gin.New()
+	e.Use(Middleware(ss))
+	e.GET("/", h)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		r.AddCookie(&http.Cookie{Name: sukhttp.DefaultCookieName, Value: key})
+	}
+
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Sessions are retrieved and their keys rotated", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		var s suk.Session
+		resp := serve(ss, key, func(c *gin.Context) {
+			s = MustSession(c)
+			c.String(http.StatusOK, s.Data.(string))
+		})
+
+		if s.Data != "a" || s.Key == key {
+			t.Errorf("got %v expected %q under a rotated key", s, "a")
+		}
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Value != s.Key {
+			t.Errorf("got %v expected the key %q", cookies, s.Key)
+		}
+	})
+
+	t.Run("Keys are handed back when aborting", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		resp := serve(ss, key, func(c *gin.Context) {
+			c.AbortWithStatus(http.StatusForbidden)
+		})
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusForbidden)
+		}
+
+		if len(resp.Cookies()) != 1 {
+			t.Errorf("got %d cookies expected %d", len(resp.Cookies()), 1)
+		}
+	})
+
+	t.Run("Requests without a session are aborted", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		resp := serve(ss, "", func(c *gin.Context) {
+			if _, ok := Session(c); !ok {
+				AbortUnauthenticated(c)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Requests without a session make MustSession panic", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		serve(ss, "", func(c *gin.Context) {
+			defer func() {
+				if got := recover(); got != sukhttp.ErrNoSession {
+					t.Errorf("got %v expected %v", got, sukhttp.ErrNoSession)
+				}
+			}()
+
+			MustSession(c)
+		})
+	})
+
+	t.Run("Staged data is stored", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set(1)
+
+		var newKey string
+		serve(ss, key, func(c *gin.Context) {
+			newKey = MustSession(c).Key
+			SetData(c, 2)
+		})
+
+		if got, _ := ss.Peek(newKey); got != 2 {
+			t.Errorf("got %v expected %v", got, 2)
+		}
+	})
+
+	t.Run("Issued keys are handed back", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		var issued suk.Session
+		resp := serve(ss, "", func(c *gin.Context) {
+			issued, _ = ss.SetSession("a")
+			if err := Issue(c, issued); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			c.JSON(http.StatusCreated, gin.H{"ok": true})
+		})
+
+		cookies := resp.Cookies()
+		if resp.StatusCode != http.StatusCreated || len(cookies) != 1 || cookies[0].Value != issued.Key {
+			t.Errorf("got %d and %v expected the key %q", resp.StatusCode, cookies, issued.Key)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Package sukgorilla provides a gorilla/sessions store backed by suk, so
+// handlers written against sessions.Store move to suk without being rewritten:
+//
+//	ss, _ := suk.New()
+//	store := sukgorilla.NewStore(ss)
+//
+//	session, _ := store.Get(r, "session")
+//	session.Values["user"] = "ed"
+//	session.Save(r, w)
+//
+// Loading a session consumes the key carried by the request, so the session
+// must be saved on every request it is loaded on, for the client to be handed
+// the key it was rotated to. Handlers that may not save it should be covered
+// by suk.WithRotationGracePeriod.
+//
+// Sessions are stored as map[any]any, which is registered with gob, so
+// backends encoding the sessions with encoding/gob only need the custom types
+// of the values to be registered.
+package sukgorilla
+
+import (
+	"encoding/gob"
+	"errors"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"github.com/gorilla/sessions"
+)
+
+var ErrNotValues = errors.New("The session wasn't set through the gorilla/sessions store.")
+
+func init() {
+	gob.Register(map[any]any{})
+}
+
+// Store is a sessions.Store keeping the values of the sessions in a suk
+// session storage, and the keys to them in the cookies.
+type Store struct {
+	// Options is the template of the options of the sessions, and so of their
+	// cookies.
+	Options *sessions.Options
+
+	// Sessions is the session storage the values are kept in.
+	Sessions *suk.SessionStorage
+}
+
+// NewStore returns a store keeping the values in ss, with secure defaults:
+// cookies for the whole site, only sent over HTTPS, hidden from scripts, not
+// sent along cross-site subrequests, and living as long as the keys.
+func NewStore(ss *suk.SessionStorage) *Store {
+	return &Store{
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   int(ss.KeyDuration() / time.Second),
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		Sessions: ss,
+	}
+}
+
+// Get returns the session with the given name, loading it once per request,
+// so each call along the request shares the same session.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session with the given name, from the key carried by the
+// cookie of the same name, consuming it. Its ID is the key it was rotated to.
+// When there's no key, or it is unknown or expired, a new session is returned.
+// When the session can't be loaded, a new session is returned along with the
+// error.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return session, nil
+	}
+
+	got, err := s.Sessions.GetSessionCtx(r.Context(), cookie.Value)
+	if err == suk.ErrNoKeyFound || err == suk.ErrKeyWasExpired {
+		return session, nil
+	} else if err != nil {
+		return session, err
+	}
+
+	values, ok := got.Data.(map[any]any)
+	if !ok {
+		return session, ErrNotValues
+	}
+
+	session.Values = maps.Clone(values)
+	session.ID = got.Key
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the values of the session and writes the cookie carrying its
+// key. New sessions are issued a key, while the ones loaded replace the
+// values stored under theirs. Sessions with a negative MaxAge are removed,
+// along with their cookie.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	ctx := r.Context()
+
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Sessions.RemoveCtx(ctx, session.ID); err != nil && err != suk.ErrNoKeyFound {
+				return err
+			}
+		}
+
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	values := maps.Clone(session.Values)
+	if values == nil {
+		values = make(map[any]any)
+	}
+
+	if session.IsNew {
+		got, err := s.Sessions.SetSessionCtx(ctx, values)
+		if err != nil {
+			return err
+		}
+
+		session.ID = got.Key
+		session.IsNew = false
+	} else {
+		err := s.Sessions.UpdateCtx(ctx, session.ID, func(any) any {
+			return values
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
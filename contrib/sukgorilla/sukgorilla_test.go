@@ -0,0 +1,187 @@
+package sukgorilla
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+// roundTrip serves a request carrying the given cookies with fn, returning the
+// cookies set on the response.
+func roundTrip(t *testing.T, cookies []*http.Cookie, fn func(w http.ResponseWriter, r *http.Request)) []*http.Cookie {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+
+	w := httptest.NewRecorder()
+	fn(w, r)
+	return w.Result().Cookies()
+}
+
+func TestStore(t *testing.T) {
+	t.Run("Values survive across requests", func(t *testing.T) {
+		ss, _ := suk.New()
+		store := NewStore(ss)
+
+		cookies := roundTrip(t, nil, func(w http.ResponseWriter, r *http.Request) {
+			session, err := store.Get(r, "session")
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if !session.IsNew {
+				t.Errorf("got %v expected %v", session.IsNew, true)
+			}
+
+			session.Values["user"] = "ed"
+			if err := session.Save(r, w); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+		})
+
+		roundTrip(t, cookies, func(w http.ResponseWriter, r *http.Request) {
+			session, err := store.Get(r, "session")
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if session.IsNew || session.Values["user"] != "ed" {
+				t.Errorf("got %v expected %v", session.Values["user"], "ed")
+			}
+		})
+	})
+
+	t.Run("Keys are rotated on each request", func(t *testing.T) {
+		ss, _ := suk.New()
+		store := NewStore(ss)
+
+		first := roundTrip(t, nil, func(w http.ResponseWriter, r *http.Request) {
+			session, _ := store.Get(r, "session")
+			session.Save(r, w)
+		})
+
+		second := roundTrip(t, first, func(w http.ResponseWriter, r *http.Request) {
+			session, _ := store.Get(r, "session")
+			session.Values["n"] = 2
+			session.Save(r, w)
+		})
+
+		if first[0].Value == second[0].Value {
+			t.Errorf("got %q expected a rotated key", second[0].Value)
+		}
+
+		if _, err := ss.Peek(first[0].Value); err != suk.ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		data, _ := ss.Peek(second[0].Value)
+		if data.(map[any]any)["n"] != 2 {
+			t.Errorf("got %v expected %v", data, 2)
+		}
+	})
+
+	t.Run("Sessions are loaded once per request", func(t *testing.T) {
+		ss, _ := suk.New()
+		store := NewStore(ss)
+
+		cookies := roundTrip(t, nil, func(w http.ResponseWriter, r *http.Request) {
+			session, _ := store.Get(r, "session")
+			session.Save(r, w)
+		})
+
+		roundTrip(t, cookies, func(w http.ResponseWriter, r *http.Request) {
+			a, _ := store.Get(r, "session")
+			b, err := store.Get(r, "session")
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if a != b {
+				t.Errorf("got %p expected %p", b, a)
+			}
+		})
+	})
+
+	t.Run("Unknown keys give new sessions", func(t *testing.T) {
+		ss, _ := suk.New()
+		store := NewStore(ss)
+
+		cookies := []*http.Cookie{{Name: "session", Value: "unknown"}}
+		roundTrip(t, cookies, func(w http.ResponseWriter, r *http.Request) {
+			session, err := store.Get(r, "session")
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if !session.IsNew {
+				t.Errorf("got %v expected %v", session.IsNew, true)
+			}
+		})
+	})
+
+	t.Run("Sessions set elsewhere are not loaded", func(t *testing.T) {
+		ss, _ := suk.New()
+		store := NewStore(ss)
+		key, _ := ss.Set("a")
+
+		cookies := []*http.Cookie{{Name: "session", Value: key}}
+		roundTrip(t, cookies, func(w http.ResponseWriter, r *http.Request) {
+			session, err := store.New(r, "session")
+			if err != ErrNotValues {
+				t.Errorf("got %v expected %v", err, ErrNotValues)
+			}
+
+			if !session.IsNew {
+				t.Errorf("got %v expected %v", session.IsNew, true)
+			}
+		})
+	})
+
+	t.Run("Negative MaxAge removes the session", func(t *testing.T) {
+		ss, _ := suk.New()
+		store := NewStore(ss)
+
+		cookies := roundTrip(t, nil, func(w http.ResponseWriter, r *http.Request) {
+			session, _ := store.Get(r, "session")
+			session.Save(r, w)
+		})
+
+		var key string
+		cleared := roundTrip(t, cookies, func(w http.ResponseWriter, r *http.Request) {
+			session, _ := store.Get(r, "session")
+			key = session.ID
+			session.Options.MaxAge = -1
+			if err := session.Save(r, w); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+		})
+
+		if _, err := ss.Peek(key); err != suk.ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if cleared[0].MaxAge >= 0 {
+			t.Errorf("got %d expected a negative MaxAge", cleared[0].MaxAge)
+		}
+	})
+
+	t.Run("Cookies live as long as the keys", func(t *testing.T) {
+		ss, _ := suk.New()
+		store := NewStore(ss)
+
+		cookies := roundTrip(t, nil, func(w http.ResponseWriter, r *http.Request) {
+			session, _ := store.Get(r, "session")
+			session.Save(r, w)
+		})
+
+		expected := int(ss.KeyDuration().Seconds())
+		if cookies[0].MaxAge != expected || !cookies[0].HttpOnly || !cookies[0].Secure {
+			t.Errorf("got %v expected a secure cookie living %ds", cookies[0], expected)
+		}
+	})
+}
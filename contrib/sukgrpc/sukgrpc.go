@@ -0,0 +1,239 @@
+// Package sukgrpc provides gRPC server interceptors for suk, retrieving the
+// session of each call from the key carried by its metadata, and handing the
+// key it was rotated to back to the client in the header metadata:
+//
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(sukgrpc.UnaryServerInterceptor(ss)),
+//		grpc.StreamInterceptor(sukgrpc.StreamServerInterceptor(ss)),
+//	)
+//
+// Handlers find the session with FromContext. Clients must send the rotated
+// key along with their next call, as the one they sent was consumed. Keys
+// issued by the handlers with Issue are handed back after the rotated one, so
+// clients must take the last value of the metadata.
+package sukgrpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/ed-henrique/suk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMetadataKey is the metadata carrying the key when none is given with
+// WithMetadataKey.
+const DefaultMetadataKey = "suk-key"
+
+var (
+	ErrEmptyMetadataKey = errors.New("The given metadata key must not be empty.")
+	ErrNoInterceptor    = errors.New("The call was not served through the sukgrpc interceptors.")
+
+	ErrNoKey = errors.New("The call carried no key.")
+)
+
+// Option customizes the interceptors.
+type Option interface {
+	apply(*config) error
+}
+
+type option func(*config) error
+
+func (o option) apply(c *config) error {
+	return o(c)
+}
+
+type config struct {
+	key      string
+	trailer  bool
+	required bool
+}
+
+// WithMetadataKey sets the metadata carrying the keys, both in the calls and
+// in their answers, DefaultMetadataKey otherwise.
+func WithMetadataKey(key string) Option {
+	return option(func(c *config) error {
+		if key == "" {
+			return ErrEmptyMetadataKey
+		}
+
+		c.key = strings.ToLower(key)
+		return nil
+	})
+}
+
+// WithTrailer makes the rotated keys be handed back in the trailer metadata,
+// rather than in the header, such as for clients only reading the trailer.
+func WithTrailer() Option {
+	return option(func(c *config) error {
+		c.trailer = true
+		return nil
+	})
+}
+
+// WithRequired makes the calls without a valid key fail with Unauthenticated,
+// rather than being served without a session.
+func WithRequired() Option {
+	return option(func(c *config) error {
+		c.required = true
+		return nil
+	})
+}
+
+type stateContextKey struct{}
+
+// state is the session of a call, as retrieved by the interceptors, along with
+// why it couldn't be, if it couldn't.
+type state struct {
+	mu      sync.Mutex
+	config  *config
+	session suk.Session
+	err     error
+}
+
+// newConfig returns the configuration set by the options, panicking if one is
+// invalid, as interceptors are set up once, when the program starts.
+func newConfig(opts []Option) *config {
+	c := &config{key: DefaultMetadataKey}
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.apply(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// retrieve retrieves the session of the call, handing its rotated key back,
+// and returns the context carrying it.
+func (c *config) retrieve(ctx context.Context, ss *suk.SessionStorage) (context.Context, error) {
+	st := &state{config: c, err: ErrNoKey}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get(c.key); len(keys) > 0 && keys[0] != "" {
+		st.session, st.err = ss.GetSessionCtx(ctx, keys[0])
+	}
+
+	if st.err == nil {
+		if err := st.writeKey(ctx); err != nil {
+			return nil, err
+		}
+	} else if c.required {
+		return nil, unauthenticated(st.err)
+	}
+
+	return context.WithValue(ctx, stateContextKey{}, st), nil
+}
+
+// writeKey hands the key of the session back to the client.
+func (st *state) writeKey(ctx context.Context) error {
+	md := metadata.Pairs(st.config.key, st.session.Key)
+	if st.config.trailer {
+		return grpc.SetTrailer(ctx, md)
+	}
+
+	return grpc.SetHeader(ctx, md)
+}
+
+// unauthenticated returns the status of the calls rejected because their
+// session couldn't be retrieved, hiding why, unless the backend failed.
+func unauthenticated(err error) error {
+	switch {
+	case errors.Is(err, ErrNoKey),
+		errors.Is(err, suk.ErrKeyWasExpired),
+		errors.Is(err, suk.ErrNoKeyFound),
+		errors.Is(err, suk.ErrForeignKey),
+		errors.Is(err, suk.ErrKeyReplayed):
+		return status.Error(codes.Unauthenticated, "a valid session key is required")
+	default:
+		return status.Error(codes.Unavailable, "the session could not be retrieved")
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor retrieving the session of each
+// unary call. It panics if an option is invalid.
+func UnaryServerInterceptor(ss *suk.SessionStorage, opts ...Option) grpc.UnaryServerInterceptor {
+	c := newConfig(opts)
+
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := c.retrieve(ctx, ss)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// serverStream is a server stream whose context carries the session.
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor returns an interceptor retrieving the session of
+// each streaming call, once, when the stream starts. It panics if an option is
+// invalid.
+func StreamServerInterceptor(ss *suk.SessionStorage, opts ...Option) grpc.StreamServerInterceptor {
+	c := newConfig(opts)
+
+	return func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := c.retrieve(stream.Context(), ss)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// FromContext returns the session of the call the context belongs to, as
+// retrieved by the interceptors.
+func FromContext(ctx context.Context) (suk.Session, bool) {
+	st, ok := ctx.Value(stateContextKey{}).(*state)
+	if !ok {
+		return suk.Session{}, false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.err != nil {
+		return suk.Session{}, false
+	}
+
+	return st.session, true
+}
+
+// Issue hands the key of the session back to the client, after the key the
+// call carried was rotated to, if it was, such as after logging in. The
+// session is also the one of the call from then on. It must be called before
+// the header is sent, unless the keys are handed back in the trailer.
+func Issue(ctx context.Context, s suk.Session) error {
+	st, ok := ctx.Value(stateContextKey{}).(*state)
+	if !ok {
+		return ErrNoInterceptor
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.session, st.err = s, nil
+	return st.writeKey(ctx)
+}
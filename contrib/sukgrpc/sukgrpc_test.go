@@ -0,0 +1,197 @@
+package sukgrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeTransport records the metadata set by the interceptors.
+type fakeTransport struct {
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (f *fakeTransport) Method() string { return "/test.Service/Method" }
+
+func (f *fakeTransport) SetHeader(md metadata.MD) error {
+	f.header = metadata.Join(f.header, md)
+	return nil
+}
+
+func (f *fakeTransport) SendHeader(md metadata.MD) error {
+	return f.SetHeader(md)
+}
+
+func (f *fakeTransport) SetTrailer(md metadata.MD) error {
+	f.trailer = metadata.Join(f.trailer, md)
+	return nil
+}
+
+// newCall returns the context of a call carrying the key, if any, along with
+// its transport.
+func newCall(key string) (context.Context, *fakeTransport) {
+	f := &fakeTransport{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), f)
+	if key != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(DefaultMetadataKey, key))
+	}
+
+	return ctx, f
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Run("Sessions are retrieved and their keys rotated", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+		ctx, f := newCall(key)
+
+		var s suk.Session
+		_, err := UnaryServerInterceptor(ss)(ctx, nil, nil, func(ctx context.Context, _ any) (any, error) {
+			var ok bool
+			if s, ok = FromContext(ctx); !ok {
+				t.Fatalf("got %v expected %v", ok, true)
+			}
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if s.Data != "a" || s.Key == key {
+			t.Errorf("got %v expected %q under a rotated key", s, "a")
+		}
+
+		if got := f.header.Get(DefaultMetadataKey); len(got) != 1 || got[0] != s.Key {
+			t.Errorf("got %v expected %q", got, s.Key)
+		}
+	})
+
+	t.Run("Calls without a valid key have no session", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		for _, key := range []string{"", "unknown"} {
+			ctx, f := newCall(key)
+
+			UnaryServerInterceptor(ss)(ctx, nil, nil, func(ctx context.Context, _ any) (any, error) {
+				if _, ok := FromContext(ctx); ok {
+					t.Errorf("got %v expected %v", ok, false)
+				}
+				return nil, nil
+			})
+
+			if len(f.header) != 0 {
+				t.Errorf("got %v expected no metadata", f.header)
+			}
+		}
+	})
+
+	t.Run("Calls without a valid key are rejected when required", func(t *testing.T) {
+		ss, _ := suk.New()
+		ctx, _ := newCall("unknown")
+
+		_, err := UnaryServerInterceptor(ss, WithRequired())(ctx, nil, nil, func(context.Context, any) (any, error) {
+			t.Errorf("got the handler called expected the call to be rejected")
+			return nil, nil
+		})
+
+		if got := status.Code(err); got != codes.Unauthenticated {
+			t.Errorf("got %v expected %v", got, codes.Unauthenticated)
+		}
+	})
+
+	t.Run("Keys are handed back in the trailer", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+		ctx, f := newCall("")
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-session", key))
+
+		UnaryServerInterceptor(ss, WithMetadataKey("X-Session"), WithTrailer())(ctx, nil, nil, func(context.Context, any) (any, error) {
+			return nil, nil
+		})
+
+		if len(f.header) != 0 || len(f.trailer.Get("x-session")) != 1 {
+			t.Errorf("got %v and %v expected the key in the trailer", f.header, f.trailer)
+		}
+	})
+
+	t.Run("Issued keys are handed back last", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+		ctx, f := newCall(key)
+
+		var issued suk.Session
+		UnaryServerInterceptor(ss)(ctx, nil, nil, func(ctx context.Context, _ any) (any, error) {
+			issued, _ = ss.SetSession("b")
+			if err := Issue(ctx, issued); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if s, _ := FromContext(ctx); s.Key != issued.Key {
+				t.Errorf("got %q expected %q", s.Key, issued.Key)
+			}
+			return nil, nil
+		})
+
+		got := f.header.Get(DefaultMetadataKey)
+		if len(got) != 2 || got[1] != issued.Key {
+			t.Errorf("got %v expected %q last", got, issued.Key)
+		}
+	})
+
+	t.Run("Calls served without the interceptors", func(t *testing.T) {
+		if err := Issue(context.Background(), suk.Session{}); err != ErrNoInterceptor {
+			t.Errorf("got %v expected %v", err, ErrNoInterceptor)
+		}
+	})
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	t.Run("Streams carry the session", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+		ctx, f := newCall(key)
+
+		err := StreamServerInterceptor(ss)(nil, &fakeStream{ctx: ctx}, nil, func(_ any, stream grpc.ServerStream) error {
+			if s, ok := FromContext(stream.Context()); !ok || s.Data != "a" {
+				t.Errorf("got %v expected %q", s.Data, "a")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if len(f.header.Get(DefaultMetadataKey)) != 1 {
+			t.Errorf("got %v expected the rotated key", f.header)
+		}
+	})
+
+	t.Run("Streams without a valid key are rejected when required", func(t *testing.T) {
+		ss, _ := suk.New()
+		ctx, _ := newCall("")
+
+		err := StreamServerInterceptor(ss, WithRequired())(nil, &fakeStream{ctx: ctx}, nil, func(any, grpc.ServerStream) error {
+			return nil
+		})
+
+		if got := status.Code(err); got != codes.Unauthenticated {
+			t.Errorf("got %v expected %v", got, codes.Unauthenticated)
+		}
+	})
+}
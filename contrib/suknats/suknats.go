@@ -0,0 +1,151 @@
+// Package suknats provides a NATS JetStream key-value backend for suk, so apps
+// already running NATS can share sessions across instances without another
+// dependency:
+//
+//	kv, _ := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "sessions", TTL: 10 * time.Minute})
+//	ss, _ := suk.New(suknats.WithNatsKV(kv), suk.WithKeyDuration(10*time.Minute))
+//
+// JetStream expires the entries of a bucket after its TTL, so the bucket TTL
+// should match the key duration. Sessions are encoded with encoding/gob, so
+// custom types must be registered with gob.Register, and keys must only use
+// the characters allowed by NATS, as the default keys do.
+package suknats
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"strings"
+
+	"github.com/ed-henrique/suk"
+	"github.com/nats-io/nats.go"
+)
+
+// Storage stores the sessions in a JetStream key-value bucket.
+type Storage struct {
+	kv nats.KeyValue
+}
+
+// New creates a storage keeping the sessions in the given bucket.
+func New(kv nats.KeyValue) *Storage {
+	return &Storage{kv}
+}
+
+// WithNatsKV stores the sessions in the given bucket. It is a shorthand for
+// suk.WithStorage(suknats.New(kv)).
+func WithNatsKV(kv nats.KeyValue) suk.Option {
+	return suk.WithStorage(New(kv))
+}
+
+func (s *Storage) Set(_ context.Context, key string, e suk.Entry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return err
+	}
+
+	_, err := s.kv.Create(key, buf.Bytes())
+	if errors.Is(err, nats.ErrKeyExists) {
+		return suk.ErrKeyExists
+	} else if err != nil || e.ID == "" {
+		return err
+	}
+
+	// The index entries of the session ID expire after the bucket TTL too.
+	if _, err := s.kv.Put(familyKey(e.ID), []byte(key)); err != nil || e.Owner == "" {
+		return err
+	}
+
+	_, err = s.kv.Put(ownerKey(e.Owner, e.ID), nil)
+	return err
+}
+
+// familyKey returns the key indexing the session with the given ID.
+func familyKey(id string) string {
+	return "family." + id
+}
+
+func (s *Storage) FindSession(_ context.Context, id string) (string, error) {
+	entry, err := s.kv.Get(familyKey(id))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return "", suk.ErrNoKeyFound
+	} else if err != nil {
+		return "", err
+	}
+
+	return string(entry.Value()), nil
+}
+
+// ownerKey returns the key indexing the session with the given ID under its
+// owner, which is encoded as owners may use characters not allowed by NATS.
+func ownerKey(owner, id string) string {
+	return "owner." + base64.RawURLEncoding.EncodeToString([]byte(owner)) + "." + id
+}
+
+// FindOwnedSessions watches the keys indexing the sessions of owner until
+// every one of them was delivered.
+func (s *Storage) FindOwnedSessions(_ context.Context, owner string) ([]string, error) {
+	w, err := s.kv.Watch(ownerKey(owner, "*"), nats.MetaOnly(), nats.IgnoreDeletes())
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop()
+
+	prefix := ownerKey(owner, "")
+	var ids []string
+	for entry := range w.Updates() {
+		if entry == nil {
+			break
+		}
+		ids = append(ids, strings.TrimPrefix(entry.Key(), prefix))
+	}
+
+	return ids, nil
+}
+
+func (s *Storage) Get(_ context.Context, key string) (suk.Entry, error) {
+	entry, err := s.kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return suk.Entry{}, suk.ErrNoKeyFound
+	} else if err != nil {
+		return suk.Entry{}, err
+	}
+
+	return decode(entry.Value())
+}
+
+// Remove takes the session only if it wasn't changed since it was read, so a
+// key is never taken twice.
+func (s *Storage) Remove(_ context.Context, key string) (suk.Entry, error) {
+	entry, err := s.kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return suk.Entry{}, suk.ErrNoKeyFound
+	} else if err != nil {
+		return suk.Entry{}, err
+	}
+
+	err = s.kv.Delete(key, nats.LastRevision(entry.Revision()))
+	if errors.Is(err, nats.ErrKeyExists) {
+		return suk.Entry{}, suk.ErrNoKeyFound
+	} else if err != nil {
+		return suk.Entry{}, err
+	}
+
+	return decode(entry.Value())
+}
+
+// ClearExpired does nothing, as JetStream expires the entries of the bucket
+// after its TTL.
+func (s *Storage) ClearExpired(_ context.Context) error {
+	return nil
+}
+
+func decode(value []byte) (suk.Entry, error) {
+	var e suk.Entry
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&e); err != nil {
+		return suk.Entry{}, err
+	}
+
+	return e, nil
+}
@@ -0,0 +1,207 @@
+package suknats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"github.com/nats-io/nats.go"
+)
+
+// fakeEntry is a revision of a key in the fake bucket.
+type fakeEntry struct {
+	nats.KeyValueEntry
+
+	key      string
+	value    []byte
+	revision uint64
+}
+
+func (e fakeEntry) Key() string      { return e.key }
+func (e fakeEntry) Value() []byte    { return e.value }
+func (e fakeEntry) Revision() uint64 { return e.revision }
+
+// fakeKV is an in-memory bucket, supporting just the calls made by the
+// storage. Deletes ignore the expected revision.
+type fakeKV struct {
+	nats.KeyValue
+
+	mu       sync.Mutex
+	entries  map[string]fakeEntry
+	revision uint64
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{entries: make(map[string]fakeEntry)}
+}
+
+func (f *fakeKV) Create(key string, value []byte) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.entries[key]; ok {
+		return 0, nats.ErrKeyExists
+	}
+
+	f.revision++
+	f.entries[key] = fakeEntry{key: key, value: value, revision: f.revision}
+	return f.revision, nil
+}
+
+func (f *fakeKV) Put(key string, value []byte) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.revision++
+	f.entries[key] = fakeEntry{key: key, value: value, revision: f.revision}
+	return f.revision, nil
+}
+
+func (f *fakeKV) Get(key string) (nats.KeyValueEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.entries[key]
+	if !ok {
+		return nil, nats.ErrKeyNotFound
+	}
+
+	return entry, nil
+}
+
+func (f *fakeKV) Delete(key string, _ ...nats.DeleteOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.entries, key)
+	return nil
+}
+
+// Watch delivers the entries under the keys, which may only end with a
+// wildcard, along with the nil entry marking the end of the initial values.
+// Later updates are never delivered.
+func (f *fakeKV) Watch(keys string, _ ...nats.WatchOpt) (nats.KeyWatcher, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	updates := make(chan nats.KeyValueEntry, len(f.entries)+1)
+	for key, entry := range f.entries {
+		if strings.HasPrefix(key, strings.TrimSuffix(keys, "*")) {
+			updates <- entry
+		}
+	}
+	updates <- nil
+
+	return fakeWatcher{updates: updates}, nil
+}
+
+// fakeWatcher delivers the entries sent by Watch.
+type fakeWatcher struct {
+	nats.KeyWatcher
+
+	updates chan nats.KeyValueEntry
+}
+
+func (w fakeWatcher) Updates() <-chan nats.KeyValueEntry { return w.updates }
+func (w fakeWatcher) Stop() error                        { return nil }
+
+func TestStorage(t *testing.T) {
+	t.Run("Sessions are stored in the bucket", func(t *testing.T) {
+		ss, _ := suk.New(WithNatsKV(newFakeKV()))
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		session, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if err := ss.Remove(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Sessions of an owner are found whichever instance issued them", func(t *testing.T) {
+		kv := newFakeKV()
+		a, _ := suk.New(WithNatsKV(kv))
+		b, _ := suk.New(WithNatsKV(kv))
+
+		key, _ := a.Set(42, suk.WithOwner("user-42"))
+		_, key, _ = b.Get(key)
+		b.Set(43, suk.WithOwner("user-42"))
+
+		if got, err := a.SessionsForOwner("user-42"); err != nil || len(got) != 2 {
+			t.Fatalf("got %v and %v expected 2 sessions", got, err)
+		}
+
+		if n, err := a.RevokeAllForOwner(context.Background(), "user-42", nil); err != nil || n != 2 {
+			t.Errorf("got %d and %v expected %d", n, err, 2)
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions are revoked whichever instance rotated them", func(t *testing.T) {
+		kv := newFakeKV()
+		a, _ := suk.New(WithNatsKV(kv))
+		b, _ := suk.New(WithNatsKV(kv))
+
+		key, _ := a.Set(42)
+		id, _ := a.SessionID(key)
+		_, key, _ = b.Get(key)
+
+		if err := a.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Colliding keys are reported", func(t *testing.T) {
+		s := New(newFakeKV())
+		ctx := context.Background()
+		e := suk.Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}
+
+		s.Set(ctx, "key", e)
+
+		if err := s.Set(ctx, "key", e); !errors.Is(err, suk.ErrKeyExists) {
+			t.Errorf("got %v expected %v", err, suk.ErrKeyExists)
+		}
+	})
+
+	t.Run("Expiration survives the round trip", func(t *testing.T) {
+		s := New(newFakeKV())
+		ctx := context.Background()
+		expiresAt := time.Now().Add(time.Hour)
+
+		s.Set(ctx, "key", suk.Entry{Data: "session", ExpiresAt: expiresAt})
+
+		e, err := s.Remove(ctx, "key")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if !e.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("got %v expected %v", e.ExpiresAt, expiresAt)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+// Package sukscs provides an alexedwards/scs store backed by suk, so apps
+// using scs keep their session data behind single-use keys by swapping the
+// store:
+//
+//	ss, _ := suk.New()
+//	sessionManager := scs.New()
+//	sessionManager.Store = sukscs.New(ss)
+//
+// scs hands its own tokens to the clients, and stores can't replace them, so
+// the tokens are mapped to the suk keys, which are rotated on every lookup and
+// commit and never leave the store. The clients keep their token until it is
+// renewed with RenewToken, as with any other store. As the mapping is kept in
+// memory, sessions are only found by the instance that committed them.
+package sukscs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+// indexed is the key a token is mapped to, along with when it expires.
+type indexed struct {
+	key       string
+	expiresAt time.Time
+}
+
+// Store is an scs store keeping the session data in a suk session storage.
+type Store struct {
+	sessions *suk.SessionStorage
+
+	mu     sync.Mutex
+	tokens map[string]indexed
+
+	// pruneAt is the amount of tokens from which expired ones are pruned, so
+	// abandoned sessions don't pile up.
+	pruneAt int
+}
+
+// minPruneAt is the least amount of tokens from which expired ones are pruned.
+const minPruneAt = 1024
+
+// New creates a store keeping the session data in ss.
+func New(ss *suk.SessionStorage) *Store {
+	return &Store{sessions: ss, tokens: make(map[string]indexed), pruneAt: minPruneAt}
+}
+
+// Find returns the data of the session of the token, rotating its key.
+func (s *Store) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+// FindCtx is like Find, but the storage operations run on the given context.
+func (s *Store) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.tokens[token]
+	if !ok {
+		return nil, false, nil
+	}
+
+	got, err := s.sessions.GetSessionCtx(ctx, i.key)
+	if err == suk.ErrNoKeyFound || err == suk.ErrKeyWasExpired {
+		delete(s.tokens, token)
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	s.tokens[token] = indexed{key: got.Key, expiresAt: got.ExpiresAt}
+
+	b, ok := got.Data.([]byte)
+	if !ok {
+		return nil, false, nil
+	}
+
+	return b, true, nil
+}
+
+// Commit stores the data of the session of the token under a new key,
+// expiring at expiry, removing the one it replaces.
+func (s *Store) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+// CommitCtx is like Commit, but the storage operations run on the given
+// context.
+func (s *Store) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		return s.delete(ctx, token)
+	}
+
+	got, err := s.sessions.SetSessionCtx(ctx, b, suk.WithTTL(ttl))
+	if err != nil {
+		return err
+	}
+
+	old, ok := s.tokens[token]
+	s.tokens[token] = indexed{key: got.Key, expiresAt: got.ExpiresAt}
+	if len(s.tokens) >= s.pruneAt {
+		s.prune()
+	}
+
+	if ok {
+		if err := s.sessions.RemoveCtx(ctx, old.key); err != nil && err != suk.ErrNoKeyFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the session of the token, if there's any.
+func (s *Store) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
+
+// DeleteCtx is like Delete, but the storage operations run on the given
+// context.
+func (s *Store) DeleteCtx(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.delete(ctx, token)
+}
+
+func (s *Store) delete(ctx context.Context, token string) error {
+	i, ok := s.tokens[token]
+	if !ok {
+		return nil
+	}
+
+	delete(s.tokens, token)
+	if err := s.sessions.RemoveCtx(ctx, i.key); err != nil && err != suk.ErrNoKeyFound {
+		return err
+	}
+
+	return nil
+}
+
+// prune forgets the tokens of the expired sessions, and waits for the tokens
+// left to double before pruning again.
+func (s *Store) prune() {
+	now := time.Now()
+	for token, i := range s.tokens {
+		if !i.expiresAt.After(now) {
+			delete(s.tokens, token)
+		}
+	}
+
+	s.pruneAt = max(2*len(s.tokens), minPruneAt)
+}
@@ -0,0 +1,143 @@
+package sukscs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/ed-henrique/suk"
+)
+
+func TestStore(t *testing.T) {
+	t.Run("Sessions are found after being committed", func(t *testing.T) {
+		ss, _ := suk.New()
+		s := New(ss)
+
+		if err := s.Commit("token", []byte("a"), time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		b, found, err := s.Find("token")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if !found || string(b) != "a" {
+			t.Errorf("got %q expected %q", b, "a")
+		}
+	})
+
+	t.Run("Keys are rotated on every lookup", func(t *testing.T) {
+		ss, _ := suk.New()
+		s := New(ss)
+		s.Commit("token", []byte("a"), time.Now().Add(time.Hour))
+		before := s.tokens["token"].key
+
+		s.Find("token")
+
+		if _, err := ss.Peek(before); err != suk.ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if got := s.tokens["token"].key; got == before {
+			t.Errorf("got %q expected a rotated key", got)
+		}
+	})
+
+	t.Run("Commits replace the session", func(t *testing.T) {
+		ss, _ := suk.New()
+		s := New(ss)
+		s.Commit("token", []byte("a"), time.Now().Add(time.Hour))
+		s.Commit("token", []byte("b"), time.Now().Add(time.Hour))
+
+		b, _, _ := s.Find("token")
+		if string(b) != "b" {
+			t.Errorf("got %q expected %q", b, "b")
+		}
+
+		if got, _ := ss.Count(); got != 1 {
+			t.Errorf("got %d sessions expected %d", got, 1)
+		}
+	})
+
+	t.Run("Unknown and deleted tokens are not found", func(t *testing.T) {
+		ss, _ := suk.New()
+		s := New(ss)
+		s.Commit("token", []byte("a"), time.Now().Add(time.Hour))
+
+		if err := s.Delete("token"); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		for _, token := range []string{"token", "unknown"} {
+			if _, found, err := s.Find(token); found || err != nil {
+				t.Errorf("got %v and %v expected not found", found, err)
+			}
+		}
+
+		if err := s.Delete("unknown"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Expired sessions are not found", func(t *testing.T) {
+		ss, _ := suk.New()
+		s := New(ss)
+		s.Commit("token", []byte("a"), time.Now().Add(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		if _, found, _ := s.Find("token"); found {
+			t.Errorf("got %v expected %v", found, false)
+		}
+
+		if _, ok := s.tokens["token"]; ok {
+			t.Errorf("got token expected it to be forgotten")
+		}
+	})
+
+	t.Run("Expired tokens are pruned", func(t *testing.T) {
+		ss, _ := suk.New()
+		s := New(ss)
+		s.pruneAt = 2
+		s.Commit("a", []byte("a"), time.Now().Add(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+		s.Commit("b", []byte("b"), time.Now().Add(time.Hour))
+
+		if _, ok := s.tokens["a"]; ok {
+			t.Errorf("got token expected it to be pruned")
+		}
+	})
+
+	t.Run("Session managers use the store", func(t *testing.T) {
+		ss, _ := suk.New()
+		sm := scs.New()
+		sm.Store = New(ss)
+
+		h := sm.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPut {
+				sm.Put(r.Context(), "user", "ed")
+				return
+			}
+
+			io.WriteString(w, sm.GetString(r.Context(), "user"))
+		}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", nil))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range w.Result().Cookies() {
+			r.AddCookie(c)
+		}
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if got := w.Body.String(); got != "ed" {
+			t.Errorf("got %q expected %q", got, "ed")
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrCountUnsupported = errors.New("The session storage backend can't count its sessions.")
+
+// Counter may be implemented by storages able to count their live entries,
+// so they support Count.
+type Counter interface {
+	Count(ctx context.Context) (int64, error)
+}
+
+func (m *shardedMap) Count(_ context.Context) (int64, error) {
+	now := time.Now()
+
+	var n int64
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for _, e := range s.entries {
+			if now.Before(e.ExpiresAt) {
+				n++
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	return n, nil
+}
+
+// Count scans the keys under the prefix, skipping scratch spaces, watermarks
+// and the index of the sessions by ID. Redis expires the sessions by itself,
+// so every key found is live. While the prefix is being migrated, the keys
+// under the previous one are counted too.
+func (r *redisDB) Count(ctx context.Context) (int64, error) {
+	p := r.prefixes.Load()
+
+	n, err := r.countPrefixed(ctx, r.epochPrefix(p.current), "")
+	if err != nil || !p.migrating {
+		return n, err
+	}
+
+	previous, err := r.countPrefixed(ctx, r.epochPrefix(p.previous), r.epochPrefix(p.current))
+	return n + previous, err
+}
+
+// countPrefixed counts the sessions under prefix, skipping the keys under
+// skipped, if any.
+func (r *redisDB) countPrefixed(ctx context.Context, prefix, skipped string) (int64, error) {
+	pattern := escapePattern(prefix) + "*"
+	scratch := prefix + scratchKey("")
+	watermarks := prefix + watermarkKey("")
+	families := prefix + familyIndexKey("")
+	owners := prefix + ownerIndexKey("")
+
+	var (
+		n      int64
+		cursor uint64
+	)
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, pattern, migrationBatchSize).Result()
+		if err != nil {
+			return n, err
+		}
+
+		for _, key := range keys {
+			if strings.HasPrefix(key, scratch) || strings.HasPrefix(key, watermarks) || strings.HasPrefix(key, families) || strings.HasPrefix(key, owners) || skipped != "" && strings.HasPrefix(key, skipped) {
+				continue
+			}
+			n++
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return n, nil
+		}
+	}
+}
+
+func (b *bufferedStorage) Count(ctx context.Context) (int64, error) {
+	local, err := b.local.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	remote, err := b.redisDB.Count(ctx)
+	return local + remote, err
+}
+
+// Count scans the nearest region, skipping the tombstones and watermarks,
+// which hold no data.
+func (m *multiRegionRedis) Count(ctx context.Context) (int64, error) {
+	var (
+		n      int64
+		cursor uint64
+	)
+	for {
+		keys, next, err := m.nearest.Scan(ctx, cursor, "*", migrationBatchSize).Result()
+		if err != nil {
+			return n, err
+		}
+
+		cmds, err := m.nearest.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.HExists(ctx, key, "data")
+			}
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+
+		for _, cmd := range cmds {
+			if cmd.(*redis.BoolCmd).Val() {
+				n++
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return n, nil
+		}
+	}
+}
+
+func (s *sqliteDB) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM suk_sessions WHERE expires_at > ?`,
+		time.Now().UnixNano(),
+	).Scan(&n)
+
+	return n, err
+}
+
+// Count returns how many live sessions are stored, such as for an operations
+// dashboard. It goes through the whole keyspace, so it's meant to be called
+// once in a while rather than on every request. Storages not implementing
+// Counter fail with ErrCountUnsupported.
+func (ss *SessionStorage) Count() (int64, error) {
+	return ss.CountCtx(ss.ctx)
+}
+
+// CountCtx is like Count, but the storage operations run on the given context.
+func (ss *SessionStorage) CountCtx(ctx context.Context) (int64, error) {
+	c, ok := ss.storage.(Counter)
+	if !ok {
+		return 0, ErrCountUnsupported
+	}
+
+	if err := ss.begin(false); err != nil {
+		return 0, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	return c.Count(ctx)
+}
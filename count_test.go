@@ -0,0 +1,86 @@
+package suk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCount(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithRedisPrefix("sessions:"))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Sync map": syncMapStorage,
+		"Redis":    redisStorage,
+		"SQLite":   sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" live sessions are counted", func(t *testing.T) {
+			keys, _ := ss.SetMany([]any{"a", "b", "c"})
+			ss.Remove(keys[0])
+			ss.Get(keys[1])
+
+			got, err := ss.Count()
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got != 2 {
+				t.Errorf("got %d expected %d", got, 2)
+			}
+
+			ss.RemoveMany(keys)
+		})
+	}
+
+	t.Run("Expired sessions are not counted", func(t *testing.T) {
+		ss, _ := New()
+		ss.Set("a", WithTTL(10*time.Millisecond))
+		ss.Set("b")
+		time.Sleep(20 * time.Millisecond)
+
+		if got, _ := ss.Count(); got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+	})
+
+	t.Run("Scratch spaces and other keys are not counted", func(t *testing.T) {
+		mr.FlushAll()
+		mr.Set("other", "data")
+
+		key, _ := redisStorage.Set("a")
+		redisStorage.Scratch(key).Set("step", 2)
+
+		if got, _ := redisStorage.Count(); got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+	})
+
+	t.Run("Tombstones are not counted", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ss, _ := newTestRegion(t, mrA, mrB)
+
+		key, _ := ss.Set("a")
+		ss.Get(key)
+
+		if got, _ := ss.Count(); got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+	})
+
+	t.Run("Backends that can't list their sessions", func(t *testing.T) {
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}))
+
+		if _, err := ss.Count(); err != ErrCountUnsupported {
+			t.Errorf("got %v expected %v", err, ErrCountUnsupported)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package suk
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+)
+
+var ErrInvalidCSRFToken = errors.New("The given CSRF token doesn't match the one of the session.")
+
+const (
+	// csrfScratchName is the name of the scratch value holding the CSRF token
+	// of a session.
+	csrfScratchName = "suk:csrf"
+
+	// csrfTokenLength is the length of the CSRF tokens, which are generated
+	// with the default alphabet whatever the key generator, for about 192 bits
+	// of entropy.
+	csrfTokenLength = 32
+)
+
+// IssueCSRF returns the CSRF token of the session with the given key, for the
+// synchronizer token pattern, generating it the first time. The token is kept
+// in the scratch space of the session, so every form of the session shares it,
+// it follows the session through key rotations, and is destroyed along with
+// it. Issuing the token neither consumes nor rotates the key. Backends without
+// scratch space fail with ErrScratchUnsupported.
+func (ss *SessionStorage) IssueCSRF(sessionKey string) (string, error) {
+	return ss.IssueCSRFCtx(ss.ctx, sessionKey)
+}
+
+// IssueCSRFCtx is like IssueCSRF, but the storage operations run on the given
+// context, and the session is checked against the fingerprint or the channel
+// binding it carries, as with GetCtx.
+func (ss *SessionStorage) IssueCSRFCtx(ctx context.Context, sessionKey string) (string, error) {
+	if ss.config.readOnly {
+		return "", ErrReadOnly
+	}
+
+	st, ok := ss.storage.(scratchStorage)
+	if !ok {
+		return "", ErrScratchUnsupported
+	}
+
+	// The token is looked up and generated at once, so concurrent requests
+	// get the same one.
+	defer ss.locks.lock(sessionKey)()
+
+	if _, err := ss.lookupEntry(ctx, sessionKey); err != nil {
+		return "", err
+	}
+
+	v, err := st.scratchGet(ctx, ss.stored(sessionKey), csrfScratchName)
+	if err == nil {
+		if token, ok := v.(string); ok {
+			return token, nil
+		}
+	} else if err != ErrNoScratchValue {
+		return "", err
+	}
+
+	token, err := defaultRandomKeyGenerator(csrfTokenLength)
+	if err != nil {
+		return "", err
+	}
+
+	if err := st.scratchSet(ctx, ss.stored(sessionKey), csrfScratchName, token); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ValidateCSRF checks the token against the CSRF token of the session with the
+// given key, failing with ErrInvalidCSRFToken when they don't match, or when
+// the session has none. Validating the token neither consumes nor rotates the
+// key.
+func (ss *SessionStorage) ValidateCSRF(sessionKey, token string) error {
+	return ss.ValidateCSRFCtx(ss.ctx, sessionKey, token)
+}
+
+// ValidateCSRFCtx is like ValidateCSRF, but the storage operations run on the
+// given context, and the session is checked against the fingerprint or the
+// channel binding it carries, as with GetCtx.
+func (ss *SessionStorage) ValidateCSRFCtx(ctx context.Context, sessionKey, token string) error {
+	v, err := ss.ScratchCtx(ctx, sessionKey).Get(csrfScratchName)
+	if err == ErrNoScratchValue {
+		return ErrInvalidCSRFToken
+	} else if err != nil {
+		return err
+	}
+
+	expected, ok := v.(string)
+	if !ok || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return ErrInvalidCSRFToken
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package suk
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCSRF(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" tokens follow key rotations", func(t *testing.T) {
+			key, _ := ss.Set("session")
+
+			token, err := ss.IssueCSRF(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if len(token) != csrfTokenLength {
+				t.Errorf("got %d characters expected %d", len(token), csrfTokenLength)
+			}
+
+			_, newKey, _ := ss.Get(key)
+
+			if err := ss.ValidateCSRF(newKey, token); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+
+		t.Run(name+" tokens are shared by the session", func(t *testing.T) {
+			key, _ := ss.Set("session")
+
+			first, _ := ss.IssueCSRF(key)
+			second, _ := ss.IssueCSRF(key)
+
+			if first != second {
+				t.Errorf("got %q expected %q", second, first)
+			}
+		})
+
+		t.Run(name+" tokens are bound to their session", func(t *testing.T) {
+			key, _ := ss.Set("session")
+			other, _ := ss.Set("other")
+
+			token, _ := ss.IssueCSRF(key)
+			ss.IssueCSRF(other)
+
+			if err := ss.ValidateCSRF(other, token); err != ErrInvalidCSRFToken {
+				t.Errorf("got %v expected %v", err, ErrInvalidCSRFToken)
+			}
+		})
+	}
+
+	t.Run("Wrong and missing tokens are rejected", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set("session")
+
+		if err := ss.ValidateCSRF(key, "token"); err != ErrInvalidCSRFToken {
+			t.Errorf("got %v expected %v", err, ErrInvalidCSRFToken)
+		}
+
+		ss.IssueCSRF(key)
+		for _, token := range []string{"", "token"} {
+			if err := ss.ValidateCSRF(key, token); err != ErrInvalidCSRFToken {
+				t.Errorf("got %v expected %v", err, ErrInvalidCSRFToken)
+			}
+		}
+	})
+
+	t.Run("Unknown sessions get no token", func(t *testing.T) {
+		ss, _ := New()
+
+		if _, err := ss.IssueCSRF("unknown"); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Backends without scratch space", func(t *testing.T) {
+		ss, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+		defer Destroy(ss)
+		key, _ := ss.Set("session")
+
+		if _, err := ss.IssueCSRF(key); err != ErrScratchUnsupported {
+			t.Errorf("got %v expected %v", err, ErrScratchUnsupported)
+		}
+	})
+}
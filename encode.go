@@ -0,0 +1,132 @@
+package suk
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"time"
+)
+
+var ErrCorruptedEntry = errors.New("The stored entry is corrupted.")
+
+// encodeData encodes the data of an entry for the backends storing bytes, such
+// as SQLite. Custom types must be registered with gob.Register.
+func encodeData(data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeData decodes the data encoded by encodeData.
+func decodeData(raw []byte) (any, error) {
+	var data any
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// wrappedData wraps the data of sessions along with their metadata, such as
+// their ID and creation time, so the backends storing bytes keep them along
+// with the session. Entries without any of them are encoded as is, so entries
+// stored before they existed decode as before.
+type wrappedData struct {
+	Data           any
+	ID             string
+	CreatedAt      time.Time
+	Owner          string
+	TTL            time.Duration
+	Deadline       time.Time
+	Epoch          uint64
+	LastAccessedAt time.Time
+	Rotations      int
+	ClientIP       string
+	UserAgent      string
+	Fingerprint    string
+	Channel        string
+}
+
+func init() {
+	gob.Register(wrappedData{})
+}
+
+// hasMetadata reports whether the entry carries anything besides its data and
+// expiration.
+func hasMetadata(e Entry) bool {
+	// Only the metadata is compared, as the data may not be comparable.
+	e.Data, e.ExpiresAt, e.scratch, e.stale = nil, time.Time{}, nil, false
+	return e != Entry{}
+}
+
+// encodeEntryData encodes the data of the entry along with its metadata, if
+// any. The data is encrypted by the sealer, if any.
+func encodeEntryData(e Entry, s *sealer) ([]byte, error) {
+	var err error
+	if e.Data, err = s.sealData(e.Data); err != nil {
+		return nil, err
+	}
+
+	if !hasMetadata(e) {
+		return encodeData(e.Data)
+	}
+
+	return encodeData(wrappedData{
+		Data:           e.Data,
+		ID:             e.ID,
+		CreatedAt:      e.CreatedAt,
+		Owner:          e.Owner,
+		TTL:            e.TTL,
+		Deadline:       e.Deadline,
+		Epoch:          e.Epoch,
+		LastAccessedAt: e.LastAccessedAt,
+		Rotations:      e.Rotations,
+		ClientIP:       e.ClientIP,
+		UserAgent:      e.UserAgent,
+		Fingerprint:    e.Fingerprint,
+		Channel:        e.Channel,
+	})
+}
+
+// decodeEntryData decodes the data encoded by encodeEntryData into an entry
+// without expiration, decrypting it with the sealer when it was encrypted.
+func decodeEntryData(raw []byte, s *sealer) (Entry, error) {
+	data, err := decodeData(raw)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	wd, wrapped := data.(wrappedData)
+	if wrapped {
+		data = wd.Data
+	}
+
+	data, stale, err := s.openData(data)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if wrapped {
+		return Entry{
+			Data:           data,
+			ID:             wd.ID,
+			CreatedAt:      wd.CreatedAt,
+			Owner:          wd.Owner,
+			TTL:            wd.TTL,
+			Deadline:       wd.Deadline,
+			Epoch:          wd.Epoch,
+			LastAccessedAt: wd.LastAccessedAt,
+			Rotations:      wd.Rotations,
+			ClientIP:       wd.ClientIP,
+			UserAgent:      wd.UserAgent,
+			Fingerprint:    wd.Fingerprint,
+			Channel:        wd.Channel,
+			stale:          stale,
+		}, nil
+	}
+
+	return Entry{Data: data, stale: stale}, nil
+}
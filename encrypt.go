@@ -0,0 +1,374 @@
+package suk
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"time"
+)
+
+var (
+	ErrUnknownEncryptionKey   = errors.New("The session was encrypted with an unknown key.")
+	ErrUnencryptableSession   = errors.New("Only strings and byte slices can be encrypted in Redis without a codec.")
+	ErrReencryptionIncomplete = errors.New("Some sessions could not be encrypted again.")
+)
+
+// sealedPrefix starts every encrypted session, so they can be told apart from
+// the ones stored before encryption was enabled.
+const sealedPrefix = "\x00suk:"
+
+// keyIDSize is how many bytes identify the key a session was encrypted with.
+const keyIDSize = 4
+
+// sealedData holds an encrypted session in the entries of the backends
+// storing bytes.
+type sealedData []byte
+
+func init() {
+	gob.Register(sealedData{})
+}
+
+// sealer encrypts sessions with AES-GCM. Encrypted sessions are made of
+// sealedPrefix, the ID of the key they were encrypted with, a random nonce and
+// the ciphertext.
+type sealer struct {
+	// id and aead are the ones of the current key, which sessions are
+	// encrypted with.
+	id   [keyIDSize]byte
+	aead cipher.AEAD
+
+	// keys holds every key sessions are decrypted with, by their ID, including
+	// the current one.
+	keys map[[keyIDSize]byte]cipher.AEAD
+}
+
+// newSealer returns a sealer encrypting sessions with the given AES key, and
+// decrypting them with it or any of the previous keys. Keys must be 16, 24 or
+// 32 bytes long.
+func newSealer(key []byte, previous ...[]byte) (*sealer, error) {
+	s := &sealer{keys: make(map[[keyIDSize]byte]cipher.AEAD)}
+	for i, k := range append([][]byte{key}, previous...) {
+		id, aead, err := newKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			s.id, s.aead = id, aead
+		}
+
+		if _, ok := s.keys[id]; !ok {
+			s.keys[id] = aead
+		}
+	}
+
+	return s, nil
+}
+
+// newKey returns the ID and the AES-GCM cipher of the key.
+func newKey(key []byte) ([keyIDSize]byte, cipher.AEAD, error) {
+	var id [keyIDSize]byte
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return id, nil, ErrInvalidEncryptionKey
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return id, nil, err
+	}
+
+	sum := sha256.Sum256(key)
+	copy(id[:], sum[:])
+	return id, aead, nil
+}
+
+// isSealed reports whether raw was encrypted by a sealer.
+func isSealed(raw []byte) bool {
+	return bytes.HasPrefix(raw, []byte(sealedPrefix))
+}
+
+// seal encrypts plaintext.
+func (s *sealer) seal(plaintext []byte) ([]byte, error) {
+	header := len(sealedPrefix) + keyIDSize
+	sealed := make([]byte, header+s.aead.NonceSize(), header+s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	copy(sealed, sealedPrefix)
+	copy(sealed[len(sealedPrefix):], s.id[:])
+
+	nonce := sealed[header:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return s.aead.Seal(sealed, nonce, plaintext, nil), nil
+}
+
+// open decrypts the session encrypted by seal, failing with
+// ErrUnknownEncryptionKey when it was encrypted with an unknown key. It
+// reports whether the session was encrypted with a previous key, so it is
+// stale.
+func (s *sealer) open(sealed []byte) (plaintext []byte, stale bool, err error) {
+	if s == nil || !isSealed(sealed) {
+		return nil, false, ErrUnknownEncryptionKey
+	}
+
+	rest := sealed[len(sealedPrefix):]
+	if len(rest) < keyIDSize {
+		return nil, false, ErrCorruptedEntry
+	}
+
+	id := [keyIDSize]byte(rest[:keyIDSize])
+	aead, ok := s.keys[id]
+	if !ok {
+		return nil, false, ErrUnknownEncryptionKey
+	}
+
+	rest = rest[keyIDSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, false, ErrCorruptedEntry
+	}
+
+	plaintext, err = aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, false, ErrCorruptedEntry
+	}
+
+	return plaintext, id != s.id, nil
+}
+
+// sealData returns the data of an entry of the backends storing bytes,
+// encrypted when the sealer isn't nil.
+func (s *sealer) sealData(data any) (any, error) {
+	if s == nil {
+		return data, nil
+	}
+
+	raw, err := encodeData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.seal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return sealedData(sealed), nil
+}
+
+// openData returns the data of an entry of the backends storing bytes,
+// decrypted when it was encrypted, and whether it is stale, as open does.
+func (s *sealer) openData(data any) (any, bool, error) {
+	sealed, ok := data.(sealedData)
+	if !ok {
+		return data, false, nil
+	}
+
+	raw, stale, err := s.open(sealed)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err = decodeData(raw)
+	return data, stale, err
+}
+
+// reseal encrypts the session stored under key again with the current
+// encryption key. Sessions are encrypted again as their keys are rotated, so
+// it is only needed when they aren't. Failures are only logged, as the session
+// is still readable.
+func (ss *SessionStorage) reseal(ctx context.Context, key string) {
+	err := ss.updateEntry(ctx, key, func(old any) (any, error) {
+		return old, nil
+	})
+	if err != nil {
+		ss.config.logger.Warn("suk: could not encrypt session again with the current key", "error", err)
+	}
+}
+
+// ReencryptAll encrypts every session encrypted with a previous encryption key
+// again with the current one, going through the storage, so the previous keys
+// given to WithEncryption can be dropped right away instead of once every
+// session outlived them. It returns how many sessions were encrypted again,
+// and does nothing without WithEncryption.
+//
+// It fails with ErrRangeUnsupported when the backend can't list its sessions.
+// Sessions whose keys are hashed and unknown to this process are only
+// encrypted again by the backends updating them in place, such as Redis, and
+// are otherwise left to be encrypted again once retrieved.
+func (ss *SessionStorage) ReencryptAll(ctx context.Context) (int, error) {
+	if ss.config.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if ss.config.sealer == nil {
+		return 0, nil
+	}
+
+	if err := ss.begin(false); err != nil {
+		return 0, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	type staleEntry struct{ stored, key string }
+
+	var stale []staleEntry
+	err := ss.rangeStored(ctx, func(stored, key string, e Entry) bool {
+		if e.stale && !ss.revoked(e) {
+			stale = append(stale, staleEntry{stored, key})
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	reencrypted := 0
+	var errs []error
+	for _, se := range stale {
+		done, err := ss.reencrypt(ctx, se.stored, se.key)
+		if done {
+			reencrypted++
+		} else if err != nil && err != ErrNoKeyFound && err != ErrKeyWasExpired {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return reencrypted, errors.Join(append([]error{ErrReencryptionIncomplete}, errs...)...)
+	}
+
+	return reencrypted, nil
+}
+
+// reencrypt encrypts the session stored under stored again with the current
+// encryption key, through its key when it is known, so it is locked against
+// rotations, or else through the backend updating it in place. It reports
+// whether the session was encrypted again.
+func (ss *SessionStorage) reencrypt(ctx context.Context, stored, key string) (bool, error) {
+	if key != "" {
+		err := ss.updateEntry(ctx, key, func(old any) (any, error) {
+			return old, nil
+		})
+		return err == nil, err
+	}
+
+	u, ok := ss.storage.(updater)
+	if !ok {
+		return false, nil
+	}
+
+	_, err := u.update(ctx, stored, func(e Entry) (Entry, error) {
+		if time.Until(e.ExpiresAt) <= 0 {
+			return Entry{}, ErrKeyWasExpired
+		}
+
+		if ss.revoked(e) {
+			return Entry{}, ErrNoKeyFound
+		}
+
+		return e, nil
+	})
+	return err == nil, err
+}
+
+// sealedStorage encrypts the data of the entries given to a storage set with
+// WithStorage, when WithEncryption is set, so third-party backends only ever
+// hold encrypted sessions.
+type sealedStorage struct {
+	Storage
+	sealer *sealer
+}
+
+func (s *sealedStorage) Set(ctx context.Context, key string, e Entry) error {
+	var err error
+	if e.Data, err = s.sealer.sealData(e.Data); err != nil {
+		return err
+	}
+
+	return s.Storage.Set(ctx, key, e)
+}
+
+func (s *sealedStorage) Get(ctx context.Context, key string) (Entry, error) {
+	e, err := s.Storage.Get(ctx, key)
+	if err != nil {
+		return e, err
+	}
+
+	return s.open(e)
+}
+
+func (s *sealedStorage) Remove(ctx context.Context, key string) (Entry, error) {
+	e, err := s.Storage.Remove(ctx, key)
+	if err != nil {
+		return e, err
+	}
+
+	return s.open(e)
+}
+
+// open decrypts the data of the entry, when it was encrypted.
+func (s *sealedStorage) open(e Entry) (Entry, error) {
+	var err error
+	if e.Data, e.stale, err = s.sealer.openData(e.Data); err != nil {
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+func (s *sealedStorage) RangePage(ctx context.Context, cursor string, limit int) ([]string, []Entry, string, error) {
+	r, ok := s.Storage.(Ranger)
+	if !ok {
+		return nil, nil, "", ErrRangeUnsupported
+	}
+
+	keys, entries, next, err := r.RangePage(ctx, cursor, limit)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	for i := range entries {
+		if entries[i], err = s.open(entries[i]); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	return keys, entries, next, nil
+}
+
+func (s *sealedStorage) Count(ctx context.Context) (int64, error) {
+	c, ok := s.Storage.(Counter)
+	if !ok {
+		return 0, ErrCountUnsupported
+	}
+
+	return c.Count(ctx)
+}
+
+func (s *sealedStorage) Clear(ctx context.Context) error {
+	c, ok := s.Storage.(Clearer)
+	if !ok {
+		return ErrClearUnsupported
+	}
+
+	return c.Clear(ctx)
+}
+
+func (s *sealedStorage) Sweep(ctx context.Context, cursor string, maxKeys int, deadline time.Time) (string, error) {
+	sw, ok := s.Storage.(Sweeper)
+	if !ok {
+		return "", s.Storage.ClearExpired(ctx)
+	}
+
+	return sw.Sweep(ctx, cursor, maxKeys, deadline)
+}
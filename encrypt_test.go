@@ -0,0 +1,234 @@
+package suk
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestEncryption(t *testing.T) {
+	gob.Register(user{})
+	key := bytes.Repeat([]byte{1}, 32)
+
+	t.Run("Sessions are encrypted in Redis", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, err := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithEncryption(key))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		k, _ := ss.Set("secret")
+		raw, _ := mr.Get(k)
+		e, _ := decodeEntryData([]byte(strings.TrimPrefix(raw, metadataPrefix)), nil)
+		if data, _ := e.Data.([]byte); strings.Contains(raw, "secret") || !isSealed(data) {
+			t.Errorf("got %q expected it encrypted", raw)
+		}
+
+		if got, _, err := ss.Get(k); err != nil || got != "secret" {
+			t.Errorf("got %v and error %v expected %v", got, err, "secret")
+		}
+	})
+
+	t.Run("Sessions are encrypted in the backends storing bytes", func(t *testing.T) {
+		s, _ := newSealer(key)
+
+		raw, err := encodeEntryData(Entry{Data: "secret", ID: "id"}, s)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if bytes.Contains(raw, []byte("secret")) {
+			t.Errorf("got %q expected it encrypted", raw)
+		}
+
+		e, err := decodeEntryData(raw, s)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if e.Data != "secret" || e.ID != "id" {
+			t.Errorf("got %v expected %v", e, Entry{Data: "secret", ID: "id"})
+		}
+	})
+
+	t.Run("Sessions survive SQLite", func(t *testing.T) {
+		ss, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")), WithEncryption(key))
+		defer Destroy(ss)
+
+		k, _ := ss.Set(user{ID: 42})
+		got, _, err := ss.Get(k)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if u, ok := got.(user); !ok || u.ID != 42 {
+			t.Errorf("got %v expected %v", got, user{ID: 42})
+		}
+	})
+
+	t.Run("Sessions are encrypted in storages set with WithStorage", func(t *testing.T) {
+		m := newShardedMap(1)
+		ss, _ := New(WithStorage(basicStorage{m}), WithEncryption(key))
+
+		k, _ := ss.Set(user{ID: 42})
+		for _, e := range m.snapshot() {
+			if _, ok := e.Data.(sealedData); !ok {
+				t.Errorf("got %v expected it encrypted", e.Data)
+			}
+		}
+
+		got, _, err := ss.Get(k)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if u, ok := got.(user); !ok || u.ID != 42 {
+			t.Errorf("got %v expected %v", got, user{ID: 42})
+		}
+	})
+
+	t.Run("Sessions stored before encryption are read", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		plain, _ := New(WithRedis(client, nil))
+		encrypted, _ := New(WithRedis(client, nil), WithEncryption(key))
+
+		k, _ := plain.Set("session")
+		if got, _, err := encrypted.Get(k); err != nil || got != "session" {
+			t.Errorf("got %v and error %v expected %v", got, err, "session")
+		}
+	})
+
+	t.Run("Sessions encrypted with other keys are not read", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithEncryption(key))
+		other, _ := New(WithRedis(client, nil), WithEncryption(bytes.Repeat([]byte{2}, 32)))
+
+		k, _ := ss.Set("session")
+		if _, _, err := other.Get(k); !errors.Is(err, ErrUnknownEncryptionKey) {
+			t.Errorf("got %v expected %v", err, ErrUnknownEncryptionKey)
+		}
+	})
+
+	t.Run("Sessions encrypted with previous keys are read and encrypted again", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		current := bytes.Repeat([]byte{2}, 32)
+		old, _ := New(WithRedis(client, nil), WithEncryption(key))
+		ss, _ := New(WithRedis(client, nil), WithEncryption(current, key))
+		onlyCurrent, _ := New(WithRedis(client, nil), WithEncryption(current))
+
+		k, _ := old.Set("session")
+		got, newKey, err := ss.Get(k)
+		if err != nil || got != "session" {
+			t.Fatalf("got %v and error %v expected %v", got, err, "session")
+		}
+
+		if got, _, err := onlyCurrent.Get(newKey); err != nil || got != "session" {
+			t.Errorf("got %v and error %v expected %v", got, err, "session")
+		}
+	})
+
+	t.Run("Sessions are encrypted again without key rotation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.db")
+		current := bytes.Repeat([]byte{2}, 32)
+
+		old, _ := New(WithSQLite(path), WithEncryption(key))
+		k, _ := old.Set("session")
+		Destroy(old)
+
+		ss, _ := New(WithSQLite(path), WithEncryption(current, key), WithoutKeyRotation())
+		if got, _, err := ss.Get(k); err != nil || got != "session" {
+			t.Fatalf("got %v and error %v expected %v", got, err, "session")
+		}
+		Destroy(ss)
+
+		onlyCurrent, _ := New(WithSQLite(path), WithEncryption(current))
+		defer Destroy(onlyCurrent)
+
+		if got, _, err := onlyCurrent.Get(k); err != nil || got != "session" {
+			t.Errorf("got %v and error %v expected %v", got, err, "session")
+		}
+	})
+
+	t.Run("Every session is encrypted again", func(t *testing.T) {
+		current := bytes.Repeat([]byte{2}, 32)
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		path := filepath.Join(t.TempDir(), "sessions.db")
+
+		backends := map[string]func(opts ...Option) (*SessionStorage, error){
+			"Redis": func(opts ...Option) (*SessionStorage, error) {
+				return New(append(opts, WithRedis(client, nil), WithHashedKeys())...)
+			},
+			"SQLite": func(opts ...Option) (*SessionStorage, error) {
+				return New(append(opts, WithSQLite(path))...)
+			},
+		}
+
+		for name, backend := range backends {
+			old, _ := backend(WithEncryption(key))
+			k1, _ := old.Set("first")
+			k2, _ := old.Set("second")
+			Destroy(old)
+
+			ss, _ := backend(WithEncryption(current, key))
+			if n, err := ss.ReencryptAll(context.Background()); err != nil || n != 2 {
+				t.Errorf("%s: got %d and error %v expected %d", name, n, err, 2)
+			}
+
+			if n, err := ss.ReencryptAll(context.Background()); err != nil || n != 0 {
+				t.Errorf("%s: got %d and error %v expected %d", name, n, err, 0)
+			}
+			Destroy(ss)
+
+			onlyCurrent, _ := backend(WithEncryption(current))
+			for _, k := range []string{k1, k2} {
+				if _, _, err := onlyCurrent.Get(k); err != nil {
+					t.Errorf("%s: got error %s", name, err.Error())
+				}
+			}
+			Destroy(onlyCurrent)
+		}
+	})
+
+	t.Run("Sessions can't be encrypted again by read-only storages", func(t *testing.T) {
+		ss, _ := NewReadOnly(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")), WithEncryption(key))
+		defer Destroy(ss)
+
+		if _, err := ss.ReencryptAll(context.Background()); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+	})
+
+	t.Run("Redis only encrypts strings and byte slices without a codec", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithEncryption(key))
+
+		if _, err := ss.Set(42); !errors.Is(err, ErrUnencryptableSession) {
+			t.Errorf("got %v expected %v", err, ErrUnencryptableSession)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithEncryption([]byte("short"))); !errors.Is(err, ErrInvalidEncryptionKey) {
+			t.Errorf("got %v expected %v", err, ErrInvalidEncryptionKey)
+		}
+
+		if _, err := New(WithEncryption(key, []byte("short"))); !errors.Is(err, ErrInvalidEncryptionKey) {
+			t.Errorf("got %v expected %v", err, ErrInvalidEncryptionKey)
+		}
+
+		if _, err := New(WithEncryption(key), WithEncryption(key)); !errors.Is(err, ErrEncryptionAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrEncryptionAlreadySet)
+		}
+	})
+}
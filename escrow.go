@@ -0,0 +1,76 @@
+package suk
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"time"
+)
+
+// escrowLabel binds the escrowed keys to their purpose, so they can't be
+// passed off as other messages encrypted to the same escrow key.
+var escrowLabel = []byte("suk key escrow")
+
+// EscrowRecord is a key delivered to the escrow sink, encrypted with RSA-OAEP
+// and SHA-256 to the escrow public key, so only the holder of the escrow
+// private key can recover it, with DecryptEscrowedKey.
+type EscrowRecord struct {
+	EncryptedKey []byte
+	IssuedAt     time.Time
+	ExpiresAt    time.Time
+
+	// Rotated tells keys issued by rotating a key, on Get, apart from keys
+	// issued to new sessions, on Set.
+	Rotated bool
+
+	// Owner is the owner of the session, as told by the function given to
+	// WithIdentityFunc, if any.
+	Owner string
+}
+
+// EscrowSink receives the escrowed keys, such as a compliance archive.
+type EscrowSink interface {
+	Escrow(ctx context.Context, record EscrowRecord) error
+}
+
+// DecryptEscrowedKey recovers the key escrowed in the record with the escrow
+// private key.
+func DecryptEscrowedKey(privateKey *rsa.PrivateKey, record EscrowRecord) (string, error) {
+	key, err := rsa.DecryptOAEP(sha256.New(), nil, privateKey, record.EncryptedKey, escrowLabel)
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+// escrow delivers the key issued for the entry to the escrow sink, if there's
+// any. When it fails, the key is removed, so no key is ever issued without
+// being escrowed. Every escrowed key is recorded in the log.
+func (ss *SessionStorage) escrow(ctx context.Context, key string, e Entry, rotated bool) error {
+	if ss.config.escrowSink == nil {
+		return nil
+	}
+
+	owner := ss.entryOwner(e)
+	encrypted, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, ss.config.escrowKey, []byte(key), escrowLabel)
+	if err == nil {
+		err = ss.config.escrowSink.Escrow(ctx, EscrowRecord{
+			EncryptedKey: encrypted,
+			IssuedAt:     time.Now(),
+			ExpiresAt:    e.ExpiresAt,
+			Rotated:      rotated,
+			Owner:        owner,
+		})
+	}
+
+	if err != nil {
+		ss.config.logger.Error("suk: key escrow failed, revoking the key", "rotated", rotated, "owner", owner, "error", err)
+		ss.storage.Remove(ctx, ss.stored(key))
+		return err
+	}
+
+	ss.config.logger.Info("suk: key escrowed", "rotated", rotated, "owner", owner, "expires_at", e.ExpiresAt)
+	return nil
+}
@@ -0,0 +1,92 @@
+package suk
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// recordingSink is an escrow sink keeping every record, or failing with err.
+type recordingSink struct {
+	records []EscrowRecord
+	err     error
+}
+
+func (r *recordingSink) Escrow(_ context.Context, record EscrowRecord) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.records = append(r.records, record)
+	return nil
+}
+
+func TestKeyEscrow(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Nil public key", func(t *testing.T) {
+		_, err := New(WithKeyEscrow(nil, &recordingSink{}))
+
+		if !errors.Is(err, ErrNilEscrowKey) {
+			t.Errorf("got %v expected %v", err, ErrNilEscrowKey)
+		}
+	})
+
+	t.Run("Nil sink", func(t *testing.T) {
+		_, err := New(WithKeyEscrow(&privateKey.PublicKey, nil))
+
+		if !errors.Is(err, ErrNilEscrowSink) {
+			t.Errorf("got %v expected %v", err, ErrNilEscrowSink)
+		}
+	})
+
+	t.Run("Issued keys are escrowed", func(t *testing.T) {
+		sink := &recordingSink{}
+		ss, _ := New(WithKeyEscrow(&privateKey.PublicKey, sink), WithLogger(logger))
+
+		key, _ := ss.Set("session")
+		_, newKey, _ := ss.Get(key)
+
+		if len(sink.records) != 2 {
+			t.Fatalf("got %d expected %d", len(sink.records), 2)
+		}
+
+		for i, expected := range []string{key, newKey} {
+			got, err := DecryptEscrowedKey(privateKey, sink.records[i])
+			if err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if got != expected {
+				t.Errorf("got %q expected %q", got, expected)
+			}
+
+			if sink.records[i].Rotated != (i == 1) {
+				t.Errorf("got %v expected %v", sink.records[i].Rotated, i == 1)
+			}
+		}
+	})
+
+	t.Run("Keys are revoked when escrow fails", func(t *testing.T) {
+		sink := &recordingSink{err: errors.New("sink is down")}
+		sm := newShardedMap(1)
+		ss, _ := New(WithStorage(sm), WithKeyEscrow(&privateKey.PublicKey, sink), WithLogger(logger))
+
+		if _, err := ss.Set("session"); !errors.Is(err, sink.err) {
+			t.Errorf("got %v expected %v", err, sink.err)
+		}
+
+		if n := sm.len(); n != 0 {
+			t.Errorf("got %d keys expected %d", n, 0)
+		}
+	})
+}
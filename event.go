@@ -0,0 +1,208 @@
+package suk
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind tells what happened to a session.
+type EventKind int
+
+const (
+	// EventCreated is emitted when a session is set.
+	EventCreated EventKind = iota + 1
+
+	// EventRotated is emitted when the key of a session is rotated.
+	EventRotated
+
+	// EventExpired is emitted when an expired session is dropped, either by
+	// trying to rotate its key or by clearing the expired keys of the
+	// in-memory storage.
+	EventExpired
+
+	// EventRemoved is emitted when a session is removed, such as by Remove,
+	// Pop or a revocation.
+	EventRemoved
+
+	// EventReplayDetected is emitted when a consumed key is presented again,
+	// as detected with WithReplayDetection, once its session was revoked.
+	EventReplayDetected
+
+	// EventRetrieved is emitted when a session is retrieved by Get without
+	// rotating its key, such as with WithoutKeyRotation.
+	EventRetrieved
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventCreated:
+		return "created"
+	case EventRotated:
+		return "rotated"
+	case EventExpired:
+		return "expired"
+	case EventRemoved:
+		return "removed"
+	case EventReplayDetected:
+		return "replay detected"
+	case EventRetrieved:
+		return "retrieved"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something that happened to a session, as given to the
+// function set with WithAuditLogger, to the hooks set with WithHooks and
+// through the channel returned by Events. Keys are only given as their SHA-256
+// hash, so events can be correlated with each other and with the keys held by
+// clients, but can't be used to retrieve the sessions.
+type Event struct {
+	Kind EventKind
+
+	// KeyHash is the hash of the key of the session, which is the consumed
+	// one for EventRotated and the replayed one for EventReplayDetected.
+	KeyHash string
+
+	// NewKeyHash is the hash of the key the session was rotated to, for
+	// EventRotated.
+	NewKeyHash string
+
+	// SessionID identifies the session across rotations, as returned by
+	// SessionID, for the backends keeping it.
+	SessionID string
+
+	// Owner is the owner of the session, if known.
+	Owner string
+
+	// Time is when the event happened.
+	Time time.Time
+
+	// Session is the session dropped, as given to OnExpire and OnRemove when
+	// Hooks.Payload is set, after going through Hooks.Redact. It is nil
+	// otherwise.
+	Session any
+}
+
+// listening reports whether anybody is told about the events.
+func (ss *SessionStorage) listening() bool {
+	return ss.config.auditLogger != nil || ss.currentPolicy().config.hooks != nil || ss.events != nil
+}
+
+// emit tells about the event of kind for the session of the entry, stored
+// under key, and rotated to newKey, if not empty.
+func (ss *SessionStorage) emit(kind EventKind, key, newKey string, e Entry) {
+	if !ss.listening() {
+		return
+	}
+
+	ev := Event{Kind: kind, KeyHash: lookupKey(key), SessionID: e.ID, Owner: ss.entryOwner(e), Time: time.Now()}
+	if newKey != "" {
+		ev.NewKeyHash = lookupKey(newKey)
+	}
+
+	ss.dispatch(ev, e.Data)
+}
+
+// expiredStored tells about the expired entry dropped by the in-memory
+// storage, which only knows the hash of its key.
+func (ss *SessionStorage) expiredStored(stored string, e Entry) {
+	if !ss.listening() {
+		return
+	}
+
+	ss.dispatch(Event{Kind: EventExpired, KeyHash: stored, SessionID: e.ID, Owner: ss.entryOwner(e), Time: time.Now()}, e.Data)
+}
+
+// removedStored tells about the entry removed under stored, when only the hash
+// of its key is known.
+func (ss *SessionStorage) removedStored(stored string, e Entry) {
+	if !ss.listening() {
+		return
+	}
+
+	ss.dispatch(Event{Kind: EventRemoved, KeyHash: stored, SessionID: e.ID, Owner: ss.entryOwner(e), Time: time.Now()}, e.Data)
+}
+
+// dispatch hands the event to the audit logger, to its hook and to the event
+// stream. Only the hook may be given the session.
+func (ss *SessionStorage) dispatch(ev Event, session any) {
+	if ss.config.auditLogger != nil {
+		ss.config.auditLogger(ev)
+	}
+
+	if hooks := ss.currentPolicy().config.hooks; hooks != nil {
+		hooks.call(ev, session)
+	}
+
+	if ss.events != nil {
+		ss.events.send(ev)
+	}
+}
+
+// Events returns the channel the events are sent through, when WithEventBuffer
+// is set, so they can be handled in goroutines of their own. Events are never
+// waited on: the ones arriving while the buffer is full are dropped, as
+// counted by DroppedEvents. The channel is closed by Close.
+//
+// Without WithEventBuffer, the channel is nil, so receiving from it blocks
+// forever.
+func (ss *SessionStorage) Events() <-chan Event {
+	if ss.events == nil {
+		return nil
+	}
+
+	return ss.events.ch
+}
+
+// DroppedEvents returns how many events were dropped because the buffer of the
+// channel returned by Events was full.
+func (ss *SessionStorage) DroppedEvents() uint64 {
+	if ss.events == nil {
+		return 0
+	}
+
+	return ss.events.dropped.Load()
+}
+
+// eventStream buffers the events until they are received from its channel.
+type eventStream struct {
+	dropped atomic.Uint64
+
+	// mu guards closing the channel, so no event is sent to it afterwards.
+	mu     sync.RWMutex
+	ch     chan Event
+	closed bool
+}
+
+func newEventStream(size int) *eventStream {
+	return &eventStream{ch: make(chan Event, size)}
+}
+
+// send buffers the event, dropping it if the buffer is full.
+func (es *eventStream) send(ev Event) {
+	es.mu.RLock()
+	defer es.mu.RUnlock()
+
+	if es.closed {
+		return
+	}
+
+	select {
+	case es.ch <- ev:
+	default:
+		es.dropped.Add(1)
+	}
+}
+
+// close closes the channel, once the buffered events are received.
+func (es *eventStream) close() {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if !es.closed {
+		es.closed = true
+		close(es.ch)
+	}
+}
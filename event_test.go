@@ -0,0 +1,184 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestWithAuditLogger(t *testing.T) {
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithAuditLogger(nil)); !errors.Is(err, ErrNilAuditLogger) {
+			t.Errorf("got %v expected %v", err, ErrNilAuditLogger)
+		}
+
+		fn := func(Event) {}
+		if _, err := New(WithAuditLogger(fn), WithAuditLogger(fn)); !errors.Is(err, ErrAuditLoggerAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrAuditLoggerAlreadySet)
+		}
+	})
+
+	t.Run("Lifecycle events", func(t *testing.T) {
+		var events []Event
+		ss, _ := New(WithAuditLogger(func(ev Event) { events = append(events, ev) }))
+
+		before := time.Now()
+		key, _ := ss.Set(10, WithOwner("user-42"))
+		_, newKey, _ := ss.Get(key)
+		ss.Remove(newKey)
+
+		expected := []Event{
+			{Kind: EventCreated, KeyHash: lookupKey(key), Owner: "user-42"},
+			{Kind: EventRotated, KeyHash: lookupKey(key), NewKeyHash: lookupKey(newKey), Owner: "user-42"},
+			{Kind: EventRemoved, KeyHash: lookupKey(newKey), Owner: "user-42"},
+		}
+
+		if len(events) != len(expected) {
+			t.Fatalf("got %v expected %v", events, expected)
+		}
+
+		for i, ev := range events {
+			if ev.Time.Before(before) {
+				t.Errorf("got %v expected a time after %v", ev.Time, before)
+			}
+
+			if ev.SessionID == "" || ev.SessionID != events[0].SessionID {
+				t.Errorf("got session ID %q expected %q", ev.SessionID, events[0].SessionID)
+			}
+
+			ev.Time, ev.SessionID = time.Time{}, ""
+			if ev != expected[i] {
+				t.Errorf("got %v expected %v", ev, expected[i])
+			}
+		}
+	})
+
+	t.Run("Expired sessions", func(t *testing.T) {
+		var kinds []EventKind
+		ss, _ := New(WithAuditLogger(func(ev Event) { kinds = append(kinds, ev.Kind) }))
+
+		rotated, _ := ss.Set(10, WithTTL(10*time.Millisecond))
+		ss.Set(10, WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		ss.Get(rotated)
+		ss.ClearExpired()
+
+		expected := []EventKind{EventCreated, EventCreated, EventExpired, EventExpired}
+		if len(kinds) != len(expected) {
+			t.Fatalf("got %v expected %v", kinds, expected)
+		}
+
+		for i := range kinds {
+			if kinds[i] != expected[i] {
+				t.Errorf("got %v expected %v", kinds, expected)
+			}
+		}
+	})
+
+	t.Run("Replayed keys", func(t *testing.T) {
+		var replays []Event
+		ss, _ := New(
+			WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+			WithReplayDetection(time.Minute, nil),
+			WithAuditLogger(func(ev Event) {
+				if ev.Kind == EventReplayDetected {
+					replays = append(replays, ev)
+				}
+			}),
+		)
+
+		stolen, _ := ss.Set(10)
+		ss.Get(stolen)
+		ss.Get(stolen)
+
+		if len(replays) != 1 || replays[0].KeyHash != lookupKey(stolen) {
+			t.Errorf("got %v expected one replay of %s", replays, lookupKey(stolen))
+		}
+	})
+
+	t.Run("Keys are never given", func(t *testing.T) {
+		var events []Event
+		ss, _ := New(WithAuditLogger(func(ev Event) { events = append(events, ev) }))
+
+		key, _ := ss.Set(10)
+		for _, ev := range events {
+			if ev.KeyHash == key {
+				t.Errorf("got key %s in event %v", key, ev)
+			}
+		}
+	})
+}
+
+func TestEvents(t *testing.T) {
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithEventBuffer(0)); !errors.Is(err, ErrNonPositiveEventBuffer) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveEventBuffer)
+		}
+
+		if _, err := New(WithEventBuffer(1), WithEventBuffer(1)); !errors.Is(err, ErrEventBufferAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrEventBufferAlreadySet)
+		}
+	})
+
+	t.Run("Without an event buffer", func(t *testing.T) {
+		ss, _ := New()
+
+		if ss.Events() != nil {
+			t.Errorf("got %v expected nil", ss.Events())
+		}
+	})
+
+	t.Run("Events are received in order", func(t *testing.T) {
+		ss, _ := New(WithEventBuffer(8))
+
+		key, _ := ss.Set(10)
+		_, newKey, _ := ss.Get(key)
+
+		created, rotated := <-ss.Events(), <-ss.Events()
+		if created.Kind != EventCreated || created.KeyHash != lookupKey(key) {
+			t.Errorf("got %v expected the creation of %s", created, lookupKey(key))
+		}
+
+		if rotated.Kind != EventRotated || rotated.NewKeyHash != lookupKey(newKey) {
+			t.Errorf("got %v expected the rotation to %s", rotated, lookupKey(newKey))
+		}
+	})
+
+	t.Run("Events past the buffer are dropped", func(t *testing.T) {
+		ss, _ := New(WithEventBuffer(2))
+
+		for range 5 {
+			ss.Set(10)
+		}
+
+		if got := len(ss.Events()); got != 2 {
+			t.Errorf("got %d expected %d", got, 2)
+		}
+
+		if got := ss.DroppedEvents(); got != 3 {
+			t.Errorf("got %d expected %d", got, 3)
+		}
+	})
+
+	t.Run("Close closes the channel", func(t *testing.T) {
+		ss, _ := New(WithEventBuffer(2))
+		ss.Set(10)
+
+		if err := ss.Close(context.Background()); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		var got int
+		for range ss.Events() {
+			got++
+		}
+
+		if got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+	})
+}
@@ -1,15 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/sukhttp"
 )
 
 // This is an example of using suk to store server-side sessions for your users
-// with a syncMap. Most error checking is skipped for brevity, but you should
+// in memory. Most error checking is skipped for brevity, but you should
 // check in your application.
 //
 // We define three simple handlers for an HTTP server:
@@ -25,21 +27,7 @@ type server struct {
 	resource string // Our top-tier SECRET, which normally would be a
 	// DB resource or a file that the user may want to retrieve
 	sessionStorage *suk.SessionStorage
-}
-
-// newAccessTokenCookie creates a new authentication cookie with the value and
-// maxAge given, adding some sane defaults.
-func newAccessTokenCookie(value string, maxAge int) *http.Cookie {
-	cookie := &http.Cookie{
-		Name:     "access-token",
-		Value:    value,
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   maxAge,
-	}
-
-	return cookie
+	cookies        *sukhttp.CookieManager
 }
 
 // getCookie generates a new "access-token" cookie, as in an user login. In an
@@ -47,27 +35,17 @@ func newAccessTokenCookie(value string, maxAge int) *http.Cookie {
 // access to him.
 func (s *server) getCookie(w http.ResponseWriter, r *http.Request) {
 	token, _ := s.sessionStorage.Set(s.resource)
-	http.SetCookie(w, newAccessTokenCookie(token, 0))
+	s.cookies.Write(w, token)
 	fmt.Fprint(w, "Cookie created")
 }
 
-// getResource checks if the session for the given key is valid, and if so,
-// returns the resource to the user.
+// getResource checks if the request carried a valid key, and if so, returns
+// the resource to the user. The middleware already retrieved the session and
+// handed the rotated key back in the cookie.
 func (s *server) getResource(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("access-token")
-
-	if err == http.ErrNoCookie {
-		http.Error(w, "No cookie, no resource", http.StatusUnauthorized)
-		return
-	}
-
-	resourceRaw, newToken, err := s.sessionStorage.Get(cookie.Value)
-
-	if err == suk.ErrNoKeyFound {
-		http.Error(w, "No key in storage", http.StatusNotFound)
-		return
-	} else if err == suk.ErrKeyWasExpired {
-		http.Error(w, "The given key is expired", http.StatusUnauthorized)
+	resourceRaw, ok := sukhttp.Session(r)
+	if !ok {
+		http.Error(w, "No valid cookie, no resource", http.StatusUnauthorized)
 		return
 	}
 
@@ -81,51 +59,61 @@ func (s *server) getResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, newAccessTokenCookie(newToken, 0))
 	fmt.Fprintf(w, "%s", resource)
 }
 
-// removeCookie removes the reference key to the session, if there's any, and
-// returns a blank "access-token" cookie, as in an user logout. In an actual
-// application, you may also perform some custom logout tasks.
+// removeCookie removes the session, if there's any, and returns a blank
+// "access-token" cookie, as in an user logout. The middleware already rotated
+// the key the cookie carried, so the session is removed through the key it was
+// rotated to, which must not be handed back either. In an actual application,
+// you may also perform some custom logout tasks.
 func (s *server) removeCookie(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("access-token")
-
-	if err == http.ErrNoCookie {
-		http.Error(w, "No cookie, why remove?", http.StatusBadRequest)
+	session, ok := sukhttp.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "No valid cookie, why remove?", http.StatusBadRequest)
 		return
 	}
 
-	err = s.sessionStorage.Remove(cookie.Value)
+	s.sessionStorage.Remove(session.Key)
+	sukhttp.Revoke(r)
 
-	http.SetCookie(w, newAccessTokenCookie("", -1))
+	s.cookies.Clear(w)
 	fmt.Fprint(w, "Cookie deleted, reference to resource lost")
 }
 
 func main() {
-	// We are using the default syncMap
+	// We are using the default in-memory storage
 	ss, err := suk.New(
 		suk.WithKeyLength(10),
 		suk.WithKeyDuration(5*time.Minute),
 		suk.WithAutoClearExpiredKeys(),
 	)
-	defer suk.Destroy(ss)
-
 	if err != nil {
 		panic(err)
 	}
+	defer ss.Close(context.Background())
+
+	// Every cookie is written from the same template, so they always match.
+	cookies := sukhttp.NewCookieManager(ss)
+	cookies.Name = "access-token"
+	cookies.SameSite = http.SameSiteStrictMode
 
 	s := &server{
 		mux:            http.NewServeMux(),
 		resource:       "SECRET",
 		sessionStorage: ss,
+		cookies:        cookies,
 	}
 
 	s.mux.HandleFunc("GET /get_cookie", s.getCookie)
 	s.mux.HandleFunc("GET /access_resource", s.getResource)
 	s.mux.HandleFunc("DELETE /remove_cookie", s.removeCookie)
 
-	if err := http.ListenAndServe(":8080", s.mux); err != nil {
+	// The middleware retrieves the session of each request carrying the cookie,
+	// rotating its key.
+	handler := sukhttp.Middleware(ss, sukhttp.WithCookieManager(cookies))(s.mux)
+
+	if err := http.ListenAndServe(":8080", handler); err != nil {
 		fmt.Printf("Server could not start. err=%s\n", err.Error())
 	}
 }
@@ -0,0 +1,246 @@
+package suk
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// expiryReader is implemented by storages that can tell when the entry stored
+// under key expires without reading its data. ExpiresAt fails with
+// ErrNoKeyFound when there's no entry. Storages not implementing it have their
+// entries read with Get instead.
+type expiryReader interface {
+	expiresAt(ctx context.Context, key string) (time.Time, error)
+}
+
+func (m *shardedMap) expiresAt(_ context.Context, key string) (time.Time, error) {
+	e, ok := m.load(key)
+	if !ok {
+		return time.Time{}, ErrNoKeyFound
+	}
+
+	return e.ExpiresAt, nil
+}
+
+func (r *redisDB) expiresAt(ctx context.Context, key string) (time.Time, error) {
+	e, err := r.lookup(key, func(key string) (Entry, error) {
+		ttl, err := r.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return Entry{}, err
+		}
+
+		// Redis replies with -2 for missing keys, and with -1 for keys that
+		// never expire, which sessions always do.
+		if ttl < 0 {
+			return Entry{}, ErrNoKeyFound
+		}
+
+		return Entry{ExpiresAt: time.Now().Add(ttl)}, nil
+	})
+
+	return e.ExpiresAt, err
+}
+
+func (b *bufferedStorage) expiresAt(ctx context.Context, key string) (time.Time, error) {
+	expiresAt, err := b.local.expiresAt(ctx, key)
+	if err != ErrNoKeyFound {
+		return expiresAt, err
+	}
+
+	return b.redisDB.expiresAt(ctx, key)
+}
+
+func (m *multiRegionRedis) expiresAt(ctx context.Context, key string) (time.Time, error) {
+	for _, client := range []*redis.Client{m.nearest, m.other} {
+		fields, err := client.HMGet(ctx, key, "tombstone", "expires").Result()
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		tombstone, _ := fields[0].(string)
+		expires, _ := fields[1].(string)
+		if tombstone == "1" {
+			return time.Time{}, ErrNoKeyFound
+		}
+
+		if expires == "" {
+			continue
+		}
+
+		ns, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return time.Unix(0, ns), nil
+	}
+
+	return time.Time{}, ErrNoKeyFound
+}
+
+func (s *sqliteDB) expiresAt(ctx context.Context, key string) (time.Time, error) {
+	var expiresAt int64
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT expires_at FROM suk_sessions WHERE key = ?`,
+		key,
+	).Scan(&expiresAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrNoKeyFound
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, expiresAt), nil
+}
+
+// Exists reports whether key holds a live session, without consuming nor
+// rotating the key, and without reading the session itself where the backend
+// allows it, as in memory, Redis and SQLite. Keys consumed during their
+// rotation grace period still exist, as Get accepts them.
+func (ss *SessionStorage) Exists(key string) (bool, error) {
+	return ss.ExistsCtx(ss.ctx, key)
+}
+
+// ExistsCtx is like Exists, but the storage operations run on the given
+// context, so per-request deadlines and cancellation propagate to the backend.
+func (ss *SessionStorage) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	if err := ss.begin(false); err != nil {
+		return false, err
+	}
+	defer ss.end()
+
+	if err := ss.checkLookups(ctx); err != nil {
+		return false, err
+	}
+
+	ok, err := ss.live(ctx, key)
+	if err == nil && !ok {
+		ss.lookedUp(ctx, ErrNoKeyFound)
+	}
+
+	return ok, err
+}
+
+// live reports whether key holds a live session, or was consumed during its
+// grace period.
+func (ss *SessionStorage) live(ctx context.Context, key string) (bool, error) {
+	// Foreign keys can't be stored, so they never exist.
+	if !ss.currentPolicy().accepts(key) {
+		return false, nil
+	}
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	if err := ss.checkLockdown(ctx, key); err != nil {
+		return false, err
+	}
+
+	ok, err := ss.exists(ctx, key)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	_, _, graced := ss.graced(ctx, key)
+	return graced, nil
+}
+
+// exists reports whether key holds a live session.
+func (ss *SessionStorage) exists(ctx context.Context, key string) (bool, error) {
+	_, err := ss.expiresAt(ctx, key)
+	if err == ErrNoKeyFound || err == ErrKeyWasExpired || err == ErrInvalidToken {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// ExpiresAt returns when the key expires, without consuming nor rotating it,
+// such as for setting the Expires or Max-Age of the cookie holding it. Keys
+// consumed during their rotation grace period expire along with the key they
+// were rotated to.
+func (ss *SessionStorage) ExpiresAt(key string) (time.Time, error) {
+	return ss.ExpiresAtCtx(ss.ctx, key)
+}
+
+// ExpiresAtCtx is like ExpiresAt, but the storage operations run on the given
+// context.
+func (ss *SessionStorage) ExpiresAtCtx(ctx context.Context, key string) (_ time.Time, err error) {
+	if err := ss.begin(false); err != nil {
+		return time.Time{}, err
+	}
+	defer ss.end()
+
+	if err := ss.checkLookups(ctx); err != nil {
+		return time.Time{}, err
+	}
+	defer func() { ss.lookedUp(ctx, err) }()
+
+	if !ss.currentPolicy().accepts(key) {
+		return time.Time{}, ErrForeignKey
+	}
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	if err := ss.checkLockdown(ctx, key); err != nil {
+		return time.Time{}, err
+	}
+
+	expiresAt, err := ss.expiresAt(ctx, key)
+	if err != ErrNoKeyFound {
+		return expiresAt, err
+	}
+
+	if e, _, ok := ss.graced(ctx, key); ok {
+		return e.ExpiresAt, nil
+	}
+
+	return time.Time{}, ErrNoKeyFound
+}
+
+// expiresAt returns when the live session stored under key expires. Stateless
+// tokens are never stored, so they are opened instead.
+func (ss *SessionStorage) expiresAt(ctx context.Context, key string) (time.Time, error) {
+	if ss.config.tokens != nil {
+		e, err := ss.openToken(key)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return e.ExpiresAt, nil
+	}
+
+	var (
+		expiresAt time.Time
+		err       error
+	)
+
+	// Telling whether sessions were revoked by RevokeCreatedBefore or
+	// InvalidateAll takes their metadata, which only comes along with them.
+	if er, ok := ss.storage.(expiryReader); ok && ss.revokedBefore.Load() == 0 && ss.epoch.Load() == 0 {
+		expiresAt, err = er.expiresAt(ctx, ss.stored(key))
+	} else {
+		var e Entry
+		e, err = ss.storage.Get(ctx, ss.stored(key))
+		if err == nil && ss.revoked(e) {
+			err = ErrNoKeyFound
+		}
+		expiresAt = e.ExpiresAt
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if time.Until(expiresAt) <= 0 {
+		return time.Time{}, ErrKeyWasExpired
+	}
+
+	return expiresAt, nil
+}
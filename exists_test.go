@@ -0,0 +1,171 @@
+package suk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestExists(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage, "SQLite": sqliteStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" stored keys exist", func(t *testing.T) {
+			key, _ := ss.Set(10)
+
+			ok, err := ss.Exists(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if !ok {
+				t.Errorf("got %v expected %v", ok, true)
+			}
+
+			// The key was neither consumed nor rotated.
+			if _, _, err := ss.Get(key); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+
+		t.Run(name+" unknown keys don't exist", func(t *testing.T) {
+			ok, err := ss.Exists("unknown")
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if ok {
+				t.Errorf("got %v expected %v", ok, false)
+			}
+		})
+	}
+
+	t.Run("Expired keys don't exist", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10, WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		if ok, _ := ss.Exists(key); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+	})
+
+	t.Run("Consumed keys don't exist", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+		ss.Get(key)
+
+		if ok, _ := ss.Exists(key); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+	})
+
+	t.Run("Graced keys exist", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+		key, _ := ss.Set(10)
+		ss.Get(key)
+
+		if ok, _ := ss.Exists(key); !ok {
+			t.Errorf("got %v expected %v", ok, true)
+		}
+	})
+
+	t.Run("Multi-region keys exist in both regions", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ssA, regionA := newTestRegion(t, mrA, mrB)
+		ssB, regionB := newTestRegion(t, mrB, mrA)
+
+		key, _ := ssA.Set(10)
+		drain(regionA, regionB)
+
+		if ok, _ := ssB.Exists(key); !ok {
+			t.Errorf("got %v expected %v", ok, true)
+		}
+
+		ssA.Get(key)
+		drain(regionA, regionB)
+
+		if ok, _ := ssB.Exists(key); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+	})
+}
+
+func TestExpiresAt(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Sync map": syncMapStorage,
+		"Redis":    redisStorage,
+		"SQLite":   sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" keys expire after their TTL", func(t *testing.T) {
+			key, _ := ss.Set(10, WithTTL(time.Hour))
+
+			expiresAt, err := ss.ExpiresAt(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if d := time.Until(expiresAt); d <= 59*time.Minute || d > time.Hour {
+				t.Errorf("got %v expected about %v", d, time.Hour)
+			}
+
+			// The key was neither consumed nor rotated.
+			if _, _, err := ss.Get(key); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+
+		t.Run(name+" unknown keys", func(t *testing.T) {
+			if _, err := ss.ExpiresAt("unknown"); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		})
+	}
+
+	t.Run("Expired keys", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10, WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		if _, err := ss.ExpiresAt(key); err != ErrKeyWasExpired {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Keys within their grace period expire with the key they were rotated to", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Second))
+		key, _ := ss.Set(10)
+		_, newKey, _ := ss.Get(key)
+
+		got, _ := ss.ExpiresAt(key)
+		expected, _ := ss.ExpiresAt(newKey)
+		if got.IsZero() || !got.Equal(expected) {
+			t.Errorf("got %v expected %v", got, expected)
+		}
+	})
+
+	t.Run("Foreign keys", func(t *testing.T) {
+		ss, _ := New(WithKeyVersion("v1"))
+
+		if _, err := ss.ExpiresAt("v2.key"); err != ErrForeignKey {
+			t.Errorf("got %v expected %v", err, ErrForeignKey)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package suk
+
+import (
+	"container/heap"
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrNoExpirationIndex = errors.New("The session storage backend doesn't keep an expiration index.")
+
+// SessionExpiration is a live session along with the time it expires at.
+type SessionExpiration struct {
+	ExpiresAt time.Time
+	Session   any
+
+	// Owner is the owner of the session, as given with WithOwner or told by the
+	// function given to WithIdentityFunc, if any.
+	Owner string
+}
+
+// NextExpirations returns the n live sessions expiring the soonest, soonest
+// first, read from the expiration index of the in-memory storage, so external
+// job systems can schedule work precisely, such as warning users before their
+// session expires, instead of polling each session. Keys are not returned, as
+// they are only kept hashed. Other backends fail with ErrNoExpirationIndex.
+func (ss *SessionStorage) NextExpirations(n int) ([]SessionExpiration, error) {
+	m, ok := ss.storage.(*shardedMap)
+	if !ok {
+		return nil, ErrNoExpirationIndex
+	}
+
+	next := m.nextExpirations(n)
+	for i := range next {
+		if next[i].Owner == "" {
+			next[i].Owner = ss.ownerOf(next[i].Session)
+		}
+	}
+
+	return next, nil
+}
+
+// nextExpirations returns the n live entries expiring the soonest, taking the
+// soonest n of each shard and then the soonest n of those.
+func (m *shardedMap) nextExpirations(n int) []SessionExpiration {
+	if n <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var next []SessionExpiration
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+
+		h := slices.Clone(s.expirations)
+		for taken := 0; taken < n && len(h) > 0; {
+			x := heap.Pop(&h).(expiration)
+			if e, ok := s.entries[x.key]; ok && e.ExpiresAt.Equal(x.at) && now.Before(x.at) {
+				next = append(next, SessionExpiration{ExpiresAt: x.at, Session: e.Data, Owner: e.Owner})
+				taken++
+			}
+		}
+
+		s.mu.RUnlock()
+	}
+
+	slices.SortStableFunc(next, func(a, b SessionExpiration) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+
+	return next[:min(n, len(next))]
+}
@@ -0,0 +1,64 @@
+package suk
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextExpirations(t *testing.T) {
+	t.Run("Soonest sessions first", func(t *testing.T) {
+		now := time.Now()
+		offsets := []time.Duration{5 * time.Minute, time.Minute, time.Hour, 2 * time.Minute}
+
+		i := 0
+		ss, _ := New(WithShards(2), WithIdentityFunc(accountID), WithKeyDurationUntil(func(time.Time) time.Time {
+			return now.Add(offsets[i])
+		}))
+
+		for ; i < len(offsets); i++ {
+			ss.Set(account{ID: offsets[i].String()})
+		}
+
+		got, err := ss.NextExpirations(3)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		expected := []time.Duration{time.Minute, 2 * time.Minute, 5 * time.Minute}
+		if len(got) != len(expected) {
+			t.Fatalf("got %d expected %d", len(got), len(expected))
+		}
+
+		for i, offset := range expected {
+			if !got[i].ExpiresAt.Equal(now.Add(offset)) {
+				t.Errorf("got %s expected %s", got[i].ExpiresAt, now.Add(offset))
+			}
+
+			if got[i].Owner != offset.String() {
+				t.Errorf("got %s expected %s", got[i].Owner, offset.String())
+			}
+		}
+	})
+
+	t.Run("Removed sessions are skipped", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+		ss.Set(20)
+		ss.Remove(key)
+
+		got, _ := ss.NextExpirations(5)
+
+		if len(got) != 1 || got[0].Session != 20 {
+			t.Errorf("got %v expected a single session %v", got, 20)
+		}
+	})
+
+	t.Run("Other backends have no expiration index", func(t *testing.T) {
+		ss, _ := New(WithStorage(&countingStorage{shardedMap: newShardedMap(1)}))
+
+		if _, err := ss.NextExpirations(1); !errors.Is(err, ErrNoExpirationIndex) {
+			t.Errorf("got %v expected %v", err, ErrNoExpirationIndex)
+		}
+	})
+}
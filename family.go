@@ -0,0 +1,428 @@
+package suk
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrFindUnsupported = errors.New("The session storage backend can't find sessions by ID.")
+
+// familyPruneInterval is how often the family index drops the sessions that
+// expired.
+const familyPruneInterval = time.Minute
+
+// newSessionID generates the ID of a new session, which its keys share across
+// rotations.
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// familyKey is the current key of a session family, along with the key it is
+// stored under. The key itself is empty when only the stored one is known,
+// such as for the sessions received in a hand-off.
+type familyKey struct {
+	key       string
+	stored    string
+	expiresAt time.Time
+}
+
+// familyIndex keeps track of the current key of each session, by session ID.
+// As the owner index, it only knows about the keys issued, rotated and
+// received by this process, so the backends index the sessions by ID too.
+type familyIndex struct {
+	mu      sync.Mutex
+	current map[string]familyKey
+	pruneAt time.Time
+}
+
+func newFamilyIndex() *familyIndex {
+	return &familyIndex{current: make(map[string]familyKey)}
+}
+
+// issued records key, stored under stored, as the current key of the session
+// id, pruning the expired sessions along the way.
+func (fi *familyIndex) issued(id, key, stored string, expiresAt time.Time) {
+	if id == "" {
+		return
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(fi.pruneAt) {
+		for id, fk := range fi.current {
+			if !now.Before(fk.expiresAt) {
+				delete(fi.current, id)
+			}
+		}
+		fi.pruneAt = now.Add(familyPruneInterval)
+	}
+
+	fi.current[id] = familyKey{key: key, stored: stored, expiresAt: expiresAt}
+}
+
+// removed forgets the session id, unless its current key is no longer the one
+// stored under stored.
+func (fi *familyIndex) removed(id, stored string) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fk, ok := fi.current[id]; ok && fk.stored == stored {
+		delete(fi.current, id)
+	}
+}
+
+// lookup returns the current key of the session id.
+func (fi *familyIndex) lookup(id string) (familyKey, bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fk, ok := fi.current[id]
+	return fk, ok
+}
+
+// keyOf returns the current key of the session id, when known.
+func (fi *familyIndex) keyOf(id string) (string, bool) {
+	fk, ok := fi.lookup(id)
+	return fk.key, ok && fk.key != ""
+}
+
+// reset forgets every session.
+func (fi *familyIndex) reset() {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.current = make(map[string]familyKey)
+}
+
+// SessionID returns the ID of the session stored under key, which stays the
+// same across rotations, without consuming the key. It identifies the session
+// for RevokeSession, such as in a list of active sessions. Third-party storages
+// not keeping the ID of their entries give an empty one.
+func (ss *SessionStorage) SessionID(key string) (string, error) {
+	return ss.SessionIDCtx(ss.ctx, key)
+}
+
+// SessionIDCtx is like SessionID, but the storage operations run on the given
+// context.
+func (ss *SessionStorage) SessionIDCtx(ctx context.Context, key string) (string, error) {
+	if err := ss.begin(false); err != nil {
+		return "", err
+	}
+	defer ss.end()
+
+	if err := ss.checkLookups(ctx); err != nil {
+		return "", err
+	}
+
+	if !ss.currentPolicy().accepts(key) {
+		ss.lookedUp(ctx, ErrForeignKey)
+		return "", ErrForeignKey
+	}
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	e, err := ss.peek(ctx, key)
+	if err != nil {
+		ss.lookedUp(ctx, err)
+		return "", err
+	}
+
+	return e.ID, nil
+}
+
+// RevokeSession revokes the session with the given ID, as returned by
+// SessionID, killing every outstanding key derived from the same login,
+// including the ones in their rotation grace period. Revoking unknown sessions
+// is not an error.
+//
+// Sessions are found through the index the backend keeps of their IDs, so
+// they are revoked whichever instance issued or rotated them. Third-party
+// storages are searched through Ranger when they implement it, and otherwise
+// only the sessions issued, rotated or received by this process are found.
+func (ss *SessionStorage) RevokeSession(sessionID string) error {
+	return ss.RevokeSessionCtx(ss.ctx, sessionID)
+}
+
+// RevokeSessionCtx is like RevokeSession, but the storage operations run on the
+// given context.
+func (ss *SessionStorage) RevokeSessionCtx(ctx context.Context, sessionID string) error {
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := ss.begin(false); err != nil {
+		return err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	return ss.revokeSession(ctx, sessionID)
+}
+
+// revokeSession revokes the session with the given ID. The session may be
+// rotated meanwhile, so it is removed again under its new key until it's gone.
+// Keys already removed are never tried again, so stale index entries can't
+// keep it going.
+func (ss *SessionStorage) revokeSession(ctx context.Context, id string) error {
+	removed := make(map[string]bool)
+	for {
+		stored, err := ss.currentStored(ctx, id)
+		if err == ErrNoKeyFound || removed[stored] {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		removed[stored] = true
+
+		if err := ss.removeStored(ctx, id, stored); err == ErrNoKeyFound {
+			ss.families.removed(id, stored)
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// SessionExists tells whether the session with the given ID, as returned by
+// SessionID, is still live, whichever key it was rotated to since, such as for
+// connections outliving the request that opened them. Sessions are found as
+// with RevokeSession.
+func (ss *SessionStorage) SessionExists(sessionID string) (bool, error) {
+	return ss.SessionExistsCtx(ss.ctx, sessionID)
+}
+
+// SessionExistsCtx is like SessionExists, but the storage operations run on
+// the given context.
+func (ss *SessionStorage) SessionExistsCtx(ctx context.Context, sessionID string) (bool, error) {
+	if err := ss.begin(false); err != nil {
+		return false, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	_, err := ss.currentStored(ctx, sessionID)
+	if err == ErrNoKeyFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Finder may be implemented by storages indexing the sessions by the ID kept
+// along with them, so RevokeSession and SessionExists find the sessions issued
+// or rotated by other instances without going through every session.
+type Finder interface {
+	// FindSession returns the key the session with the given ID was last
+	// stored under, failing with ErrNoKeyFound when there's none. The key
+	// may no longer hold the session, such as once it is rotated or removed.
+	FindSession(ctx context.Context, id string) (string, error)
+}
+
+// FindSession finds nothing, as the sessions kept in memory are only issued,
+// rotated or received by this process, whose family index knows them all.
+func (m *shardedMap) FindSession(_ context.Context, _ string) (string, error) {
+	return "", ErrNoKeyFound
+}
+
+// familyIndexKey returns the key, relative to the prefix of the sessions, of
+// the index entry pointing to the key of the session with the given ID.
+func familyIndexKey(id string) string {
+	return "family:" + id
+}
+
+// FindSession reads the index entry written along with the session, under the
+// previous prefix too while it is being migrated.
+func (r *redisDB) FindSession(ctx context.Context, id string) (string, error) {
+	p := r.prefixes.Load()
+
+	prefixes := []string{p.current}
+	if p.migrating {
+		prefixes = append(prefixes, p.previous)
+	}
+
+	for _, prefix := range prefixes {
+		key, err := r.client.Get(ctx, r.epochPrefix(prefix)+familyIndexKey(id)).Result()
+		if err == redis.Nil {
+			continue
+		}
+
+		return key, err
+	}
+
+	return "", ErrNoKeyFound
+}
+
+// FindSession reads the index entry of the nearest region, falling back to the
+// other one when it wasn't replicated yet.
+func (m *multiRegionRedis) FindSession(ctx context.Context, id string) (string, error) {
+	for _, client := range []*redis.Client{m.nearest, m.other} {
+		key, err := client.HGet(ctx, familyIndexKey(id), "next").Result()
+		if err == redis.Nil {
+			continue
+		}
+
+		return key, err
+	}
+
+	return "", ErrNoKeyFound
+}
+
+func (s *sqliteDB) FindSession(ctx context.Context, id string) (string, error) {
+	var key string
+	err := s.db.QueryRowContext(ctx, "SELECT key FROM suk_families WHERE id = ?", id).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoKeyFound
+	}
+
+	return key, err
+}
+
+func (s *sealedStorage) FindSession(ctx context.Context, id string) (string, error) {
+	f, ok := s.Storage.(Finder)
+	if !ok {
+		return "", ErrFindUnsupported
+	}
+
+	return f.FindSession(ctx, id)
+}
+
+// currentStored returns the key the live session with the given ID is stored
+// under, as known by this process or, failing that, by the backend.
+func (ss *SessionStorage) currentStored(ctx context.Context, id string) (string, error) {
+	stored, _, err := ss.currentEntry(ctx, id)
+	return stored, err
+}
+
+// currentEntry is like currentStored, but returns the session along with the
+// key it is stored under.
+func (ss *SessionStorage) currentEntry(ctx context.Context, id string) (string, Entry, error) {
+	if id == "" {
+		return "", Entry{}, ErrNoKeyFound
+	}
+
+	if fk, ok := ss.families.lookup(id); ok {
+		if e, ok, err := ss.held(ctx, fk.stored, id); err != nil {
+			return "", Entry{}, err
+		} else if ok {
+			return fk.stored, e, nil
+		}
+
+		// The session was rotated or removed by another instance.
+		ss.families.removed(id, fk.stored)
+	}
+
+	if f, ok := ss.storage.(Finder); ok {
+		stored, err := f.FindSession(ctx, id)
+		if err == ErrFindUnsupported {
+			return ss.searchStored(ctx, id)
+		} else if err != nil {
+			return "", Entry{}, err
+		}
+
+		e, ok, err := ss.held(ctx, stored, id)
+		if err != nil || !ok {
+			return "", Entry{}, cmp.Or(err, ErrNoKeyFound)
+		}
+
+		return stored, e, nil
+	}
+
+	return ss.searchStored(ctx, id)
+}
+
+// held returns the session stored under stored, telling whether it is the
+// live session with the given ID.
+func (ss *SessionStorage) held(ctx context.Context, stored, id string) (Entry, bool, error) {
+	e, err := ss.storage.Get(ctx, stored)
+	if err == ErrNoKeyFound {
+		return Entry{}, false, nil
+	} else if err != nil {
+		return Entry{}, false, err
+	}
+
+	return e, e.ID == id && time.Until(e.ExpiresAt) > 0 && !ss.revoked(e), nil
+}
+
+// searchStored goes through every session of ranged storages, returning the
+// live session with the given ID along with the key it is stored under.
+func (ss *SessionStorage) searchStored(ctx context.Context, id string) (string, Entry, error) {
+	r, ok := ss.storage.(Ranger)
+	if !ok {
+		return "", Entry{}, ErrNoKeyFound
+	}
+
+	cursor := ""
+	for {
+		keys, entries, next, err := r.RangePage(ctx, cursor, rangePageSize)
+		if err == ErrRangeUnsupported {
+			return "", Entry{}, ErrNoKeyFound
+		} else if err != nil {
+			return "", Entry{}, err
+		}
+
+		for i, e := range entries {
+			if e.ID == id && time.Until(e.ExpiresAt) > 0 && !ss.revoked(e) {
+				return keys[i], e, nil
+			}
+		}
+
+		if next == "" {
+			return "", Entry{}, ErrNoKeyFound
+		}
+		cursor = next
+	}
+}
+
+// removeStored removes the session with the given ID stored under stored,
+// whose key this process may only know hashed. Sessions bound to a client are
+// removed whatever the client of the context.
+func (ss *SessionStorage) removeStored(ctx context.Context, id, stored string) error {
+	if !ss.hashKeys {
+		_, err := ss.remove(ctx, stored)
+		return err
+	}
+
+	if fk, ok := ss.families.lookup(id); ok && fk.stored == stored && fk.key != "" {
+		_, err := ss.remove(ctx, fk.key)
+		return err
+	}
+
+	e, err := ss.storage.Remove(ctx, stored)
+	if err != nil {
+		return err
+	}
+
+	ss.families.removed(e.ID, stored)
+	ss.removedStored(stored, e)
+
+	if sc, ok := ss.storage.(scratchStorage); ok {
+		return sc.scratchDrop(ctx, stored)
+	}
+
+	return nil
+}
+
+// familyIssued records the key issued for the entry as the current key of its
+// session.
+func (ss *SessionStorage) familyIssued(key string, e Entry) {
+	ss.families.issued(e.ID, key, ss.stored(key), e.ExpiresAt)
+}
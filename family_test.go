@@ -0,0 +1,193 @@
+package suk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// rangedStorage is a third-party storage implementing Ranger but not Finder.
+type rangedStorage struct {
+	Storage
+	Ranger
+}
+
+func TestSessionFamilies(t *testing.T) {
+	t.Run("Session IDs survive rotations", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.Set(10)
+		id, _ := ss.SessionID(key)
+		_, key, _ = ss.Get(key)
+
+		got, err := ss.SessionID(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if id == "" || got != id {
+			t.Errorf("got %q expected %q", got, id)
+		}
+	})
+
+	t.Run("Sessions get their own IDs", func(t *testing.T) {
+		ss, _ := New()
+
+		first, _ := ss.Set(10)
+		second, _ := ss.Set(10)
+		firstID, _ := ss.SessionID(first)
+		secondID, _ := ss.SessionID(second)
+
+		if firstID == secondID {
+			t.Errorf("got %q twice expected different IDs", firstID)
+		}
+	})
+
+	t.Run("Revoking a session kills its current key", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.Set(10)
+		other, _ := ss.Set(20)
+		id, _ := ss.SessionID(key)
+		_, key, _ = ss.Get(key)
+
+		if err := ss.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		if _, _, err := ss.Get(other); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Revoking a session kills its graced keys", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+
+		key, _ := ss.Set(10)
+		id, _ := ss.SessionID(key)
+		ss.Get(key)
+
+		ss.RevokeSession(id)
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Revoking unknown sessions", func(t *testing.T) {
+		ss, _ := New()
+
+		if err := ss.RevokeSession("unknown"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Session IDs are kept by backends storing bytes", func(t *testing.T) {
+		ss, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+		defer Destroy(ss)
+
+		key, _ := ss.Set(10)
+		id, _ := ss.SessionID(key)
+		_, key, _ = ss.Get(key)
+
+		ss.RevokeSession(id)
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions exist across rotations", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.Set(10)
+		id, _ := ss.SessionID(key)
+		_, key, _ = ss.Get(key)
+
+		if ok, err := ss.SessionExists(id); err != nil || !ok {
+			t.Errorf("got %v and %v expected %v", ok, err, true)
+		}
+
+		ss.Remove(key)
+		if ok, _ := ss.SessionExists(id); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+
+		if ok, _ := ss.SessionExists("unknown"); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+	})
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" sessions are revoked whichever instance rotated them", func(t *testing.T) {
+			opt := backend(t)
+			a, _ := New(opt)
+			defer Destroy(a)
+			b, _ := New(opt)
+			defer Destroy(b)
+
+			key, _ := a.Set(10)
+			id, _ := a.SessionID(key)
+			_, key, _ = b.Get(key)
+			_, key, _ = b.Get(key)
+
+			if ok, err := a.SessionExists(id); err != nil || !ok {
+				t.Errorf("got %v and %v expected %v", ok, err, true)
+			}
+
+			if err := a.RevokeSession(id); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if _, _, err := b.Get(key); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+
+			if ok, _ := b.SessionExists(id); ok {
+				t.Errorf("got %v expected %v", ok, false)
+			}
+		})
+	}
+
+	t.Run("Sessions of ranged storages are found by going through them", func(t *testing.T) {
+		m := newShardedMap(1)
+		a, _ := New(WithStorage(rangedStorage{m, m}))
+		b, _ := New(WithStorage(rangedStorage{m, m}))
+
+		key, _ := a.Set(10)
+		id, _ := a.SessionID(key)
+		_, key, _ = b.Get(key)
+
+		if err := a.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := b.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Read-only storages can't revoke sessions", func(t *testing.T) {
+		ss, _ := NewReadOnly()
+
+		if err := ss.RevokeSession("unknown"); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+	})
+
+	t.Run("Expired sessions are pruned from the index", func(t *testing.T) {
+		fi := newFamilyIndex()
+
+		fi.issued("a", "key", "key", time.Now().Add(-time.Second))
+		fi.pruneAt = time.Time{}
+		fi.issued("b", "other", "other", time.Now().Add(time.Minute))
+
+		if _, ok := fi.keyOf("a"); ok {
+			t.Error("expected expired session to be pruned")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package suk
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+)
+
+var ErrFingerprintMismatch = errors.New("The session was set for a client with another fingerprint.")
+
+type fingerprintContextKey struct{}
+
+// FingerprintContext returns a copy of ctx carrying the fingerprint of the
+// client, such as its IP address and User-Agent. Sessions set with it are
+// bound to the fingerprint, so retrieving them with a context carrying another
+// one fails with ErrFingerprintMismatch, which keeps stolen keys from being
+// replayed by other clients. The session is left as it is, so its own client
+// can still use it.
+//
+// An empty fingerprint is a fingerprint of its own, so clients stripping the
+// details it is made of can't use the sessions bound to them, while sessions
+// set with it are not bound. Contexts without a fingerprint, such as the ones
+// of background jobs, are not checked. The fingerprint is kept by every
+// backend, Redis included, across rotations.
+func FingerprintContext(ctx context.Context, fingerprint string) context.Context {
+	return context.WithValue(ctx, fingerprintContextKey{}, fingerprint)
+}
+
+// fingerprintOf returns the fingerprint carried by ctx, hashed so the backends
+// never hold the client details it is made of, and whether it carries one.
+func fingerprintOf(ctx context.Context) (string, bool) {
+	fingerprint, ok := ctx.Value(fingerprintContextKey{}).(string)
+	if !ok {
+		return "", false
+	}
+
+	if fingerprint == "" {
+		return "", true
+	}
+
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// checkFingerprint fails with ErrFingerprintMismatch when the entry is bound to
+// a fingerprint other than the one carried by ctx.
+func checkFingerprint(ctx context.Context, e Entry) error {
+	if e.Fingerprint == "" {
+		return nil
+	}
+
+	fingerprint, ok := fingerprintOf(ctx)
+	if !ok || subtle.ConstantTimeCompare([]byte(fingerprint), []byte(e.Fingerprint)) == 1 {
+		return nil
+	}
+
+	return ErrFingerprintMismatch
+}
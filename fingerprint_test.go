@@ -0,0 +1,178 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestFingerprint(t *testing.T) {
+	syncMapStorage, _ := New()
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}), nil))
+	multiRegionStorage, _ := New(WithMultiRegionRedis(
+		redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		nil,
+	))
+
+	storages := map[string]*SessionStorage{
+		"Sync map":           syncMapStorage,
+		"SQLite":             sqliteStorage,
+		"Redis":              redisStorage,
+		"Multi-region Redis": multiRegionStorage,
+	}
+
+	firefox := FingerprintContext(context.Background(), "Firefox")
+	curl := FingerprintContext(context.Background(), "curl")
+
+	for name, ss := range storages {
+		t.Run(name+" sessions are bound to their fingerprint", func(t *testing.T) {
+			key, _ := ss.SetCtx(firefox, "session")
+
+			if _, _, err := ss.GetCtx(curl, key); err != ErrFingerprintMismatch {
+				t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+			}
+
+			if _, err := ss.PeekCtx(curl, key); err != ErrFingerprintMismatch {
+				t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+			}
+
+			got, key, err := ss.GetCtx(firefox, key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got != "session" {
+				t.Errorf("got %v expected %q", got, "session")
+			}
+
+			if _, _, err := ss.GetCtx(curl, key); err != ErrFingerprintMismatch {
+				t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+			}
+		})
+	}
+
+	t.Run("Fingerprints are hashed", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.SetCtx(firefox, "session")
+
+		e, _ := ss.storage.Get(ss.ctx, ss.stored(key))
+		if e.Fingerprint == "" || e.Fingerprint == "Firefox" {
+			t.Errorf("got %q expected the hash of %q", e.Fingerprint, "Firefox")
+		}
+	})
+
+	t.Run("Contexts without a fingerprint are not checked", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.SetCtx(firefox, "session")
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		key, _ = ss.Set("session")
+		if _, _, err := ss.GetCtx(curl, key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Batches and updates are bound to the fingerprint", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.SetCtx(firefox, "session")
+
+		results, _ := ss.GetManyCtx(curl, []string{key})
+		if err := results[0].Err; err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		err := ss.UpdateCtx(curl, key, func(any) any { return "stolen" })
+		if err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		got, _, err := ss.GetCtx(firefox, key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got != "session" {
+			t.Errorf("got %v expected %q", got, "session")
+		}
+	})
+
+	t.Run("Removals are bound to the fingerprint", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.SetCtx(firefox, "session", WithOwner("alice"))
+
+		if err := ss.RemoveCtx(curl, key); err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		if _, err := ss.PopCtx(curl, key); err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		if err := ss.RemoveManyCtx(curl, []string{key}); !errors.Is(err, ErrFingerprintMismatch) {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		if revoked, err := ss.RevokeAllForOwner(curl, "alice", nil); err != nil || revoked != 1 {
+			t.Errorf("got %d and error %v expected %d", revoked, err, 1)
+		}
+	})
+
+	t.Run("Scratch space is bound to the fingerprint", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.SetCtx(firefox, "session")
+
+		if err := ss.ScratchCtx(curl, key).Set("progress", "50"); err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		if _, err := ss.IssueCSRFCtx(curl, key); err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		if err := ss.AddFlashCtx(curl, key, "saved"); err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		if err := ss.ScratchCtx(firefox, key).Set("progress", "50"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Empty fingerprints don't match", func(t *testing.T) {
+		ss, _ := New()
+		stripped := FingerprintContext(context.Background(), "")
+
+		key, _ := ss.SetCtx(firefox, "session")
+		if _, _, err := ss.GetCtx(stripped, key); err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		key, _ = ss.SetCtx(stripped, "session")
+		if _, _, err := ss.GetCtx(curl, key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Stateless tokens are bound to their fingerprint", func(t *testing.T) {
+		ss, _ := New(WithStatelessTokens(make([]byte, tokenKeySize)))
+		token, _ := ss.SetCtx(firefox, "session")
+
+		if _, _, err := ss.GetCtx(curl, token); err != ErrFingerprintMismatch {
+			t.Errorf("got %v expected %v", err, ErrFingerprintMismatch)
+		}
+
+		if _, _, err := ss.GetCtx(firefox, token); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+}
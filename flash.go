@@ -0,0 +1,111 @@
+package suk
+
+import (
+	"context"
+	"encoding/gob"
+	"slices"
+)
+
+// flashScratchName is the name of the scratch value holding the flashes of a
+// session.
+const flashScratchName = "suk:flashes"
+
+func init() {
+	gob.Register([]any{})
+}
+
+// AddFlash adds v to the flashes of the session with the given key, one-shot
+// values deleted once read with Flashes, such as the messages shown after a
+// redirect. Flashes are kept in the scratch space of the session, so they
+// follow it through key rotations and are destroyed along with it. Adding a
+// flash neither consumes nor rotates the key. As the scratch values, flashes
+// kept in Redis are encoded with encoding/gob, so custom types must be
+// registered with gob.Register. Backends without scratch space fail with
+// ErrScratchUnsupported.
+func (ss *SessionStorage) AddFlash(sessionKey string, v any) error {
+	return ss.AddFlashCtx(ss.ctx, sessionKey, v)
+}
+
+// AddFlashCtx is like AddFlash, but the storage operations run on the given
+// context, and the session is checked against the fingerprint or the channel
+// binding it carries, as with GetCtx.
+func (ss *SessionStorage) AddFlashCtx(ctx context.Context, sessionKey string, v any) error {
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	if v == nil {
+		return ErrNilSession
+	}
+
+	st, ok := ss.storage.(scratchStorage)
+	if !ok {
+		return ErrScratchUnsupported
+	}
+
+	defer ss.locks.lock(sessionKey)()
+
+	flashes, err := ss.flashes(ctx, st, sessionKey)
+	if err != nil {
+		return err
+	}
+
+	// The flashes read are never changed in place, as in memory they are the
+	// ones stored.
+	return st.scratchSet(ctx, ss.stored(sessionKey), flashScratchName, append(slices.Clip(flashes), v))
+}
+
+// Flashes returns the flashes of the session with the given key, oldest
+// first, deleting them. It returns none when the session has none. Reading the
+// flashes neither consumes nor rotates the key.
+func (ss *SessionStorage) Flashes(sessionKey string) ([]any, error) {
+	return ss.FlashesCtx(ss.ctx, sessionKey)
+}
+
+// FlashesCtx is like Flashes, but the storage operations run on the given
+// context, and the session is checked against the fingerprint or the channel
+// binding it carries, as with GetCtx.
+func (ss *SessionStorage) FlashesCtx(ctx context.Context, sessionKey string) ([]any, error) {
+	if ss.config.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	st, ok := ss.storage.(scratchStorage)
+	if !ok {
+		return nil, ErrScratchUnsupported
+	}
+
+	defer ss.locks.lock(sessionKey)()
+
+	flashes, err := ss.flashes(ctx, st, sessionKey)
+	if err != nil || len(flashes) == 0 {
+		return nil, err
+	}
+
+	if err := st.scratchDelete(ctx, ss.stored(sessionKey), flashScratchName); err != nil {
+		return nil, err
+	}
+
+	return flashes, nil
+}
+
+// flashes returns the flashes stored in the scratch space of the live session.
+func (ss *SessionStorage) flashes(ctx context.Context, st scratchStorage, key string) ([]any, error) {
+	if _, err := ss.lookupEntry(ctx, key); err != nil {
+		return nil, err
+	}
+
+	v, err := st.scratchGet(ctx, ss.stored(key), flashScratchName)
+	if err == ErrNoScratchValue {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	flashes, ok := v.([]any)
+	if !ok {
+		return nil, ErrCorruptedEntry
+	}
+
+	return flashes, nil
+}
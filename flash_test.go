@@ -0,0 +1,92 @@
+package suk
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestFlashes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" flashes are read once, oldest first", func(t *testing.T) {
+			key, _ := ss.Set("session")
+
+			for _, v := range []any{"saved", 2} {
+				if err := ss.AddFlash(key, v); err != nil {
+					t.Fatalf("got error %s", err.Error())
+				}
+			}
+
+			got, err := ss.Flashes(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if expected := []any{"saved", 2}; !slices.Equal(got, expected) {
+				t.Errorf("got %v expected %v", got, expected)
+			}
+
+			if got, _ := ss.Flashes(key); got != nil {
+				t.Errorf("got %v expected no flashes", got)
+			}
+		})
+
+		t.Run(name+" flashes follow key rotations", func(t *testing.T) {
+			key, _ := ss.Set("session")
+			ss.AddFlash(key, "saved")
+			_, newKey, _ := ss.Get(key)
+
+			if got, _ := ss.Flashes(newKey); !slices.Equal(got, []any{"saved"}) {
+				t.Errorf("got %v expected %v", got, []any{"saved"})
+			}
+		})
+
+		t.Run(name+" flashes are destroyed along with the session", func(t *testing.T) {
+			key, _ := ss.Set("session")
+			ss.AddFlash(key, "saved")
+			ss.Remove(key)
+
+			if got, _ := ss.Flashes(key); got != nil {
+				t.Errorf("got %v expected no flashes", got)
+			}
+		})
+
+		t.Run(name+" unknown sessions get no flashes", func(t *testing.T) {
+			if err := ss.AddFlash("unknown", "saved"); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		})
+	}
+
+	t.Run("Nil flashes", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set("session")
+
+		if err := ss.AddFlash(key, nil); err != ErrNilSession {
+			t.Errorf("got %v expected %v", err, ErrNilSession)
+		}
+	})
+
+	t.Run("Backends without scratch space", func(t *testing.T) {
+		ss, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+		defer Destroy(ss)
+		key, _ := ss.Set("session")
+
+		if err := ss.AddFlash(key, "saved"); err != ErrScratchUnsupported {
+			t.Errorf("got %v expected %v", err, ErrScratchUnsupported)
+		}
+
+		if _, err := ss.Flashes(key); err != ErrScratchUnsupported {
+			t.Errorf("got %v expected %v", err, ErrScratchUnsupported)
+		}
+	})
+}
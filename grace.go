@@ -0,0 +1,162 @@
+package suk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sessionChain follows a session across its rotations.
+type sessionChain struct {
+	current   string
+	rotatedAt time.Time
+}
+
+// consumedKey is a key consumed by a rotation, still being tracked.
+type consumedKey struct {
+	chain      *sessionChain
+	consumedAt time.Time
+}
+
+// consumedKeys remembers, for a while, the keys consumed by rotations along
+// with the chain of the session they belonged to, so the key each one was
+// eventually rotated to can be found, whatever the amount of rotations since.
+// Consumed keys are kept hashed, as they must never be usable to retrieve the
+// session once they stop being tracked.
+type consumedKeys struct {
+	mu      sync.Mutex
+	window  time.Duration
+	keys    map[string]consumedKey
+	chains  map[string]*sessionChain
+	pruneAt time.Time
+}
+
+func newConsumedKeys(window time.Duration) *consumedKeys {
+	return &consumedKeys{
+		window: window,
+		keys:   make(map[string]consumedKey),
+		chains: make(map[string]*sessionChain),
+	}
+}
+
+// add tracks the key consumed by the rotation to newKey, pruning the keys
+// tracked for longer than the window along the way.
+func (ck *consumedKeys) add(key, newKey string) {
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+
+	now := time.Now()
+	if !now.Before(ck.pruneAt) {
+		ck.prune(now)
+		ck.pruneAt = now.Add(ck.window)
+	}
+
+	id := lookupKey(key)
+	chain, ok := ck.chains[id]
+	if !ok {
+		chain = new(sessionChain)
+	}
+	delete(ck.chains, id)
+
+	chain.current = newKey
+	chain.rotatedAt = now
+	ck.chains[lookupKey(newKey)] = chain
+	ck.keys[id] = consumedKey{chain: chain, consumedAt: now}
+}
+
+// prune stops tracking the keys consumed for longer than the window, along
+// with the chains not rotated since. It must be called with mu held.
+func (ck *consumedKeys) prune(now time.Time) {
+	for id, c := range ck.keys {
+		if now.Sub(c.consumedAt) >= ck.window {
+			delete(ck.keys, id)
+		}
+	}
+
+	for id, chain := range ck.chains {
+		if now.Sub(chain.rotatedAt) >= ck.window {
+			delete(ck.chains, id)
+		}
+	}
+}
+
+// resolve returns the latest key the consumed key was rotated to, along with
+// how long ago it was consumed, if it's still tracked.
+func (ck *consumedKeys) resolve(key string) (string, time.Duration, bool) {
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+
+	c, ok := ck.keys[lookupKey(key)]
+	if !ok {
+		return "", 0, false
+	}
+
+	age := time.Since(c.consumedAt)
+	if age >= ck.window {
+		return "", 0, false
+	}
+
+	return c.chain.current, age, true
+}
+
+// reset stops tracking every consumed key.
+func (ck *consumedKeys) reset() {
+	ck.mu.Lock()
+	defer ck.mu.Unlock()
+
+	ck.keys = make(map[string]consumedKey)
+	ck.chains = make(map[string]*sessionChain)
+}
+
+// trackConsumed tracks the key consumed by the rotation to newKey, when either
+// WithRotationGracePeriod or WithReplayDetection is set.
+func (ss *SessionStorage) trackConsumed(key, newKey string) {
+	if ss.consumedKeys != nil {
+		ss.consumedKeys.add(key, newKey)
+	}
+}
+
+// graced resolves a consumed key still in its grace period, returning the
+// entry it was rotated to along with its current key.
+func (ss *SessionStorage) graced(ctx context.Context, key string) (Entry, string, bool) {
+	if ss.consumedKeys == nil || ss.config.rotationGracePeriod == 0 {
+		return Entry{}, "", false
+	}
+
+	newKey, age, ok := ss.consumedKeys.resolve(key)
+	if !ok || age >= ss.config.rotationGracePeriod {
+		return Entry{}, "", false
+	}
+
+	e, err := ss.peek(ctx, newKey)
+	if err != nil {
+		return Entry{}, "", false
+	}
+
+	return e, newKey, true
+}
+
+// consumed handles a Get of a key that was not found, which may have been
+// consumed by a rotation. Within the grace period, the session it was rotated
+// to is returned, and past it, its reuse is handled as a replay.
+func (ss *SessionStorage) consumed(ctx context.Context, key string) (any, string, error) {
+	e, newKey, err := ss.consumedEntry(ctx, key)
+	if err != nil {
+		return struct{}{}, "", err
+	}
+
+	return e.Data, newKey, nil
+}
+
+// consumedEntry is like consumed, but returns the whole entry.
+func (ss *SessionStorage) consumedEntry(ctx context.Context, key string) (Entry, string, error) {
+	if e, newKey, ok := ss.graced(ctx, key); ok {
+		return e, newKey, nil
+	}
+
+	if err := ss.detectReplay(ctx, key); err != nil {
+		return Entry{}, "", err
+	}
+
+	return Entry{}, "", ErrNoKeyFound
+}
@@ -0,0 +1,122 @@
+package suk
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotationGracePeriod(t *testing.T) {
+	t.Run("Non-positive grace period", func(t *testing.T) {
+		_, err := New(WithRotationGracePeriod(0))
+
+		if !errors.Is(err, ErrNonPositiveRotationGracePeriod) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveRotationGracePeriod)
+		}
+	})
+
+	t.Run("Consumed keys are refused without a grace period", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.Set(10)
+		ss.Get(key)
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Consumed keys map to the rotated key", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+
+		key, _ := ss.Set(10)
+		_, newKey, _ := ss.Get(key)
+
+		session, gracedKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 10 || gracedKey != newKey {
+			t.Errorf("got %v and %q expected %v and %q", session, gracedKey, 10, newKey)
+		}
+
+		// The rotated key was not consumed by the graced Get.
+		if _, _, err := ss.Get(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Graced keys follow later rotations", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+
+		key, _ := ss.Set(10)
+		_, second, _ := ss.Get(key)
+		_, third, _ := ss.Get(second)
+
+		if _, got, _ := ss.Get(key); got != third {
+			t.Errorf("got %q expected %q", got, third)
+		}
+	})
+
+	t.Run("Parallel requests share the session", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+		key, _ := ss.Set(10)
+
+		var (
+			mu   sync.Mutex
+			keys = make(map[string]bool)
+			wg   sync.WaitGroup
+		)
+
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				_, newKey, err := ss.Get(key)
+				if err != nil {
+					t.Errorf("got error %s", err.Error())
+					return
+				}
+
+				mu.Lock()
+				keys[newKey] = true
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if len(keys) != 1 {
+			t.Errorf("got %d keys expected %d", len(keys), 1)
+		}
+	})
+
+	t.Run("Grace period ends", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(10 * time.Millisecond))
+
+		key, _ := ss.Set(10)
+		ss.Get(key)
+		time.Sleep(20 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Removing a graced key ends the session", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+
+		key, _ := ss.Set(10)
+		_, newKey, _ := ss.Get(key)
+
+		if err := ss.Remove(key); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(newKey); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package suk
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrHandoffUnsupported = errors.New("Only sessions kept in memory can be handed off.")
+	ErrEmptyHandoffToken  = errors.New("The given hand-off token is empty.")
+	ErrHandoffRefused     = errors.New("The hand-off was refused by the receiving instance.")
+	ErrHandoffTooLarge    = errors.New("The hand-off holds more sessions than can be received.")
+)
+
+// The limits of the hand-offs received by HandoffHandler, so a sender can't
+// run the receiving instance out of memory, even holding the token.
+var (
+	maxHandoffBytes   int64 = 1 << 30
+	maxHandoffEntries       = 1 << 22
+)
+
+// handoffEntry is a session as streamed during a hand-off. Keys are streamed
+// as stored, so hashed keys are never turned back into usable ones.
+type handoffEntry struct {
+	Key       string
+	Data      any
+	ExpiresAt time.Time
+	ID        string
+	CreatedAt time.Time
+	Owner     string
+	TTL       time.Duration
+	Deadline  time.Time
+	Epoch     uint64
+
+	LastAccessedAt time.Time
+	Rotations      int
+	ClientIP       string
+	UserAgent      string
+	Fingerprint    string
+	Channel        string
+}
+
+// entry returns the entry handed off.
+func (he handoffEntry) entry() Entry {
+	return Entry{
+		Data:           he.Data,
+		ExpiresAt:      he.ExpiresAt,
+		ID:             he.ID,
+		CreatedAt:      he.CreatedAt,
+		Owner:          he.Owner,
+		TTL:            he.TTL,
+		Deadline:       he.Deadline,
+		Epoch:          he.Epoch,
+		LastAccessedAt: he.LastAccessedAt,
+		Rotations:      he.Rotations,
+		ClientIP:       he.ClientIP,
+		UserAgent:      he.UserAgent,
+		Fingerprint:    he.Fingerprint,
+		Channel:        he.Channel,
+	}
+}
+
+// HandOff streams the sessions kept in memory to the instance replacing this
+// one during a rolling deploy, by posting them with client, or
+// http.DefaultClient if nil, to the HandoffHandler served at url,
+// authenticated with token, so sessions survive the deploy without an external
+// store. It returns how many sessions were handed off.
+//
+// Sessions are moved rather than copied: every operation waits for the
+// hand-off, and the sessions handed off are then removed from this instance,
+// so no key can be used on both. Call it once Drain returns, so no new
+// sessions are issued meanwhile. Sessions invalidated or revoked here are not
+// handed off. Sessions are encoded with encoding/gob, so custom types must be
+// registered with gob.Register, and scratch spaces are not handed off.
+func (ss *SessionStorage) HandOff(ctx context.Context, client *http.Client, url, token string) (int, error) {
+	m, ok := ss.storage.(*shardedMap)
+	if !ok {
+		return 0, ErrHandoffUnsupported
+	}
+
+	if token == "" {
+		return 0, ErrEmptyHandoffToken
+	}
+
+	defer ss.locks.lockAll()()
+
+	now := time.Now()
+	entries := m.snapshot()
+	for key, e := range entries {
+		if !now.Before(e.ExpiresAt) || ss.revoked(e) {
+			delete(entries, key)
+		}
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		enc := gob.NewEncoder(w)
+		for key, e := range entries {
+			if err := enc.Encode(handoffEntry{key, e.Data, e.ExpiresAt, e.ID, e.CreatedAt, e.Owner, e.TTL, e.Deadline, e.Epoch, e.LastAccessedAt, e.Rotations, e.ClientIP, e.UserAgent, e.Fingerprint, e.Channel}); err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+		w.Close()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, r)
+	if err != nil {
+		r.Close()
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-gob")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("%w: %s: %s", ErrHandoffRefused, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	for key := range entries {
+		m.delete(key)
+	}
+
+	return len(entries), nil
+}
+
+// HandoffHandler returns the handler receiving the sessions handed off with
+// HandOff by the instance this one replaces, storing them in memory. Requests
+// must be authenticated with token, which should be a long random secret
+// shared by both instances, as whoever holds it can plant sessions. It
+// responds with how many sessions were received. Hand-offs over 1 GiB or
+// holding over 4 million sessions are refused as a whole, with a 413 status.
+//
+// Keys already in use here are skipped, and the owner index of
+// WithIdentityFunc doesn't know about the sessions received, while
+// RevokeSession does.
+func (ss *SessionStorage) HandoffHandler(token string) http.Handler {
+	expected := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		m, ok := ss.storage.(*shardedMap)
+		if !ok || ss.config.readOnly {
+			http.Error(w, ErrHandoffUnsupported.Error(), http.StatusNotImplemented)
+			return
+		}
+
+		// Nothing is stored unless the whole stream is received, as the sender
+		// keeps every session when the hand-off fails.
+		var entries []handoffEntry
+		dec := gob.NewDecoder(http.MaxBytesReader(w, r.Body, maxHandoffBytes))
+		for {
+			var he handoffEntry
+			var tooLarge *http.MaxBytesError
+			if err := dec.Decode(&he); err == io.EOF {
+				break
+			} else if errors.As(err, &tooLarge) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			} else if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if len(entries) == maxHandoffEntries {
+				http.Error(w, ErrHandoffTooLarge.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			entries = append(entries, he)
+		}
+
+		// The sessions were live on the sending instance, so they join the
+		// current epoch here, whatever the epoch they had there.
+		received := 0
+		now := time.Now()
+		epoch := ss.epoch.Load() + 1
+		for _, he := range entries {
+			e := he.entry()
+			e.Epoch = epoch
+			if now.Before(he.ExpiresAt) && m.insertStored(he.Key, e) {
+				ss.families.issued(e.ID, "", he.Key, e.ExpiresAt)
+				ss.owners.add(ss.entryOwner(e), he.Key, e)
+				received++
+			}
+		}
+
+		w.Write([]byte(strconv.Itoa(received)))
+	})
+}
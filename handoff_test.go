@@ -0,0 +1,179 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandOff(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Sessions are moved to the replacing instance", func(t *testing.T) {
+		old, _ := New()
+		replacement, _ := New()
+		srv := httptest.NewServer(replacement.HandoffHandler("secret"))
+		defer srv.Close()
+
+		key, _ := old.Set(42)
+		old.Drain(ctx)
+
+		n, err := old.HandOff(ctx, srv.Client(), srv.URL, "secret")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if n != 1 {
+			t.Errorf("got %d expected %d", n, 1)
+		}
+
+		session, _, err := replacement.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := old.Get(key); !errors.Is(err, ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions join the epoch of the replacing instance", func(t *testing.T) {
+		old, _ := New()
+		replacement, _ := New()
+		srv := httptest.NewServer(replacement.HandoffHandler("secret"))
+		defer srv.Close()
+
+		invalidated, _ := old.Set("invalidated")
+		old.InvalidateAll()
+		key, _ := old.Set(42)
+		replacement.InvalidateAll()
+		replacement.InvalidateAll()
+
+		n, err := old.HandOff(ctx, srv.Client(), srv.URL, "secret")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if n != 1 {
+			t.Errorf("got %d expected %d", n, 1)
+		}
+
+		if _, _, err := replacement.Get(invalidated); !errors.Is(err, ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		if _, _, err := replacement.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Sessions received can be revoked", func(t *testing.T) {
+		old, _ := New()
+		replacement, _ := New()
+		srv := httptest.NewServer(replacement.HandoffHandler("secret"))
+		defer srv.Close()
+
+		key, _ := old.Set(42)
+		id, _ := old.SessionID(key)
+
+		if _, err := old.HandOff(ctx, srv.Client(), srv.URL, "secret"); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if err := replacement.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := replacement.Get(key); !errors.Is(err, ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions received are listed for their owners", func(t *testing.T) {
+		old, _ := New()
+		replacement, _ := New()
+		srv := httptest.NewServer(replacement.HandoffHandler("secret"))
+		defer srv.Close()
+
+		old.Set(42, WithOwner("user-42"))
+		old.Drain(ctx)
+
+		if _, err := old.HandOff(ctx, srv.Client(), srv.URL, "secret"); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got, err := replacement.SessionsForOwner("user-42"); err != nil || len(got) != 1 {
+			t.Errorf("got %v and %v expected one session", got, err)
+		}
+	})
+
+	t.Run("Wrong tokens are refused", func(t *testing.T) {
+		old, _ := New()
+		replacement, _ := New()
+		srv := httptest.NewServer(replacement.HandoffHandler("secret"))
+		defer srv.Close()
+
+		key, _ := old.Set(42)
+
+		if _, err := old.HandOff(ctx, srv.Client(), srv.URL, "guess"); !errors.Is(err, ErrHandoffRefused) {
+			t.Errorf("got %v expected %v", err, ErrHandoffRefused)
+		}
+
+		if _, _, err := old.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Oversized hand-offs are refused", func(t *testing.T) {
+		defer func(bytes int64, entries int) {
+			maxHandoffBytes, maxHandoffEntries = bytes, entries
+		}(maxHandoffBytes, maxHandoffEntries)
+
+		limits := map[string]func(){
+			"bytes":   func() { maxHandoffBytes, maxHandoffEntries = 64, 1<<22 },
+			"entries": func() { maxHandoffBytes, maxHandoffEntries = 1<<30, 1 },
+		}
+
+		for name, limit := range limits {
+			limit()
+
+			old, _ := New()
+			replacement, _ := New()
+			srv := httptest.NewServer(replacement.HandoffHandler("secret"))
+			defer srv.Close()
+
+			keys, _ := old.SetMany([]any{strings.Repeat("a", 100), strings.Repeat("b", 100)})
+			if _, err := old.HandOff(ctx, srv.Client(), srv.URL, "secret"); !errors.Is(err, ErrHandoffRefused) {
+				t.Errorf("%s: got %v expected %v", name, err, ErrHandoffRefused)
+			}
+
+			for _, key := range keys {
+				if ok, _ := replacement.Exists(key); ok {
+					t.Errorf("%s: got %v expected %v", name, ok, false)
+				}
+			}
+		}
+	})
+
+	t.Run("Empty token", func(t *testing.T) {
+		ss, _ := New()
+
+		if _, err := ss.HandOff(ctx, nil, "http://localhost", ""); !errors.Is(err, ErrEmptyHandoffToken) {
+			t.Errorf("got %v expected %v", err, ErrEmptyHandoffToken)
+		}
+	})
+
+	t.Run("Only sessions kept in memory can be handed off", func(t *testing.T) {
+		ss, _ := New(WithStorage(&countingStorage{shardedMap: newShardedMap(1)}))
+
+		if _, err := ss.HandOff(ctx, nil, "http://localhost", "secret"); !errors.Is(err, ErrHandoffUnsupported) {
+			t.Errorf("got %v expected %v", err, ErrHandoffUnsupported)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package suk
+
+// Hooks are the functions called on each transition in the lifecycle of the
+// sessions, as set with WithHooks. Each is given the event describing the
+// transition, which identifies the session by its ID and the hash of its key,
+// but doesn't carry the session itself unless Payload is set. Nil hooks are
+// skipped.
+type Hooks struct {
+	// OnSet is called when a session is set, with an EventCreated.
+	OnSet func(Event)
+
+	// OnGet is called when a session is retrieved by Get, with an
+	// EventRotated, or an EventRetrieved when its key is not rotated.
+	OnGet func(Event)
+
+	// OnExpire is called when an expired session is dropped, with an
+	// EventExpired. As with the audit logger, only the expirations seen by
+	// rotating keys or, for the in-memory storage, by clearing expired keys
+	// are told about.
+	OnExpire func(Event)
+
+	// OnRemove is called when a session is removed, with an EventRemoved,
+	// including when it is revoked because one of its consumed keys was
+	// replayed.
+	OnRemove func(Event)
+
+	// Payload gives OnExpire and OnRemove the session being dropped, in the
+	// Session field of their event, so cleanup logic can use its contents.
+	// The other hooks, the audit logger and the channel returned by Events
+	// are never given it.
+	Payload bool
+
+	// Redact returns what OnExpire and OnRemove are given of the session when
+	// Payload is set, such as a copy without its secrets. It must not modify
+	// the session it is given, which may still be returned, such as by Pop.
+	Redact func(session any) any
+
+	// Async calls each hook in a goroutine of its own, so slow hooks don't
+	// hold up the operations. Otherwise, hooks are called while the operation
+	// is in flight, so they must be quick and must not use the session
+	// storage.
+	Async bool
+}
+
+// call calls the hook for the kind of the event, if any, giving it the session
+// when it is dropped and Payload is set.
+func (h *Hooks) call(ev Event, session any) {
+	var hook func(Event)
+	switch ev.Kind {
+	case EventCreated:
+		hook = h.OnSet
+	case EventRotated, EventRetrieved:
+		hook = h.OnGet
+	case EventExpired:
+		hook = h.OnExpire
+	case EventRemoved:
+		hook = h.OnRemove
+	}
+
+	if hook == nil {
+		return
+	}
+
+	if h.Payload && (ev.Kind == EventExpired || ev.Kind == EventRemoved) {
+		ev.Session = session
+		if h.Redact != nil && session != nil {
+			ev.Session = h.Redact(session)
+		}
+	}
+
+	if h.Async {
+		go hook(ev)
+		return
+	}
+
+	hook(ev)
+}
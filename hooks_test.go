@@ -0,0 +1,130 @@
+package suk
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithHooks(t *testing.T) {
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithHooks(Hooks{Async: true})); !errors.Is(err, ErrNoHooks) {
+			t.Errorf("got %v expected %v", err, ErrNoHooks)
+		}
+
+		hooks := Hooks{OnSet: func(Event) {}}
+		if _, err := New(WithHooks(hooks), WithHooks(hooks)); !errors.Is(err, ErrHooksAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrHooksAlreadySet)
+		}
+
+		hooks.Redact = func(session any) any { return session }
+		if _, err := New(WithHooks(hooks)); !errors.Is(err, ErrRedactWithoutPayload) {
+			t.Errorf("got %v expected %v", err, ErrRedactWithoutPayload)
+		}
+	})
+
+	t.Run("Each transition calls its hook", func(t *testing.T) {
+		var calls []string
+		record := func(name string) func(Event) {
+			return func(Event) { calls = append(calls, name) }
+		}
+
+		ss, _ := New(WithHooks(Hooks{
+			OnSet:    record("set"),
+			OnGet:    record("get"),
+			OnExpire: record("expire"),
+			OnRemove: record("remove"),
+		}))
+
+		key, _ := ss.Set(10)
+		_, key, _ = ss.Get(key)
+		ss.Remove(key)
+
+		expiring, _ := ss.Set(10, WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+		ss.Get(expiring)
+
+		expected := []string{"set", "get", "remove", "set", "expire"}
+		if len(calls) != len(expected) {
+			t.Fatalf("got %v expected %v", calls, expected)
+		}
+
+		for i := range calls {
+			if calls[i] != expected[i] {
+				t.Errorf("got %v expected %v", calls, expected)
+			}
+		}
+	})
+
+	t.Run("Gets without rotation", func(t *testing.T) {
+		var kinds []EventKind
+		ss, _ := New(WithoutKeyRotation(), WithHooks(Hooks{OnGet: func(ev Event) { kinds = append(kinds, ev.Kind) }}))
+
+		key, _ := ss.Set(10)
+		ss.Get(key)
+
+		if len(kinds) != 1 || kinds[0] != EventRetrieved {
+			t.Errorf("got %v expected %v", kinds, []EventKind{EventRetrieved})
+		}
+	})
+
+	t.Run("Dropped sessions are given to the hooks asking for them", func(t *testing.T) {
+		var sessions []any
+		record := func(ev Event) { sessions = append(sessions, ev.Session) }
+
+		ss, _ := New(WithHooks(Hooks{
+			OnSet:    record,
+			OnRemove: record,
+			OnExpire: record,
+			Payload:  true,
+			Redact:   func(session any) any { return session.(int) * 10 },
+		}))
+
+		key, _ := ss.Set(1)
+		ss.Remove(key)
+
+		expiring, _ := ss.Set(2, WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+		ss.Get(expiring)
+
+		expected := []any{nil, 10, nil, 20}
+		if len(sessions) != len(expected) {
+			t.Fatalf("got %v expected %v", sessions, expected)
+		}
+
+		for i := range sessions {
+			if sessions[i] != expected[i] {
+				t.Errorf("got %v expected %v", sessions, expected)
+			}
+		}
+	})
+
+	t.Run("Dropped sessions are not given without asking for them", func(t *testing.T) {
+		var session any = "unset"
+		ss, _ := New(WithHooks(Hooks{OnRemove: func(ev Event) { session = ev.Session }}))
+
+		key, _ := ss.Set(1)
+		ss.Remove(key)
+
+		if session != nil {
+			t.Errorf("got %v expected %v", session, nil)
+		}
+	})
+
+	t.Run("Asynchronous hooks", func(t *testing.T) {
+		removed := make(chan Event, 1)
+		ss, _ := New(WithHooks(Hooks{OnRemove: func(ev Event) { removed <- ev }, Async: true}))
+
+		key, _ := ss.Set(10)
+		ss.Remove(key)
+
+		select {
+		case ev := <-removed:
+			if ev.KeyHash != lookupKey(key) {
+				t.Errorf("got %s expected %s", ev.KeyHash, lookupKey(key))
+			}
+		case <-time.After(time.Second):
+			t.Error("got no call expected one")
+		}
+	})
+}
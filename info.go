@@ -0,0 +1,76 @@
+package suk
+
+import (
+	"context"
+	"time"
+)
+
+// SessionInfo describes a session, as listed by Range or returned by
+// GetWithInfo, such as for audits and anomaly detection.
+type SessionInfo struct {
+	// ID identifies the session for RevokeSession.
+	ID string
+
+	// Owner is the owner of the session, as given with WithOwner or told by
+	// the function given to WithIdentityFunc.
+	Owner     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+
+	// LastAccessedAt is when the key of the session was last rotated, or when
+	// it was set, if it never was.
+	LastAccessedAt time.Time
+
+	// Rotations is how many times the key of the session was rotated.
+	Rotations int
+
+	// ClientIP and UserAgent describe the client the session was set for, as
+	// given with WithClient.
+	ClientIP  string
+	UserAgent string
+}
+
+// info describes the session of the entry.
+func (ss *SessionStorage) info(e Entry) SessionInfo {
+	return SessionInfo{
+		ID:             e.ID,
+		Owner:          ss.entryOwner(e),
+		CreatedAt:      e.CreatedAt,
+		ExpiresAt:      e.ExpiresAt,
+		LastAccessedAt: e.LastAccessedAt,
+		Rotations:      e.Rotations,
+		ClientIP:       e.ClientIP,
+		UserAgent:      e.UserAgent,
+	}
+}
+
+// WithClient records the IP address and User-Agent of the client the session
+// is set for, as told by the request logging in, so they can be compared
+// against the ones of later requests by GetWithInfo. Either may be empty.
+// They are kept across rotations, except by backends keeping only the session
+// data, such as Redis.
+func WithClient(ip, userAgent string) SetOption {
+	return setOption(func(c *setConfig) error {
+		c.clientIP = ip
+		c.userAgent = userAgent
+		return nil
+	})
+}
+
+// GetWithInfo is like Get, but also describes the session, as it is after the
+// key was rotated. In read-only session storages, and the ones created with
+// WithoutKeyRotation, keys are not rotated, so accesses are not recorded.
+func (ss *SessionStorage) GetWithInfo(key string) (any, SessionInfo, string, error) {
+	return ss.GetWithInfoCtx(ss.ctx, key)
+}
+
+// GetWithInfoCtx is like GetWithInfo, but the storage operations run on the
+// given context.
+func (ss *SessionStorage) GetWithInfoCtx(ctx context.Context, key string) (any, SessionInfo, string, error) {
+	s, err := ss.GetSessionCtx(ctx, key)
+	if err != nil {
+		return struct{}{}, SessionInfo{}, "", err
+	}
+
+	return s.Data, s.Meta, s.Key, nil
+}
@@ -0,0 +1,93 @@
+package suk
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetWithInfo(t *testing.T) {
+	syncMapStorage, _ := New()
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Sync map": syncMapStorage,
+		"SQLite":   sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" sessions are described", func(t *testing.T) {
+			key, _ := ss.Set("a", WithOwner("user-42"), WithClient("203.0.113.7", "curl/8.0"))
+			_, key, _ = ss.Get(key)
+
+			session, info, newKey, err := ss.GetWithInfo(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if session != "a" || newKey == key {
+				t.Errorf("got %v and %q expected %v and a new key", session, newKey, "a")
+			}
+
+			if info.Rotations != 2 {
+				t.Errorf("got %d rotations expected %d", info.Rotations, 2)
+			}
+
+			if info.CreatedAt.IsZero() || !info.LastAccessedAt.After(info.CreatedAt) {
+				t.Errorf("got last access at %v expected after %v", info.LastAccessedAt, info.CreatedAt)
+			}
+
+			if info.Owner != "user-42" || info.ClientIP != "203.0.113.7" || info.UserAgent != "curl/8.0" {
+				t.Errorf("got %v expected the owner and client given on Set", info)
+			}
+		})
+	}
+
+	t.Run("New sessions were last accessed when set", func(t *testing.T) {
+		ss, _ := New(WithoutKeyRotation())
+		key, _ := ss.Set("a")
+		time.Sleep(time.Millisecond)
+
+		_, info, newKey, err := ss.GetWithInfo(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if newKey != key || info.Rotations != 0 || !info.LastAccessedAt.Equal(info.CreatedAt) {
+			t.Errorf("got %q and %v expected %q never accessed", newKey, info, key)
+		}
+	})
+
+	t.Run("Listed sessions are described", func(t *testing.T) {
+		ss, _ := New()
+		ss.Set("a", WithClient("203.0.113.7", ""))
+
+		ss.Range(func(_ string, info SessionInfo) bool {
+			if info.ClientIP != "203.0.113.7" || info.Rotations != 0 {
+				t.Errorf("got %v expected the client given on Set", info)
+			}
+			return true
+		})
+	})
+
+	t.Run("Typed sessions are described", func(t *testing.T) {
+		typed := NewTyped[user](syncMapStorage)
+		key, _ := typed.Set(user{ID: 42})
+
+		got, info, _, err := typed.GetWithInfo(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got.ID != 42 || info.Rotations != 1 {
+			t.Errorf("got %v and %d rotations expected %d and %d", got.ID, info.Rotations, 42, 1)
+		}
+	})
+
+	t.Run("Unknown keys", func(t *testing.T) {
+		if _, _, _, err := syncMapStorage.GetWithInfo("unknown"); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrPendingRestores = errors.New("Some sessions consumed by failed rotations could not be restored.")
+
+const (
+	// maxJournalSize bounds how many sessions may wait to be restored at once.
+	maxJournalSize = 1024
+
+	// journalRetryInterval is how long to wait before trying to restore the
+	// journaled sessions again.
+	journalRetryInterval = time.Second
+)
+
+// rotationJournal holds the sessions whose key was consumed by a rotation that
+// failed to store them again, and which could not be restored under their old
+// key either, until they can be. Its zero value is ready to use.
+type rotationJournal struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	retryAt time.Time
+}
+
+// add journals the entry to be restored under key later, reporting whether
+// there was room for it.
+func (j *rotationJournal) add(key string, e Entry) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) >= maxJournalSize {
+		return false
+	}
+
+	if j.entries == nil {
+		j.entries = make(map[string]Entry)
+	}
+
+	if len(j.entries) == 0 {
+		j.retryAt = time.Now().Add(journalRetryInterval)
+	}
+
+	j.entries[key] = e
+	return true
+}
+
+// take removes and returns the entry journaled under key.
+func (j *rotationJournal) take(key string) (Entry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e, ok := j.entries[key]
+	delete(j.entries, key)
+	return e, ok
+}
+
+// reset drops every journaled entry.
+func (j *rotationJournal) reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entries = nil
+}
+
+// retry stores the journaled entries back, dropping the expired ones. It does
+// nothing until the retry interval passed since the last attempt, and stops
+// at the first failure, as the storage is probably still failing.
+func (j *rotationJournal) retry(ctx context.Context, storage Storage) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.entries) == 0 || time.Now().Before(j.retryAt) {
+		return
+	}
+
+	j.restore(ctx, storage)
+}
+
+// flush stores the journaled entries back right away, failing with
+// ErrPendingRestores if any is left.
+func (j *rotationJournal) flush(ctx context.Context, storage Storage) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.restore(ctx, storage)
+	if len(j.entries) > 0 {
+		return ErrPendingRestores
+	}
+
+	return nil
+}
+
+// restore stores the journaled entries back. It must be called with the lock
+// held.
+func (j *rotationJournal) restore(ctx context.Context, storage Storage) {
+	now := time.Now()
+
+	for key, e := range j.entries {
+		if !now.Before(e.ExpiresAt) {
+			delete(j.entries, key)
+			continue
+		}
+
+		err := storage.Set(ctx, key, e)
+		if err != nil && err != ErrKeyExists {
+			j.retryAt = now.Add(journalRetryInterval)
+			return
+		}
+
+		delete(j.entries, key)
+	}
+}
+
+// restore stores the entry consumed from key back under it, after a rotation
+// failed to store it under a new key, so errors of the storage never destroy
+// live sessions. When that fails as well, the entry is journaled under the
+// stored key, to be restored by the next rotations, which also find it under
+// its old key.
+func (ss *SessionStorage) restore(ctx context.Context, key string, e Entry) {
+	stored := ss.stored(key)
+
+	// The rotation may have failed because ctx is done, which must not keep
+	// the session from being restored.
+	err := ss.storage.Set(context.WithoutCancel(ctx), stored, e)
+	if err == nil || err == ErrKeyExists {
+		return
+	}
+
+	if ss.journal.add(stored, e) {
+		ss.config.logger.Warn("suk: could not restore session after a failed rotation, journaling it", "error", err)
+		return
+	}
+
+	ss.config.logger.Error("suk: could not restore session after a failed rotation, the journal is full", "error", err)
+}
@@ -0,0 +1,115 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var errStorageDown = errors.New("storage is down")
+
+// flakyStorage fails the given amount of sets and removes before working
+// again.
+type flakyStorage struct {
+	*shardedMap
+	failSets    int
+	failRemoves int
+}
+
+func (f *flakyStorage) Set(ctx context.Context, key string, e Entry) error {
+	if f.failSets > 0 {
+		f.failSets--
+		return errStorageDown
+	}
+
+	return f.shardedMap.Set(ctx, key, e)
+}
+
+func (f *flakyStorage) Remove(ctx context.Context, key string) (Entry, error) {
+	if f.failRemoves > 0 {
+		f.failRemoves--
+		return Entry{}, errStorageDown
+	}
+
+	return f.shardedMap.Remove(ctx, key)
+}
+
+func TestFailedRotations(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Sessions are restored when rotations fail", func(t *testing.T) {
+		fs := &flakyStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(fs), WithLogger(logger))
+		key, _ := ss.Set(10)
+
+		fs.failSets = 1
+		if _, _, err := ss.Get(key); !errors.Is(err, errStorageDown) {
+			t.Errorf("got %v expected %v", err, errStorageDown)
+		}
+
+		session, _, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 10 {
+			t.Errorf("got %v expected %v", session, 10)
+		}
+	})
+
+	t.Run("Sessions that can't be restored are journaled", func(t *testing.T) {
+		fs := &flakyStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(fs), WithLogger(logger))
+		key, _ := ss.Set(10)
+
+		fs.failSets = 2
+		if _, _, err := ss.Get(key); !errors.Is(err, errStorageDown) {
+			t.Errorf("got %v expected %v", err, errStorageDown)
+		}
+
+		session, _, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 10 {
+			t.Errorf("got %v expected %v", session, 10)
+		}
+	})
+
+	t.Run("Journaled sessions are restored once the storage is back", func(t *testing.T) {
+		fs := &flakyStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(fs), WithLogger(logger))
+		key, _ := ss.Set(10)
+
+		fs.failSets = 2
+		ss.Get(key)
+
+		ss.journal.retryAt = time.Now()
+		other, _ := ss.Set(20)
+		ss.Get(other)
+
+		if _, err := fs.Get(context.Background(), key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if _, ok := ss.journal.take(key); ok {
+			t.Error("expected restored session to leave the journal")
+		}
+	})
+
+	t.Run("Expired journaled sessions are dropped", func(t *testing.T) {
+		var j rotationJournal
+		j.add("key", Entry{Data: 10, ExpiresAt: time.Now().Add(-time.Minute)})
+		j.retryAt = time.Now()
+
+		j.retry(context.Background(), newShardedMap(1))
+
+		if _, ok := j.take("key"); ok {
+			t.Error("expected expired session to be dropped")
+		}
+	})
+}
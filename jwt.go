@@ -0,0 +1,138 @@
+package suk
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmptyJWTSecret    = errors.New("The given JWT secret is empty.")
+	ErrNonPositiveJWTTTL = errors.New("The given JWT duration must be positive.")
+	ErrInvalidJWT        = errors.New("The given JWT is malformed or its signature is invalid.")
+	ErrJWTExpired        = errors.New("The given JWT has expired.")
+)
+
+// jwtHeader is the encoded header of every JWT issued, which are signed with
+// HMAC-SHA256.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// jwtClaims are the claims of the JWTs issued, whose ID is the key they wrap.
+type jwtClaims struct {
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// JWTBridge wraps the keys of a session storage in short-lived JWTs, signed
+// with HMAC-SHA256, so API gateways only understanding JWTs can check them,
+// while sessions can still be removed server-side. Each JWT carries its key as
+// its jti claim, so it must be guarded like the key itself.
+//
+// As keys are single-use, the ones wrapped in JWTs are meant to be only peeked
+// at, such as with a session storage created with WithoutKeyRotation.
+type JWTBridge struct {
+	ss     *SessionStorage
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewJWTBridge returns a bridge issuing JWTs for the keys of the session
+// storage, valid for ttl and signed with the secret.
+func NewJWTBridge(ss *SessionStorage, secret []byte, ttl time.Duration) (*JWTBridge, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptyJWTSecret
+	}
+
+	if ttl <= 0 {
+		return nil, ErrNonPositiveJWTTTL
+	}
+
+	return &JWTBridge{ss: ss, secret: bytes.Clone(secret), ttl: ttl}, nil
+}
+
+// Issue returns a JWT wrapping the key, which expires after the duration of
+// the bridge. The key is not looked up, so it is up to the caller to only wrap
+// keys it just got from the session storage.
+func (b *JWTBridge) Issue(key string) (string, error) {
+	now := time.Now()
+	payload, err := json.Marshal(jwtClaims{ID: key, IssuedAt: now.Unix(), ExpiresAt: now.Add(b.ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + b.sign(unsigned), nil
+}
+
+// Validate checks the signature and the expiration of the JWT, and then
+// whether the key it wraps is still stored, returning its session without
+// consuming the key, as Peek does. JWTs that are malformed or not signed with
+// the secret fail with ErrInvalidJWT, and expired ones with ErrJWTExpired,
+// without reaching the backend.
+func (b *JWTBridge) Validate(token string) (any, error) {
+	return b.ValidateCtx(b.ss.ctx, token)
+}
+
+// ValidateCtx is like Validate, but the storage operations run on the given
+// context.
+func (b *JWTBridge) ValidateCtx(ctx context.Context, token string) (any, error) {
+	key, err := b.Key(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.ss.PeekCtx(ctx, key)
+}
+
+// Key returns the key wrapped in the JWT, checking its signature and its
+// expiration, but not whether the key is still stored.
+func (b *JWTBridge) Key(token string) (string, error) {
+	unsigned, signature, ok := cutLast(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(b.sign(unsigned))) {
+		return "", ErrInvalidJWT
+	}
+
+	header, encoded, ok := strings.Cut(unsigned, ".")
+	if !ok || header != jwtHeader {
+		return "", ErrInvalidJWT
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidJWT
+	}
+
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" {
+		return "", ErrInvalidJWT
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return "", ErrJWTExpired
+	}
+
+	return claims.ID, nil
+}
+
+// sign returns the encoded HMAC-SHA256 signature of the header and payload.
+func (b *JWTBridge) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, b.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
@@ -0,0 +1,98 @@
+package suk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJWTBridge(t *testing.T) {
+	secret := []byte("secret")
+
+	t.Run("Signatures match the ones of other JWT libraries", func(t *testing.T) {
+		b, _ := NewJWTBridge(nil, []byte("your-256-bit-secret"), time.Minute)
+		unsigned := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiIxMjM0NTY3ODkwIiwibmFtZSI6IkpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyfQ"
+		expected := "SflKxwRJSMeKKF2QT4fwpMeJf36POk6yJV_adQssw5c"
+
+		if !strings.HasPrefix(unsigned, jwtHeader+".") {
+			t.Errorf("got header %s expected %s", jwtHeader, unsigned[:strings.Index(unsigned, ".")])
+		}
+
+		if got := b.sign(unsigned); got != expected {
+			t.Errorf("got %s expected %s", got, expected)
+		}
+	})
+
+	t.Run("JWTs wrap the key", func(t *testing.T) {
+		ss, _ := New(WithoutKeyRotation())
+		b, _ := NewJWTBridge(ss, secret, time.Minute)
+
+		key, _ := ss.Set("session")
+		token, err := b.Issue(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got, err := b.Key(token); err != nil || got != key {
+			t.Errorf("got %s and %v expected %s", got, err, key)
+		}
+
+		got, err := b.Validate(token)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got != "session" {
+			t.Errorf("got %v expected %q", got, "session")
+		}
+	})
+
+	t.Run("Removed sessions are not valid", func(t *testing.T) {
+		ss, _ := New(WithoutKeyRotation())
+		b, _ := NewJWTBridge(ss, secret, time.Minute)
+
+		key, _ := ss.Set("session")
+		token, _ := b.Issue(key)
+		ss.Remove(key)
+
+		if _, err := b.Validate(token); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Forged and expired JWTs", func(t *testing.T) {
+		ss, _ := New(WithoutKeyRotation())
+		b, _ := NewJWTBridge(ss, secret, time.Minute)
+		other, _ := NewJWTBridge(ss, []byte("other"), time.Minute)
+		expired, _ := NewJWTBridge(ss, secret, time.Nanosecond)
+
+		key, _ := ss.Set("session")
+		forged, _ := other.Issue(key)
+		if _, err := b.Validate(forged); !errors.Is(err, ErrInvalidJWT) {
+			t.Errorf("got %v expected %v", err, ErrInvalidJWT)
+		}
+
+		unsigned := "eyJhbGciOiJub25lIn0." + strings.Split(forged, ".")[1] + "."
+		if _, err := b.Validate(unsigned); !errors.Is(err, ErrInvalidJWT) {
+			t.Errorf("got %v expected %v", err, ErrInvalidJWT)
+		}
+
+		token, _ := expired.Issue(key)
+		if _, err := b.Validate(token); !errors.Is(err, ErrJWTExpired) {
+			t.Errorf("got %v expected %v", err, ErrJWTExpired)
+		}
+	})
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		ss, _ := New()
+
+		if _, err := NewJWTBridge(ss, nil, time.Minute); !errors.Is(err, ErrEmptyJWTSecret) {
+			t.Errorf("got %v expected %v", err, ErrEmptyJWTSecret)
+		}
+
+		if _, err := NewJWTBridge(ss, secret, 0); !errors.Is(err, ErrNonPositiveJWTTTL) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveJWTTTL)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package suk
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// keyTagSize is how many bytes of the HMAC tag are kept, which are encoded to
+// keyTagLength characters at the end of each signed key.
+const (
+	keyTagSize   = 16
+	keyTagLength = 22
+)
+
+// keyTag returns the HMAC-SHA256 tag of the key body under the secret,
+// truncated to keyTagSize bytes and encoded as URL-safe base64, so it only
+// holds characters keys may already contain.
+func keyTag(secret []byte, body string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:keyTagSize])
+}
+
+// signed appends the HMAC tag of the keys generated by rkg to them.
+func signed(secret []byte, rkg func(uint64) (string, error)) func(uint64) (string, error) {
+	return func(n uint64) (string, error) {
+		key, err := rkg(n)
+		if err != nil {
+			return "", err
+		}
+
+		return key + keyTag(secret, key), nil
+	}
+}
+
+// signedByPolicy reports whether the key carries a valid tag under the secret
+// of the policy, or any of its previous ones. It always does when no secret
+// was set with WithKeySigning.
+func (p *policy) signedByPolicy(key string) bool {
+	if p.config.keySigningSecret == nil {
+		return true
+	}
+
+	if len(key) <= keyTagLength {
+		return false
+	}
+
+	body, tag := key[:len(key)-keyTagLength], key[len(key)-keyTagLength:]
+	for _, secret := range append([][]byte{p.config.keySigningSecret}, p.config.previousKeySigningSecrets...) {
+		if hmac.Equal([]byte(tag), []byte(keyTag(secret, body))) {
+			return true
+		}
+	}
+
+	return false
+}
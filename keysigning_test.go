@@ -0,0 +1,105 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// lookupCountingStorage counts the lookups, so tests can tell whether the
+// backend was reached.
+type lookupCountingStorage struct {
+	Storage
+	lookups int
+}
+
+func (r *lookupCountingStorage) Get(ctx context.Context, key string) (Entry, error) {
+	r.lookups++
+	return r.Storage.Get(ctx, key)
+}
+
+func (r *lookupCountingStorage) Remove(ctx context.Context, key string) (Entry, error) {
+	r.lookups++
+	return r.Storage.Remove(ctx, key)
+}
+
+func TestKeySigning(t *testing.T) {
+	secret := []byte("secret")
+
+	t.Run("Signed keys are accepted", func(t *testing.T) {
+		ss, _ := New(WithKeySigning(secret))
+
+		key, _ := ss.Set("session")
+		got, newKey, err := ss.Get(key)
+		if err != nil || got != "session" {
+			t.Fatalf("got %v and error %v expected %v", got, err, "session")
+		}
+
+		if !ss.Accepts(newKey) {
+			t.Errorf("got %v expected %v", false, true)
+		}
+	})
+
+	t.Run("Forged keys are rejected without a lookup", func(t *testing.T) {
+		storage := &lookupCountingStorage{Storage: newShardedMap(1)}
+		ss, _ := New(WithStorage(storage), WithKeySigning(secret))
+
+		key, _ := ss.Set("session")
+		forged := key[:len(key)-1] + string(key[len(key)-1]^1)
+
+		if _, _, err := ss.Get(forged); !errors.Is(err, ErrForeignKey) {
+			t.Errorf("got %v expected %v", err, ErrForeignKey)
+		}
+
+		if _, _, err := ss.Get("short"); !errors.Is(err, ErrForeignKey) {
+			t.Errorf("got %v expected %v", err, ErrForeignKey)
+		}
+
+		if storage.lookups != 0 {
+			t.Errorf("got %d lookups expected %d", storage.lookups, 0)
+		}
+	})
+
+	t.Run("Keys signed with other secrets are rejected", func(t *testing.T) {
+		other, _ := New(WithKeySigning([]byte("other")))
+		key, _ := other.Set("session")
+
+		ss, _ := New(WithKeySigning(secret))
+		if ss.Accepts(key) {
+			t.Errorf("got %v expected %v", true, false)
+		}
+	})
+
+	t.Run("Keys signed with previous secrets are accepted", func(t *testing.T) {
+		old, _ := New(WithKeySigning(secret))
+		key, _ := old.Set("session")
+
+		ss, _ := New(WithKeySigning([]byte("new secret"), secret))
+		if !ss.Accepts(key) {
+			t.Errorf("got %v expected %v", false, true)
+		}
+	})
+
+	t.Run("Versioned keys are signed", func(t *testing.T) {
+		ss, _ := New(WithKeyVersion("s1"), WithKeySigning(secret))
+
+		key, _ := ss.Set("session")
+		if KeyVersion(key) != "s1" || !ss.Accepts(key) {
+			t.Errorf("got %s expected a signed key of version %s", key, "s1")
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithKeySigning(nil)); !errors.Is(err, ErrEmptyKeySigningSecret) {
+			t.Errorf("got %v expected %v", err, ErrEmptyKeySigningSecret)
+		}
+
+		if _, err := New(WithKeySigning(secret, []byte{})); !errors.Is(err, ErrEmptyKeySigningSecret) {
+			t.Errorf("got %v expected %v", err, ErrEmptyKeySigningSecret)
+		}
+
+		if _, err := New(WithKeySigning(secret), WithKeySigning(secret)); !errors.Is(err, ErrKeySigningAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrKeySigningAlreadySet)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package suk
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrForeignKey = errors.New("The given key was not issued by this session storage.")
+
+// keyVersionSeparator separates the version code from the rest of the key.
+const keyVersionSeparator = "."
+
+// KeyVersion returns the version code the key was issued with by a session
+// storage configured with WithKeyVersion, which is everything before its first
+// dot. Unversioned keys may contain dots as well, so the code returned is only
+// meaningful when compared against the versions in use, such as for routing
+// keys between session storages:
+//
+//	switch suk.KeyVersion(key) {
+//	case "u1":
+//		session, newKey, err = users.Get(key)
+//	case "a1":
+//		session, newKey, err = admins.Get(key)
+//	}
+func KeyVersion(key string) string {
+	version, _, ok := strings.Cut(key, keyVersionSeparator)
+	if !ok {
+		return ""
+	}
+
+	return version
+}
+
+// Accepts reports whether the key carries a version accepted by the session
+// storage, and a valid tag when WithKeySigning is set, without looking it up.
+// It always does when neither WithKeyVersion nor WithKeySigning is set. With
+// WithStatelessTokens, it only reports whether the key looks like a token.
+func (ss *SessionStorage) Accepts(key string) bool {
+	return ss.currentPolicy().accepts(key)
+}
+
+// accepts reports whether the key carries a version accepted by the policy,
+// and a valid tag, or whether it is a token for the stateless session storages.
+func (p *policy) accepts(key string) bool {
+	if p.config.tokens != nil {
+		return strings.HasPrefix(key, tokenHeader)
+	}
+
+	if !p.signedByPolicy(key) {
+		return false
+	}
+
+	if p.config.keyVersion == "" {
+		return true
+	}
+
+	version, _, ok := strings.Cut(key, keyVersionSeparator)
+	if ok && (version == p.config.keyVersion || slices.Contains(p.config.acceptedKeyVersions, version)) {
+		return true
+	}
+
+	return slices.Contains(p.config.acceptedKeyVersions, "")
+}
+
+// versioned prefixes the keys generated by rkg with the version code.
+func versioned(version string, rkg func(uint64) (string, error)) func(uint64) (string, error) {
+	return func(n uint64) (string, error) {
+		key, err := rkg(n)
+		if err != nil {
+			return "", err
+		}
+
+		return version + keyVersionSeparator + key, nil
+	}
+}
@@ -0,0 +1,107 @@
+package suk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyVersion(t *testing.T) {
+	t.Run("Invalid versions", func(t *testing.T) {
+		for _, opt := range []Option{WithKeyVersion(""), WithKeyVersion("s.1"), WithKeyVersion("s1", "a.1")} {
+			_, err := New(opt)
+
+			if !errors.Is(err, ErrInvalidKeyVersion) {
+				t.Errorf("got %v expected %v", err, ErrInvalidKeyVersion)
+			}
+		}
+	})
+
+	t.Run("Version already set", func(t *testing.T) {
+		_, err := New(WithKeyVersion("s1"), WithKeyVersion("s2"))
+
+		if !errors.Is(err, ErrKeyVersionAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrKeyVersionAlreadySet)
+		}
+	})
+
+	t.Run("Keys are issued with the version", func(t *testing.T) {
+		ss, _ := New(WithKeyVersion("s1"))
+		defer Destroy(ss)
+
+		key, _ := ss.Set(42)
+		if !strings.HasPrefix(key, "s1.") {
+			t.Fatalf("got %s expected the prefix %s", key, "s1.")
+		}
+
+		if got := KeyVersion(key); got != "s1" {
+			t.Errorf("got %s expected %s", got, "s1")
+		}
+
+		_, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if !strings.HasPrefix(newKey, "s1.") {
+			t.Errorf("got %s expected the prefix %s", newKey, "s1.")
+		}
+	})
+
+	t.Run("Foreign keys are rejected", func(t *testing.T) {
+		ss, _ := New(WithKeyVersion("s1"))
+		defer Destroy(ss)
+
+		if _, _, err := ss.Get("a1.whatever"); !errors.Is(err, ErrForeignKey) {
+			t.Errorf("got %v expected %v", err, ErrForeignKey)
+		}
+
+		if ss.Accepts("whatever") {
+			t.Error("expected unversioned key to be rejected")
+		}
+
+		if err := ss.Remove("a1.whatever"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Keys with accepted versions are still looked up", func(t *testing.T) {
+		old, _ := New()
+		key, _ := old.Set(42)
+
+		ss, _ := New(WithStorage(old.storage), WithHashedKeys(), WithKeyVersion("s1", ""))
+		defer Destroy(ss)
+
+		session, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if KeyVersion(newKey) != "s1" {
+			t.Errorf("got %s expected the version %s", newKey, "s1")
+		}
+	})
+
+	t.Run("Versions can be changed at runtime", func(t *testing.T) {
+		ss, _ := New(WithKeyVersion("s1"))
+		defer Destroy(ss)
+
+		key, _ := ss.Set(42)
+		if err := ss.Reconfigure(WithKeyVersion("s2", "s1")); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		_, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if KeyVersion(newKey) != "s2" {
+			t.Errorf("got %s expected the version %s", newKey, "s2")
+		}
+	})
+}
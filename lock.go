@@ -0,0 +1,80 @@
+package suk
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LockStats reports the contention on the locks guarding the session storage,
+// which tells whether locking is a bottleneck.
+type LockStats struct {
+	// Acquisitions is the amount of times the locks were acquired.
+	Acquisitions uint64
+
+	// Contended is the amount of acquisitions that had to wait for the lock to
+	// be released.
+	Contended uint64
+
+	// TotalWait and MaxWait are the total and the longest time spent waiting
+	// for the locks.
+	TotalWait time.Duration
+	MaxWait   time.Duration
+
+	// Waiting is the amount of operations waiting for the locks right now, and
+	// MaxWaiting is the most there ever were at once.
+	Waiting    int64
+	MaxWaiting int64
+}
+
+// meteredMutex is a mutex that measures its own contention. Uncontended
+// acquisitions only cost an extra atomic increment.
+type meteredMutex struct {
+	sync.Mutex
+
+	acquisitions atomic.Uint64
+	contended    atomic.Uint64
+	totalWait    atomic.Int64
+	maxWait      atomic.Int64
+	waiting      atomic.Int64
+	maxWaiting   atomic.Int64
+}
+
+func (m *meteredMutex) Lock() {
+	m.acquisitions.Add(1)
+	if m.Mutex.TryLock() {
+		return
+	}
+
+	m.contended.Add(1)
+	storeMax(&m.maxWaiting, m.waiting.Add(1))
+
+	start := time.Now()
+	m.Mutex.Lock()
+	wait := int64(time.Since(start))
+
+	m.waiting.Add(-1)
+	m.totalWait.Add(wait)
+	storeMax(&m.maxWait, wait)
+}
+
+// storeMax stores v in max if it is greater than its current value.
+func storeMax(max *atomic.Int64, v int64) {
+	for {
+		cur := max.Load()
+		if v <= cur || max.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+func (m *meteredMutex) stats() LockStats {
+	return LockStats{
+		Acquisitions: m.acquisitions.Load(),
+		Contended:    m.contended.Load(),
+		TotalWait:    time.Duration(m.totalWait.Load()),
+		MaxWait:      time.Duration(m.maxWait.Load()),
+		Waiting:      m.waiting.Load(),
+		MaxWaiting:   m.maxWaiting.Load(),
+	}
+}
@@ -0,0 +1,69 @@
+package suk
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMeteredMutex(t *testing.T) {
+	t.Run("Uncontended acquisitions", func(t *testing.T) {
+		var m meteredMutex
+
+		for range 3 {
+			m.Lock()
+			m.Unlock()
+		}
+
+		got := m.stats()
+
+		if got.Acquisitions != 3 {
+			t.Errorf("got %d expected %d", got.Acquisitions, 3)
+		}
+
+		if got.Contended != 0 {
+			t.Errorf("got %d expected %d", got.Contended, 0)
+		}
+	})
+
+	t.Run("Contended acquisitions", func(t *testing.T) {
+		var m meteredMutex
+		wg := new(sync.WaitGroup)
+
+		m.Lock()
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Lock()
+				m.Unlock()
+			}()
+		}
+
+		for m.stats().Waiting != 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		m.Unlock()
+		wg.Wait()
+
+		got := m.stats()
+
+		if got.Contended != 2 {
+			t.Errorf("got %d expected %d", got.Contended, 2)
+		}
+
+		if got.MaxWaiting != 2 {
+			t.Errorf("got %d expected %d", got.MaxWaiting, 2)
+		}
+
+		if got.Waiting != 0 {
+			t.Errorf("got %d expected %d", got.Waiting, 0)
+		}
+
+		if got.MaxWait < 5*time.Millisecond {
+			t.Errorf("got %s expected at least %s", got.MaxWait, 5*time.Millisecond)
+		}
+	})
+}
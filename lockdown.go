@@ -0,0 +1,84 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrLockdown                    = errors.New("The session storage is in lockdown.")
+	ErrNonPositiveLockdownDuration = errors.New("The given lockdown duration must be positive.")
+	ErrNoElevationFunc             = errors.New("No elevation function was set with WithElevatedKeyLength.")
+)
+
+// lockdown holds the state of an emergency lockdown.
+type lockdown struct {
+	until          time.Time
+	exceptElevated bool
+}
+
+// Lockdown makes every Get fail with ErrLockdown for the given duration,
+// acting as a circuit breaker during an active security incident. The keys
+// presented meanwhile are not consumed, so every stored session can be used
+// again once the lockdown is over, or lifted with LiftLockdown.
+func (ss *SessionStorage) Lockdown(d time.Duration) error {
+	return ss.startLockdown(d, false)
+}
+
+// LockdownNonElevated is like Lockdown, but still lets sessions flagged as
+// elevated by the function given to WithElevatedKeyLength through, so
+// administrators can keep working during the incident.
+func (ss *SessionStorage) LockdownNonElevated(d time.Duration) error {
+	if ss.currentPolicy().isElevated == nil {
+		return ErrNoElevationFunc
+	}
+
+	return ss.startLockdown(d, true)
+}
+
+func (ss *SessionStorage) startLockdown(d time.Duration, exceptElevated bool) error {
+	if d <= 0 {
+		return ErrNonPositiveLockdownDuration
+	}
+
+	ss.opsMu.Lock()
+	defer ss.opsMu.Unlock()
+	ss.lockdown = lockdown{until: time.Now().Add(d), exceptElevated: exceptElevated}
+	return nil
+}
+
+// LiftLockdown ends the current lockdown, if there's any.
+func (ss *SessionStorage) LiftLockdown() {
+	ss.opsMu.Lock()
+	defer ss.opsMu.Unlock()
+	ss.lockdown = lockdown{}
+}
+
+// checkLockdown fails with ErrLockdown if the session under key can't be
+// retrieved because of a lockdown.
+func (ss *SessionStorage) checkLockdown(ctx context.Context, key string) error {
+	ss.opsMu.Lock()
+	ld := ss.lockdown
+	ss.opsMu.Unlock()
+
+	if time.Now().After(ld.until) {
+		return nil
+	}
+
+	if !ld.exceptElevated {
+		return ErrLockdown
+	}
+
+	e, err := ss.peek(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	isElevated := ss.currentPolicy().isElevated
+	if isElevated == nil || !isElevated(e.Data) {
+		return ErrLockdown
+	}
+
+	return nil
+}
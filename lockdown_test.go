@@ -0,0 +1,68 @@
+package suk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockdown(t *testing.T) {
+	t.Run("Gets fail during lockdown without consuming keys", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		ss.Lockdown(time.Hour)
+
+		if _, _, err := ss.Get(key); err != ErrLockdown {
+			t.Errorf("got %v expected %v", err, ErrLockdown)
+		}
+
+		ss.LiftLockdown()
+
+		got, _, err := ss.Get(key)
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if got != 10 {
+			t.Errorf("got %v expected %d", got, 10)
+		}
+	})
+
+	t.Run("Lockdown is over after its duration", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		ss.Lockdown(time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Elevated sessions get through non-elevated lockdowns", func(t *testing.T) {
+		ss, _ := New(WithElevatedKeyLength(64, func(session any) bool {
+			return session == "admin"
+		}))
+		userKey, _ := ss.Set("user")
+		adminKey, _ := ss.Set("admin")
+
+		ss.LockdownNonElevated(time.Hour)
+
+		if _, _, err := ss.Get(userKey); err != ErrLockdown {
+			t.Errorf("got %v expected %v", err, ErrLockdown)
+		}
+
+		if _, _, err := ss.Get(adminKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Non-elevated lockdown requires an elevation function", func(t *testing.T) {
+		ss, _ := New()
+
+		if err := ss.LockdownNonElevated(time.Hour); err != ErrNoElevationFunc {
+			t.Errorf("got %v expected %v", err, ErrNoElevationFunc)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package suk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// lookupKey returns the key in-process storages keep the session under key
+// with, which is its SHA-256 hash. Maps and B+trees compare keys byte by byte,
+// so lookups of raw keys could take longer the more of a stored key is guessed
+// right. The hash of a guess shares nothing predictable with the hash of the
+// key, so there's nothing to learn from the timing of lookups. It is encoded
+// in hex so the cursors of ClearExpiredCtx stay printable.
+func lookupKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// storedKey returns the key the backends store the session under key with,
+// which is the one given by lookupKey when hashKeys is set, and key itself
+// otherwise.
+func storedKey(key string, hashKeys bool) string {
+	if hashKeys {
+		return lookupKey(key)
+	}
+
+	return key
+}
+
+// stored returns the key the session under key is stored with in the backend.
+// Keys are hashed here, before they reach any backend, so storages set with
+// WithStorage never see the raw keys either.
+func (ss *SessionStorage) stored(key string) string {
+	return storedKey(key, ss.hashKeys)
+}
+
+// storedKeys returns the keys the sessions under keys are stored with.
+func (ss *SessionStorage) storedKeys(keys []string) []string {
+	stored := make([]string, len(keys))
+	for i, key := range keys {
+		stored[i] = ss.stored(key)
+	}
+
+	return stored
+}
+
+// listedKey returns the key ranged storages list the session stored under
+// stored with, which is empty when they only keep the hash of the keys.
+func listedKey(stored string, hashKeys bool) string {
+	if hashKeys {
+		return ""
+	}
+
+	return stored
+}
@@ -0,0 +1,105 @@
+package suk
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLookupKey(t *testing.T) {
+	t.Run("Raw keys are not kept in memory", func(t *testing.T) {
+		ss, _ := New()
+		defer Destroy(ss)
+
+		key, _ := ss.Set(10)
+
+		if _, ok := ss.storage.(*shardedMap).load(key); ok {
+			t.Error("expected raw key not to be stored")
+		}
+
+		if _, ok := ss.storage.(*shardedMap).load(lookupKey(key)); !ok {
+			t.Error("expected hashed key to be stored")
+		}
+	})
+
+	t.Run("Storages set with WithStorage are given hashed keys", func(t *testing.T) {
+		m := newShardedMap(1)
+		ss, _ := New(WithStorage(m), WithHashedKeys())
+
+		key, _ := ss.Set(10)
+
+		if _, ok := m.load(key); ok {
+			t.Error("expected raw key not to be stored")
+		}
+
+		if _, ok := m.load(lookupKey(key)); !ok {
+			t.Error("expected hashed key to be stored")
+		}
+	})
+}
+
+func TestHashedKeys(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithHashedKeys())
+	multiRegionStorage, _ := New(WithMultiRegionRedis(
+		redis.NewClient(&redis.Options{Addr: mrA.Addr()}),
+		redis.NewClient(&redis.Options{Addr: mrB.Addr()}),
+		nil,
+	), WithHashedKeys())
+	defer Destroy(multiRegionStorage)
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")), WithHashedKeys())
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Redis":              redisStorage,
+		"Multi-region Redis": multiRegionStorage,
+		"SQLite":             sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" sessions are found by their hashed keys", func(t *testing.T) {
+			key, _ := ss.Set("session")
+
+			keys, _, _, err := ss.storage.(Ranger).RangePage(ss.ctx, "", rangePageSize)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			// Only the hashes reach the backend.
+			for _, k := range keys {
+				if k == key {
+					t.Errorf("got %q expected it hashed", k)
+				}
+			}
+
+			got, newKey, err := ss.Get(key)
+			if err != nil || got != "session" {
+				t.Fatalf("got %v and error %v expected %v", got, err, "session")
+			}
+
+			if err := ss.Remove(newKey); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+	}
+
+	t.Run("Scratch spaces follow hashed keys", func(t *testing.T) {
+		key, _ := redisStorage.Set("session")
+		redisStorage.Scratch(key).Set("name", "value")
+		_, newKey, _ := redisStorage.Get(key)
+
+		if got, err := redisStorage.Scratch(newKey).Get("name"); err != nil || got != "value" {
+			t.Errorf("got %v and error %v expected %v", got, err, "value")
+		}
+
+		for _, k := range mr.Keys() {
+			if strings.Contains(k, key) || strings.Contains(k, newKey) {
+				t.Errorf("got %q expected it hashed", k)
+			}
+		}
+	})
+}
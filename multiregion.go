@@ -0,0 +1,315 @@
+package suk
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultReplicationQueueSize bounds the writes waiting to be replicated to the
+// other region. When full, writes are replicated synchronously instead.
+const defaultReplicationQueueSize = 1024
+
+// applyScript writes an entry to a region, unless that region already holds a
+// newer one for the same key, so the newest rotation always wins. An entry is
+// either a live session (with data), a tombstone left by a consumed key (with
+// the key it was rotated to, if any) or the index entry of a session ID (with
+// the key the session was last written to), which holds no data either.
+//
+// It returns whether the entry was applied and, when a tombstone replaced an
+// older one pointing elsewhere, the key that lost the conflict, which must then
+// be tombstoned in both regions.
+var applyScript = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], 'rotated')
+if cur and tonumber(cur) >= tonumber(ARGV[1]) then
+	return {0, ''}
+end
+
+local loser = ''
+if ARGV[2] == '1' then
+	local prev = redis.call('HGET', KEYS[1], 'next')
+	if prev and prev ~= ARGV[3] then
+		loser = prev
+	end
+end
+
+redis.call('DEL', KEYS[1])
+if ARGV[2] ~= '0' then
+	redis.call('HSET', KEYS[1], 'rotated', ARGV[1], 'tombstone', '1', 'next', ARGV[3])
+else
+	redis.call('HSET', KEYS[1], 'rotated', ARGV[1], 'data', ARGV[3], 'expires', ARGV[5])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {1, loser}
+`)
+
+// regionEntry is a write to be applied to a region.
+type regionEntry struct {
+	key       string
+	rotated   int64
+	tombstone bool
+	index     bool
+	// payload holds the session data for live entries, the key it was
+	// rotated to for tombstones, or the key of the session for index entries.
+	payload any
+	expires time.Time
+}
+
+// multiRegionRedis stores sessions in two regional Redis deployments. Reads are
+// served by the nearest one, while writes are applied to the nearest one and
+// asynchronously replicated to the other.
+type multiRegionRedis struct {
+	nearest *redis.Client
+	other   *redis.Client
+
+	// ctx is the context replication runs on, as it outlives the operation
+	// that triggered it.
+	ctx    context.Context
+	logger *slog.Logger
+
+	// payload serializes the sessions, when WithCodec or WithEncryption is
+	// set.
+	payload redisPayload
+
+	queue   chan regionEntry
+	pending sync.WaitGroup
+}
+
+// apply writes the entry to the given region, tombstoning the key that lost a
+// rotation conflict, if any, in both regions.
+func (m *multiRegionRedis) apply(ctx context.Context, client *redis.Client, e regionEntry) error {
+	flag := "0"
+	if e.tombstone {
+		flag = "1"
+	} else if e.index {
+		flag = "2"
+	}
+
+	ttl := time.Until(e.expires)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+
+	res, err := applyScript.Run(
+		ctx,
+		client,
+		[]string{e.key},
+		e.rotated,
+		flag,
+		e.payload,
+		ttl.Milliseconds(),
+		e.expires.UnixNano(),
+	).Slice()
+	if err != nil {
+		return err
+	}
+
+	if loser, _ := res[1].(string); loser != "" {
+		t := regionEntry{key: loser, rotated: e.rotated, tombstone: true, payload: "", expires: e.expires}
+		if err := m.apply(ctx, client, t); err != nil {
+			return err
+		}
+
+		if client == m.nearest {
+			m.replicate(t)
+		} else if err := m.apply(ctx, m.nearest, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write applies the entry to the nearest region and replicates it.
+func (m *multiRegionRedis) write(ctx context.Context, e regionEntry) error {
+	if err := m.apply(ctx, m.nearest, e); err != nil {
+		return err
+	}
+
+	m.replicate(e)
+	return nil
+}
+
+// replicate queues the entry to be applied to the other region.
+func (m *multiRegionRedis) replicate(e regionEntry) {
+	m.pending.Add(1)
+
+	select {
+	case m.queue <- e:
+	default:
+		m.replicateNow(e)
+	}
+}
+
+func (m *multiRegionRedis) replicateNow(e regionEntry) {
+	defer m.pending.Done()
+
+	if err := m.apply(m.ctx, m.other, e); err != nil {
+		m.logger.Error("suk: could not replicate session to the other region", "error", err)
+	}
+}
+
+// replicateQueued replicates the queued entries until stop is closed.
+func (m *multiRegionRedis) replicateQueued(stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case e := <-m.queue:
+			m.replicateNow(e)
+		}
+	}
+}
+
+// load reads the live session under key from the nearest region, falling back
+// to the other one when the key wasn't replicated yet.
+func (m *multiRegionRedis) load(ctx context.Context, key string) (Entry, int64, error) {
+	for _, client := range []*redis.Client{m.nearest, m.other} {
+		fields, err := client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return Entry{}, 0, err
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields["tombstone"] == "1" {
+			return Entry{}, 0, ErrNoKeyFound
+		}
+
+		rotated, _ := strconv.ParseInt(fields["rotated"], 10, 64)
+		expires, _ := strconv.ParseInt(fields["expires"], 10, 64)
+		e, err := m.payload.decodeEntry(fields["data"])
+		if err != nil {
+			return Entry{}, 0, err
+		}
+
+		e.ExpiresAt = time.Unix(0, expires)
+		return e, rotated, nil
+	}
+
+	return Entry{}, 0, ErrNoKeyFound
+}
+
+// exists reports whether key is in use in the nearest region.
+func (m *multiRegionRedis) exists(ctx context.Context, key string) error {
+	n, err := m.nearest.Exists(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return ErrKeyExists
+	}
+
+	return nil
+}
+
+func (m *multiRegionRedis) Set(ctx context.Context, key string, e Entry) error {
+	if err := m.exists(ctx, key); err != nil {
+		return err
+	}
+
+	payload, err := m.payload.encodeEntry(e)
+	if err != nil {
+		return err
+	}
+
+	le := regionEntry{key: key, rotated: time.Now().UnixNano(), payload: payload, expires: e.ExpiresAt}
+	if err := m.write(ctx, le); err != nil {
+		return err
+	}
+
+	return m.index(ctx, le, e)
+}
+
+// index points the index entry of the session ID to the key the session was
+// written to, as of the rotation time of the write, so the newest one wins in
+// both regions.
+func (m *multiRegionRedis) index(ctx context.Context, le regionEntry, e Entry) error {
+	if e.ID == "" {
+		return nil
+	}
+
+	return m.write(ctx, regionEntry{key: familyIndexKey(e.ID), rotated: le.rotated, index: true, payload: le.key, expires: e.ExpiresAt})
+}
+
+func (m *multiRegionRedis) Get(ctx context.Context, key string) (Entry, error) {
+	e, _, err := m.load(ctx, key)
+	return e, err
+}
+
+// consume tombstones the key, pointing it to the key it was rotated to, if
+// any, and returns the rotation time of the tombstone. The tombstone lives as
+// long as the key would, so it can't be consumed again in either region.
+func (m *multiRegionRedis) consume(ctx context.Context, key, next string, rotated int64, e Entry) (int64, error) {
+	expires := e.ExpiresAt
+	if time.Until(expires) < time.Minute {
+		expires = time.Now().Add(time.Minute)
+	}
+
+	if now := time.Now().UnixNano(); rotated < now {
+		rotated = now
+	}
+
+	t := regionEntry{key: key, rotated: rotated, tombstone: true, payload: next, expires: expires}
+	return rotated, m.write(ctx, t)
+}
+
+func (m *multiRegionRedis) Remove(ctx context.Context, key string) (Entry, error) {
+	e, rotated, err := m.load(ctx, key)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if _, err := m.consume(ctx, key, "", rotated+1, e); err != nil {
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+func (m *multiRegionRedis) rotate(ctx context.Context, oldKey, newKey string, update func(Entry) (Entry, error)) (Entry, error) {
+	e, rotated, err := m.load(ctx, oldKey)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if err := m.exists(ctx, newKey); err != nil {
+		return Entry{}, err
+	}
+
+	ne, err := update(e)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	payload, err := m.payload.encodeEntry(ne)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// The old key is tombstoned before the new one is written, so it can't be
+	// consumed twice in this region. Both share the same rotation time, so the
+	// new key is linked to the rotation that created it.
+	rotated, err = m.consume(ctx, oldKey, newKey, rotated+1, e)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	le := regionEntry{key: newKey, rotated: rotated, payload: payload, expires: ne.ExpiresAt}
+	if err := m.write(ctx, le); err != nil {
+		return Entry{}, err
+	}
+
+	return ne, m.index(ctx, le, ne)
+}
+
+func (m *multiRegionRedis) ClearExpired(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package suk
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRegion returns a session storage on a multi-region Redis whose
+// replication queue must be drained by hand, so the tests control when
+// replication happens.
+func newTestRegion(t *testing.T, nearest, other *miniredis.Miniredis) (*SessionStorage, *multiRegionRedis) {
+	mr := &multiRegionRedis{
+		nearest: redis.NewClient(&redis.Options{Addr: nearest.Addr()}),
+		other:   redis.NewClient(&redis.Options{Addr: other.Addr()}),
+		ctx:     context.Background(),
+		logger:  slog.Default(),
+		queue:   make(chan regionEntry, defaultReplicationQueueSize),
+	}
+
+	ss, err := New(WithStorage(mr))
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+
+	return ss, mr
+}
+
+// drain replicates everything queued by the given regions.
+func drain(regions ...*multiRegionRedis) {
+	for {
+		replicated := false
+		for _, r := range regions {
+			select {
+			case e := <-r.queue:
+				r.replicateNow(e)
+				replicated = true
+			default:
+			}
+		}
+
+		if !replicated {
+			return
+		}
+	}
+}
+
+func TestMultiRegionRedis(t *testing.T) {
+	t.Run("Sessions are replicated to the other region", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		a, ra := newTestRegion(t, mrA, mrB)
+		b, rb := newTestRegion(t, mrB, mrA)
+
+		key, err := a.Set("session")
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		drain(ra, rb)
+
+		got, err := b.peek(b.ctx, key)
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if got.Data != "session" {
+			t.Errorf("got %v expected %v", got.Data, "session")
+		}
+	})
+
+	t.Run("Consumed keys can't be used in the other region", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		a, ra := newTestRegion(t, mrA, mrB)
+		b, rb := newTestRegion(t, mrB, mrA)
+
+		key, _ := a.Set("session")
+		drain(ra, rb)
+
+		if _, _, err := a.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		drain(ra, rb)
+
+		if _, _, err := b.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Newest concurrent rotation wins", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		a, ra := newTestRegion(t, mrA, mrB)
+		b, rb := newTestRegion(t, mrB, mrA)
+
+		key, _ := a.Set("session")
+		drain(ra, rb)
+
+		_, older, _ := a.Get(key)
+		_, newer, _ := b.Get(key)
+		drain(ra, rb)
+
+		for _, ss := range []*SessionStorage{a, b} {
+			if _, err := ss.peek(ss.ctx, older); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+
+			if got, _ := ss.peek(ss.ctx, newer); got.Data != "session" {
+				t.Errorf("got %v expected %v", got.Data, "session")
+			}
+		}
+	})
+
+	t.Run("Session storage with multi-region Redis", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ss, err := New(WithMultiRegionRedis(
+			redis.NewClient(&redis.Options{Addr: mrA.Addr()}),
+			redis.NewClient(&redis.Options{Addr: mrB.Addr()}),
+			nil,
+		))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer Destroy(ss)
+
+		key, _ := ss.Set("session")
+		got, _, err := ss.Get(key)
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if got != "session" {
+			t.Errorf("got %v expected %v", got, "session")
+		}
+	})
+}
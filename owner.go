@@ -0,0 +1,558 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrEmptyOwner        = errors.New("The given owner is empty.")
+	ErrTooManySessions   = errors.New("The owner already holds as many sessions as allowed.")
+	ErrOwnersUnsupported = errors.New("The session storage backend can't find sessions by owner.")
+)
+
+// SessionLimitPolicy tells what to do when an owner holding as many sessions
+// as allowed by WithMaxSessionsPerOwner is given another one.
+type SessionLimitPolicy int
+
+const (
+	// EvictOldest revokes the sessions of the owner created the earliest, to
+	// make room for the new one.
+	EvictOldest SessionLimitPolicy = iota
+
+	// RejectNew refuses the new session with ErrTooManySessions.
+	RejectNew
+)
+
+// OwnerStats reports the sessions indexed by owner.
+type OwnerStats struct {
+	// Owners is the amount of owners holding at least one live session, and
+	// Sessions the amount of live sessions they hold.
+	Owners   int
+	Sessions int
+
+	// MaxSessions is the most sessions held by a single owner.
+	MaxSessions int
+}
+
+// ownerIndex keeps track of the stored keys of the live sessions of each owner.
+// As the family index, it only knows about the keys issued, rotated and
+// received by this process, so it is only relied upon to find the sessions
+// kept in memory, and the backends index the sessions by owner themselves.
+type ownerIndex struct {
+	mu     sync.Mutex
+	keys   map[string]map[string]ownedKey
+	owners map[string]string
+}
+
+// ownedKey is an indexed key of an owner.
+type ownedKey struct {
+	id        string
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+func newOwnerIndex() *ownerIndex {
+	return &ownerIndex{
+		keys:   make(map[string]map[string]ownedKey),
+		owners: make(map[string]string),
+	}
+}
+
+// add indexes the stored key of the entry under owner, pruning the expired keys of
+// owner along the way.
+func (oi *ownerIndex) add(owner, key string, e Entry) {
+	if owner == "" {
+		return
+	}
+
+	oi.mu.Lock()
+	defer oi.mu.Unlock()
+
+	keys, ok := oi.keys[owner]
+	if !ok {
+		keys = make(map[string]ownedKey)
+		oi.keys[owner] = keys
+	}
+
+	now := time.Now()
+	for k, owned := range keys {
+		if !now.Before(owned.expiresAt) {
+			delete(keys, k)
+			delete(oi.owners, k)
+		}
+	}
+
+	keys[key] = ownedKey{id: e.ID, createdAt: e.CreatedAt, expiresAt: e.ExpiresAt}
+	oi.owners[key] = owner
+}
+
+// remove drops the key from the index.
+func (oi *ownerIndex) remove(key string) {
+	oi.mu.Lock()
+	defer oi.mu.Unlock()
+	oi.drop(key)
+}
+
+// drop drops the key from the index. It must be called with the lock held.
+func (oi *ownerIndex) drop(key string) {
+	owner, ok := oi.owners[key]
+	if !ok {
+		return
+	}
+
+	delete(oi.owners, key)
+	delete(oi.keys[owner], key)
+	if len(oi.keys[owner]) == 0 {
+		delete(oi.keys, owner)
+	}
+}
+
+// keysOf returns the stored keys of the live sessions of owner, the ones
+// created the earliest first.
+func (oi *ownerIndex) keysOf(owner string) []string {
+	oi.mu.Lock()
+	defer oi.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]string, 0, len(oi.keys[owner]))
+	for k, owned := range oi.keys[owner] {
+		if now.Before(owned.expiresAt) {
+			keys = append(keys, k)
+		}
+	}
+
+	owned := oi.keys[owner]
+	slices.SortFunc(keys, func(a, b string) int {
+		return owned[a].createdAt.Compare(owned[b].createdAt)
+	})
+
+	return keys
+}
+
+// prune drops every expired key from the index.
+func (oi *ownerIndex) prune(now time.Time) {
+	oi.mu.Lock()
+	defer oi.mu.Unlock()
+
+	for key, owner := range oi.owners {
+		if !now.Before(oi.keys[owner][key].expiresAt) {
+			oi.drop(key)
+		}
+	}
+}
+
+// reset drops every key from the index.
+func (oi *ownerIndex) reset() {
+	oi.mu.Lock()
+	defer oi.mu.Unlock()
+
+	oi.keys = make(map[string]map[string]ownedKey)
+	oi.owners = make(map[string]string)
+}
+
+func (oi *ownerIndex) stats() OwnerStats {
+	oi.mu.Lock()
+	defer oi.mu.Unlock()
+
+	now := time.Now()
+	var s OwnerStats
+	for _, keys := range oi.keys {
+		live := 0
+		for _, owned := range keys {
+			if now.Before(owned.expiresAt) {
+				live++
+			}
+		}
+
+		if live > 0 {
+			s.Owners++
+			s.Sessions += live
+			s.MaxSessions = max(s.MaxSessions, live)
+		}
+	}
+
+	return s
+}
+
+// ownerOf returns the owner of the session, as told by the function given to
+// WithIdentityFunc, or an empty string when there's none.
+func (ss *SessionStorage) ownerOf(session any) string {
+	if ss.config.identity == nil {
+		return ""
+	}
+
+	return ss.config.identity(session)
+}
+
+// entryOwner returns the owner of the entry, as given with WithOwner or,
+// failing that, as told by the function given to WithIdentityFunc.
+func (ss *SessionStorage) entryOwner(e Entry) string {
+	if e.Owner != "" {
+		return e.Owner
+	}
+
+	return ss.ownerOf(e.Data)
+}
+
+// indexIssued indexes the key issued for the entry under its owner.
+func (ss *SessionStorage) indexIssued(key string, e Entry) {
+	ss.owners.add(ss.entryOwner(e), ss.stored(key), e)
+}
+
+// indexRemoved drops the consumed or removed key from the owner index.
+func (ss *SessionStorage) indexRemoved(key string) {
+	ss.owners.remove(ss.stored(key))
+}
+
+// OwnerFinder may be implemented by storages indexing the sessions by the owner
+// kept along with them, as Finder does by ID, so the sessions of an owner are
+// found whichever instance issued them, without going through every session.
+type OwnerFinder interface {
+	// FindOwnedSessions returns the IDs of the sessions of owner, as given to
+	// FindSession. It may return the IDs of sessions since removed or expired,
+	// which are skipped.
+	FindOwnedSessions(ctx context.Context, owner string) ([]string, error)
+}
+
+// ownerIndexKey returns the key, relative to the prefix of the sessions, of
+// the index entry holding the IDs of the sessions of owner.
+func ownerIndexKey(owner string) string {
+	return "owner:" + owner
+}
+
+// FindOwnedSessions reads the index entry written along with the sessions of
+// owner, under the previous prefix too while it is being migrated. IDs whose
+// own index entry expired are dropped along the way, unless the keys are being
+// migrated.
+func (r *redisDB) FindOwnedSessions(ctx context.Context, owner string) ([]string, error) {
+	p := r.prefixes.Load()
+
+	prefixes := []string{p.current}
+	if p.migrating {
+		prefixes = append(prefixes, p.previous)
+	}
+
+	var ids []string
+	for _, prefix := range prefixes {
+		prefix = r.epochPrefix(prefix)
+		indexed, err := r.client.SMembers(ctx, prefix+ownerIndexKey(owner)).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.migrating {
+			ids = append(ids, indexed...)
+			continue
+		}
+
+		exists := make([]*redis.IntCmd, len(indexed))
+		_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, id := range indexed {
+				exists[i] = pipe.Exists(ctx, prefix+familyIndexKey(id))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var expired []any
+		for i, id := range indexed {
+			if exists[i].Val() == 0 {
+				expired = append(expired, id)
+			} else {
+				ids = append(ids, id)
+			}
+		}
+
+		if len(expired) > 0 {
+			if err := r.client.SRem(ctx, prefix+ownerIndexKey(owner), expired...).Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// FindOwnedSessions adds the buffered sessions of owner to the ones indexed by
+// Redis.
+func (b *bufferedStorage) FindOwnedSessions(ctx context.Context, owner string) ([]string, error) {
+	ids, err := b.redisDB.FindOwnedSessions(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range b.local.snapshot() {
+		if e.Owner == owner && e.ID != "" {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *sqliteDB) FindOwnedSessions(ctx context.Context, owner string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM suk_families WHERE owner = ?", owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *sealedStorage) FindOwnedSessions(ctx context.Context, owner string) ([]string, error) {
+	f, ok := s.Storage.(OwnerFinder)
+	if !ok {
+		return nil, ErrOwnersUnsupported
+	}
+
+	return f.FindOwnedSessions(ctx, owner)
+}
+
+// findsOwners tells whether the sessions of an owner can be found in the
+// storage, either through the owner index of this process, when they are kept
+// in memory, through OwnerFinder or by going through them with Ranger.
+func (ss *SessionStorage) findsOwners() bool {
+	s := ss.storage
+	if sealed, ok := s.(*sealedStorage); ok {
+		s = sealed.Storage
+	}
+
+	_, inMemory := s.(*shardedMap)
+	_, finder := s.(OwnerFinder)
+	_, ranger := s.(Ranger)
+	return inMemory || finder || ranger
+}
+
+// ownedEntry is a live session of an owner, along with the key it is stored
+// under.
+type ownedEntry struct {
+	stored string
+	e      Entry
+}
+
+// ownedEntries returns the live sessions of owner, the ones created the
+// earliest first, failing with ErrOwnersUnsupported when they can't be found.
+func (ss *SessionStorage) ownedEntries(ctx context.Context, owner string) ([]ownedEntry, error) {
+	if owner == "" {
+		return nil, nil
+	}
+
+	var (
+		owned []ownedEntry
+		err   error
+	)
+	if _, ok := ss.storage.(*shardedMap); ok {
+		owned, err = ss.localOwned(ctx, owner)
+	} else {
+		owned, err = ss.indexedOwned(ctx, owner)
+		if err == ErrOwnersUnsupported {
+			owned, err = ss.searchOwned(ctx, owner)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(owned, func(a, b ownedEntry) int {
+		return a.e.CreatedAt.Compare(b.e.CreatedAt)
+	})
+
+	return owned, nil
+}
+
+// localOwned returns the live sessions of owner kept in memory, as known by
+// the owner index of this process.
+func (ss *SessionStorage) localOwned(ctx context.Context, owner string) ([]ownedEntry, error) {
+	var owned []ownedEntry
+	for _, stored := range ss.owners.keysOf(owner) {
+		e, err := ss.storage.Get(ctx, stored)
+		if err == ErrNoKeyFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if time.Until(e.ExpiresAt) > 0 && !ss.revoked(e) {
+			owned = append(owned, ownedEntry{stored, e})
+		}
+	}
+
+	return owned, nil
+}
+
+// indexedOwned returns the live sessions of owner, as indexed by storages
+// implementing OwnerFinder.
+func (ss *SessionStorage) indexedOwned(ctx context.Context, owner string) ([]ownedEntry, error) {
+	f, ok := ss.storage.(OwnerFinder)
+	if !ok {
+		return nil, ErrOwnersUnsupported
+	}
+
+	ids, err := f.FindOwnedSessions(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(ids)
+	var owned []ownedEntry
+	for _, id := range slices.Compact(ids) {
+		stored, e, err := ss.currentEntry(ctx, id)
+		if err == ErrNoKeyFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if ss.entryOwner(e) == owner {
+			owned = append(owned, ownedEntry{stored, e})
+		}
+	}
+
+	return owned, nil
+}
+
+// searchOwned goes through every session of ranged storages, returning the
+// live sessions of owner.
+func (ss *SessionStorage) searchOwned(ctx context.Context, owner string) ([]ownedEntry, error) {
+	var owned []ownedEntry
+	err := ss.rangeStored(ctx, func(stored, _ string, e Entry) bool {
+		if ss.entryOwner(e) == owner && !ss.revoked(e) {
+			owned = append(owned, ownedEntry{stored, e})
+		}
+		return true
+	})
+	if err == ErrRangeUnsupported {
+		return nil, ErrOwnersUnsupported
+	}
+
+	return owned, err
+}
+
+// revokeOwned revokes the session of an owner, under whichever key it was
+// rotated to since it was found.
+func (ss *SessionStorage) revokeOwned(ctx context.Context, o ownedEntry) error {
+	if o.e.ID != "" {
+		return ss.revokeSession(ctx, o.e.ID)
+	}
+
+	if err := ss.removeStored(ctx, "", o.stored); err != nil && err != ErrNoKeyFound {
+		return err
+	}
+
+	return nil
+}
+
+// enforceSessionLimit makes room for n new sessions of owner, as set with
+// WithMaxSessionsPerOwner, either revoking its oldest sessions or failing with
+// ErrTooManySessions.
+func (ss *SessionStorage) enforceSessionLimit(ctx context.Context, owner string, n int) error {
+	c := ss.currentPolicy().config
+	if c.maxSessionsPerOwner == 0 || owner == "" {
+		return nil
+	}
+
+	owned, err := ss.ownedEntries(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	excess := len(owned) + n - c.maxSessionsPerOwner
+	if excess <= 0 {
+		return nil
+	}
+
+	if c.sessionLimitPolicy == RejectNew || n > c.maxSessionsPerOwner {
+		return ErrTooManySessions
+	}
+
+	for _, o := range owned[:excess] {
+		if err := ss.revokeOwned(ctx, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OwnedSession is a live session of an owner, as listed by SessionsForOwner.
+type OwnedSession struct {
+	// ID identifies the session for RevokeSession.
+	ID        string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// SessionsForOwner lists the live sessions of the owner, as given with
+// WithOwner or told by the function given to WithIdentityFunc, soonest to
+// expire first, such as for a page listing the devices a user is logged in
+// from. Keys are never returned, as they would let anyone reading the list
+// take over the sessions.
+//
+// Sessions are found through the index the backend keeps of their owners, so
+// they are listed whichever instance issued them. Storages not implementing
+// OwnerFinder, such as multi-region Redis, are searched through Ranger, and
+// the ones implementing neither fail with ErrOwnersUnsupported.
+func (ss *SessionStorage) SessionsForOwner(owner string) ([]OwnedSession, error) {
+	return ss.SessionsForOwnerCtx(ss.ctx, owner)
+}
+
+// SessionsForOwnerCtx is like SessionsForOwner, but the storage operations run
+// on the given context.
+func (ss *SessionStorage) SessionsForOwnerCtx(ctx context.Context, owner string) ([]OwnedSession, error) {
+	if err := ss.begin(false); err != nil {
+		return nil, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	owned, err := ss.ownedEntries(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]OwnedSession, len(owned))
+	for i, o := range owned {
+		sessions[i] = OwnedSession{ID: o.e.ID, CreatedAt: o.e.CreatedAt, ExpiresAt: o.e.ExpiresAt}
+	}
+
+	slices.SortFunc(sessions, func(a, b OwnedSession) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+
+	return sessions, nil
+}
+
+// WithOwner sets the owner of the session, such as the ID of the user logging
+// in, so it can be found with SessionsForOwner and revoked with
+// RevokeAllForOwner. It takes precedence over the function given to
+// WithIdentityFunc. The owner is kept across rotations.
+func WithOwner(owner string) SetOption {
+	return setOption(func(c *setConfig) error {
+		if owner == "" {
+			return ErrEmptyOwner
+		}
+
+		c.owner = owner
+		return nil
+	})
+}
@@ -0,0 +1,348 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type account struct {
+	ID string
+}
+
+func accountID(session any) string {
+	if a, ok := session.(account); ok {
+		return a.ID
+	}
+
+	return ""
+}
+
+func TestIdentityFunc(t *testing.T) {
+	t.Run("Nil identity function", func(t *testing.T) {
+		_, err := New(WithIdentityFunc(nil))
+
+		if !errors.Is(err, ErrNilIdentityFunc) {
+			t.Errorf("got %v expected %v", err, ErrNilIdentityFunc)
+		}
+	})
+
+	t.Run("Identity function already set", func(t *testing.T) {
+		_, err := New(WithIdentityFunc(accountID), WithIdentityFunc(accountID))
+
+		if !errors.Is(err, ErrIdentityFuncAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrIdentityFuncAlreadySet)
+		}
+	})
+
+	t.Run("Identity function can't be changed at runtime", func(t *testing.T) {
+		ss, _ := New()
+
+		if err := ss.Reconfigure(WithIdentityFunc(accountID)); !errors.Is(err, ErrNotReconfigurable) {
+			t.Errorf("got %v expected %v", err, ErrNotReconfigurable)
+		}
+	})
+
+	t.Run("Keys follow their sessions through rotations", func(t *testing.T) {
+		ss, _ := New(WithIdentityFunc(accountID))
+
+		key, _ := ss.Set(account{"user-42"})
+		_, newKey, _ := ss.Get(key)
+		other, _ := ss.Set(account{"user-42"})
+
+		got := ss.owners.keysOf("user-42")
+		slices.Sort(got)
+		expected := []string{ss.stored(newKey), ss.stored(other)}
+		slices.Sort(expected)
+
+		if !slices.Equal(got, expected) {
+			t.Errorf("got %v expected %v", got, expected)
+		}
+
+		ss.Remove(other)
+
+		if got := ss.owners.keysOf("user-42"); !slices.Equal(got, []string{ss.stored(newKey)}) {
+			t.Errorf("got %v expected %v", got, []string{ss.stored(newKey)})
+		}
+	})
+
+	t.Run("Sessions without owner are not indexed", func(t *testing.T) {
+		ss, _ := New(WithIdentityFunc(accountID))
+		ss.Set("anonymous")
+
+		if got := ss.Stats().Owners.Sessions; got != 0 {
+			t.Errorf("got %d expected %d", got, 0)
+		}
+	})
+
+	t.Run("Owner stats", func(t *testing.T) {
+		ss, _ := New(WithIdentityFunc(accountID))
+		ss.Set(account{"user-1"})
+		ss.Set(account{"user-1"})
+		ss.Set(account{"user-2"})
+
+		got := *ss.Stats().Owners
+		expected := OwnerStats{Owners: 2, Sessions: 3, MaxSessions: 2}
+
+		if got != expected {
+			t.Errorf("got %+v expected %+v", got, expected)
+		}
+	})
+
+	t.Run("Expired keys are pruned", func(t *testing.T) {
+		ss, _ := New(WithIdentityFunc(accountID), WithKeyDuration(time.Millisecond))
+		ss.Set(account{"user-42"})
+
+		time.Sleep(2 * time.Millisecond)
+		ss.ClearExpired()
+
+		if n := len(ss.owners.owners); n != 0 {
+			t.Errorf("got %d expected %d", n, 0)
+		}
+	})
+}
+
+func TestWithOwner(t *testing.T) {
+	t.Run("Empty owner", func(t *testing.T) {
+		ss, _ := New()
+
+		if _, err := ss.Set(10, WithOwner("")); !errors.Is(err, ErrEmptyOwner) {
+			t.Errorf("got %v expected %v", err, ErrEmptyOwner)
+		}
+	})
+
+	t.Run("Owners follow their sessions through rotations", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.Set(10, WithOwner("user-42"))
+		id, _ := ss.SessionID(key)
+		_, key, _ = ss.Get(key)
+
+		got, _ := ss.SessionsForOwner("user-42")
+		if len(got) != 1 || got[0].ID != id {
+			t.Fatalf("got %v expected a session with ID %q", got, id)
+		}
+
+		if got[0].ExpiresAt.IsZero() {
+			t.Error("expected the expiration to be set")
+		}
+	})
+
+	t.Run("Owners given on Set override the identity function", func(t *testing.T) {
+		ss, _ := New(WithIdentityFunc(accountID))
+
+		ss.Set(account{"user-1"}, WithOwner("user-2"))
+
+		if got, _ := ss.SessionsForOwner("user-1"); len(got) != 0 {
+			t.Errorf("got %v expected no sessions", got)
+		}
+
+		if got, _ := ss.SessionsForOwner("user-2"); len(got) != 1 {
+			t.Errorf("got %v expected one session", got)
+		}
+	})
+
+	t.Run("Sessions are listed soonest to expire first", func(t *testing.T) {
+		ss, _ := New()
+
+		ss.Set(10, WithOwner("user-42"), WithTTL(time.Hour))
+		ss.Set(20, WithOwner("user-42"), WithTTL(time.Minute))
+
+		got, _ := ss.SessionsForOwner("user-42")
+		if len(got) != 2 || !got[0].ExpiresAt.Before(got[1].ExpiresAt) {
+			t.Errorf("got %v expected two sessions, soonest first", got)
+		}
+	})
+
+	t.Run("Removed sessions are not listed", func(t *testing.T) {
+		ss, _ := New()
+
+		key, _ := ss.Set(10, WithOwner("user-42"))
+		ss.Remove(key)
+
+		if got, _ := ss.SessionsForOwner("user-42"); len(got) != 0 {
+			t.Errorf("got %v expected no sessions", got)
+		}
+	})
+
+	t.Run("Owners are kept by backends storing bytes", func(t *testing.T) {
+		ss, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+		defer Destroy(ss)
+
+		key, _ := ss.Set(10, WithOwner("user-42"))
+		ss.Get(key)
+
+		if got, _ := ss.SessionsForOwner("user-42"); len(got) != 1 {
+			t.Errorf("got %v expected one session", got)
+		}
+	})
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" sessions are listed whichever instance issued them", func(t *testing.T) {
+			opt := backend(t)
+			a, _ := New(opt)
+			defer Destroy(a)
+			b, _ := New(opt)
+			defer Destroy(b)
+
+			key, _ := a.Set(10, WithOwner("user-42"))
+			b.Get(key)
+			b.Set(20, WithOwner("user-42"))
+
+			if got, err := a.SessionsForOwner("user-42"); err != nil || len(got) != 2 {
+				t.Errorf("got %v and %v expected two sessions", got, err)
+			}
+		})
+	}
+
+	t.Run("Redis drops the expired sessions from the index", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+		ss.Set(10, WithOwner("user-42"), WithTTL(time.Second))
+		ss.Set(20, WithOwner("user-42"), WithTTL(time.Hour))
+
+		mr.FastForward(2 * time.Second)
+
+		ids, err := ss.storage.(OwnerFinder).FindOwnedSessions(context.Background(), "user-42")
+		if err != nil || len(ids) != 1 {
+			t.Fatalf("got %v and %v expected one ID", ids, err)
+		}
+
+		if members, _ := mr.Members(ownerIndexKey("user-42")); len(members) != 1 {
+			t.Errorf("got %v expected one ID", members)
+		}
+	})
+
+	t.Run("Storages that can't find owners", func(t *testing.T) {
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}))
+
+		if _, err := ss.SessionsForOwner("user-42"); err != ErrOwnersUnsupported {
+			t.Errorf("got %v expected %v", err, ErrOwnersUnsupported)
+		}
+	})
+}
+
+func TestMaxSessionsPerOwner(t *testing.T) {
+	t.Run("Non-positive maximum", func(t *testing.T) {
+		_, err := New(WithMaxSessionsPerOwner(0, EvictOldest))
+
+		if !errors.Is(err, ErrNonPositiveMaxSessions) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveMaxSessions)
+		}
+	})
+
+	t.Run("Unknown policy", func(t *testing.T) {
+		_, err := New(WithMaxSessionsPerOwner(1, SessionLimitPolicy(-1)))
+
+		if !errors.Is(err, ErrUnknownSessionLimitPolicy) {
+			t.Errorf("got %v expected %v", err, ErrUnknownSessionLimitPolicy)
+		}
+	})
+
+	t.Run("Oldest sessions are evicted", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionsPerOwner(2, EvictOldest))
+
+		oldest, _ := ss.Set(1, WithOwner("user-42"))
+		older, _ := ss.Set(2, WithOwner("user-42"))
+
+		// Rotating a session doesn't make it any younger.
+		_, oldest, _ = ss.Get(oldest)
+
+		newest, err := ss.Set(3, WithOwner("user-42"))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(oldest); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		for _, key := range []string{older, newest} {
+			if _, _, err := ss.Get(key); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		}
+	})
+
+	t.Run("New sessions are rejected", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionsPerOwner(1, RejectNew))
+
+		first, _ := ss.Set(1, WithOwner("user-42"))
+
+		if _, err := ss.Set(2, WithOwner("user-42")); err != ErrTooManySessions {
+			t.Errorf("got %v expected %v", err, ErrTooManySessions)
+		}
+
+		if _, err := ss.Set(3, WithOwner("user-7")); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(first); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Sessions without owner are not limited", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionsPerOwner(1, RejectNew))
+
+		for range 3 {
+			if _, err := ss.Set(1); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		}
+	})
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" limit holds across instances", func(t *testing.T) {
+			opt := backend(t)
+			a, _ := New(opt, WithMaxSessionsPerOwner(1, RejectNew))
+			defer Destroy(a)
+			b, _ := New(opt, WithMaxSessionsPerOwner(1, EvictOldest))
+			defer Destroy(b)
+
+			oldest, _ := a.Set(1, WithOwner("user-42"))
+			if _, err := b.Set(2, WithOwner("user-42")); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if _, _, err := a.Get(oldest); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+
+			if _, err := a.Set(3, WithOwner("user-42")); err != ErrTooManySessions {
+				t.Errorf("got %v expected %v", err, ErrTooManySessions)
+			}
+		})
+	}
+
+	t.Run("Storages that can't find owners are refused", func(t *testing.T) {
+		_, err := New(WithStorage(basicStorage{newShardedMap(1)}), WithMaxSessionsPerOwner(1, RejectNew))
+		if err != ErrOwnersUnsupported {
+			t.Errorf("got %v expected %v", err, ErrOwnersUnsupported)
+		}
+
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}))
+		if err := ss.Reconfigure(WithMaxSessionsPerOwner(1, RejectNew)); err != ErrOwnersUnsupported {
+			t.Errorf("got %v expected %v", err, ErrOwnersUnsupported)
+		}
+	})
+
+	t.Run("Limit can be changed at runtime", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionsPerOwner(1, RejectNew))
+		ss.Set(1, WithOwner("user-42"))
+
+		if err := ss.Reconfigure(WithMaxSessionsPerOwner(2, RejectNew)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := ss.Set(2, WithOwner("user-42")); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNotRedis         = errors.New("The session storage is not backed by Redis.")
+	ErrPrefixMismatch   = errors.New("The given old prefix is not the current Redis prefix.")
+	ErrMigrationRunning = errors.New("A prefix migration is already running for this session storage.")
+	ErrNestedPrefix     = errors.New("The given new prefix is a prefix of the old one.")
+)
+
+// migrationBatchSize is how many keys are scanned, and renamed, at once.
+const migrationBatchSize = 100
+
+// MigratePrefix re-homes the keyspace of the Redis backend, renaming every key
+// prefixed with oldPrefix, which must be the current prefix, to be prefixed
+// with newPrefix instead. Keys are scanned and renamed in batches, keeping
+// their TTL, and it returns how many keys were renamed, leaving out the index
+// of the sessions by ID.
+//
+// New keys are stored under newPrefix as soon as the migration starts, while
+// keys not renamed yet are still found under oldPrefix, so sessions survive
+// the migration. Other instances sharing the same Redis must be restarted
+// with the new prefix once it is done.
+//
+// Keys already prefixed with newPrefix are left alone. When oldPrefix is
+// empty, every other key in the database is renamed, so only do that on a
+// database dedicated to suk. Renamed keys could be mistaken for keys left to
+// rename when newPrefix is a shorter prefix of oldPrefix, such as from "app:"
+// to "app" or to no prefix at all, so it fails with ErrNestedPrefix instead.
+func (ss *SessionStorage) MigratePrefix(ctx context.Context, oldPrefix, newPrefix string) (int, error) {
+	if ss.config.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	var r *redisDB
+	switch st := ss.storage.(type) {
+	case *redisDB:
+		r = st
+	case *bufferedStorage:
+		r = st.redisDB
+	default:
+		return 0, ErrNotRedis
+	}
+
+	p := r.prefixes.Load()
+	if p.migrating {
+		return 0, ErrMigrationRunning
+	}
+
+	if p.current != oldPrefix {
+		return 0, ErrPrefixMismatch
+	}
+
+	if len(newPrefix) < len(oldPrefix) && strings.HasPrefix(oldPrefix, newPrefix) {
+		return 0, ErrNestedPrefix
+	}
+
+	migrating := &redisPrefixes{current: newPrefix, previous: oldPrefix, migrating: true}
+	if !r.prefixes.CompareAndSwap(p, migrating) {
+		return 0, ErrMigrationRunning
+	}
+
+	renamed, err := r.renamePrefix(ctx, oldPrefix, newPrefix)
+	if err != nil {
+		// Keys are left under both prefixes, so the migration must be run again
+		// from the start.
+		r.prefixes.Store(p)
+		return renamed, err
+	}
+
+	r.prefixes.Store(&redisPrefixes{current: newPrefix})
+	return renamed, nil
+}
+
+// mergeOwnersScript moves the index entry of an owner to the new prefix, along
+// with the IDs indexed there since the migration started, keeping the latest
+// expiration of both.
+var mergeOwnersScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	return 0
+end
+ttl = math.max(ttl, redis.call('PTTL', KEYS[2]))
+redis.call('SUNIONSTORE', KEYS[2], KEYS[1], KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ttl)
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// renamePrefix renames every key prefixed with oldPrefix to be prefixed with
+// newPrefix instead, counting the ones not indexing the sessions by ID or by
+// owner.
+func (r *redisDB) renamePrefix(ctx context.Context, oldPrefix, newPrefix string) (int, error) {
+	renamed := 0
+	pattern := escapePattern(oldPrefix) + "*"
+	families := familyIndexKey("")
+	owners := ownerIndexKey("")
+
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, pattern, migrationBatchSize).Result()
+		if err != nil {
+			return renamed, err
+		}
+
+		// Errors are checked for each command, as renaming keys that expired, or
+		// were consumed, after being scanned is fine.
+		var indexes []bool
+		cmds, _ := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				if newPrefix != "" && strings.HasPrefix(key, newPrefix) {
+					continue
+				}
+
+				// Owners setting sessions since the migration started are
+				// indexed under the new prefix already, so both are merged.
+				newKey := newPrefix + strings.TrimPrefix(key, oldPrefix)
+				if strings.Contains(key, owners) {
+					mergeOwnersScript.Eval(ctx, pipe, []string{key, newKey})
+				} else {
+					pipe.RenameNX(ctx, key, newKey)
+				}
+				indexes = append(indexes, strings.Contains(key, families) || strings.Contains(key, owners))
+			}
+			return nil
+		})
+
+		for i, cmd := range cmds {
+			err := cmd.Err()
+			if err != nil && !strings.Contains(err.Error(), "no such key") {
+				return renamed, err
+			}
+
+			if rename, ok := cmd.(*redis.BoolCmd); ok && rename.Val() && !indexes[i] {
+				renamed++
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return renamed, nil
+		}
+	}
+}
+
+// escapePattern escapes the glob characters of s, for it to be used in a SCAN
+// pattern.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
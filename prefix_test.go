@@ -0,0 +1,160 @@
+package suk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMigratePrefix(t *testing.T) {
+	t.Run("Keys are stored under the Redis prefix", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithRedisPrefix("suk:"))
+
+		key, _ := ss.Set("session")
+
+		if got := storedData(t, mr, "suk:"+key); got != "session" {
+			t.Errorf("got %q expected %q", got, "session")
+		}
+	})
+
+	t.Run("Sessions survive a prefix migration", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithRedisPrefix("old:"))
+		mr.Set("unrelated", "data")
+
+		keys := make([]string, 0, 250)
+		for range 250 {
+			key, _ := ss.Set("session")
+			keys = append(keys, key)
+		}
+
+		renamed, err := ss.MigratePrefix(context.Background(), "old:", "new:")
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if renamed != 250 {
+			t.Errorf("got %d expected %d", renamed, 250)
+		}
+
+		if !mr.Exists("unrelated") {
+			t.Error("expected unrelated key to be left alone")
+		}
+
+		for _, key := range keys {
+			if !mr.Exists("new:" + key) {
+				t.Errorf("expected key %q to be migrated", key)
+			}
+
+			if mr.TTL("new:"+key) <= 0 {
+				t.Errorf("expected key %q to keep its TTL", key)
+			}
+		}
+
+		if _, _, err := ss.Get(keys[0]); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Keys not migrated yet are found under the old prefix", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithRedisPrefix("old:"))
+		key, _ := ss.Set("session")
+
+		r := ss.storage.(*redisDB)
+		r.prefixes.Store(&redisPrefixes{current: "new:", previous: "old:", migrating: true})
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Owners indexed under both prefixes are merged", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithRedisPrefix("old:"))
+		ss.Set("a", WithOwner("user-42"))
+
+		// A session is set by another instance once the migration started.
+		r := ss.storage.(*redisDB)
+		r.prefixes.Store(&redisPrefixes{current: "new:", previous: "old:", migrating: true})
+		ss.Set("b", WithOwner("user-42"))
+
+		if _, err := r.renamePrefix(context.Background(), "old:", "new:"); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		r.prefixes.Store(&redisPrefixes{current: "new:"})
+
+		if got, err := ss.SessionsForOwner("user-42"); err != nil || len(got) != 2 {
+			t.Errorf("got %v and %v expected two sessions", got, err)
+		}
+
+		if mr.TTL("new:"+ownerIndexKey("user-42")) <= 0 {
+			t.Error("expected the owner index to keep its TTL")
+		}
+	})
+
+	t.Run("Old prefix must be the current prefix", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithRedisPrefix("old:"))
+
+		if _, err := ss.MigratePrefix(context.Background(), "other:", "new:"); err != ErrPrefixMismatch {
+			t.Errorf("got %v expected %v", err, ErrPrefixMismatch)
+		}
+	})
+
+	t.Run("Old prefixes can be extended", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithRedisPrefix("app"))
+		key, _ := ss.Set("session")
+
+		if renamed, err := ss.MigratePrefix(context.Background(), "app", "app:"); err != nil || renamed != 1 {
+			t.Errorf("got %d and error %v expected %d", renamed, err, 1)
+		}
+
+		if !mr.Exists("app:" + key) {
+			t.Errorf("expected key %q to be migrated", key)
+		}
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("New prefix can't be a shorter prefix of the old one", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		ss, _ := New(WithRedis(client, nil), WithRedisPrefix("app:"))
+		key, _ := ss.Set("session")
+
+		for _, newPrefix := range []string{"app", ""} {
+			if _, err := ss.MigratePrefix(context.Background(), "app:", newPrefix); err != ErrNestedPrefix {
+				t.Errorf("got %v expected %v", err, ErrNestedPrefix)
+			}
+		}
+
+		if !mr.Exists("app:" + key) {
+			t.Errorf("expected key %q to be left alone", key)
+		}
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Migrating requires Redis", func(t *testing.T) {
+		ss, _ := New()
+
+		if _, err := ss.MigratePrefix(context.Background(), "", "new:"); err != ErrNotRedis {
+			t.Errorf("got %v expected %v", err, ErrNotRedis)
+		}
+	})
+}
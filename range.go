@@ -0,0 +1,328 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrRangeUnsupported = errors.New("The session storage backend can't list its sessions.")
+
+// rangePageSize is the amount of entries listed at once by Range.
+const rangePageSize = 100
+
+// Ranger may be implemented by storages able to list their entries, so they
+// support Range. RangePage lists them in pages of at most limit entries,
+// starting from the cursor, which is empty for the first page. It returns the
+// keys the entries are stored with along with them, and the cursor of the next
+// page, which is empty once every entry was listed.
+type Ranger interface {
+	RangePage(ctx context.Context, cursor string, limit int) (keys []string, entries []Entry, next string, err error)
+}
+
+func (m *shardedMap) RangePage(_ context.Context, cursor string, limit int) ([]string, []Entry, string, error) {
+	var stored []string
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for k := range s.entries {
+			if k > cursor {
+				stored = append(stored, k)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	slices.Sort(stored)
+
+	next := ""
+	if len(stored) > limit {
+		stored = stored[:limit]
+		next = stored[limit-1]
+	}
+
+	var (
+		keys    []string
+		entries []Entry
+	)
+	for _, k := range stored {
+		e, ok := m.load(k)
+		if !ok {
+			continue
+		}
+
+		keys = append(keys, k)
+		entries = append(entries, e)
+	}
+
+	return keys, entries, next, nil
+}
+
+// RangePage scans the keys under the prefix, skipping scratch spaces,
+// watermarks and the indexes of the sessions by ID and by owner. While a prefix
+// migration is in progress, only the keys already migrated are listed.
+func (r *redisDB) RangePage(ctx context.Context, cursor string, limit int) ([]string, []Entry, string, error) {
+	var start uint64
+	if cursor != "" {
+		var err error
+		if start, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	prefix := r.epochPrefix(r.prefixes.Load().current)
+	scratch := prefix + scratchKey("")
+	watermarks := prefix + watermarkKey("")
+	families := prefix + familyIndexKey("")
+	owners := prefix + ownerIndexKey("")
+
+	scanned, next, err := r.client.Scan(ctx, start, escapePattern(prefix)+"*", int64(limit)).Result()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var stored []string
+	for _, key := range scanned {
+		if !strings.HasPrefix(key, scratch) && !strings.HasPrefix(key, watermarks) && !strings.HasPrefix(key, families) && !strings.HasPrefix(key, owners) {
+			stored = append(stored, key)
+		}
+	}
+
+	gets := make([]*redis.StringCmd, len(stored))
+	pttls := make([]*redis.DurationCmd, len(stored))
+	r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range stored {
+			pttls[i] = pipe.PTTL(ctx, key)
+			gets[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+
+	var (
+		keys    []string
+		entries []Entry
+	)
+	for i, key := range stored {
+		// Keys consumed since they were scanned are skipped.
+		e, err := r.entry(gets[i], pttls[i], gets[i].Err())
+		if err == ErrNoKeyFound {
+			continue
+		} else if err != nil {
+			return nil, nil, "", err
+		}
+
+		keys = append(keys, strings.TrimPrefix(key, prefix))
+		entries = append(entries, e)
+	}
+
+	if next == 0 {
+		return keys, entries, "", nil
+	}
+
+	return keys, entries, strconv.FormatUint(next, 10), nil
+}
+
+// RangePage lists the buffered sessions along with the first page of Redis,
+// as there are never many of them.
+func (b *bufferedStorage) RangePage(ctx context.Context, cursor string, limit int) ([]string, []Entry, string, error) {
+	keys, entries, next, err := b.redisDB.RangePage(ctx, cursor, limit)
+	if err != nil || cursor != "" {
+		return keys, entries, next, err
+	}
+
+	for key, e := range b.local.snapshot() {
+		keys = append(keys, key)
+		entries = append(entries, e)
+	}
+
+	return keys, entries, next, nil
+}
+
+// RangePage scans the nearest region, skipping the tombstones.
+func (m *multiRegionRedis) RangePage(ctx context.Context, cursor string, limit int) ([]string, []Entry, string, error) {
+	var start uint64
+	if cursor != "" {
+		var err error
+		if start, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, nil, "", err
+		}
+	}
+
+	scanned, next, err := m.nearest.Scan(ctx, start, "*", int64(limit)).Result()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(scanned))
+	m.nearest.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range scanned {
+			cmds[i] = pipe.HGetAll(ctx, key)
+		}
+		return nil
+	})
+
+	var (
+		keys    []string
+		entries []Entry
+	)
+	for i, key := range scanned {
+		fields, err := cmds[i].Result()
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+		raw, ok := fields["data"]
+		if !ok {
+			continue
+		}
+
+		e, err := m.payload.decodeEntry(raw)
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+		expires, _ := strconv.ParseInt(fields["expires"], 10, 64)
+		e.ExpiresAt = time.Unix(0, expires)
+		keys = append(keys, key)
+		entries = append(entries, e)
+	}
+
+	if next == 0 {
+		return keys, entries, "", nil
+	}
+
+	return keys, entries, strconv.FormatUint(next, 10), nil
+}
+
+func (s *sqliteDB) RangePage(ctx context.Context, cursor string, limit int) ([]string, []Entry, string, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT key, data, expires_at FROM suk_sessions WHERE key > ? ORDER BY key LIMIT ?`,
+		cursor,
+		limit,
+	)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer rows.Close()
+
+	var (
+		keys    []string
+		entries []Entry
+		last    string
+	)
+	for rows.Next() {
+		var (
+			key       string
+			raw       []byte
+			expiresAt int64
+		)
+		if err := rows.Scan(&key, &raw, &expiresAt); err != nil {
+			return nil, nil, "", err
+		}
+
+		e, err := decodeEntryData(raw, s.sealer)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		e.ExpiresAt = time.Unix(0, expiresAt)
+
+		keys = append(keys, key)
+		entries = append(entries, e)
+		last = key
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, "", err
+	}
+
+	if len(keys) < limit {
+		return keys, entries, "", nil
+	}
+
+	return keys, entries, last, nil
+}
+
+// Range calls fn for every live session, until fn returns false, such as for
+// an admin dashboard or batch maintenance. Sessions are listed a page at a
+// time, and fn is called out of any lock, so it may use the session storage,
+// even to remove the sessions listed. Sessions set or removed meanwhile may or
+// may not be listed.
+//
+// The keys given to fn can be used to take over the sessions, so they must
+// never leave the trusted side. Backends that only keep the hash of the keys,
+// such as the in-memory one, can only tell the keys issued or rotated by this
+// process, and give empty keys otherwise, such as for the sessions handed off
+// from another instance. Storages not implementing Ranger fail with
+// ErrRangeUnsupported.
+func (ss *SessionStorage) Range(fn func(key string, info SessionInfo) bool) error {
+	return ss.RangeCtx(ss.ctx, fn)
+}
+
+// RangeCtx is like Range, but the storage operations run on the given context.
+func (ss *SessionStorage) RangeCtx(ctx context.Context, fn func(key string, info SessionInfo) bool) error {
+	if err := ss.begin(false); err != nil {
+		return err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	return ss.rangeEntries(ctx, func(key string, e Entry) bool {
+		if ss.revoked(e) {
+			return true
+		}
+
+		return fn(key, ss.info(e))
+	})
+}
+
+// rangeEntries calls fn for every unexpired entry, until fn returns false,
+// resolving the keys of the hashed ones when they're known.
+func (ss *SessionStorage) rangeEntries(ctx context.Context, fn func(key string, e Entry) bool) error {
+	return ss.rangeStored(ctx, func(_, key string, e Entry) bool {
+		return fn(key, e)
+	})
+}
+
+// rangeStored is rangeEntries, also giving fn the key each entry is stored
+// under in the backend.
+func (ss *SessionStorage) rangeStored(ctx context.Context, fn func(stored, key string, e Entry) bool) error {
+	r, ok := ss.storage.(Ranger)
+	if !ok {
+		return ErrRangeUnsupported
+	}
+
+	cursor := ""
+	for {
+		keys, entries, next, err := r.RangePage(ctx, cursor, rangePageSize)
+		if err != nil {
+			return err
+		}
+
+		for i, e := range entries {
+			if time.Until(e.ExpiresAt) <= 0 {
+				continue
+			}
+
+			key := listedKey(keys[i], ss.hashKeys)
+			if key == "" && e.ID != "" {
+				key, _ = ss.families.keyOf(e.ID)
+			}
+
+			if !fn(keys[i], key, e) {
+				return nil
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
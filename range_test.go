@@ -0,0 +1,137 @@
+package suk
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRange(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithRedisPrefix("sessions:"))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Sync map": syncMapStorage,
+		"Redis":    redisStorage,
+		"SQLite":   sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" live sessions are listed", func(t *testing.T) {
+			sessions := make([]any, 2*rangePageSize+1)
+			for i := range sessions {
+				sessions[i] = i
+			}
+
+			keys, _ := ss.SetMany(sessions)
+			ss.Remove(keys[0])
+
+			var listed []string
+			err := ss.Range(func(key string, _ SessionInfo) bool {
+				listed = append(listed, key)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			slices.Sort(listed)
+			expected := slices.Clone(keys[1:])
+			slices.Sort(expected)
+			if !slices.Equal(listed, expected) {
+				t.Errorf("got %d keys expected %d", len(listed), len(expected))
+			}
+
+			ss.RemoveMany(keys)
+		})
+	}
+
+	t.Run("Sessions are described", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set("a", WithOwner("user-42"))
+		id, _ := ss.SessionID(key)
+
+		ss.Range(func(got string, info SessionInfo) bool {
+			if got != key || info.ID != id || info.Owner != "user-42" || info.CreatedAt.IsZero() || info.ExpiresAt.IsZero() {
+				t.Errorf("got %q and %v expected %q of %q", got, info, key, "user-42")
+			}
+			return true
+		})
+	})
+
+	t.Run("Listing stops when asked to", func(t *testing.T) {
+		ss, _ := New()
+		ss.SetMany([]any{"a", "b", "c"})
+
+		n := 0
+		ss.Range(func(string, SessionInfo) bool {
+			n++
+			return false
+		})
+
+		if n != 1 {
+			t.Errorf("got %d calls expected %d", n, 1)
+		}
+	})
+
+	t.Run("Listed sessions can be removed", func(t *testing.T) {
+		ss, _ := New()
+		ss.SetMany([]any{"a", "b", "c"})
+
+		ss.Range(func(key string, _ SessionInfo) bool {
+			if err := ss.Remove(key); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+			return true
+		})
+
+		if got, _ := ss.Count(); got != 0 {
+			t.Errorf("got %d sessions expected %d", got, 0)
+		}
+	})
+
+	t.Run("Expired sessions are not listed", func(t *testing.T) {
+		ss, _ := New()
+		ss.Set("a", WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		ss.Range(func(key string, _ SessionInfo) bool {
+			t.Errorf("got %q expected no session", key)
+			return true
+		})
+	})
+
+	t.Run("Multi-region tombstones are not listed", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ss, _ := newTestRegion(t, mrA, mrB)
+
+		key, _ := ss.Set("a")
+		_, newKey, _ := ss.Get(key)
+
+		var listed []string
+		ss.Range(func(key string, _ SessionInfo) bool {
+			listed = append(listed, key)
+			return true
+		})
+
+		if !slices.Equal(listed, []string{newKey}) {
+			t.Errorf("got %v expected %v", listed, []string{newKey})
+		}
+	})
+
+	t.Run("Backends that can't list their sessions", func(t *testing.T) {
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}))
+
+		err := ss.Range(func(string, SessionInfo) bool { return true })
+		if err != ErrRangeUnsupported {
+			t.Errorf("got %v expected %v", err, ErrRangeUnsupported)
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIssueRateLimited  = errors.New("Too many sessions were issued for this source.")
+	ErrLookupRateLimited = errors.New("Too many lookups failed for this source.")
+)
+
+type sourceContextKey struct{}
+
+// SourceContext returns a copy of ctx carrying the identifier of the source
+// asking for a session, such as the client IP, so SetCtx and GetCtx can
+// enforce the limits set with WithIssueRateLimit and WithLookupRateLimit for
+// it.
+func SourceContext(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceContextKey{}, source)
+}
+
+// sourceFrom returns the source identifier carried by ctx, if there's any.
+func sourceFrom(ctx context.Context) (string, bool) {
+	source, ok := ctx.Value(sourceContextKey{}).(string)
+	return source, ok
+}
+
+// tokenBucket holds the sessions a source can still be issued.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// issueLimiter limits how many sessions each source is issued, with a token
+// bucket per source refilled at rate tokens per second up to burst tokens.
+type issueLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+
+	// pruneAt is the amount of buckets that triggers dropping the full ones,
+	// which are the same as no bucket at all.
+	pruneAt int
+}
+
+// minPruneAt keeps the limiter from pruning small bucket sets over and over.
+const minPruneAt = 1024
+
+func newIssueLimiter(rate float64, burst int) *issueLimiter {
+	return &issueLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		pruneAt: minPruneAt,
+	}
+}
+
+// allow takes a token from the bucket of source, reporting whether there was
+// one to take.
+func (l *issueLimiter) allow(source string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[source]
+	if !ok {
+		l.prune(now)
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[source] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// prune drops the buckets that refilled completely, once there are too many of
+// them, so sources seen once don't pile up forever.
+func (l *issueLimiter) prune(now time.Time) {
+	if len(l.buckets) < l.pruneAt {
+		return
+	}
+
+	for source, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, source)
+		}
+	}
+
+	l.pruneAt = max(minPruneAt, 2*len(l.buckets))
+}
+
+// lookupLimiter counts the failed lookups of each source over a sliding
+// window, keeping the time of each failure still in it.
+type lookupLimiter struct {
+	limit      int
+	window     time.Duration
+	onExceeded func(source string)
+
+	mu       sync.Mutex
+	failures map[string][]time.Time
+
+	// pruneAt is the amount of sources that triggers dropping the ones
+	// without failures in the window.
+	pruneAt int
+}
+
+func newLookupLimiter(limit int, window time.Duration, onExceeded func(source string)) *lookupLimiter {
+	return &lookupLimiter{
+		limit:      limit,
+		window:     window,
+		onExceeded: onExceeded,
+		failures:   make(map[string][]time.Time),
+		pruneAt:    minPruneAt,
+	}
+}
+
+// blocked reports whether the source failed as many lookups as the limit
+// within the window.
+func (l *lookupLimiter) blocked(source string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.recent(source, now)) >= l.limit
+}
+
+// failed records a failed lookup of the source, calling onExceeded once the
+// source reaches the limit.
+func (l *lookupLimiter) failed(source string, now time.Time) {
+	l.mu.Lock()
+	failures := l.recent(source, now)
+	if len(failures) == 0 {
+		l.prune(now)
+	}
+
+	failures = append(failures, now)
+	l.failures[source] = failures
+	exceeded := len(failures) == l.limit
+	l.mu.Unlock()
+
+	if exceeded && l.onExceeded != nil {
+		l.onExceeded(source)
+	}
+}
+
+// recent drops the failures of the source that slid out of the window,
+// returning the remaining ones.
+func (l *lookupLimiter) recent(source string, now time.Time) []time.Time {
+	failures := l.failures[source]
+
+	i := 0
+	for i < len(failures) && now.Sub(failures[i]) >= l.window {
+		i++
+	}
+
+	if i == len(failures) {
+		delete(l.failures, source)
+		return nil
+	}
+
+	failures = failures[i:]
+	l.failures[source] = failures
+	return failures
+}
+
+// prune drops the sources without failures in the window, once there are too
+// many of them, so sources failing once don't pile up forever.
+func (l *lookupLimiter) prune(now time.Time) {
+	if len(l.failures) < l.pruneAt {
+		return
+	}
+
+	for source := range l.failures {
+		l.recent(source, now)
+	}
+
+	l.pruneAt = max(minPruneAt, 2*len(l.failures))
+}
+
+// checkLookups fails with ErrLookupRateLimited when the source carried by ctx
+// failed too many lookups.
+func (ss *SessionStorage) checkLookups(ctx context.Context) error {
+	source, ok := sourceFrom(ctx)
+	if ok && ss.lookups != nil && ss.lookups.blocked(source, time.Now()) {
+		return ErrLookupRateLimited
+	}
+
+	return nil
+}
+
+// lookupEntry peeks at the entry stored under key, as the operations looking
+// sessions up by key other than Get and Peek do, enforcing the limit of failed
+// lookups.
+func (ss *SessionStorage) lookupEntry(ctx context.Context, key string) (Entry, error) {
+	if err := ss.checkLookups(ctx); err != nil {
+		return Entry{}, err
+	}
+
+	e, err := ss.peek(ctx, key)
+	ss.lookedUp(ctx, err)
+	return e, err
+}
+
+// lookedUp records the lookup of the source carried by ctx as failed, when it
+// failed with err because the key was not found or not accepted.
+func (ss *SessionStorage) lookedUp(ctx context.Context, err error) {
+	if err != ErrNoKeyFound && err != ErrForeignKey {
+		return
+	}
+
+	if source, ok := sourceFrom(ctx); ok && ss.lookups != nil {
+		ss.lookups.failed(source, time.Now())
+	}
+}
@@ -0,0 +1,226 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssueRateLimit(t *testing.T) {
+	t.Run("Non-positive rate", func(t *testing.T) {
+		_, err := New(WithIssueRateLimit(0, 1))
+
+		if !errors.Is(err, ErrNonPositiveIssueRate) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveIssueRate)
+		}
+	})
+
+	t.Run("Non-positive burst", func(t *testing.T) {
+		_, err := New(WithIssueRateLimit(1, 0))
+
+		if !errors.Is(err, ErrNonPositiveIssueBurst) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveIssueBurst)
+		}
+	})
+
+	t.Run("Rate limit already set", func(t *testing.T) {
+		_, err := New(WithIssueRateLimit(1, 1), WithIssueRateLimit(1, 1))
+
+		if !errors.Is(err, ErrIssueRateLimitAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrIssueRateLimitAlreadySet)
+		}
+	})
+
+	t.Run("Sources are limited separately", func(t *testing.T) {
+		ss, _ := New(WithIssueRateLimit(0.001, 2))
+		ctx := SourceContext(context.Background(), "10.0.0.1")
+
+		for range 2 {
+			if _, err := ss.SetCtx(ctx, "session"); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		}
+
+		if _, err := ss.SetCtx(ctx, "session"); !errors.Is(err, ErrIssueRateLimited) {
+			t.Errorf("got %v expected %v", err, ErrIssueRateLimited)
+		}
+
+		other := SourceContext(context.Background(), "10.0.0.2")
+		if _, err := ss.SetCtx(other, "session"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if _, err := ss.Set("session"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Buckets are refilled over time", func(t *testing.T) {
+		l := newIssueLimiter(10, 1)
+		now := time.Now()
+
+		if !l.allow("source", now) {
+			t.Errorf("got %v expected %v", false, true)
+		}
+
+		if l.allow("source", now) {
+			t.Errorf("got %v expected %v", true, false)
+		}
+
+		if !l.allow("source", now.Add(100*time.Millisecond)) {
+			t.Errorf("got %v expected %v", false, true)
+		}
+	})
+
+	t.Run("Full buckets are pruned", func(t *testing.T) {
+		l := newIssueLimiter(10, 1)
+		now := time.Now()
+
+		for i := range minPruneAt {
+			l.allow(string(rune(i)), now)
+		}
+
+		l.allow("source", now.Add(time.Second))
+
+		if got := len(l.buckets); got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+	})
+}
+
+func TestLookupRateLimit(t *testing.T) {
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithLookupRateLimit(0, time.Minute, nil)); !errors.Is(err, ErrNonPositiveLookupLimit) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveLookupLimit)
+		}
+
+		if _, err := New(WithLookupRateLimit(1, 0, nil)); !errors.Is(err, ErrNonPositiveLookupWindow) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveLookupWindow)
+		}
+
+		if _, err := New(WithLookupRateLimit(1, time.Minute, nil), WithLookupRateLimit(1, time.Minute, nil)); !errors.Is(err, ErrLookupRateLimitAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrLookupRateLimitAlreadySet)
+		}
+	})
+
+	t.Run("Sources guessing keys are limited", func(t *testing.T) {
+		var exceeded []string
+		ss, _ := New(WithLookupRateLimit(2, time.Minute, func(source string) {
+			exceeded = append(exceeded, source)
+		}))
+		ctx := SourceContext(context.Background(), "10.0.0.1")
+		key, _ := ss.Set("session")
+
+		for _, guess := range []string{"guess-1", "guess-2"} {
+			if _, _, err := ss.GetCtx(ctx, guess); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		}
+
+		if _, _, err := ss.GetCtx(ctx, key); err != ErrLookupRateLimited {
+			t.Errorf("got %v expected %v", err, ErrLookupRateLimited)
+		}
+
+		if _, err := ss.PeekCtx(ctx, key); err != ErrLookupRateLimited {
+			t.Errorf("got %v expected %v", err, ErrLookupRateLimited)
+		}
+
+		if len(exceeded) != 1 || exceeded[0] != "10.0.0.1" {
+			t.Errorf("got %v expected %v", exceeded, []string{"10.0.0.1"})
+		}
+
+		other := SourceContext(context.Background(), "10.0.0.2")
+		if _, _, err := ss.GetCtx(other, key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Every lookup by key is limited", func(t *testing.T) {
+		guesses := map[string]func(ss *SessionStorage, ctx context.Context, key string) error{
+			"Exists": func(ss *SessionStorage, ctx context.Context, key string) error {
+				_, err := ss.ExistsCtx(ctx, key)
+				return err
+			},
+			"ExpiresAt": func(ss *SessionStorage, ctx context.Context, key string) error {
+				_, err := ss.ExpiresAtCtx(ctx, key)
+				return err
+			},
+			"GetMany": func(ss *SessionStorage, ctx context.Context, key string) error {
+				results, err := ss.GetManyCtx(ctx, []string{key})
+				if err != nil {
+					return err
+				}
+				return results[0].Err
+			},
+			"Update": func(ss *SessionStorage, ctx context.Context, key string) error {
+				return ss.UpdateCtx(ctx, key, func(old any) any { return old })
+			},
+			"SessionID": func(ss *SessionStorage, ctx context.Context, key string) error {
+				_, err := ss.SessionIDCtx(ctx, key)
+				return err
+			},
+			"Scratch": func(ss *SessionStorage, ctx context.Context, key string) error {
+				_, err := ss.ScratchCtx(ctx, key).Get("progress")
+				return err
+			},
+		}
+
+		for name, guess := range guesses {
+			t.Run(name, func(t *testing.T) {
+				ss, _ := New(WithLookupRateLimit(2, time.Minute, nil))
+				ctx := SourceContext(context.Background(), "10.0.0.1")
+				key, _ := ss.Set("session")
+
+				guess(ss, ctx, "guess-1")
+				guess(ss, ctx, "guess-2")
+
+				if err := guess(ss, ctx, key); err != ErrLookupRateLimited {
+					t.Errorf("got %v expected %v", err, ErrLookupRateLimited)
+				}
+			})
+		}
+	})
+
+	t.Run("Successful lookups are not counted", func(t *testing.T) {
+		ss, _ := New(WithLookupRateLimit(1, time.Minute, nil), WithoutKeyRotation())
+		ctx := SourceContext(context.Background(), "10.0.0.1")
+		key, _ := ss.Set("session")
+
+		for range 3 {
+			if _, _, err := ss.GetCtx(ctx, key); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		}
+	})
+
+	t.Run("Failures slide out of the window", func(t *testing.T) {
+		l := newLookupLimiter(2, time.Second, nil)
+		now := time.Now()
+
+		l.failed("source", now)
+		l.failed("source", now.Add(500*time.Millisecond))
+		if !l.blocked("source", now.Add(900*time.Millisecond)) {
+			t.Errorf("got %v expected %v", false, true)
+		}
+
+		if l.blocked("source", now.Add(1100*time.Millisecond)) {
+			t.Errorf("got %v expected %v", true, false)
+		}
+	})
+
+	t.Run("Sources without failures are pruned", func(t *testing.T) {
+		l := newLookupLimiter(1, time.Second, nil)
+		now := time.Now()
+
+		for i := range minPruneAt {
+			l.failed(string(rune(i)), now)
+		}
+
+		l.failed("source", now.Add(time.Second))
+
+		if got := len(l.failures); got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package suk
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+var ErrNotReconfigurable = errors.New("The given option can't be changed at runtime.")
+
+// policy holds the settings derived from the options that can be changed at
+// runtime with Reconfigure. A policy is never modified once built, so it can be
+// used without holding the lock after being read.
+type policy struct {
+	config config
+
+	keyLength          uint64
+	elevatedKeyLength  uint64
+	isElevated         func(session any) bool
+	expiresAt          func(time.Time) time.Time
+	absoluteExpiration time.Duration
+	clearInterval      time.Duration
+	rkg                func(uint64) (string, error)
+	limiter            *issueLimiter
+}
+
+// newPolicy builds the policy for the config. The issue limiter of the previous
+// policy, if given, is kept unless the rate limit changed, so the sessions
+// already issued to each source keep counting.
+func newPolicy(c config, auditor *keyAuditor, previous *policy) (*policy, error) {
+	p := policy{
+		config:             c,
+		keyLength:          defaultKeyLength,
+		elevatedKeyLength:  c.elevatedKeyLength,
+		isElevated:         c.isElevated,
+		absoluteExpiration: c.absoluteExpiration,
+		clearInterval:      defaultDurationToExpire,
+		rkg:                defaultRandomKeyGenerator,
+	}
+
+	if c.customKeyLength != nil {
+		p.keyLength = *c.customKeyLength
+	}
+
+	if c.customKeyDuration != nil {
+		p.clearInterval = *c.customKeyDuration
+	}
+
+	durationToExpire := p.clearInterval
+	p.expiresAt = func(now time.Time) time.Time {
+		return now.Add(durationToExpire)
+	}
+
+	if c.autoClearInterval != nil {
+		p.clearInterval = *c.autoClearInterval
+	}
+	if c.customKeyExpiration != nil {
+		p.expiresAt = c.customKeyExpiration
+	}
+
+	if c.customRandomKeyGenerator != nil {
+		p.rkg = c.customRandomKeyGenerator
+	}
+
+	if auditor != nil {
+		p.rkg = auditor.wrap(p.rkg)
+	}
+
+	if c.keyVersion != "" {
+		p.rkg = versioned(c.keyVersion, p.rkg)
+	}
+
+	if c.keySigningSecret != nil {
+		p.rkg = signed(c.keySigningSecret, p.rkg)
+	}
+
+	if previous != nil && previous.config.issueRate == c.issueRate && previous.config.issueBurst == c.issueBurst {
+		p.limiter = previous.limiter
+	} else if c.issueRate != 0 {
+		p.limiter = newIssueLimiter(c.issueRate, c.issueBurst)
+	}
+
+	if c.isElevated != nil && c.elevatedKeyLength <= p.keyLength {
+		return nil, ErrElevatedKeyLengthTooShort
+	}
+
+	return &p, nil
+}
+
+// keyLengthFor returns the length of the keys issued for the session.
+func (p *policy) keyLengthFor(session any) uint64 {
+	if p.isElevated != nil && p.isElevated(session) {
+		return p.elevatedKeyLength
+	}
+
+	return p.keyLength
+}
+
+// currentPolicy returns the policy in use.
+func (ss *SessionStorage) currentPolicy() *policy {
+	ss.policyMu.RLock()
+	defer ss.policyMu.RUnlock()
+	return ss.policy
+}
+
+// scheduleAutoClear schedules the auto clear job for the policy p, replacing
+// the one scheduled for the previous policy when its interval changed. It must
+// be called with policyMu held, or before the session storage is shared.
+func (ss *SessionStorage) scheduleAutoClear(previous, p *policy) {
+	if !p.config.autoClearExpiredKeys || ss.config.readOnly {
+		return
+	}
+
+	if previous != nil && previous.config.autoClearExpiredKeys && previous.clearInterval == p.clearInterval {
+		return
+	}
+
+	if ss.stopAutoClear != nil {
+		ss.stopAutoClear()
+	}
+
+	ss.stopAutoClear = ss.schedule("auto clear", p.clearInterval, func() {
+		ss.ClearExpired()
+	})
+}
+
+// Reconfigure changes the session policy at runtime, so long-running servers
+// can tune it without restarts. Only the following options can be given, and
+// the sessions already issued keep their expiration:
+//
+//   - WithKeyLength and WithElevatedKeyLength
+//   - WithKeyDuration and WithKeyDurationUntil
+//   - WithSlidingExpiration and WithAbsoluteExpiration
+//   - WithAutoClearExpiredKeys and WithAutoClearInterval
+//   - WithCustomRandomKeyGenerator
+//   - WithKeyVersion
+//   - WithIssueRateLimit
+//   - WithMaxSessionsPerOwner
+//   - WithMaxSessionSize
+//   - WithHooks, replacing the hooks set before
+//
+// Options not listed, such as the ones picking the backend, fail with
+// ErrNotReconfigurable. No change is made unless every option is valid.
+func (ss *SessionStorage) Reconfigure(opts ...Option) error {
+	var next config
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.apply(&next); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if !next.reconfigurable() {
+		return ErrNotReconfigurable
+	}
+
+	if next.maxSessionsPerOwner != 0 && !ss.findsOwners() {
+		return ErrOwnersUnsupported
+	}
+
+	ss.policyMu.Lock()
+	defer ss.policyMu.Unlock()
+
+	previous := ss.policy
+	p, err := newPolicy(previous.config.merge(next), ss.keyAuditor, previous)
+	if err != nil {
+		return err
+	}
+
+	ss.scheduleAutoClear(previous, p)
+	ss.policy = p
+	return nil
+}
+
+// reconfigurable reports whether only options accepted by Reconfigure were
+// applied to the config. It is an allowlist: the settings merge applies are
+// cleared, and any other setting left fails the check, so options added later
+// are refused until merge applies them.
+func (c config) reconfigurable() bool {
+	c.customKeyLength = nil
+	c.isElevated, c.elevatedKeyLength = nil, 0
+	c.customKeyDuration, c.customKeyExpiration = nil, nil
+	c.expirationModeSet, c.absoluteExpiration = false, 0
+	c.autoClearExpiredKeys, c.autoClearInterval = false, nil
+	c.customRandomKeyGenerator = nil
+	c.keyVersion, c.acceptedKeyVersions = "", nil
+	c.maxSessionsPerOwner, c.sessionLimitPolicy = 0, 0
+	c.issueRate, c.issueBurst = 0, 0
+	c.maxSessionSize = 0
+	c.hooks = nil
+
+	return reflect.ValueOf(c).IsZero()
+}
+
+// merge returns the config with the settings applied to next replacing its own.
+func (c config) merge(next config) config {
+	if next.customKeyLength != nil {
+		c.customKeyLength = next.customKeyLength
+	}
+
+	if next.isElevated != nil {
+		c.isElevated = next.isElevated
+		c.elevatedKeyLength = next.elevatedKeyLength
+	}
+
+	if next.customKeyDuration != nil || next.customKeyExpiration != nil {
+		c.customKeyDuration = next.customKeyDuration
+		c.customKeyExpiration = next.customKeyExpiration
+	}
+
+	if next.expirationModeSet {
+		c.expirationModeSet = true
+		c.absoluteExpiration = next.absoluteExpiration
+	}
+
+	if next.autoClearExpiredKeys {
+		c.autoClearExpiredKeys = true
+	}
+
+	if next.autoClearInterval != nil {
+		c.autoClearInterval = next.autoClearInterval
+	}
+
+	if next.customRandomKeyGenerator != nil {
+		c.customRandomKeyGenerator = next.customRandomKeyGenerator
+	}
+
+	if next.keyVersion != "" {
+		c.keyVersion = next.keyVersion
+		c.acceptedKeyVersions = next.acceptedKeyVersions
+	}
+
+	if next.maxSessionsPerOwner != 0 {
+		c.maxSessionsPerOwner = next.maxSessionsPerOwner
+		c.sessionLimitPolicy = next.sessionLimitPolicy
+	}
+
+	if next.issueRate != 0 {
+		c.issueRate = next.issueRate
+		c.issueBurst = next.issueBurst
+	}
+
+	if next.maxSessionSize != 0 {
+		c.maxSessionSize = next.maxSessionSize
+	}
+
+	if next.hooks != nil {
+		c.hooks = next.hooks
+	}
+
+	return c
+}
@@ -0,0 +1,223 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReconfigure(t *testing.T) {
+	t.Run("Backend options are not reconfigurable", func(t *testing.T) {
+		ss, _ := New()
+
+		err := ss.Reconfigure(WithSQLite("sessions.db"))
+		if !errors.Is(err, ErrNotReconfigurable) {
+			t.Errorf("got %v expected %v", err, ErrNotReconfigurable)
+		}
+	})
+
+	t.Run("New keys use the new key length", func(t *testing.T) {
+		ss, _ := New(WithKeyLength(16))
+
+		if err := ss.Reconfigure(WithKeyLength(48)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		key, _ := ss.Set("session")
+		if len(key) != 48 {
+			t.Errorf("got %d expected %d", len(key), 48)
+		}
+	})
+
+	t.Run("New sessions use the new key duration", func(t *testing.T) {
+		ss, _ := New(WithKeyDuration(time.Hour))
+		key, _ := ss.Set("session")
+
+		if err := ss.Reconfigure(WithKeyDuration(time.Millisecond)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		newKey, _ := ss.Set("session")
+		time.Sleep(5 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(newKey); !errors.Is(err, ErrKeyWasExpired) {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Invalid options leave the policy untouched", func(t *testing.T) {
+		ss, _ := New(WithKeyLength(16))
+
+		err := ss.Reconfigure(WithKeyLength(32), WithElevatedKeyLength(24, func(any) bool { return true }))
+		if !errors.Is(err, ErrElevatedKeyLengthTooShort) {
+			t.Errorf("got %v expected %v", err, ErrElevatedKeyLengthTooShort)
+		}
+
+		key, _ := ss.Set("session")
+		if len(key) != 16 {
+			t.Errorf("got %d expected %d", len(key), 16)
+		}
+	})
+
+	t.Run("Auto clear can be turned on", func(t *testing.T) {
+		sched := new(ManualScheduler)
+		cs := &countingStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(cs), WithScheduler(sched), WithKeyDuration(time.Millisecond))
+
+		ss.Set("session")
+		if err := ss.Reconfigure(WithAutoClearExpiredKeys()); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		sched.Run()
+
+		if count := cs.len(); count != 0 {
+			t.Errorf("got %d expected %d", count, 0)
+		}
+	})
+
+	t.Run("Rate limits keep counting when unchanged", func(t *testing.T) {
+		ss, _ := New(WithIssueRateLimit(0.001, 1))
+		ctx := SourceContext(context.Background(), "10.0.0.1")
+		ss.SetCtx(ctx, "session")
+
+		if err := ss.Reconfigure(WithKeyLength(48)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := ss.SetCtx(ctx, "session"); !errors.Is(err, ErrIssueRateLimited) {
+			t.Errorf("got %v expected %v", err, ErrIssueRateLimited)
+		}
+	})
+
+	t.Run("Options accepted are applied", func(t *testing.T) {
+		key := make([]byte, 32)
+		opts := map[string]Option{
+			"WithKeyLength":                WithKeyLength(32),
+			"WithElevatedKeyLength":        WithElevatedKeyLength(48, func(any) bool { return false }),
+			"WithKeyDuration":              WithKeyDuration(time.Hour),
+			"WithKeyDurationUntil":         WithKeyDurationUntil(func(now time.Time) time.Time { return now }),
+			"WithSlidingExpiration":        WithSlidingExpiration(),
+			"WithAbsoluteExpiration":       WithAbsoluteExpiration(time.Hour),
+			"WithRotationGracePeriod":      WithRotationGracePeriod(time.Second),
+			"WithReplayDetection":          WithReplayDetection(time.Minute, nil),
+			"WithoutKeyRotation":           WithoutKeyRotation(),
+			"WithAutoClearExpiredKeys":     WithAutoClearExpiredKeys(),
+			"WithAutoClearInterval":        WithAutoClearInterval(time.Minute),
+			"WithCustomRandomKeyGenerator": WithCustomRandomKeyGenerator(defaultRandomKeyGenerator),
+			"WithKeyVersion":               WithKeyVersion("v2", "v1"),
+			"WithIdentityFunc":             WithIdentityFunc(func(any) string { return "" }),
+			"WithMaxSessionsPerOwner":      WithMaxSessionsPerOwner(2, EvictOldest),
+			"WithKeyAudit":                 WithKeyAudit(10),
+			"WithUsageSampling":            WithUsageSampling(10),
+			"WithShards":                   WithShards(4),
+			"WithCodec":                    WithCodec(JSONCodec{}),
+			"WithMaxSessionSize":           WithMaxSessionSize(1024),
+			"WithEncryption":               WithEncryption(key),
+			"WithHashedKeys":               WithHashedKeys(),
+			"WithKeySigning":               WithKeySigning(key),
+			"WithStatelessTokens":          WithStatelessTokens(key),
+			"WithIssueRateLimit":           WithIssueRateLimit(1, 1),
+			"WithLookupRateLimit":          WithLookupRateLimit(1, time.Second, nil),
+			"WithAuditLogger":              WithAuditLogger(func(Event) {}),
+			"WithHooks":                    WithHooks(Hooks{OnSet: func(Event) {}}),
+			"WithEventBuffer":              WithEventBuffer(8),
+			"WithBaseContext":              WithBaseContext(context.Background()),
+			"WithScheduler":                WithScheduler(new(ManualScheduler)),
+		}
+
+		for name, opt := range opts {
+			var next config
+			if err := opt.apply(&next); err != nil {
+				t.Fatalf("%s: got error %s", name, err.Error())
+			}
+
+			if !next.reconfigurable() {
+				continue
+			}
+
+			given, merged := reflect.ValueOf(next), reflect.ValueOf(config{}.merge(next))
+			for i := range given.NumField() {
+				if !given.Field(i).IsZero() && merged.Field(i).IsZero() {
+					t.Errorf("%s: got %s dropped expected it applied", name, given.Type().Field(i).Name)
+				}
+			}
+		}
+	})
+
+	t.Run("Options not applied are refused", func(t *testing.T) {
+		ss, _ := New()
+		key := make([]byte, 32)
+
+		for _, opt := range []Option{
+			WithEncryption(key),
+			WithKeySigning(key),
+			WithAuditLogger(func(Event) {}),
+			WithLookupRateLimit(1, time.Second, nil),
+			WithHashedKeys(),
+			WithEventBuffer(8),
+			WithCodec(JSONCodec{}),
+			WithStatelessTokens(key),
+		} {
+			if err := ss.Reconfigure(opt); !errors.Is(err, ErrNotReconfigurable) {
+				t.Errorf("got %v expected %v", err, ErrNotReconfigurable)
+			}
+		}
+	})
+
+	t.Run("New sessions are held to the new maximum size", func(t *testing.T) {
+		ss, _ := New()
+
+		if err := ss.Reconfigure(WithMaxSessionSize(1)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := ss.Set("large session"); !errors.Is(err, ErrSessionTooLarge) {
+			t.Errorf("got %v expected %v", err, ErrSessionTooLarge)
+		}
+	})
+
+	t.Run("Hooks are replaced", func(t *testing.T) {
+		var calls []string
+		ss, _ := New(WithHooks(Hooks{OnSet: func(Event) { calls = append(calls, "old") }}))
+
+		if err := ss.Reconfigure(WithHooks(Hooks{OnSet: func(Event) { calls = append(calls, "new") }})); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		ss.Set("session")
+		if len(calls) != 1 || calls[0] != "new" {
+			t.Errorf("got %v expected %v", calls, []string{"new"})
+		}
+	})
+
+	t.Run("Reconfiguring is safe while serving sessions", func(t *testing.T) {
+		ss, _ := New()
+
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					key, _ := ss.Set("session")
+					ss.Get(key)
+				}
+			}()
+		}
+
+		for i := range 100 {
+			ss.Reconfigure(WithKeyLength(uint64(16 + i%16)))
+		}
+
+		wg.Wait()
+	})
+}
@@ -0,0 +1,38 @@
+package suk
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrKeyReplayed = errors.New("The given key was already used, so its session was revoked.")
+
+// detectReplay checks whether the key was already consumed by a rotation, past
+// its grace period, if any. Such a reuse means the key was most likely stolen,
+// as either the client or the thief presenting it holds a stale copy, so the
+// whole session is revoked and ErrKeyReplayed returned.
+func (ss *SessionStorage) detectReplay(ctx context.Context, key string) error {
+	if ss.consumedKeys == nil || ss.config.replayWindow == 0 {
+		return nil
+	}
+
+	current, _, ok := ss.consumedKeys.resolve(key)
+	if !ok {
+		return nil
+	}
+
+	ss.config.logger.Warn("suk: consumed key replayed, revoking its session")
+
+	e, err := ss.remove(ctx, current)
+	ss.emit(EventReplayDetected, key, "", e)
+	if ss.config.onReplay != nil {
+		ss.config.onReplay(key)
+	}
+
+	if err != nil && err != ErrNoKeyFound {
+		ss.config.logger.Error("suk: revoking replayed session failed", "error", err)
+		return errors.Join(ErrKeyReplayed, err)
+	}
+
+	return ErrKeyReplayed
+}
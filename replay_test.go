@@ -0,0 +1,111 @@
+package suk
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestReplayDetection(t *testing.T) {
+	quiet := WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	t.Run("Non-positive replay window", func(t *testing.T) {
+		_, err := New(WithReplayDetection(0, nil))
+
+		if !errors.Is(err, ErrNonPositiveReplayWindow) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveReplayWindow)
+		}
+	})
+
+	t.Run("Replayed keys revoke the session chain", func(t *testing.T) {
+		var replayed []string
+		ss, _ := New(quiet, WithReplayDetection(time.Minute, func(oldKey string) {
+			replayed = append(replayed, oldKey)
+		}))
+
+		stolen, _ := ss.Set(10)
+		_, key, _ := ss.Get(stolen)
+		_, key, _ = ss.Get(key)
+
+		if _, _, err := ss.Get(stolen); err != ErrKeyReplayed {
+			t.Errorf("got %v expected %v", err, ErrKeyReplayed)
+		}
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		if len(replayed) != 1 || replayed[0] != stolen {
+			t.Errorf("got %v expected %v", replayed, []string{stolen})
+		}
+	})
+
+	t.Run("Unknown keys are not replays", func(t *testing.T) {
+		ss, _ := New(quiet, WithReplayDetection(time.Minute, nil))
+
+		if _, _, err := ss.Get("unknown"); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Replays within the grace period are allowed", func(t *testing.T) {
+		ss, _ := New(quiet, WithRotationGracePeriod(20*time.Millisecond), WithReplayDetection(time.Minute, nil))
+
+		key, _ := ss.Set(10)
+		_, newKey, _ := ss.Get(key)
+
+		if _, got, err := ss.Get(key); err != nil || got != newKey {
+			t.Errorf("got %q and error %v expected %q", got, err, newKey)
+		}
+
+		time.Sleep(30 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); err != ErrKeyReplayed {
+			t.Errorf("got %v expected %v", err, ErrKeyReplayed)
+		}
+	})
+
+	t.Run("Keys are forgotten after the window", func(t *testing.T) {
+		ss, _ := New(quiet, WithReplayDetection(10*time.Millisecond, nil))
+
+		key, _ := ss.Set(10)
+		_, newKey, _ := ss.Get(key)
+		time.Sleep(20 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		if _, _, err := ss.Get(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+}
+
+func TestConsumedKeys(t *testing.T) {
+	t.Run("Long chains resolve to their current key", func(t *testing.T) {
+		ck := newConsumedKeys(time.Minute)
+
+		for i := range 100 {
+			ck.add(string(rune('a'+i)), string(rune('a'+i+1)))
+		}
+
+		if got, _, _ := ck.resolve("a"); got != string(rune('a'+100)) {
+			t.Errorf("got %q expected %q", got, string(rune('a'+100)))
+		}
+	})
+
+	t.Run("Pruning drops stale chains", func(t *testing.T) {
+		ck := newConsumedKeys(10 * time.Millisecond)
+
+		ck.add("a", "b")
+		time.Sleep(20 * time.Millisecond)
+		ck.add("c", "d")
+
+		if len(ck.keys) != 1 || len(ck.chains) != 1 {
+			t.Errorf("got %d keys and %d chains expected %d and %d", len(ck.keys), len(ck.chains), 1, 1)
+		}
+	})
+}
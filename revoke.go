@@ -0,0 +1,308 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// Deprecated: RevokeAllForOwner no longer needs WithIdentityFunc, as
+	// owners may be given with WithOwner instead, so it never fails with it.
+	ErrNoIdentityFunc = errors.New("No identity function was set with WithIdentityFunc.")
+
+	ErrRevocationIncomplete = errors.New("Some sessions could not be revoked.")
+)
+
+// RevokeProgress reports the progress of a bulk revocation, after each session
+// is handled.
+type RevokeProgress struct {
+	// Done is how many sessions were handled so far, out of Total.
+	Done  int
+	Total int
+
+	// Err is why the last session handled could not be revoked, if it
+	// couldn't.
+	Err error
+}
+
+// RevokeAllForOwner revokes every session of the owner, as given with WithOwner
+// or told by the function given to WithIdentityFunc, such as when an account
+// is compromised, returning how many sessions were revoked. Progress, if not
+// nil, is called after each session is handled.
+//
+// Sessions are found as with SessionsForOwner, so they are revoked whichever
+// instance issued them, and it fails with ErrOwnersUnsupported when they can't
+// be found.
+//
+// Sessions that fail to be revoked don't stop the revocation. They are left
+// in the storage instead, and it fails with ErrRevocationIncomplete, joined
+// with the errors of each session, so calling it again resumes the revocation
+// with only the sessions left. The same goes when the context is done midway.
+func (ss *SessionStorage) RevokeAllForOwner(ctx context.Context, owner string, progress func(RevokeProgress)) (int, error) {
+	if ss.config.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if err := ss.begin(false); err != nil {
+		return 0, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	owned, err := ss.ownedEntries(ctx, owner)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	var errs []error
+	for i, o := range owned {
+		if err := ctx.Err(); err != nil {
+			return revoked, err
+		}
+
+		err := ss.revokeOwned(ctx, o)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("session %d of %d: %w", i+1, len(owned), err))
+		} else {
+			revoked++
+		}
+
+		if progress != nil {
+			progress(RevokeProgress{Done: i + 1, Total: len(owned), Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return revoked, errors.Join(append([]error{ErrRevocationIncomplete}, errs...)...)
+	}
+
+	return revoked, nil
+}
+
+// RevokeReport tells what a revocation would revoke, as returned by
+// RevokeAllForOwnerDryRun and RevokeCreatedBeforeDryRun.
+type RevokeReport struct {
+	// Count is how many live sessions would be revoked.
+	Count int
+
+	// Sample describes some of them, up to the size asked for.
+	Sample []SessionInfo
+}
+
+// add counts the session described by info, sampling it while the sample holds
+// less than size sessions.
+func (r *RevokeReport) add(info SessionInfo, size int) {
+	if len(r.Sample) < size {
+		r.Sample = append(r.Sample, info)
+	}
+
+	r.Count++
+}
+
+// RevokeAllForOwnerDryRun reports what RevokeAllForOwner would revoke without
+// revoking anything, such as before acting on a support ticket: how many
+// sessions the owner holds, along with a sample of up to size of them, oldest
+// first. Sessions are found as with SessionsForOwner, so it works on read-only
+// storages too, and fails with ErrOwnersUnsupported when they can't be found.
+func (ss *SessionStorage) RevokeAllForOwnerDryRun(ctx context.Context, owner string, size int) (RevokeReport, error) {
+	if err := ss.begin(false); err != nil {
+		return RevokeReport{}, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	owned, err := ss.ownedEntries(ctx, owner)
+	if err != nil {
+		return RevokeReport{}, err
+	}
+
+	var report RevokeReport
+	for _, o := range owned {
+		report.add(ss.info(o.e), size)
+	}
+
+	return report, nil
+}
+
+// RevokeCreatedBefore revokes every session created before t, such as after
+// rotating a signing secret or discovering a breach, returning how many
+// sessions were removed. Revocations only ever move forward, so revoking
+// sessions created before an earlier time does nothing.
+//
+// From then on, sessions created before t are told apart as not found, even
+// when they couldn't be removed, such as when their keys are unknown to this
+// process or the backend can't list its sessions, in which case they are left
+// to expire. As with InvalidateAll, t is kept by the storages implementing
+// Watermarker, so it survives restarts and other instances pick it up within
+// a second.
+func (ss *SessionStorage) RevokeCreatedBefore(ctx context.Context, t time.Time) (int, error) {
+	if ss.config.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	ss.revokeBefore(t.UnixNano())
+
+	if err := ss.begin(false); err != nil {
+		return 0, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	before, err := ss.raiseWatermark(ctx, revokedBeforeWatermark, t.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	ss.revokeBefore(before)
+
+	var keys []string
+	err = ss.rangeEntries(ctx, func(key string, e Entry) bool {
+		if key != "" && ss.revoked(e) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err == ErrRangeUnsupported {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	var errs []error
+	for _, key := range keys {
+		if _, err := ss.remove(ctx, key); err == nil {
+			revoked++
+		} else if err != ErrNoKeyFound {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return revoked, errors.Join(append([]error{ErrRevocationIncomplete}, errs...)...)
+	}
+
+	return revoked, nil
+}
+
+// RevokeCreatedBeforeDryRun reports what RevokeCreatedBefore would revoke
+// without revoking anything nor raising the watermark: how many live sessions
+// were created before t, along with a sample of up to size of them. Sessions
+// already revoked are left out. It goes through every session, as Range does,
+// so it works on read-only storages too, and fails with ErrRangeUnsupported on
+// the storages not implementing Ranger.
+func (ss *SessionStorage) RevokeCreatedBeforeDryRun(ctx context.Context, t time.Time, size int) (RevokeReport, error) {
+	var report RevokeReport
+	err := ss.RangeCtx(ctx, func(_ string, info SessionInfo) bool {
+		if !info.CreatedAt.IsZero() && info.CreatedAt.Before(t) {
+			report.add(info, size)
+		}
+
+		return true
+	})
+	if err != nil {
+		return RevokeReport{}, err
+	}
+
+	return report, nil
+}
+
+// InvalidateAll invalidates every session at once, such as after a breach,
+// without going through the storage, however many sessions it holds. It bumps
+// the epoch stored along with each session, so the sessions set in the
+// previous ones are no longer found and are left to expire, while the ones set
+// afterwards are not affected. Redis also stores the keys of each epoch under
+// their own segment after the prefix, so the sessions of past ones are not
+// listed.
+//
+// The epoch is kept by the storages implementing Watermarker, such as Redis
+// and SQLite, so it survives restarts, and other instances using the storage
+// pick it up within a second. Until they do, the sessions they set are
+// invalidated too. It fails when the storage couldn't keep the epoch, which
+// is bumped in this process anyway.
+func (ss *SessionStorage) InvalidateAll() error {
+	return ss.InvalidateAllCtx(ss.ctx)
+}
+
+// InvalidateAllCtx is like InvalidateAll, but the storage operations run on the
+// given context, so per-request deadlines and cancellation propagate to the
+// backend.
+func (ss *SessionStorage) InvalidateAllCtx(ctx context.Context) error {
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	epoch := ss.epoch.Load() + 1
+	ss.advanceEpoch(epoch)
+
+	// Epochs raised meanwhile by other instances already invalidate the
+	// sessions set here.
+	raised, err := ss.raiseWatermark(ctx, epochWatermark, int64(epoch))
+	if err != nil {
+		return err
+	}
+
+	ss.advanceEpoch(uint64(raised))
+	return nil
+}
+
+// advanceEpoch moves to the given epoch, unless already in a later one.
+func (ss *SessionStorage) advanceEpoch(epoch uint64) {
+	for {
+		current := ss.epoch.Load()
+		if epoch <= current || ss.epoch.CompareAndSwap(current, epoch) {
+			break
+		}
+	}
+
+	switch st := ss.storage.(type) {
+	case *redisDB:
+		st.advanceEpoch(epoch)
+	case *bufferedStorage:
+		st.advanceEpoch(epoch)
+	}
+}
+
+// advanceEpoch moves the keys to the segment of the given epoch, unless they
+// are already in a later one.
+func (r *redisDB) advanceEpoch(epoch uint64) {
+	for {
+		current := r.epoch.Load()
+		if epoch <= current || r.epoch.CompareAndSwap(current, epoch) {
+			return
+		}
+	}
+}
+
+// revokeBefore revokes the sessions created before the given time, in Unix
+// nanoseconds, unless the ones created before a later time already are.
+func (ss *SessionStorage) revokeBefore(before int64) {
+	for {
+		current := ss.revokedBefore.Load()
+		if before <= current || ss.revokedBefore.CompareAndSwap(current, before) {
+			return
+		}
+	}
+}
+
+// revoked tells whether the entry was created before the time given to
+// RevokeCreatedBefore, or set before the last call to InvalidateAll.
+func (ss *SessionStorage) revoked(e Entry) bool {
+	if e.Epoch != 0 && e.Epoch <= ss.epoch.Load() {
+		return true
+	}
+
+	before := ss.revokedBefore.Load()
+	return before != 0 && !e.CreatedAt.IsZero() && e.CreatedAt.UnixNano() < before
+}
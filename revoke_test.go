@@ -0,0 +1,436 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRevokeAllForOwner(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Owners given on Set are revoked without an identity function", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10, WithOwner("user-42"))
+		other, _ := ss.Set(20)
+
+		if n, err := ss.RevokeAllForOwner(ctx, "user-42", nil); err != nil || n != 1 {
+			t.Errorf("got %d and error %v expected %d", n, err, 1)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		if _, _, err := ss.Get(other); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Every session of the owner is revoked", func(t *testing.T) {
+		ss, _ := New(WithIdentityFunc(accountID))
+		first, _ := ss.Set(account{"user-42"})
+		second, _ := ss.Set(account{"user-42"})
+		other, _ := ss.Set(account{"user-7"})
+
+		var reports []RevokeProgress
+		n, err := ss.RevokeAllForOwner(ctx, "user-42", func(p RevokeProgress) {
+			reports = append(reports, p)
+		})
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if n != 2 {
+			t.Errorf("got %d expected %d", n, 2)
+		}
+
+		if len(reports) != 2 || reports[1] != (RevokeProgress{Done: 2, Total: 2}) {
+			t.Errorf("got %v expected 2 reports ending with %v", reports, RevokeProgress{Done: 2, Total: 2})
+		}
+
+		for _, key := range []string{first, second} {
+			if _, _, err := ss.Get(key); !errors.Is(err, ErrNoKeyFound) {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		}
+
+		if _, _, err := ss.Get(other); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Failed revocations can be resumed", func(t *testing.T) {
+		fs := &flakyStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(fs), WithIdentityFunc(accountID))
+		for range 3 {
+			ss.Set(account{"user-42"})
+		}
+
+		fs.failRemoves = 1
+		var failed int
+		n, err := ss.RevokeAllForOwner(ctx, "user-42", func(p RevokeProgress) {
+			if p.Err != nil {
+				failed++
+			}
+		})
+
+		if !errors.Is(err, ErrRevocationIncomplete) || !errors.Is(err, errStorageDown) {
+			t.Errorf("got %v expected %v", err, ErrRevocationIncomplete)
+		}
+
+		if n != 2 || failed != 1 {
+			t.Errorf("got %d revoked and %d failed expected %d and %d", n, failed, 2, 1)
+		}
+
+		n, err = ss.RevokeAllForOwner(ctx, "user-42", nil)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if n != 1 {
+			t.Errorf("got %d expected %d", n, 1)
+		}
+
+		if got := fs.len(); got != 0 {
+			t.Errorf("got %d expected %d", got, 0)
+		}
+	})
+
+	t.Run("Dry runs revoke nothing", func(t *testing.T) {
+		ss, _ := New()
+		first, _ := ss.Set("a", WithOwner("user-42"))
+		second, _ := ss.Set("b", WithOwner("user-42"))
+		ss.Set("c", WithOwner("user-7"))
+
+		report, err := ss.RevokeAllForOwnerDryRun(ctx, "user-42", 1)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if report.Count != 2 {
+			t.Errorf("got %d expected %d", report.Count, 2)
+		}
+
+		if len(report.Sample) != 1 || report.Sample[0].Owner != "user-42" {
+			t.Errorf("got %v expected %d session of %s", report.Sample, 1, "user-42")
+		}
+
+		for _, key := range []string{first, second} {
+			if ok, _ := ss.Exists(key); !ok {
+				t.Errorf("got %v expected %v", ok, true)
+			}
+		}
+	})
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" dry runs work on read-only storages", func(t *testing.T) {
+			opt := backend(t)
+			a, _ := New(opt)
+			b, _ := NewReadOnly(opt)
+			defer Destroy(a)
+			defer Destroy(b)
+
+			a.Set("a", WithOwner("user-42"))
+
+			if report, err := b.RevokeAllForOwnerDryRun(ctx, "user-42", 1); err != nil || report.Count != 1 {
+				t.Errorf("got %d and error %v expected %d", report.Count, err, 1)
+			}
+		})
+	}
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" sessions of an owner are revoked whichever instance issued them", func(t *testing.T) {
+			opt := backend(t)
+			a, _ := New(opt)
+			b, _ := New(opt)
+			defer Destroy(a)
+			defer Destroy(b)
+
+			key, _ := a.Set("a", WithOwner("user-42"))
+			_, key, _ = b.Get(key)
+			other, _ := b.Set("b", WithOwner("user-42"))
+
+			if n, err := a.RevokeAllForOwner(ctx, "user-42", nil); err != nil || n != 2 {
+				t.Errorf("got %d and %v expected %d", n, err, 2)
+			}
+
+			for _, key := range []string{key, other} {
+				if _, _, err := b.Get(key); err != ErrNoKeyFound {
+					t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+				}
+			}
+		})
+	}
+}
+
+func TestRevokeCreatedBefore(t *testing.T) {
+	ctx := context.Background()
+
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}), nil))
+	multiRegionStorage, _ := New(WithMultiRegionRedis(
+		redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		nil,
+	))
+
+	storages := map[string]*SessionStorage{
+		"Sync map":           syncMapStorage,
+		"SQLite":             sqliteStorage,
+		"Redis":              redisStorage,
+		"Multi-region Redis": multiRegionStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" older sessions are revoked", func(t *testing.T) {
+			old, _ := ss.Set("a")
+			time.Sleep(time.Millisecond)
+			cutoff := time.Now()
+			time.Sleep(time.Millisecond)
+			recent, _ := ss.Set("b")
+
+			n, err := ss.RevokeCreatedBefore(ctx, cutoff)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if n != 1 {
+				t.Errorf("got %d expected %d", n, 1)
+			}
+
+			if _, _, err := ss.Get(old); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+
+			if _, _, err := ss.Get(recent); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+	}
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" revocations are shared by every instance", func(t *testing.T) {
+			opt := backend(t)
+			a, _ := New(opt)
+			b, _ := New(opt)
+			defer Destroy(a)
+			defer Destroy(b)
+
+			cutoff := time.Now()
+			if _, err := a.RevokeCreatedBefore(ctx, cutoff); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if err := b.refreshWatermarks(ctx); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			restarted, _ := New(opt)
+			defer Destroy(restarted)
+
+			for _, ss := range []*SessionStorage{b, restarted} {
+				if got := ss.revokedBefore.Load(); got != cutoff.UnixNano() {
+					t.Errorf("got %d expected %d", got, cutoff.UnixNano())
+				}
+			}
+		})
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" dry runs revoke nothing", func(t *testing.T) {
+			ss.Clear()
+			old, _ := ss.Set("a", WithOwner("user-42"))
+			time.Sleep(time.Millisecond)
+			cutoff := time.Now()
+			time.Sleep(time.Millisecond)
+			ss.Set("b")
+
+			report, err := ss.RevokeCreatedBeforeDryRun(ctx, cutoff, 1)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if report.Count != 1 || len(report.Sample) != 1 || report.Sample[0].Owner != "user-42" {
+				t.Errorf("got %v expected %d session of %s", report, 1, "user-42")
+			}
+
+			if _, err := ss.Peek(old); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if n, err := ss.RevokeCreatedBefore(ctx, cutoff); err != nil || n != 1 {
+				t.Errorf("got %d and error %v expected %d", n, err, 1)
+			}
+		})
+	}
+
+	t.Run("Dry runs need to list the sessions", func(t *testing.T) {
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}))
+
+		if _, err := ss.RevokeCreatedBeforeDryRun(ctx, time.Now(), 1); err != ErrRangeUnsupported {
+			t.Errorf("got %v expected %v", err, ErrRangeUnsupported)
+		}
+	})
+
+	t.Run("Sessions that couldn't be removed are still rejected", func(t *testing.T) {
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}))
+		key, _ := ss.Set("a")
+		time.Sleep(time.Millisecond)
+
+		if n, err := ss.RevokeCreatedBefore(ctx, time.Now()); err != nil || n != 0 {
+			t.Errorf("got %d and error %v expected %d", n, err, 0)
+		}
+
+		if ok, _ := ss.Exists(key); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+
+		if _, err := ss.Peek(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Revoked sessions are forgotten by their owners", func(t *testing.T) {
+		ss, _ := New()
+		ss.Set("a", WithOwner("user-42"))
+		time.Sleep(time.Millisecond)
+		ss.RevokeCreatedBefore(ctx, time.Now())
+
+		if n, _ := ss.RevokeAllForOwner(ctx, "user-42", nil); n != 0 {
+			t.Errorf("got %d expected %d", n, 0)
+		}
+	})
+
+	t.Run("Revocations never move backwards", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set("a")
+		time.Sleep(time.Millisecond)
+		cutoff := time.Now()
+
+		ss.RevokeCreatedBefore(ctx, cutoff)
+		ss.RevokeCreatedBefore(ctx, cutoff.Add(-time.Hour))
+
+		if _, err := ss.Peek(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+}
+
+func TestInvalidateAll(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithRedisPrefix("sessions:"))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{
+		"Sync map": syncMapStorage,
+		"Redis":    redisStorage,
+		"SQLite":   sqliteStorage,
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" sessions of past epochs are invalidated", func(t *testing.T) {
+			old, _ := ss.Set("a")
+			_, rotated, _ := ss.Get(old)
+			ss.InvalidateAll()
+			recent, _ := ss.Set("b")
+
+			if _, _, err := ss.Get(rotated); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+
+			session, newKey, err := ss.Get(recent)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if session != "b" {
+				t.Errorf("got %v expected %v", session, "b")
+			}
+
+			if _, _, err := ss.Get(newKey); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			ss.Clear()
+		})
+	}
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" invalidations are shared by every instance", func(t *testing.T) {
+			opt := backend(t)
+			a, _ := New(opt)
+			b, _ := New(opt)
+			defer Destroy(a)
+			defer Destroy(b)
+
+			old, _ := b.Set("a")
+			forgotten, _ := b.Set("b")
+
+			if err := a.InvalidateAll(); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if err := b.refreshWatermarks(context.Background()); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if _, _, err := b.Get(old); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+
+			recent, _ := b.Set("c")
+			if _, _, err := a.Get(recent); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			restarted, _ := New(opt)
+			defer Destroy(restarted)
+
+			if _, _, err := restarted.Get(forgotten); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		})
+	}
+
+	t.Run("Read-only storages can't invalidate sessions", func(t *testing.T) {
+		ss, _ := NewReadOnly()
+
+		if err := ss.InvalidateAll(); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+	})
+
+	t.Run("Redis only lists the sessions of the current epoch", func(t *testing.T) {
+		redisStorage.Set("a")
+		redisStorage.InvalidateAll()
+		key, _ := redisStorage.Set("b")
+
+		if got, _ := redisStorage.Count(); got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+
+		var listed []string
+		redisStorage.Range(func(key string, _ SessionInfo) bool {
+			listed = append(listed, key)
+			return true
+		})
+
+		if len(listed) != 1 || listed[0] != key {
+			t.Errorf("got %v expected %v", listed, []string{key})
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package suk
+
+import (
+	"sync"
+	"time"
+)
+
+// Scheduler drives the periodic background jobs of a session storage, such as
+// clearing expired keys or flushing the outage buffer.
+//
+// The default scheduler runs each job in its own goroutine using a ticker.
+// Environments where in-process goroutines are not an option, or test
+// harnesses, may drive the jobs themselves instead, for example with the
+// ManualScheduler.
+type Scheduler interface {
+	// Schedule arranges for job to be run every interval, until the returned
+	// stop function is called. Jobs never panic.
+	Schedule(interval time.Duration, job func()) (stop func())
+}
+
+// tickerScheduler runs each job in a goroutine, on every tick of its own
+// ticker.
+type tickerScheduler struct{}
+
+func (tickerScheduler) Schedule(interval time.Duration, job func()) func() {
+	stop := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				job()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
+
+// ManualScheduler is a Scheduler that never runs jobs by itself. Instead, every
+// job scheduled is run on each call to Run, which allows sweeps to be driven by
+// external cron jobs or task queues, such as the ones on Google App Engine:
+//
+//	sched := new(suk.ManualScheduler)
+//	ss, _ := suk.New(suk.WithAutoClearExpiredKeys(), suk.WithScheduler(sched))
+//
+//	http.HandleFunc("/cron/sweep", func(w http.ResponseWriter, r *http.Request) {
+//		sched.Run()
+//	})
+type ManualScheduler struct {
+	mu     sync.Mutex
+	nextID int
+	jobs   map[int]func()
+}
+
+// Schedule registers the job, ignoring the interval.
+func (ms *ManualScheduler) Schedule(_ time.Duration, job func()) func() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.jobs == nil {
+		ms.jobs = make(map[int]func())
+	}
+
+	id := ms.nextID
+	ms.nextID++
+	ms.jobs[id] = job
+
+	return func() {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		delete(ms.jobs, id)
+	}
+}
+
+// Run runs every scheduled job once, in the order they were scheduled.
+func (ms *ManualScheduler) Run() {
+	ms.mu.Lock()
+	jobs := make([]func(), 0, len(ms.jobs))
+	for id := range ms.nextID {
+		if job, ok := ms.jobs[id]; ok {
+			jobs = append(jobs, job)
+		}
+	}
+	ms.mu.Unlock()
+
+	for _, job := range jobs {
+		job()
+	}
+}
+
+// schedule schedules the job with the configured scheduler, recovering from
+// panics on each run so one bad run can't stop the job forever. It returns the
+// function that stops the job.
+func (ss *SessionStorage) schedule(name string, interval time.Duration, job func()) func() {
+	return ss.config.scheduler.Schedule(interval, func() {
+		ss.safeRun(name, job)
+	})
+}
@@ -0,0 +1,56 @@
+package suk
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestManualScheduler(t *testing.T) {
+	t.Run("Auto clear driven by a manual scheduler", func(t *testing.T) {
+		sched := new(ManualScheduler)
+		ss, _ := New(WithAutoClearExpiredKeys(), WithScheduler(sched))
+		defer Destroy(ss)
+
+		ss.storage.(*shardedMap).store("expired", Entry{Data: 10, ExpiresAt: time.Now().Add(-time.Minute)})
+		sched.Run()
+
+		if _, ok := ss.storage.(*shardedMap).load("expired"); ok {
+			t.Error("expected expired key to be cleared")
+		}
+	})
+
+	t.Run("Stopped jobs are not run", func(t *testing.T) {
+		sched := new(ManualScheduler)
+
+		runs := 0
+		stop := sched.Schedule(time.Minute, func() { runs++ })
+		sched.Run()
+		stop()
+		sched.Run()
+
+		if runs != 1 {
+			t.Errorf("got %d expected %d", runs, 1)
+		}
+	})
+
+	t.Run("Panicking jobs keep being scheduled", func(t *testing.T) {
+		sched := new(ManualScheduler)
+		ss, _ := New(WithScheduler(sched), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+		runs := 0
+		stop := ss.schedule("test", time.Minute, func() {
+			runs++
+			panic("boom")
+		})
+		defer stop()
+
+		sched.Run()
+		sched.Run()
+
+		if runs != 2 {
+			t.Errorf("got %d expected %d", runs, 2)
+		}
+	})
+}
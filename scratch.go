@@ -0,0 +1,272 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrNoScratchValue     = errors.New("No scratch value was found with the given name.")
+	ErrScratchUnsupported = errors.New("The session storage backend doesn't support scratch space.")
+)
+
+// scratchStorage is implemented by the backends supporting scratch space. The
+// scratch space of a key is moved along with it when it is rotated and dropped
+// when it is removed.
+type scratchStorage interface {
+	scratchSet(ctx context.Context, key, name string, v any) error
+	scratchGet(ctx context.Context, key, name string) (any, error)
+	scratchDelete(ctx context.Context, key, name string) error
+	scratchMove(ctx context.Context, oldKey, newKey string) error
+	scratchDrop(ctx context.Context, key string) error
+}
+
+// Scratch is the scratch space of a session, holding small ephemeral values,
+// such as upload progress or wizard state, that live as long as the session
+// does. The values follow the session through key rotations and are destroyed
+// along with it, when it expires or is removed. In Redis, values are encoded
+// with encoding/gob, and encrypted along with the sessions, so custom types
+// must be registered with gob.Register.
+type Scratch struct {
+	ss  *SessionStorage
+	ctx context.Context
+	key string
+}
+
+// Scratch returns the scratch space of the session with the given key. Using
+// the scratch space neither consumes nor rotates the key.
+func (ss *SessionStorage) Scratch(key string) *Scratch {
+	return ss.ScratchCtx(ss.ctx, key)
+}
+
+// ScratchCtx is like Scratch, but the storage operations run on the given
+// context, and the session is checked against the fingerprint or the channel
+// binding it carries, as with GetCtx.
+func (ss *SessionStorage) ScratchCtx(ctx context.Context, key string) *Scratch {
+	return &Scratch{ss: ss, ctx: ctx, key: key}
+}
+
+// storage returns the scratch storage, once the session is known to be live
+// and bound to the client of the context, if any.
+func (s *Scratch) storage() (scratchStorage, error) {
+	st, ok := s.ss.storage.(scratchStorage)
+	if !ok {
+		return nil, ErrScratchUnsupported
+	}
+
+	if _, err := s.ss.lookupEntry(s.ctx, s.key); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// Set stores the value under the given name.
+func (s *Scratch) Set(name string, v any) error {
+	if s.ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	defer s.ss.locks.lock(s.key)()
+	st, err := s.storage()
+	if err != nil {
+		return err
+	}
+
+	return st.scratchSet(s.ctx, s.ss.stored(s.key), name, v)
+}
+
+// Get retrieves the value stored under the given name.
+func (s *Scratch) Get(name string) (any, error) {
+	defer s.ss.locks.lock(s.key)()
+	st, err := s.storage()
+	if err != nil {
+		return nil, err
+	}
+
+	return st.scratchGet(s.ctx, s.ss.stored(s.key), name)
+}
+
+// Delete removes the value stored under the given name.
+func (s *Scratch) Delete(name string) error {
+	if s.ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	defer s.ss.locks.lock(s.key)()
+	st, err := s.storage()
+	if err != nil {
+		return err
+	}
+
+	return st.scratchDelete(s.ctx, s.ss.stored(s.key), name)
+}
+
+// scratchOf returns the scratch space of the live session under key.
+func (m *shardedMap) scratchOf(ctx context.Context, key string) (*sync.Map, error) {
+	e, err := m.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Until(e.ExpiresAt) <= 0 {
+		return nil, ErrKeyWasExpired
+	}
+
+	return e.scratch, nil
+}
+
+func (m *shardedMap) scratchSet(ctx context.Context, key, name string, v any) error {
+	scratch, err := m.scratchOf(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	scratch.Store(name, v)
+	return nil
+}
+
+func (m *shardedMap) scratchGet(ctx context.Context, key, name string) (any, error) {
+	scratch, err := m.scratchOf(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := scratch.Load(name)
+	if !ok {
+		return nil, ErrNoScratchValue
+	}
+
+	return v, nil
+}
+
+func (m *shardedMap) scratchDelete(ctx context.Context, key, name string) error {
+	scratch, err := m.scratchOf(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	scratch.Delete(name)
+	return nil
+}
+
+// scratchMove does nothing, as the scratch space is carried over by the entry
+// itself.
+func (m *shardedMap) scratchMove(_ context.Context, _, _ string) error {
+	return nil
+}
+
+// scratchDrop does nothing, as the scratch space is dropped along with the
+// entry.
+func (m *shardedMap) scratchDrop(_ context.Context, _ string) error {
+	return nil
+}
+
+// scratchKey returns the key holding the scratch space of the session under
+// key in Redis, before prefixing. Generated keys never contain a colon, so it
+// can't clash with them.
+func scratchKey(key string) string {
+	return "scratch:" + key
+}
+
+// scratchSetScript sets a field of the scratch space, giving it the same TTL as
+// its session, only if the session exists.
+var scratchSetScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl <= 0 then
+	return 0
+end
+
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[2], ttl)
+return 1
+`)
+
+// scratchGetScript gets a field of the scratch space, only if the session
+// exists, as the scratch space may outlive it by a few milliseconds.
+var scratchGetScript = redis.NewScript(`
+if redis.call('PTTL', KEYS[1]) <= 0 then
+	return {0}
+end
+
+return {1, redis.call('HGET', KEYS[2], ARGV[1])}
+`)
+
+// moveScratchScript moves the scratch space of a rotated session to its new
+// key, if there's any.
+var moveScratchScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+
+redis.call('RENAME', KEYS[1], KEYS[2])
+redis.call('PEXPIRE', KEYS[2], redis.call('PTTL', KEYS[3]))
+return 1
+`)
+
+func (r *redisDB) scratchMove(ctx context.Context, oldKey, newKey string) error {
+	keys := []string{r.scratchSpaceKey(oldKey), r.scratchSpaceKey(newKey), r.key(newKey)}
+	return moveScratchScript.Run(ctx, r.client, keys).Err()
+}
+
+func (r *redisDB) scratchDrop(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.scratchSpaceKey(key)).Err()
+}
+
+// scratchPayload serializes the scratch values with GobCodec, whatever the
+// codec of the sessions, so they come back with the type they were set with,
+// encrypting them as the sessions are.
+func (r *redisDB) scratchPayload() redisPayload {
+	return redisPayload{codec: GobCodec{}, sealer: r.payload.sealer}
+}
+
+func (r *redisDB) scratchSet(ctx context.Context, key, name string, v any) error {
+	raw, err := r.scratchPayload().encode(v)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{r.key(key), r.scratchSpaceKey(key)}
+	ok, err := scratchSetScript.Run(ctx, r.client, keys, name, raw).Int()
+	if err != nil {
+		return err
+	}
+
+	if ok == 0 {
+		return ErrNoKeyFound
+	}
+
+	return nil
+}
+
+func (r *redisDB) scratchGet(ctx context.Context, key, name string) (any, error) {
+	keys := []string{r.key(key), r.scratchSpaceKey(key)}
+	res, err := scratchGetScript.Run(ctx, r.client, keys, name).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, _ := res[0].(int64); ok == 0 {
+		return nil, ErrNoKeyFound
+	}
+
+	if len(res) < 2 {
+		return nil, ErrNoScratchValue
+	}
+
+	raw, ok := res[1].(string)
+	if !ok {
+		return nil, ErrNoScratchValue
+	}
+
+	v, _, err := r.scratchPayload().decode(raw)
+	return v, err
+}
+
+func (r *redisDB) scratchDelete(ctx context.Context, key, name string) error {
+	return r.client.HDel(ctx, r.scratchSpaceKey(key), name).Err()
+}
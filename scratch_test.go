@@ -0,0 +1,116 @@
+package suk
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestScratch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" scratch follows key rotations", func(t *testing.T) {
+			key, _ := ss.Set("session")
+
+			if err := ss.Scratch(key).Set("progress", "50"); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			_, newKey, _ := ss.Get(key)
+
+			got, err := ss.Scratch(newKey).Get("progress")
+			if err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if got != "50" {
+				t.Errorf("got %v expected %v", got, "50")
+			}
+
+			if _, err := ss.Scratch(key).Get("progress"); err == nil {
+				t.Error("expected error for rotated key")
+			}
+		})
+
+		t.Run(name+" scratch is destroyed along with the session", func(t *testing.T) {
+			key, _ := ss.Set("session")
+			ss.Scratch(key).Set("progress", "50")
+			ss.Remove(key)
+
+			if _, err := ss.Scratch(key).Get("progress"); err == nil {
+				t.Error("expected error for removed session")
+			}
+		})
+
+		t.Run(name+" scratch values can be deleted", func(t *testing.T) {
+			key, _ := ss.Set("session")
+			ss.Scratch(key).Set("progress", "50")
+			ss.Scratch(key).Delete("progress")
+
+			if _, err := ss.Scratch(key).Get("progress"); err != ErrNoScratchValue {
+				t.Errorf("got %v expected %v", err, ErrNoScratchValue)
+			}
+		})
+
+		t.Run(name+" scratch values keep their type", func(t *testing.T) {
+			key, _ := ss.Set("session")
+			ss.Scratch(key).Set("progress", 50)
+
+			if got, err := ss.Scratch(key).Get("progress"); err != nil || got != 50 {
+				t.Errorf("got %v and error %v expected %v", got, err, 50)
+			}
+		})
+
+		t.Run(name+" scratch of revoked sessions can't be read", func(t *testing.T) {
+			key, _ := ss.Set("session")
+			ss.Scratch(key).Set("progress", 50)
+			time.Sleep(time.Millisecond)
+			ss.RevokeCreatedBefore(context.Background(), time.Now())
+
+			if _, err := ss.Scratch(key).Get("progress"); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		})
+
+		t.Run(name+" scratch requires a session", func(t *testing.T) {
+			if err := ss.Scratch("unknown").Set("progress", "50"); err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		})
+	}
+
+	t.Run("Redis scratch values are encrypted", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithEncryption(make([]byte, 32)))
+
+		key, _ := ss.Set("session")
+		ss.Scratch(key).Set("progress", "secret progress")
+
+		if got := mr.HGet(scratchKey(key), "progress"); got == "" || strings.Contains(got, "secret progress") {
+			t.Errorf("got %q expected it encrypted", got)
+		}
+
+		if got, err := ss.Scratch(key).Get("progress"); err != nil || got != "secret progress" {
+			t.Errorf("got %v and error %v expected %v", got, err, "secret progress")
+		}
+	})
+
+	t.Run("Redis scratch expires along with the session", func(t *testing.T) {
+		key, _ := redisStorage.Set("session")
+		redisStorage.Scratch(key).Set("progress", "50")
+		mr.FastForward(defaultDurationToExpire + time.Second)
+
+		if _, err := redisStorage.Scratch(key).Get("progress"); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+}
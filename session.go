@@ -0,0 +1,66 @@
+package suk
+
+import (
+	"context"
+	"time"
+)
+
+// Session is a session along with the key it is stored under, as returned by
+// SetSession and GetSession, so the key can't be mistaken for anything else.
+type Session struct {
+	// Data is the session itself.
+	Data any
+
+	// Key is the key the session is now stored under, which must be handed to
+	// the client in place of the one given, if any.
+	Key string
+
+	// ExpiresAt is when Key expires, such as for the Expires of the cookie
+	// holding it.
+	ExpiresAt time.Time
+
+	// Meta describes the session.
+	Meta SessionInfo
+}
+
+// newSession returns the session of the entry stored under key.
+func (ss *SessionStorage) newSession(key string, e Entry) Session {
+	return Session{Data: e.Data, Key: key, ExpiresAt: e.ExpiresAt, Meta: ss.info(e)}
+}
+
+// SetSession is like Set, but returns the whole session.
+func (ss *SessionStorage) SetSession(data any, opts ...SetOption) (Session, error) {
+	return ss.SetSessionCtx(ss.ctx, data, opts...)
+}
+
+// SetSessionCtx is like SetSession, but the storage operations run on the
+// given context.
+func (ss *SessionStorage) SetSessionCtx(ctx context.Context, data any, opts ...SetOption) (Session, error) {
+	e, key, err := ss.set(ctx, data, opts)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return ss.newSession(key, e), nil
+}
+
+// GetSession is like Get, but returns the whole session.
+func (ss *SessionStorage) GetSession(key string) (Session, error) {
+	return ss.GetSessionCtx(ss.ctx, key)
+}
+
+// GetSessionCtx is like GetSession, but the storage operations run on the
+// given context.
+func (ss *SessionStorage) GetSessionCtx(ctx context.Context, key string) (Session, error) {
+	if err := ss.begin(false); err != nil {
+		return Session{}, err
+	}
+	defer ss.end()
+
+	e, newKey, err := ss.get(ctx, key)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return ss.newSession(newKey, e), nil
+}
@@ -0,0 +1,68 @@
+package suk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSession(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" sessions round-trip", func(t *testing.T) {
+			set, err := ss.SetSession("a", WithTTL(time.Hour))
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if set.Data != "a" || set.Key == "" || set.Meta.ID == "" {
+				t.Errorf("got %v expected a described session of %v", set, "a")
+			}
+
+			if d := time.Until(set.ExpiresAt); d <= 59*time.Minute || d > time.Hour {
+				t.Errorf("got %v expected about %v", d, time.Hour)
+			}
+
+			got, err := ss.GetSession(set.Key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got.Data != "a" || got.Key == set.Key || got.ExpiresAt.IsZero() {
+				t.Errorf("got %v expected %v under a new key", got, "a")
+			}
+
+			if _, _, err := ss.Get(got.Key); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		})
+	}
+
+	t.Run("Got sessions are described", func(t *testing.T) {
+		set, _ := syncMapStorage.SetSession("a", WithOwner("user-42"))
+		got, _ := syncMapStorage.GetSession(set.Key)
+
+		if got.Meta.ID != set.Meta.ID || got.Meta.Owner != "user-42" || got.Meta.Rotations != 1 {
+			t.Errorf("got %v expected %v rotated once", got.Meta, set.Meta)
+		}
+	})
+
+	t.Run("Unknown keys", func(t *testing.T) {
+		if _, err := syncMapStorage.GetSession("unknown"); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Nil sessions", func(t *testing.T) {
+		if _, err := syncMapStorage.SetSession(nil); err != ErrNilSession {
+			t.Errorf("got %v expected %v", err, ErrNilSession)
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package suk
+
+import (
+	"container/heap"
+	"context"
+	"hash/maphash"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultShards is the amount of shards used unless WithShards is given.
+const defaultShards = 32
+
+// shard is a part of the in-memory storage, with its own lock.
+type shard struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+
+	// expirations holds the expiration of every entry, soonest first, so the
+	// expired ones are found without going through the live ones. Entries
+	// removed before expiring are left behind, and skipped once popped.
+	expirations expirationHeap
+}
+
+// expiration is the time the entry under key expires at.
+type expiration struct {
+	at  time.Time
+	key string
+}
+
+// expirationHeap is a min-heap of expirations, implementing heap.Interface.
+type expirationHeap []expiration
+
+func (h expirationHeap) Len() int           { return len(h) }
+func (h expirationHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h expirationHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expirationHeap) Push(x any)        { *h = append(*h, x.(expiration)) }
+
+func (h *expirationHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// put stores the entry, tracking its expiration. It must be called with the
+// lock held.
+func (s *shard) put(stored string, e Entry) {
+	s.entries[stored] = e
+	heap.Push(&s.expirations, expiration{e.ExpiresAt, stored})
+
+	// Sessions mostly end by being rotated, rather than by expiring, so the
+	// heap is rebuilt from the live entries once most of it is left behind.
+	if len(s.expirations) > 2*len(s.entries)+64 {
+		s.expirations = s.expirations[:0]
+		for k, e := range s.entries {
+			s.expirations = append(s.expirations, expiration{e.ExpiresAt, k})
+		}
+		heap.Init(&s.expirations)
+	}
+}
+
+// clearExpired deletes the entries expired by now, adding them to dropped, if
+// not nil. It must be called with the lock held.
+func (s *shard) clearExpired(now time.Time, dropped map[string]Entry) {
+	for len(s.expirations) > 0 && !now.Before(s.expirations[0].at) {
+		x := heap.Pop(&s.expirations).(expiration)
+
+		// The key may have been removed, and even issued again, since.
+		if e, ok := s.entries[x.key]; ok && e.ExpiresAt.Equal(x.at) {
+			delete(s.entries, x.key)
+			if dropped != nil {
+				dropped[x.key] = e
+			}
+		}
+	}
+}
+
+// shardedMap stores the sessions in memory, split into shards by key, so
+// operations on different keys seldom wait on each other. Keys are stored as
+// they are given, which, but for the outage buffer, are the hashes given by
+// lookupKey, as the session storage always hashes the keys kept in memory.
+type shardedMap struct {
+	seed   maphash.Seed
+	shards []shard
+
+	// onExpire is told about the entries dropped because they expired, by
+	// the key they were stored with.
+	onExpire func(stored string, e Entry)
+}
+
+func newShardedMap(n int) *shardedMap {
+	m := &shardedMap{seed: maphash.MakeSeed(), shards: make([]shard, n)}
+	for i := range m.shards {
+		m.shards[i].entries = make(map[string]Entry)
+	}
+
+	return m
+}
+
+// shardOf returns the shard holding the stored key.
+func (m *shardedMap) shardOf(stored string) *shard {
+	return &m.shards[maphash.String(m.seed, stored)%uint64(len(m.shards))]
+}
+
+// load returns the entry under the stored key.
+func (m *shardedMap) load(stored string) (Entry, bool) {
+	s := m.shardOf(stored)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[stored]
+	return e, ok
+}
+
+// store puts the entry under the stored key, replacing any other.
+func (m *shardedMap) store(stored string, e Entry) {
+	s := m.shardOf(stored)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(stored, e)
+}
+
+// insertStored puts the entry under the stored key unless it is in use by a
+// live session, reporting whether it did.
+func (m *shardedMap) insertStored(stored string, e Entry) bool {
+	if e.scratch == nil {
+		e.scratch = new(sync.Map)
+	}
+
+	s := m.shardOf(stored)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.entries[stored]; ok && time.Now().Before(old.ExpiresAt) {
+		return false
+	}
+
+	s.put(stored, e)
+	return true
+}
+
+// delete removes the entry under the stored key.
+func (m *shardedMap) delete(stored string) {
+	s := m.shardOf(stored)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, stored)
+}
+
+// len returns how many entries are stored.
+func (m *shardedMap) len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.entries)
+		s.mu.RUnlock()
+	}
+
+	return n
+}
+
+// snapshot returns a copy of every entry stored, by stored key.
+func (m *shardedMap) snapshot() map[string]Entry {
+	entries := make(map[string]Entry)
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for k, e := range s.entries {
+			entries[k] = e
+		}
+		s.mu.RUnlock()
+	}
+
+	return entries
+}
+
+func (m *shardedMap) Set(_ context.Context, key string, e Entry) error {
+	if e.scratch == nil {
+		e.scratch = new(sync.Map)
+	}
+
+	s := m.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; ok {
+		return ErrKeyExists
+	}
+
+	s.put(key, e)
+	return nil
+}
+
+func (m *shardedMap) Get(_ context.Context, key string) (Entry, error) {
+	e, ok := m.load(key)
+	if !ok {
+		return Entry{}, ErrNoKeyFound
+	}
+
+	return e, nil
+}
+
+func (m *shardedMap) Remove(_ context.Context, key string) (Entry, error) {
+	s := m.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return Entry{}, ErrNoKeyFound
+	}
+
+	delete(s.entries, key)
+	return e, nil
+}
+
+// ClearExpired clears one shard at a time, so only the operations on the
+// shard being cleared wait for it. Only the expired entries are gone through,
+// however many live ones there are, so it is cheap enough to run often.
+func (m *shardedMap) ClearExpired(_ context.Context) error {
+	var dropped map[string]Entry
+	if m.onExpire != nil {
+		dropped = make(map[string]Entry)
+	}
+
+	now := time.Now()
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		s.clearExpired(now, dropped)
+		s.mu.Unlock()
+	}
+
+	for stored, e := range dropped {
+		m.onExpire(stored, e)
+	}
+
+	return nil
+}
+
+// keyLocks serializes the operations on the same key, such as rotating it
+// while its scratch space is used, while operations on keys of different
+// shards run in parallel.
+type keyLocks struct {
+	seed   maphash.Seed
+	shards []meteredMutex
+}
+
+func newKeyLocks(n int) *keyLocks {
+	return &keyLocks{seed: maphash.MakeSeed(), shards: make([]meteredMutex, n)}
+}
+
+// lock locks the shard of key, returning the function unlocking it.
+func (l *keyLocks) lock(key string) func() {
+	m := &l.shards[maphash.String(l.seed, key)%uint64(len(l.shards))]
+	m.Lock()
+	return m.Unlock
+}
+
+// lockMany locks the shards of every key, in order, returning the function
+// unlocking them.
+func (l *keyLocks) lockMany(keys []string) func() {
+	shards := make([]uint64, 0, len(keys))
+	for _, key := range keys {
+		shards = append(shards, maphash.String(l.seed, key)%uint64(len(l.shards)))
+	}
+	slices.Sort(shards)
+	shards = slices.Compact(shards)
+
+	for _, i := range shards {
+		l.shards[i].Lock()
+	}
+
+	return func() {
+		for _, i := range shards {
+			l.shards[i].Unlock()
+		}
+	}
+}
+
+// lockAll locks every shard, in order, returning the function unlocking them.
+func (l *keyLocks) lockAll() func() {
+	for i := range l.shards {
+		l.shards[i].Lock()
+	}
+
+	return func() {
+		for i := range l.shards {
+			l.shards[i].Unlock()
+		}
+	}
+}
+
+// stats sums up the contention on every shard.
+func (l *keyLocks) stats() LockStats {
+	var total LockStats
+	for i := range l.shards {
+		s := l.shards[i].stats()
+		total.Acquisitions += s.Acquisitions
+		total.Contended += s.Contended
+		total.TotalWait += s.TotalWait
+		total.MaxWait = max(total.MaxWait, s.MaxWait)
+		total.Waiting += s.Waiting
+		total.MaxWaiting = max(total.MaxWaiting, s.MaxWaiting)
+	}
+
+	return total
+}
@@ -0,0 +1,169 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	t.Run("Non-positive shard count", func(t *testing.T) {
+		_, err := New(WithShards(0))
+
+		if !errors.Is(err, ErrNonPositiveShards) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveShards)
+		}
+	})
+
+	t.Run("Shard count already set", func(t *testing.T) {
+		_, err := New(WithShards(4), WithShards(8))
+
+		if !errors.Is(err, ErrShardsAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrShardsAlreadySet)
+		}
+	})
+
+	t.Run("Sessions are spread over the shards", func(t *testing.T) {
+		ss, _ := New(WithShards(4))
+
+		for i := range 100 {
+			ss.Set(i)
+		}
+
+		m := ss.storage.(*shardedMap)
+		for i := range m.shards {
+			if len(m.shards[i].entries) == 0 {
+				t.Errorf("got empty shard %d", i)
+			}
+		}
+
+		if got := m.len(); got != 100 {
+			t.Errorf("got %d expected %d", got, 100)
+		}
+	})
+
+	t.Run("Concurrent rotations of the same key", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		var got atomic.Int64
+		wg := new(sync.WaitGroup)
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := ss.Get(key); err == nil {
+					got.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if got.Load() != 1 {
+			t.Errorf("got %d expected %d", got.Load(), 1)
+		}
+	})
+
+	t.Run("Lock stats add up every shard", func(t *testing.T) {
+		ss, _ := New(WithShards(4))
+
+		for i := range 10 {
+			key, _ := ss.Set(i)
+			ss.Get(key)
+		}
+
+		if got := ss.Stats().Lock.Acquisitions; got != 10 {
+			t.Errorf("got %d expected %d", got, 10)
+		}
+	})
+}
+
+func TestExpirationHeap(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Only expired sessions are cleared", func(t *testing.T) {
+		m := newShardedMap(1)
+		m.Set(ctx, "expired", Entry{Data: 1, ExpiresAt: time.Now().Add(-time.Minute)})
+		m.Set(ctx, "live", Entry{Data: 2, ExpiresAt: time.Now().Add(time.Hour)})
+
+		m.ClearExpired(ctx)
+
+		if _, ok := m.load("expired"); ok {
+			t.Error("expected expired key to be cleared")
+		}
+
+		if _, ok := m.load("live"); !ok {
+			t.Error("expected live key to be kept")
+		}
+	})
+
+	t.Run("Keys issued again are not cleared by their former expiration", func(t *testing.T) {
+		m := newShardedMap(1)
+		m.Set(ctx, "key", Entry{Data: 1, ExpiresAt: time.Now().Add(time.Millisecond)})
+		m.Remove(ctx, "key")
+		m.Set(ctx, "key", Entry{Data: 2, ExpiresAt: time.Now().Add(time.Hour)})
+
+		time.Sleep(2 * time.Millisecond)
+		m.ClearExpired(ctx)
+
+		if _, ok := m.load("key"); !ok {
+			t.Error("expected key issued again to be kept")
+		}
+	})
+
+	t.Run("Expirations of removed sessions don't pile up", func(t *testing.T) {
+		m := newShardedMap(1)
+		for i := range 1_000 {
+			key := strconv.Itoa(i)
+			m.Set(ctx, key, Entry{Data: i, ExpiresAt: time.Now().Add(time.Hour)})
+			m.Remove(ctx, key)
+		}
+
+		if got := len(m.shards[0].expirations); got > 65 {
+			t.Errorf("got %d expected at most %d", got, 65)
+		}
+	})
+}
+
+// counterKeys generates unique keys cheaply, so benchmarks measure the storage
+// rather than crypto/rand.
+func counterKeys() Option {
+	var n atomic.Uint64
+	return WithCustomRandomKeyGenerator(func(uint64) (string, error) {
+		return strconv.FormatUint(n.Add(1), 36), nil
+	})
+}
+
+func BenchmarkShards(b *testing.B) {
+	for _, shards := range []int{1, 8, 32, 128} {
+		b.Run(fmt.Sprintf("%d shards", shards), func(b *testing.B) {
+			ss, _ := New(WithShards(shards), counterKeys())
+			defer Destroy(ss)
+
+			b.RunParallel(func(pb *testing.PB) {
+				key, _ := ss.Set(10)
+				for pb.Next() {
+					_, key, _ = ss.Get(key)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkClearExpired(b *testing.B) {
+	ctx := context.Background()
+	m := newShardedMap(defaultShards)
+	for i := range 100_000 {
+		m.Set(ctx, strconv.Itoa(i), Entry{Data: i, ExpiresAt: time.Now().Add(time.Hour)})
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		m.ClearExpired(ctx)
+	}
+}
@@ -0,0 +1,67 @@
+package suk
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrSessionTooLarge = errors.New("The session is larger than the maximum session size.")
+
+// SessionTooLargeError is returned when a session is larger than the maximum
+// set with WithMaxSessionSize. It matches ErrSessionTooLarge with errors.Is.
+type SessionTooLargeError struct {
+	// Size is how many bytes the serialized session takes.
+	Size int
+
+	// Max is the maximum session size, in bytes.
+	Max int
+}
+
+func (e *SessionTooLargeError) Error() string {
+	return fmt.Sprintf("The session takes %d bytes, over the maximum session size of %d bytes.", e.Size, e.Max)
+}
+
+func (e *SessionTooLargeError) Is(target error) bool {
+	return target == ErrSessionTooLarge
+}
+
+// checkSize fails with a SessionTooLargeError when the session is larger than
+// the maximum session size, if any.
+func (ss *SessionStorage) checkSize(session any) error {
+	maxSize := ss.currentPolicy().config.maxSessionSize
+	if maxSize == 0 {
+		return nil
+	}
+
+	size, err := ss.sessionSize(session)
+	if err != nil {
+		return err
+	}
+
+	if size > maxSize {
+		return &SessionTooLargeError{Size: size, Max: maxSize}
+	}
+
+	return nil
+}
+
+// sessionSize returns how many bytes the session takes once serialized by the
+// codec, if any. Strings and byte slices are measured as they are, and every
+// other session as encoded with encoding/gob, as the backends storing bytes
+// do.
+func (ss *SessionStorage) sessionSize(session any) (int, error) {
+	if ss.config.codec != nil {
+		raw, err := ss.config.codec.Marshal(session)
+		return len(raw), err
+	}
+
+	switch s := session.(type) {
+	case string:
+		return len(s), nil
+	case []byte:
+		return len(s), nil
+	}
+
+	raw, err := encodeData(session)
+	return len(raw), err
+}
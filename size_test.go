@@ -0,0 +1,78 @@
+package suk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMaxSessionSize(t *testing.T) {
+	t.Run("Sessions within the limit are set", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionSize(8))
+
+		if _, err := ss.Set(strings.Repeat("a", 8)); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Larger sessions are rejected", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionSize(8))
+
+		_, err := ss.Set(strings.Repeat("a", 9))
+		if !errors.Is(err, ErrSessionTooLarge) {
+			t.Fatalf("got %v expected %v", err, ErrSessionTooLarge)
+		}
+
+		var tooLarge *SessionTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Size != 9 || tooLarge.Max != 8 {
+			t.Errorf("got %v expected a session of 9 bytes over 8", err)
+		}
+	})
+
+	t.Run("Larger updates are rejected", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionSize(8))
+		key, _ := ss.Set("a")
+
+		err := ss.Update(key, func(old any) any {
+			return strings.Repeat("a", 9)
+		})
+		if !errors.Is(err, ErrSessionTooLarge) {
+			t.Errorf("got %v expected %v", err, ErrSessionTooLarge)
+		}
+
+		if got, _ := ss.Peek(key); got != "a" {
+			t.Errorf("got %v expected %v", got, "a")
+		}
+	})
+
+	t.Run("Larger sessions in a batch are rejected", func(t *testing.T) {
+		ss, _ := New(WithMaxSessionSize(8))
+
+		if _, err := ss.SetMany([]any{"a", strings.Repeat("a", 9)}); !errors.Is(err, ErrSessionTooLarge) {
+			t.Errorf("got %v expected %v", err, ErrSessionTooLarge)
+		}
+	})
+
+	t.Run("Sessions are measured as serialized by the codec", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil), WithCodec(JSONCodec{}), WithMaxSessionSize(8))
+
+		// The quotes take the session over the limit.
+		if _, err := ss.Set(strings.Repeat("a", 7)); !errors.Is(err, ErrSessionTooLarge) {
+			t.Errorf("got %v expected %v", err, ErrSessionTooLarge)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithMaxSessionSize(0)); !errors.Is(err, ErrNonPositiveMaxSessionSize) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveMaxSessionSize)
+		}
+
+		if _, err := New(WithMaxSessionSize(1), WithMaxSessionSize(2)); !errors.Is(err, ErrMaxSessionSizeAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrMaxSessionSizeAlreadySet)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package suk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrNoSQLiteDriver = errors.New("No SQLite driver was registered with database/sql.")
+
+// sqliteDrivers lists the names SQLite drivers register themselves under, such
+// as modernc.org/sqlite and github.com/mattn/go-sqlite3.
+var sqliteDrivers = []string{"sqlite", "sqlite3"}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS suk_sessions (
+	key        TEXT PRIMARY KEY,
+	data       BLOB NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS suk_sessions_expires_at ON suk_sessions (expires_at);
+CREATE TABLE IF NOT EXISTS suk_watermarks (
+	name  TEXT PRIMARY KEY,
+	value INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS suk_families (
+	id    TEXT PRIMARY KEY,
+	key   TEXT NOT NULL,
+	owner TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS suk_families_key ON suk_families (key);
+CREATE INDEX IF NOT EXISTS suk_families_owner ON suk_families (owner);
+CREATE TRIGGER IF NOT EXISTS suk_sessions_families AFTER DELETE ON suk_sessions BEGIN
+	DELETE FROM suk_families WHERE key = old.key;
+END;
+`
+
+// sqliteDB stores the sessions in a SQLite database. Sessions are encoded with
+// encoding/gob, so custom types must be registered with gob.Register.
+type sqliteDB struct {
+	db *sql.DB
+
+	// sealer encrypts the sessions, when WithEncryption is set.
+	sealer *sealer
+}
+
+// openSQLite opens the SQLite database at path, creating the sessions table
+// when needed, with whichever SQLite driver was registered.
+func openSQLite(path string) (*sqliteDB, error) {
+	drivers := sql.Drivers()
+	i := slices.IndexFunc(sqliteDrivers, func(name string) bool {
+		return slices.Contains(drivers, name)
+	})
+	if i == -1 {
+		return nil, ErrNoSQLiteDriver
+	}
+
+	db, err := sql.Open(sqliteDrivers[i], path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite serializes writes anyway, and a single connection avoids both
+	// SQLITE_BUSY errors and every connection getting its own in-memory
+	// database.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteDB{db: db}, nil
+}
+
+func (s *sqliteDB) Set(ctx context.Context, key string, e Entry) error {
+	data, err := encodeEntryData(e, s.sealer)
+	if err != nil {
+		return err
+	}
+
+	// Expired sessions not cleared yet don't hold on to their keys.
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO suk_sessions (key, data, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET data = excluded.data, expires_at = excluded.expires_at
+		WHERE suk_sessions.expires_at <= ?`,
+		key,
+		data,
+		e.ExpiresAt.UnixNano(),
+		time.Now().UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrKeyExists
+	}
+
+	if e.ID == "" {
+		return nil
+	}
+
+	// The trigger drops the index entry once the session is deleted.
+	_, err = s.db.ExecContext(
+		ctx,
+		`INSERT INTO suk_families (id, key, owner) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET key = excluded.key, owner = excluded.owner`,
+		e.ID,
+		key,
+		e.Owner,
+	)
+	return err
+}
+
+func (s *sqliteDB) entry(row *sql.Row) (Entry, error) {
+	var (
+		raw       []byte
+		expiresAt int64
+	)
+
+	err := row.Scan(&raw, &expiresAt)
+	if err == sql.ErrNoRows {
+		return Entry{}, ErrNoKeyFound
+	} else if err != nil {
+		return Entry{}, err
+	}
+
+	e, err := decodeEntryData(raw, s.sealer)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e.ExpiresAt = time.Unix(0, expiresAt)
+	return e, nil
+}
+
+func (s *sqliteDB) Get(ctx context.Context, key string) (Entry, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT data, expires_at FROM suk_sessions WHERE key = ?`,
+		key,
+	)
+
+	return s.entry(row)
+}
+
+func (s *sqliteDB) Remove(ctx context.Context, key string) (Entry, error) {
+	row := s.db.QueryRowContext(
+		ctx,
+		`DELETE FROM suk_sessions WHERE key = ? RETURNING data, expires_at`,
+		key,
+	)
+
+	return s.entry(row)
+}
+
+func (s *sqliteDB) ClearExpired(ctx context.Context) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM suk_sessions WHERE expires_at <= ?`,
+		time.Now().UnixNano(),
+	)
+	return err
+}
+
+func (s *sqliteDB) Close() error {
+	return s.db.Close()
+}
+
+// Sweep checks the keys in batches of maxKeys. As a single statement can't be
+// paused, the deadline cancels the statement instead.
+func (s *sqliteDB) Sweep(ctx context.Context, cursor string, maxKeys int, deadline time.Time) (string, error) {
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	// A negative limit means no limit to SQLite.
+	limit := -1
+	if maxKeys > 0 {
+		limit = maxKeys
+	}
+
+	var (
+		n    int
+		last sql.NullString
+	)
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*), MAX(key) FROM (SELECT key FROM suk_sessions WHERE key > ? ORDER BY key LIMIT ?)`,
+		cursor,
+		limit,
+	).Scan(&n, &last)
+	if err != nil {
+		return cursor, err
+	}
+
+	if n == 0 {
+		return "", nil
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		`DELETE FROM suk_sessions WHERE key > ? AND key <= ? AND expires_at <= ?`,
+		cursor,
+		last.String,
+		time.Now().UnixNano(),
+	)
+	if err != nil {
+		return cursor, err
+	}
+
+	if limit < 0 || n < limit {
+		return "", nil
+	}
+
+	return last.String, nil
+}
@@ -0,0 +1,105 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLite(t *testing.T) {
+	t.Run("Empty path", func(t *testing.T) {
+		_, err := New(WithSQLite(""))
+
+		if !errors.Is(err, ErrEmptySQLitePath) {
+			t.Errorf("got %v expected %v", err, ErrEmptySQLitePath)
+		}
+	})
+
+	t.Run("SQLite can't be used along with another storage", func(t *testing.T) {
+		_, err := New(WithStorage(&countingStorage{}), WithSQLite("sessions.db"))
+
+		if !errors.Is(err, ErrStorageAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrStorageAlreadySet)
+		}
+	})
+
+	t.Run("Sessions survive restarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.db")
+
+		ss, err := New(WithSQLite(path))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		Destroy(ss)
+
+		ss, err = New(WithSQLite(path))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer Destroy(ss)
+
+		session, newKey, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+
+		if err := ss.Remove(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Expired sessions are cleared", func(t *testing.T) {
+		ss, err := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")), WithKeyDuration(time.Millisecond))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer Destroy(ss)
+
+		key, _ := ss.Set("session")
+		time.Sleep(5 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); !errors.Is(err, ErrKeyWasExpired) {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+
+		ss.ClearExpired()
+
+		var count int
+		ss.storage.(*sqliteDB).db.QueryRow(`SELECT COUNT(*) FROM suk_sessions`).Scan(&count)
+		if count != 0 {
+			t.Errorf("got %d expected %d", count, 0)
+		}
+	})
+
+	t.Run("Colliding keys are reported", func(t *testing.T) {
+		db, err := openSQLite(filepath.Join(t.TempDir(), "sessions.db"))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer db.Close()
+
+		e := Entry{Data: "session", ExpiresAt: time.Now().Add(time.Hour)}
+		db.Set(context.Background(), "key", e)
+
+		if err := db.Set(context.Background(), "key", e); !errors.Is(err, ErrKeyExists) {
+			t.Errorf("got %v expected %v", err, ErrKeyExists)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Dialect holds the SQL differences between databases. Queries are built with
+// the name of the table, already quoted when needed.
+type Dialect struct {
+	// Placeholder returns the placeholder of the nth argument of a query,
+	// counting from 1.
+	Placeholder func(n int) string
+
+	// Insert returns the statement inserting a session, with the arguments
+	// id, data, expires_at and the current time, in that order. A session
+	// already stored under the id must only be replaced if it expired by the
+	// current time, so no row is affected when the id is taken.
+	Insert func(table string, p func(n int) string) string
+
+	// Migrations returns the statements creating and evolving the table, in
+	// order. Statements already applied must never change, as only the ones
+	// after the last applied are run.
+	Migrations func(table string) []string
+}
+
+// SQLite is the dialect for SQLite 3.24 and newer.
+var SQLite = Dialect{
+	Placeholder: func(int) string { return "?" },
+	Insert: func(table string, p func(int) string) string {
+		return fmt.Sprintf(
+			`INSERT INTO %[1]s (id, data, expires_at) VALUES (%[2]s, %[3]s, %[4]s)
+			ON CONFLICT (id) DO UPDATE SET data = excluded.data, expires_at = excluded.expires_at
+			WHERE %[1]s.expires_at <= %[5]s`,
+			table, p(1), p(2), p(3), p(4),
+		)
+	},
+	Migrations: func(table string) []string {
+		return []string{
+			fmt.Sprintf(`CREATE TABLE %s (id TEXT PRIMARY KEY, data BLOB NOT NULL, expires_at INTEGER NOT NULL)`, table),
+			fmt.Sprintf(`CREATE INDEX %[1]s_expires_at ON %[1]s (expires_at)`, table),
+		}
+	},
+}
+
+// Postgres is the dialect for PostgreSQL 9.5 and newer.
+var Postgres = Dialect{
+	Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	Insert:      SQLite.Insert,
+	Migrations: func(table string) []string {
+		return []string{
+			fmt.Sprintf(`CREATE TABLE %s (id TEXT PRIMARY KEY, data BYTEA NOT NULL, expires_at BIGINT NOT NULL)`, table),
+			fmt.Sprintf(`CREATE INDEX %[1]s_expires_at ON %[1]s (expires_at)`, table),
+		}
+	},
+}
+
+// MySQL is the dialect for MySQL and MariaDB. Updating a row to the values it
+// already holds doesn't count as affecting it, which Insert relies on.
+var MySQL = Dialect{
+	Placeholder: func(int) string { return "?" },
+	Insert: func(table string, p func(int) string) string {
+		return fmt.Sprintf(
+			`INSERT INTO %[1]s (id, data, expires_at) VALUES (%[2]s, %[3]s, %[4]s)
+			ON DUPLICATE KEY UPDATE
+			data = IF(expires_at <= %[5]s, VALUES(data), data),
+			expires_at = IF(expires_at <= %[5]s, VALUES(expires_at), expires_at)`,
+			table, p(1), p(2), p(3), p(4),
+		)
+	},
+	Migrations: func(table string) []string {
+		return []string{
+			fmt.Sprintf(`CREATE TABLE %s (id VARCHAR(255) PRIMARY KEY, data LONGBLOB NOT NULL, expires_at BIGINT NOT NULL)`, table),
+			fmt.Sprintf(`CREATE INDEX %[1]s_expires_at ON %[1]s (expires_at)`, table),
+		}
+	},
+}
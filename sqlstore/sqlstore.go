@@ -0,0 +1,335 @@
+// Package sqlstore provides a database/sql backend for suk, so sessions can be
+// kept in any SQL database with a Dialect for it. The table holding the
+// sessions is created and migrated by New:
+//
+//	db, _ := sql.Open("pgx", "postgres://localhost/app")
+//	store, _ := sqlstore.New(context.Background(), db, sqlstore.Postgres, "sessions")
+//	ss, _ := suk.New(suk.WithStorage(store))
+//
+// Sessions are encoded with encoding/gob, so custom types must be registered
+// with gob.Register.
+package sqlstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+// DefaultTable is the table the sessions are kept in when none is given.
+const DefaultTable = "suk_sessions"
+
+var (
+	ErrNilDB             = errors.New("The given database must not be nil.")
+	ErrIncompleteDialect = errors.New("The given dialect must set Placeholder, Insert and Migrations.")
+	ErrUnknownMigration  = errors.New("The table was migrated by a newer version of sqlstore.")
+	ErrMigrationFailed   = errors.New("A migration of the sessions table failed.")
+)
+
+// Store stores the sessions in a table of a SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+
+	insert, get, take, clear string
+
+	getWatermark, insertWatermark, raiseWatermark string
+
+	findFamily, dropFamily, insertFamily, takeFamily, clearFamilies, findOwned string
+}
+
+// New creates a store keeping the sessions in the given table, applying every
+// migration of the dialect not applied to it yet. The migrations applied are
+// tracked in a table named after it, with the suffix "_migrations", the
+// watermarks of suk, such as the epoch bumped by InvalidateAll, are kept in
+// another one, with the suffix "_watermarks", and the index of the sessions by
+// ID and by owner in a third one, with the suffix "_families".
+func New(ctx context.Context, db *sql.DB, dialect Dialect, table string) (*Store, error) {
+	if db == nil {
+		return nil, ErrNilDB
+	}
+
+	if dialect.Placeholder == nil || dialect.Insert == nil || dialect.Migrations == nil {
+		return nil, ErrIncompleteDialect
+	}
+
+	if table == "" {
+		table = DefaultTable
+	}
+
+	p := dialect.Placeholder
+	s := &Store{
+		db:      db,
+		dialect: dialect,
+		table:   table,
+		insert:  dialect.Insert(table, p),
+		get:     fmt.Sprintf(`SELECT data, expires_at FROM %s WHERE id = %s`, table, p(1)),
+		take:    fmt.Sprintf(`DELETE FROM %s WHERE id = %s`, table, p(1)),
+		clear:   fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= %s`, table, p(1)),
+
+		getWatermark:    fmt.Sprintf(`SELECT value FROM %s_watermarks WHERE name = %s`, table, p(1)),
+		insertWatermark: fmt.Sprintf(`INSERT INTO %s_watermarks (name, value) VALUES (%s, %s)`, table, p(1), p(2)),
+		raiseWatermark:  fmt.Sprintf(`UPDATE %s_watermarks SET value = %s WHERE name = %s AND value < %s`, table, p(1), p(2), p(3)),
+
+		findFamily:    fmt.Sprintf(`SELECT id FROM %s_families WHERE session_id = %s`, table, p(1)),
+		dropFamily:    fmt.Sprintf(`DELETE FROM %s_families WHERE session_id = %s`, table, p(1)),
+		insertFamily:  fmt.Sprintf(`INSERT INTO %s_families (session_id, id, expires_at, owner) VALUES (%s, %s, %s, %s)`, table, p(1), p(2), p(3), p(4)),
+		takeFamily:    fmt.Sprintf(`DELETE FROM %s_families WHERE session_id = %s AND id = %s`, table, p(1), p(2)),
+		clearFamilies: fmt.Sprintf(`DELETE FROM %s_families WHERE expires_at <= %s`, table, p(1)),
+		findOwned:     fmt.Sprintf(`SELECT session_id FROM %s_families WHERE owner = %s AND expires_at > %s`, table, p(1), p(2)),
+	}
+
+	if err := s.Migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Migrate applies every migration of the dialect not applied to the table yet,
+// each in its own transaction. It is called by New, so it only needs to be
+// called again if the table is dropped while the store is in use.
+func (s *Store) Migrate(ctx context.Context) error {
+	p := s.dialect.Placeholder
+	versions := s.table + "_migrations"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL PRIMARY KEY)`, versions),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s_watermarks (name VARCHAR(255) NOT NULL PRIMARY KEY, value BIGINT NOT NULL)`, s.table),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s_families (session_id VARCHAR(255) NOT NULL PRIMARY KEY, id VARCHAR(255) NOT NULL, expires_at BIGINT NOT NULL, owner VARCHAR(255) NOT NULL)`, s.table),
+	)
+	if err != nil {
+		return err
+	}
+
+	var applied int
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s`, versions)).Scan(&applied)
+	if err != nil {
+		return err
+	}
+
+	migrations := s.dialect.Migrations(s.table)
+	if applied > len(migrations) {
+		return ErrUnknownMigration
+	}
+
+	for i, stmt := range migrations[applied:] {
+		version := applied + i + 1
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+
+			// Another instance applying the same migration concurrently makes
+			// this fail on the primary key, rolling the migration back.
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (version) VALUES (%s)`, versions, p(1)), version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%w: version %d: %w", ErrMigrationFailed, version, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) Set(ctx context.Context, key string, e suk.Entry) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return err
+	}
+
+	// Expired sessions not cleared yet don't hold on to their keys. The
+	// session is indexed by ID in the same transaction.
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, s.insert, key, buf.Bytes(), e.ExpiresAt.UnixNano(), time.Now().UnixNano())
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return suk.ErrKeyExists
+		}
+
+		if e.ID == "" {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, s.dropFamily, e.ID); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, s.insertFamily, e.ID, key, e.ExpiresAt.UnixNano(), e.Owner)
+		return err
+	})
+}
+
+func (s *Store) Get(ctx context.Context, key string) (suk.Entry, error) {
+	return s.load(ctx, s.db.QueryRowContext(ctx, s.get, key))
+}
+
+func (s *Store) load(ctx context.Context, row *sql.Row) (suk.Entry, error) {
+	var (
+		data      []byte
+		expiresAt int64
+	)
+
+	if err := row.Scan(&data, &expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return suk.Entry{}, suk.ErrNoKeyFound
+		}
+
+		return suk.Entry{}, err
+	}
+
+	var e suk.Entry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return suk.Entry{}, err
+	}
+
+	return e, nil
+}
+
+// Remove reads and deletes the session in a single transaction. When two calls
+// race for the same key, only the one whose delete affects the row gets the
+// session.
+func (s *Store) Remove(ctx context.Context, key string) (suk.Entry, error) {
+	var e suk.Entry
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		e, err = s.load(ctx, tx.QueryRowContext(ctx, s.get, key))
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, s.take, key)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return suk.ErrNoKeyFound
+		}
+
+		// The index entry is left alone once the session was rotated.
+		_, err = tx.ExecContext(ctx, s.takeFamily, e.ID, key)
+		return err
+	})
+	if err != nil {
+		return suk.Entry{}, err
+	}
+
+	return e, nil
+}
+
+func (s *Store) ClearExpired(ctx context.Context) error {
+	now := time.Now().UnixNano()
+	if _, err := s.db.ExecContext(ctx, s.clear, now); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, s.clearFamilies, now)
+	return err
+}
+
+func (s *Store) FindSession(ctx context.Context, id string) (string, error) {
+	var key string
+	err := s.db.QueryRowContext(ctx, s.findFamily, id).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", suk.ErrNoKeyFound
+	}
+
+	return key, err
+}
+
+func (s *Store) FindOwnedSessions(ctx context.Context, owner string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.findOwned, owner, time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// RaiseWatermark reads and raises the watermark in a single transaction.
+func (s *Store) RaiseWatermark(ctx context.Context, name string, value int64) (int64, error) {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var stored int64
+		err := tx.QueryRowContext(ctx, s.getWatermark, name).Scan(&stored)
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = tx.ExecContext(ctx, s.insertWatermark, name, value)
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		if stored >= value {
+			value = stored
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, s.raiseWatermark, value, name, value)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,294 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+	"github.com/ed-henrique/suk/suktest"
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("got error %s", err.Error())
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Nil database", func(t *testing.T) {
+		_, err := New(ctx, nil, SQLite, "")
+
+		if !errors.Is(err, ErrNilDB) {
+			t.Errorf("got %v expected %v", err, ErrNilDB)
+		}
+	})
+
+	t.Run("Incomplete dialect", func(t *testing.T) {
+		_, err := New(ctx, openDB(t), Dialect{}, "")
+
+		if !errors.Is(err, ErrIncompleteDialect) {
+			t.Errorf("got %v expected %v", err, ErrIncompleteDialect)
+		}
+	})
+
+	t.Run("Sessions are single use", func(t *testing.T) {
+		store, err := New(ctx, openDB(t), SQLite, "")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		ss, err := suk.New(suk.WithStorage(store))
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer suk.Destroy(ss)
+
+		key, err := ss.Set(42)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		session, _, err := ss.Get(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if session != 42 {
+			t.Errorf("got %v expected %v", session, 42)
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Live keys are not replaced, expired ones are", func(t *testing.T) {
+		store, _ := New(ctx, openDB(t), SQLite, "")
+
+		live := suk.Entry{Data: 1, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := store.Set(ctx, "live", live); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if err := store.Set(ctx, "live", live); !errors.Is(err, suk.ErrKeyExists) {
+			t.Errorf("got %v expected %v", err, suk.ErrKeyExists)
+		}
+
+		store.Set(ctx, "expired", suk.Entry{Data: 1, ExpiresAt: time.Now().Add(-time.Minute)})
+		if err := store.Set(ctx, "expired", suk.Entry{Data: 2, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		e, err := store.Get(ctx, "expired")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if e.Data != 2 {
+			t.Errorf("got %v expected %v", e.Data, 2)
+		}
+	})
+
+	t.Run("Expired sessions are cleared", func(t *testing.T) {
+		store, _ := New(ctx, openDB(t), SQLite, "")
+
+		store.Set(ctx, "expired", suk.Entry{Data: 1, ExpiresAt: time.Now().Add(-time.Minute)})
+		store.Set(ctx, "live", suk.Entry{Data: 1, ExpiresAt: time.Now().Add(time.Hour)})
+
+		if err := store.ClearExpired(ctx); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := store.Get(ctx, "expired"); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if _, err := store.Get(ctx, "live"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Invalidations are kept in the database", func(t *testing.T) {
+		db := openDB(t)
+		store, _ := New(ctx, db, SQLite, "")
+		ss, _ := suk.New(suk.WithStorage(store))
+
+		key, _ := ss.Set(42)
+		if err := ss.InvalidateAll(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		suk.Destroy(ss)
+
+		store, _ = New(ctx, db, SQLite, "")
+		ss, _ = suk.New(suk.WithStorage(store))
+		defer suk.Destroy(ss)
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if got, err := store.RaiseWatermark(ctx, "epoch", 0); err != nil || got != 1 {
+			t.Errorf("got %d and error %v expected %d", got, err, 1)
+		}
+	})
+
+	t.Run("Sessions are revoked whichever instance rotated them", func(t *testing.T) {
+		store, _ := New(ctx, openDB(t), SQLite, "")
+		a, _ := suk.New(suk.WithStorage(store))
+		b, _ := suk.New(suk.WithStorage(store))
+
+		key, _ := a.Set(42)
+		id, _ := a.SessionID(key)
+		_, key, _ = b.Get(key)
+
+		if err := a.RevokeSession(id); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions of an owner are revoked whichever instance issued them", func(t *testing.T) {
+		store, _ := New(ctx, openDB(t), SQLite, "")
+		a, _ := suk.New(suk.WithStorage(store))
+		b, _ := suk.New(suk.WithStorage(store))
+
+		key, _ := a.Set(42, suk.WithOwner("user-42"))
+		_, key, _ = b.Get(key)
+		b.Set(43, suk.WithOwner("user-42"))
+
+		if ids, err := store.FindOwnedSessions(ctx, "user-42"); err != nil || len(ids) != 2 {
+			t.Fatalf("got %v and error %v expected two IDs", ids, err)
+		}
+
+		if n, err := a.RevokeAllForOwner(ctx, "user-42", nil); err != nil || n != 2 {
+			t.Errorf("got %d and error %v expected %d", n, err, 2)
+		}
+
+		if _, _, err := b.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Index entries go away with their sessions", func(t *testing.T) {
+		store, _ := New(ctx, openDB(t), SQLite, "")
+
+		store.Set(ctx, "removed", suk.Entry{Data: 1, ID: "a", ExpiresAt: time.Now().Add(time.Hour)})
+		store.Set(ctx, "expired", suk.Entry{Data: 1, ID: "b", ExpiresAt: time.Now().Add(-time.Hour)})
+
+		if key, err := store.FindSession(ctx, "a"); err != nil || key != "removed" {
+			t.Errorf("got %q and error %v expected %q", key, err, "removed")
+		}
+
+		store.Remove(ctx, "removed")
+		store.ClearExpired(ctx)
+
+		for _, id := range []string{"a", "b"} {
+			if _, err := store.FindSession(ctx, id); !errors.Is(err, suk.ErrNoKeyFound) {
+				t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+			}
+		}
+	})
+
+	t.Run("Migrations are only applied once", func(t *testing.T) {
+		db := openDB(t)
+
+		store, err := New(ctx, db, SQLite, "sessions")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		store.Set(ctx, "key", suk.Entry{Data: 1, ExpiresAt: time.Now().Add(time.Hour)})
+
+		store, err = New(ctx, db, SQLite, "sessions")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, err := store.Get(ctx, "key"); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Tables migrated by newer versions are rejected", func(t *testing.T) {
+		db := openDB(t)
+		New(ctx, db, SQLite, "")
+		db.Exec(`INSERT INTO suk_sessions_migrations (version) VALUES (99)`)
+
+		_, err := New(ctx, db, SQLite, "")
+
+		if !errors.Is(err, ErrUnknownMigration) {
+			t.Errorf("got %v expected %v", err, ErrUnknownMigration)
+		}
+	})
+
+	t.Run("Failed migrations are rolled back", func(t *testing.T) {
+		db := openDB(t)
+		broken := SQLite
+		broken.Migrations = func(table string) []string {
+			return append(SQLite.Migrations(table), "NOT SQL")
+		}
+
+		_, err := New(ctx, db, broken, "")
+		if !errors.Is(err, ErrMigrationFailed) {
+			t.Errorf("got %v expected %v", err, ErrMigrationFailed)
+		}
+
+		var applied int
+		db.QueryRow(`SELECT MAX(version) FROM suk_sessions_migrations`).Scan(&applied)
+
+		if applied != 2 {
+			t.Errorf("got %d expected %d", applied, 2)
+		}
+	})
+}
+
+func TestDialects(t *testing.T) {
+	t.Run("Postgres placeholders are numbered", func(t *testing.T) {
+		got := Postgres.Placeholder(3)
+
+		if got != "$3" {
+			t.Errorf("got %s expected %s", got, "$3")
+		}
+	})
+
+	t.Run("Every dialect is complete", func(t *testing.T) {
+		for _, d := range []Dialect{SQLite, Postgres, MySQL} {
+			if d.Placeholder == nil || d.Insert == nil || len(d.Migrations("t")) == 0 {
+				t.Errorf("got incomplete dialect %+v", d)
+			}
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	suktest.Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+		store, err := New(context.Background(), openDB(t), SQLite, "")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		ss, err := suk.New(append(opts, suk.WithStorage(store))...)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		t.Cleanup(func() { ss.Close(context.Background()) })
+
+		return ss
+	})
+}
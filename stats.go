@@ -0,0 +1,148 @@
+package suk
+
+import (
+	"strings"
+	"sync"
+)
+
+// Stats holds runtime statistics about a session storage.
+type Stats struct {
+	// Lock reports the contention on the session storage locks.
+	Lock LockStats
+
+	// KeyAudit is only set when the session storage was created with
+	// WithKeyAudit.
+	KeyAudit *KeyAudit
+
+	// Owners reports the sessions indexed by owner, as given with WithOwner or
+	// told by the function given to WithIdentityFunc, out of the ones issued,
+	// rotated and received by this process.
+	Owners *OwnerStats
+
+	// Usage is only set when the session storage was created with
+	// WithUsageSampling.
+	Usage *UsageStats
+}
+
+// KeyAudit reports how the characters of the sampled generated keys are
+// distributed over the key alphabet, so the key generator can be checked for
+// bias.
+type KeyAudit struct {
+	// SampledKeys is the amount of generated keys that were sampled.
+	SampledKeys uint64
+
+	// Characters counts how many times each character of the alphabet appeared
+	// in the sampled keys.
+	Characters map[byte]uint64
+
+	// ForeignCharacters counts the characters found in sampled keys that are not
+	// part of the alphabet, which may happen with custom key generators.
+	ForeignCharacters uint64
+
+	// ChiSquared is Pearson's chi-squared statistic of the character counts
+	// against an uniform distribution over the alphabet, with
+	// DegreesOfFreedom degrees of freedom. The lower it is, the less biased the
+	// key generator is. As a reference, for the default alphabet of 65
+	// characters, values over 90 are unlikely (p < 0.025) for an unbiased
+	// generator.
+	ChiSquared       float64
+	DegreesOfFreedom int
+}
+
+// Stats returns a snapshot of the statistics collected so far.
+func (ss *SessionStorage) Stats() Stats {
+	s := Stats{Lock: ss.locks.stats()}
+	if ss.keyAuditor != nil {
+		ka := ss.keyAuditor.snapshot()
+		s.KeyAudit = &ka
+	}
+
+	os := ss.owners.stats()
+	s.Owners = &os
+
+	if ss.usage != nil {
+		us := ss.usage.snapshot()
+		s.Usage = &us
+	}
+
+	return s
+}
+
+// keyAuditor samples generated keys, counting their characters.
+type keyAuditor struct {
+	mu sync.Mutex
+
+	alphabet  string
+	every     uint64
+	generated uint64
+	sampled   uint64
+	counts    [256]uint64
+}
+
+func newKeyAuditor(alphabet string, every uint64) *keyAuditor {
+	return &keyAuditor{alphabet: alphabet, every: every}
+}
+
+// wrap returns a random key generator that samples the keys generated by rkg.
+func (ka *keyAuditor) wrap(rkg func(uint64) (string, error)) func(uint64) (string, error) {
+	return func(n uint64) (string, error) {
+		key, err := rkg(n)
+		if err != nil {
+			return "", err
+		}
+
+		ka.observe(key)
+		return key, nil
+	}
+}
+
+func (ka *keyAuditor) observe(key string) {
+	ka.mu.Lock()
+	defer ka.mu.Unlock()
+
+	ka.generated++
+	if (ka.generated-1)%ka.every != 0 {
+		return
+	}
+
+	ka.sampled++
+	for i := 0; i < len(key); i++ {
+		ka.counts[key[i]]++
+	}
+}
+
+func (ka *keyAuditor) snapshot() KeyAudit {
+	ka.mu.Lock()
+	defer ka.mu.Unlock()
+
+	audit := KeyAudit{
+		SampledKeys:      ka.sampled,
+		Characters:       make(map[byte]uint64, len(ka.alphabet)),
+		DegreesOfFreedom: len(ka.alphabet) - 1,
+	}
+
+	var total uint64
+	for c, count := range ka.counts {
+		if strings.IndexByte(ka.alphabet, byte(c)) == -1 {
+			audit.ForeignCharacters += count
+		}
+	}
+
+	for i := 0; i < len(ka.alphabet); i++ {
+		count := ka.counts[ka.alphabet[i]]
+		audit.Characters[ka.alphabet[i]] = count
+		total += count
+	}
+
+	if total == 0 {
+		return audit
+	}
+
+	expected := float64(total) / float64(len(ka.alphabet))
+	for _, count := range audit.Characters {
+		diff := float64(count) - expected
+		audit.ChiSquared += diff * diff / expected
+	}
+
+	return audit
+}
@@ -0,0 +1,71 @@
+package suk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyAudit(t *testing.T) {
+	t.Run("Stats without key audit", func(t *testing.T) {
+		ss, _ := New()
+
+		if got := ss.Stats().KeyAudit; got != nil {
+			t.Errorf("got %v expected nil", got)
+		}
+	})
+
+	t.Run("Key audit samples every other key", func(t *testing.T) {
+		ss, _ := New(WithKeyAudit(2))
+
+		for i := range 10 {
+			ss.Set(i)
+		}
+
+		got := ss.Stats().KeyAudit.SampledKeys
+		var expected uint64 = 5
+
+		if got != expected {
+			t.Errorf("got %d expected %d", got, expected)
+		}
+	})
+
+	t.Run("Key audit of the default generator is not biased", func(t *testing.T) {
+		ss, _ := New(WithKeyAudit(1))
+
+		for i := range 1_000 {
+			ss.Set(i)
+		}
+
+		audit := ss.Stats().KeyAudit
+
+		if audit.ForeignCharacters != 0 {
+			t.Errorf("got %d foreign characters expected 0", audit.ForeignCharacters)
+		}
+
+		// Way above the critical value for 64 degrees of freedom, so this only
+		// fails for a clearly biased generator.
+		if audit.ChiSquared > 150 {
+			t.Errorf("got chi-squared %f, generator looks biased", audit.ChiSquared)
+		}
+	})
+
+	t.Run("Key audit of a biased generator", func(t *testing.T) {
+		ss, _ := New(
+			WithKeyAudit(1),
+			WithCustomRandomKeyGenerator(func(n uint64) (string, error) {
+				key, err := defaultRandomKeyGenerator(n / 2)
+				return strings.Repeat("a", int(n-n/2)) + key, err
+			}),
+		)
+
+		for i := range 100 {
+			ss.Set(i)
+		}
+
+		audit := ss.Stats().KeyAudit
+
+		if audit.ChiSquared < 150 {
+			t.Errorf("got chi-squared %f, expected generator to look biased", audit.ChiSquared)
+		}
+	})
+}
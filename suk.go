@@ -1,15 +1,19 @@
 // Package suk offers easy server-side session management using single-use
 // keys.
 //
-// You may use an in-memory map (default) or a Redis client to hold your
-// sessions. Do note that, when using an in-memory map, the session data is lost
-// as soon as the program stops.
+// You may use an in-memory map (default), a Redis client or your own Storage
+// implementation to hold your sessions. Do note that, when using an in-memory
+// map, the session data is lost as soon as the program stops.
 package suk
 
 import (
 	"context"
 	"errors"
+	"io"
+	"log/slog"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -28,172 +32,388 @@ const (
 var (
 	defaultDurationToExpire = 10 * time.Minute
 
-	ErrKeyWasExpired = errors.New("The given key has expired.")
-	ErrNoKeyFound    = errors.New("No value was found with the given key.")
-	ErrNilSession    = errors.New("The session passed can't be nil.")
+	ErrKeyWasExpired  = errors.New("The given key has expired.")
+	ErrNoKeyFound     = errors.New("No value was found with the given key.")
+	ErrNilSession     = errors.New("The session passed can't be nil.")
+	ErrDraining       = errors.New("The session storage is draining and can't issue new sessions.")
+	ErrPastExpiration = errors.New("The computed key expiration is not in the future.")
+	ErrReadOnly       = errors.New("The session storage is read-only.")
+	ErrKeyExists      = errors.New("The given key is already in use.")
 )
 
-type storage interface {
-	set(any) (string, error)
-	get(string) (any, string, error)
-	remove(string) error
-	clearExpired() error
-}
+// Entry is a session as held by a Storage.
+type Entry struct {
+	// Data is the session itself.
+	Data any
 
-type value struct {
-	data       any
-	expiration time.Time
-}
+	// ExpiresAt is when the key of the session expires. Storages may drop the
+	// entry once it is reached.
+	ExpiresAt time.Time
+
+	// TTL is the key duration given to the session with WithTTL, which is kept
+	// across rotations. It is zero for sessions using the default one.
+	TTL time.Duration
+
+	// ID identifies the session across rotations, as returned by SessionID.
+	ID string
+
+	// CreatedAt is when the session was set, which is kept across rotations.
+	CreatedAt time.Time
+
+	// LastAccessedAt is when the key of the session was last rotated, or when
+	// it was set, if it never was.
+	LastAccessedAt time.Time
+
+	// Rotations is how many times the key of the session was rotated.
+	Rotations int
+
+	// ClientIP and UserAgent describe the client the session was set for, as
+	// given with WithClient.
+	ClientIP  string
+	UserAgent string
+
+	// Fingerprint is the hash of the fingerprint of the client the session
+	// was bound to with FingerprintContext when it was set.
+	Fingerprint string
+
+	// Channel is the hash of the binding of the TLS channel the session was
+	// bound to with ChannelContext when it was set.
+	Channel string
+
+	// Owner is the owner of the session, as given with WithOwner or told by
+	// the function given to WithIdentityFunc when it was set.
+	Owner string
+
+	// Deadline is when the session expires whatever its activity, as set by
+	// WithAbsoluteExpiration, so keys are never renewed past it. It is zero for
+	// sessions without one.
+	Deadline time.Time
+
+	// Epoch is the epoch the session was set in, which starts at one and is
+	// bumped by InvalidateAll, so the sessions of past ones are no longer
+	// found. It is kept across rotations, and is zero for the sessions of
+	// storages not keeping it.
+	Epoch uint64
 
-type syncMap struct {
-	*sync.Map
+	// scratch holds the scratch space of in-memory sessions, which is carried
+	// over when the key is rotated.
+	scratch *sync.Map
 
-	keyLength        uint64
-	durationToExpire time.Duration
-	rkg              func(uint64) (string, error)
+	// stale is set when the session was decrypted with a previous encryption
+	// key, so it must be encrypted again with the current one.
+	stale bool
 }
 
-func (s *syncMap) set(session any) (string, error) {
-	if session == nil {
-		return "", ErrNilSession
-	}
+// Storage is implemented by the backends holding the sessions, such as the
+// default in-memory map or Redis, and may be implemented by third parties to
+// plug their own backends in with WithStorage.
+//
+// The session storage generates the keys, rotates them and checks whether they
+// expired, so storages only need to persist the entries. Keys are given as the
+// session storage stores them, which, with WithHashedKeys, are their hashes.
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	// Set stores the entry under key, failing with ErrKeyExists when key is
+	// already in use.
+	Set(ctx context.Context, key string, e Entry) error
 
-	id, err := s.rkg(s.keyLength)
-	if err != nil {
-		return "", err
-	}
+	// Get returns the entry stored under key, failing with ErrNoKeyFound when
+	// there's none.
+	Get(ctx context.Context, key string) (Entry, error)
 
-	var ok bool
-	for {
-		_, ok = s.Load(id)
-		if !ok {
-			break
-		}
+	// Remove atomically removes the entry stored under key and returns it,
+	// failing with ErrNoKeyFound when there's none. As keys are single-use, it
+	// must never return the same entry twice.
+	Remove(ctx context.Context, key string) (Entry, error)
 
-		id, err = s.rkg(s.keyLength)
-		if err != nil {
-			return "", err
-		}
-	}
+	// ClearExpired removes every expired entry. Storages that expire entries by
+	// themselves, such as Redis, may do nothing.
+	ClearExpired(ctx context.Context) error
+}
 
-	v := value{data: session, expiration: time.Now().Add(s.durationToExpire)}
-	s.Store(id, v)
-	return id, nil
+// rotator is implemented by storages that need to know which key an entry was
+// rotated to, such as the multi-region Redis. Rotate consumes oldKey, storing
+// the entry returned by update under newKey, and fails with ErrKeyExists when
+// newKey is already in use.
+type rotator interface {
+	rotate(ctx context.Context, oldKey, newKey string, update func(Entry) (Entry, error)) (Entry, error)
 }
 
-func (s *syncMap) get(key string) (any, string, error) {
-	session, loaded := s.LoadAndDelete(key)
-	if !loaded {
-		return nil, "", ErrNoKeyFound
-	}
+type redisDB struct {
+	client *redis.Client
 
-	v := session.(value)
-	if time.Until(v.expiration) <= 0 {
-		return nil, "", ErrKeyWasExpired
-	}
+	// payload serializes the sessions, when WithCodec or WithEncryption is
+	// set.
+	payload redisPayload
 
-	newKey, err := s.set(session)
-	if err != nil {
-		return nil, "", err
-	}
+	// prefixes holds the prefix of every key, which changes on MigratePrefix.
+	prefixes atomic.Pointer[redisPrefixes]
 
-	return v.data, newKey, nil
+	// epoch is the epoch bumped by InvalidateAll. Keys are stored under a
+	// segment of their epoch after the prefix, so the ones set before are no
+	// longer found.
+	epoch atomic.Uint64
 }
 
-func (s *syncMap) remove(key string) error {
-	s.Delete(key)
-	return nil
+// redisPrefixes holds the prefix of the Redis keys and, while they are being
+// migrated, the prefix they are being migrated from.
+type redisPrefixes struct {
+	current   string
+	previous  string
+	migrating bool
 }
 
-func (s *syncMap) clearExpired() error {
-	s.Range(func(k, v any) bool {
-		vl := v.(value)
-		if time.Until(vl.expiration) <= 0 {
-			s.Delete(k)
-		}
-		return true
-	})
-	return nil
+func newRedisDB(client *redis.Client, prefix string, payload redisPayload) *redisDB {
+	r := &redisDB{client: client, payload: payload}
+	r.prefixes.Store(&redisPrefixes{current: prefix})
+	return r
 }
 
-type redisDB struct {
-	*redis.Client
+// key returns the Redis key for the given key.
+func (r *redisDB) key(key string) string {
+	return r.epochPrefix(r.prefixes.Load().current) + key
+}
 
-	ctx              context.Context
-	keyLength        uint64
-	durationToExpire time.Duration
-	rkg              func(uint64) (string, error)
+// scratchSpaceKey returns the Redis key of the scratch space of the session
+// under the given key.
+func (r *redisDB) scratchSpaceKey(key string) string {
+	return r.epochPrefix(r.prefixes.Load().current) + scratchKey(key)
 }
 
-func (r *redisDB) set(session any) (string, error) {
-	if session == nil {
-		return "", ErrNilSession
+// epochPrefix returns the prefix of the keys of the current epoch under the
+// given prefix, which is the prefix itself until InvalidateAll is called.
+func (r *redisDB) epochPrefix(prefix string) string {
+	epoch := r.epoch.Load()
+	if epoch == 0 {
+		return prefix
+	}
+
+	return prefix + "epoch:" + strconv.FormatUint(epoch, 10) + ":"
+}
+
+// lookup runs fn with the Redis key for the given key, trying again with the
+// previous prefix when the key wasn't found while a migration is in progress.
+func (r *redisDB) lookup(key string, fn func(string) (Entry, error)) (Entry, error) {
+	p := r.prefixes.Load()
+
+	e, err := fn(r.epochPrefix(p.current) + key)
+	if err == ErrNoKeyFound && p.migrating {
+		return fn(r.epochPrefix(p.previous) + key)
 	}
 
-	id, err := r.rkg(r.keyLength)
+	return e, err
+}
+
+// setScript sets a session unless its key is taken, along with the index entry
+// pointing to it by ID, if any, which expires with it, and the ID in the index
+// entry of its owner, if any, which expires with the last session of the owner.
+var setScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2], 'NX') then
+	return 0
+end
+if KEYS[2] then
+	redis.call('SET', KEYS[2], ARGV[3], 'PX', ARGV[2])
+end
+if KEYS[3] then
+	redis.call('SADD', KEYS[3], ARGV[4])
+	if redis.call('PTTL', KEYS[3]) < tonumber(ARGV[2]) then
+		redis.call('PEXPIRE', KEYS[3], ARGV[2])
+	end
+end
+return 1
+`)
+
+// setArgs returns the keys and arguments of setScript for the entry stored
+// under key.
+func (r *redisDB) setArgs(key string, e Entry) ([]string, []any, error) {
+	ttl := time.Until(e.ExpiresAt)
+	if ttl <= 0 {
+		return nil, nil, ErrPastExpiration
+	}
+
+	data, err := r.payload.encodeEntry(e)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
-	for {
-		_, err = r.Get(r.ctx, id).Result()
-		if err == redis.Nil {
-			break
-		} else if err != nil {
-			return "", err
+	prefix := r.epochPrefix(r.prefixes.Load().current)
+	keys := []string{r.key(key)}
+	if e.ID != "" {
+		keys = append(keys, prefix+familyIndexKey(e.ID))
+		if e.Owner != "" {
+			keys = append(keys, prefix+ownerIndexKey(e.Owner))
 		}
+	}
 
-		id, err = r.rkg(r.keyLength)
-		if err != nil {
-			return "", err
-		}
+	return keys, []any{data, max(ttl.Milliseconds(), 1), key, e.ID}, nil
+}
+
+func (r *redisDB) Set(ctx context.Context, key string, e Entry) error {
+	keys, args, err := r.setArgs(key, e)
+	if err != nil {
+		return err
 	}
 
-	err = r.Set(r.ctx, id, session, r.durationToExpire).Err()
+	ok, err := setScript.Run(ctx, r.client, keys, args...).Bool()
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	if !ok {
+		return ErrKeyExists
 	}
 
-	return id, nil
+	return nil
 }
 
-func (r *redisDB) get(key string) (any, string, error) {
-	session, err := r.GetDel(r.ctx, key).Result()
-	if err == redis.Nil {
-		return nil, "", ErrNoKeyFound
+// entry reads the entry out of the results of a GET (or GETDEL) and a PTTL.
+func (r *redisDB) entry(get *redis.StringCmd, pttl *redis.DurationCmd, err error) (Entry, error) {
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, ErrNoKeyFound
 	} else if err != nil {
-		return nil, "", err
+		return Entry{}, err
 	}
 
-	newKey, err := r.set(session)
+	e, err := r.payload.decodeEntry(get.Val())
 	if err != nil {
-		return nil, "", err
+		return Entry{}, err
 	}
 
-	return session, newKey, nil
+	e.ExpiresAt = time.Now().Add(pttl.Val())
+	return e, nil
+}
+
+func (r *redisDB) Get(ctx context.Context, key string) (Entry, error) {
+	return r.lookup(key, func(key string) (Entry, error) {
+		var (
+			get  *redis.StringCmd
+			pttl *redis.DurationCmd
+		)
+
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pttl = pipe.PTTL(ctx, key)
+			get = pipe.Get(ctx, key)
+			return nil
+		})
+
+		return r.entry(get, pttl, err)
+	})
 }
 
-func (r *redisDB) remove(key string) error {
-	return r.Del(r.ctx, key).Err()
+func (r *redisDB) Remove(ctx context.Context, key string) (Entry, error) {
+	return r.lookup(key, func(key string) (Entry, error) {
+		var (
+			get  *redis.StringCmd
+			pttl *redis.DurationCmd
+		)
+
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pttl = pipe.PTTL(ctx, key)
+			get = pipe.GetDel(ctx, key)
+			return nil
+		})
+
+		return r.entry(get, pttl, err)
+	})
 }
 
-func (r *redisDB) clearExpired() error {
+func (r *redisDB) ClearExpired(_ context.Context) error {
 	return nil
 }
 
 type SessionStorage struct {
-	config  config
-	storage storage
-	mu      *sync.Mutex
+	config     config
+	storage    Storage
+	locks      *keyLocks
+	keyAuditor *keyAuditor
+
+	// ctx is the context storage operations run on, unless one is given, as in
+	// SetCtx.
+	ctx context.Context
 
-	// stopChannel is only used when WithAutoClearExpiredKeys is set, to finish
-	// the underlying go routine that keeps ticking the autoclear.
+	// hashKeys is set when the keys are hashed before they reach the storage,
+	// as with WithHashedKeys, and always for the in-memory storage.
+	hashKeys bool
+
+	// policyMu guards the fields below, which Reconfigure may change at
+	// runtime.
+	policyMu      sync.RWMutex
+	policy        *policy
+	stopAutoClear func()
+
+	// stopChannel is only used by backends with background workers, such as
+	// the multi-region Redis, to finish them.
 	stopChannel chan struct{}
+
+	// stops holds the functions that stop the scheduled jobs.
+	stops    []func()
+	stopOnce sync.Once
+
+	// ownedStorage is set when the storage was opened by the session storage
+	// itself, such as with WithSQLite, so it is closed on Destroy.
+	ownedStorage io.Closer
+
+	// opsMu guards the fields below, which track the operations in flight so
+	// Drain can wait for them to finish.
+	opsMu    sync.Mutex
+	active   int
+	draining bool
+	closed   bool
+	idle     chan struct{}
+	lockdown lockdown
+
+	// journal holds the sessions waiting to be restored after failed
+	// rotations.
+	journal rotationJournal
+
+	// owners indexes the sessions by owner, as given with WithOwner or told by
+	// the function given to WithIdentityFunc.
+	owners *ownerIndex
+
+	// usage samples the issued sessions, when WithUsageSampling is set.
+	usage *usageSampler
+
+	// families indexes the current key of each session by its ID.
+	families *familyIndex
+
+	// consumedKeys maps the consumed keys to the keys they were rotated to,
+	// when WithRotationGracePeriod or WithReplayDetection is set.
+	consumedKeys *consumedKeys
+
+	// lookups counts the failed lookups of each source, when
+	// WithLookupRateLimit is set.
+	lookups *lookupLimiter
+
+	// events buffers the events received from Events, when WithEventBuffer is
+	// set.
+	events *eventStream
+
+	// revokedBefore is when the sessions revoked by RevokeCreatedBefore were
+	// created before, in Unix nanoseconds, or zero when none was.
+	revokedBefore atomic.Int64
+
+	// epoch is the epoch bumped by InvalidateAll, as kept by the storage when
+	// it implements Watermarker.
+	epoch atomic.Uint64
 }
 
 // New creates a new session storage.
 func New(opts ...Option) (*SessionStorage, error) {
-	var c config
+	return newSessionStorage(config{}, opts)
+}
+
+// NewReadOnly creates a new session storage in validation-only mode. Get
+// returns the session without rotating its key, so the given key is returned
+// back, while Set, Remove and ClearExpired fail with ErrReadOnly. Expired keys
+// are never cleared, even when WithAutoClearExpiredKeys is set.
+//
+// It is meant for sidecar validators and reporting jobs sharing a backend,
+// such as Redis, that must never mutate the session state.
+func NewReadOnly(opts ...Option) (*SessionStorage, error) {
+	return newSessionStorage(config{readOnly: true}, opts)
+}
+
+func newSessionStorage(c config, opts []Option) (*SessionStorage, error) {
 	errs := make([]error, 0, len(opts))
 	for _, opt := range opts {
 		if err := opt.apply(&c); err != nil {
@@ -205,114 +425,822 @@ func New(opts ...Option) (*SessionStorage, error) {
 		return nil, errors.Join(errs...)
 	}
 
-	ss := SessionStorage{config: c, mu: &sync.Mutex{}}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+
+	if c.scheduler == nil {
+		c.scheduler = tickerScheduler{}
+	}
+
+	shards := c.shards
+	if shards == 0 {
+		shards = defaultShards
+	}
+
+	ss := SessionStorage{
+		config:   c,
+		hashKeys: c.hashKeys,
+		locks:    newKeyLocks(shards),
+		owners:   newOwnerIndex(),
+		families: newFamilyIndex(),
+	}
 
-	var keyLength uint64 = defaultKeyLength
-	if c.customKeyLength != nil {
-		keyLength = *c.customKeyLength
+	if window := max(c.rotationGracePeriod, c.replayWindow); window > 0 {
+		ss.consumedKeys = newConsumedKeys(window)
 	}
 
-	var durationToExpire time.Duration
-	if c.customKeyDuration != nil {
-		durationToExpire = *c.customKeyDuration
-	} else {
-		durationToExpire = defaultDurationToExpire
+	if c.usageSampleRate != 0 {
+		ss.usage = newUsageSampler(c.usageSampleRate)
 	}
 
-	var rkg func(uint64) (string, error)
-	if c.customRandomKeyGenerator != nil {
-		rkg = c.customRandomKeyGenerator
-	} else {
-		rkg = defaultRandomKeyGenerator
+	if c.lookupLimit != 0 {
+		ss.lookups = newLookupLimiter(c.lookupLimit, c.lookupWindow, c.onLookupLimit)
 	}
 
-	if c.redisClient != nil {
-		cd := redisDB{new(redis.Client), c.redisCtx, keyLength, durationToExpire, rkg}
-		ss.storage = &cd
+	if c.eventBuffer != 0 {
+		ss.events = newEventStream(c.eventBuffer)
+	}
 
-		return &ss, nil
+	if c.keyAuditRate != 0 {
+		ss.keyAuditor = newKeyAuditor(defaultPossibleKeyCharacters, c.keyAuditRate)
 	}
 
-	sm := syncMap{new(sync.Map), keyLength, durationToExpire, rkg}
-	ss.storage = &sm
+	p, err := newPolicy(c, ss.keyAuditor, nil)
+	if err != nil {
+		return nil, err
+	}
+	ss.policy = p
+
+	ss.ctx = context.Background()
+	if c.redisCtx != nil {
+		ss.ctx = c.redisCtx
+	} else if c.baseCtx != nil {
+		ss.ctx = c.baseCtx
+	}
 
-	if c.autoClearExpiredKeys {
+	if c.codec != nil && c.redisClient == nil && c.nearestRedisClient == nil {
+		return nil, ErrCodecWithoutRedis
+	}
+
+	payload := redisPayload{codec: c.codec, sealer: c.sealer}
+
+	switch {
+	case c.storage != nil:
+		ss.storage = c.storage
+		if c.sealer != nil {
+			ss.storage = &sealedStorage{Storage: c.storage, sealer: c.sealer}
+		}
+	case c.redisClient != nil:
+		ss.storage = newRedisDB(c.redisClient, c.redisPrefix, payload)
+	case c.sqlitePath != "":
+		db, err := openSQLite(c.sqlitePath)
+		if err != nil {
+			return nil, err
+		}
+
+		db.sealer = c.sealer
+		ss.storage = db
+		ss.ownedStorage = db
+	case c.nearestRedisClient != nil:
+		mr := multiRegionRedis{
+			nearest: c.nearestRedisClient,
+			other:   c.otherRedisClient,
+			ctx:     ss.ctx,
+			logger:  c.logger,
+			payload: payload,
+			queue:   make(chan regionEntry, defaultReplicationQueueSize),
+		}
+		ss.storage = &mr
 		ss.stopChannel = make(chan struct{})
 
-		go func() {
-			ticker := time.NewTicker(durationToExpire)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ss.stopChannel:
-					return
-				case <-ticker.C:
-					ss.ClearExpired()
-				}
-			}
-		}()
+		ss.runWorker("multi-region replication", func() {
+			mr.replicateQueued(ss.stopChannel)
+		})
+	default:
+		m := newShardedMap(shards)
+		m.onExpire = ss.expiredStored
+		ss.storage = m
+		ss.hashKeys = true
+	}
+
+	if c.outageBufferSize > 0 {
+		remote, ok := ss.storage.(*redisDB)
+		if !ok {
+			return nil, ErrOutageBufferWithoutRedis
+		}
+
+		if !c.readOnly {
+			ss.storage = &bufferedStorage{remote, newShardedMap(shards), c.outageBufferSize}
+			ss.stops = append(ss.stops, ss.schedule("outage buffer flush", c.outageRetryInterval, func() {
+				ss.flushOutageBuffer(ss.ctx)
+			}))
+		}
+	}
+
+	if c.maxSessionsPerOwner != 0 && !ss.findsOwners() {
+		return nil, ErrOwnersUnsupported
+	}
+
+	ss.scheduleWatermarkRefresh()
+	ss.scheduleAutoClear(nil, p)
+
+	if c.baseCtx != nil {
+		context.AfterFunc(c.baseCtx, ss.stopBackground)
 	}
 
 	return &ss, nil
 }
 
-// Destroy cleans up and removes a session storage.
+// Destroy closes the session storage, ignoring any error.
+//
+// Deprecated: Use Close, which reports whether pending state was flushed.
 func Destroy(ss *SessionStorage) {
-	if ss.config.autoClearExpiredKeys {
-		close(ss.stopChannel)
+	ss.Close(context.Background())
+}
+
+// begin registers an operation as in flight. Operations that would issue a
+// new session are refused with ErrDraining once Drain was called, and every
+// operation is refused once the base context is done or Close was called.
+func (ss *SessionStorage) begin(issuing bool) error {
+	if base := ss.config.baseCtx; base != nil && base.Err() != nil {
+		return context.Cause(base)
 	}
 
-	ss = nil
+	ss.opsMu.Lock()
+	defer ss.opsMu.Unlock()
+
+	if ss.closed {
+		return ErrClosed
+	}
+
+	if issuing && ss.draining {
+		return ErrDraining
+	}
+
+	ss.active++
+	return nil
+}
+
+// end marks an operation started with begin as finished.
+func (ss *SessionStorage) end() {
+	ss.opsMu.Lock()
+	defer ss.opsMu.Unlock()
+
+	ss.active--
+	if ss.active == 0 && ss.idle != nil {
+		close(ss.idle)
+		ss.idle = nil
+	}
+}
+
+// Drain stops the session storage from issuing new sessions, making Set return
+// ErrDraining, while Get and Remove keep being served. It blocks until the
+// operations in flight finish or the context is done, in which case the
+// context error is returned.
+//
+// This is meant for graceful handovers, such as blue/green deployments, where
+// the old instance should only serve the sessions it already holds, or hand
+// them off to the new one with HandOff.
+func (ss *SessionStorage) Drain(ctx context.Context) error {
+	ss.opsMu.Lock()
+	ss.draining = true
+	return ss.waitIdle(ctx)
+}
+
+// waitIdle waits for the operations in flight to finish. It must be called
+// with opsMu held, which it releases.
+func (ss *SessionStorage) waitIdle(ctx context.Context) error {
+	if ss.active == 0 {
+		ss.opsMu.Unlock()
+		return nil
+	}
+
+	if ss.idle == nil {
+		ss.idle = make(chan struct{})
+	}
+	idle := ss.idle
+	ss.opsMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// Set assigns the session and returns a key for it.
-func (ss *SessionStorage) Set(session any) (string, error) {
-	ss.mu.Lock()
-	defer ss.mu.Unlock()
-	key, err := ss.storage.set(session)
+// expiration computes the expiration of a key issued right now.
+func (ss *SessionStorage) expiration() (time.Time, error) {
+	now := time.Now()
+	expiration := ss.currentPolicy().expiresAt(now)
+	if !expiration.After(now) {
+		return time.Time{}, ErrPastExpiration
+	}
+
+	return expiration, nil
+}
+
+// insert stores the entry under a newly generated key, generating another one
+// whenever the key is already in use.
+func (ss *SessionStorage) insert(ctx context.Context, e Entry) (string, error) {
+	p := ss.currentPolicy()
+	for {
+		key, err := p.rkg(p.keyLengthFor(e.Data))
+		if err != nil {
+			return "", err
+		}
+
+		err = ss.storage.Set(ctx, ss.stored(key), e)
+		if err == ErrKeyExists {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		return key, nil
+	}
+}
+
+// renew checks whether the entry expired, giving it a new expiration
+// otherwise.
+func (ss *SessionStorage) renew(e Entry) (Entry, error) {
+	if time.Until(e.ExpiresAt) <= 0 {
+		return Entry{}, ErrKeyWasExpired
+	}
+
+	if ss.revoked(e) {
+		return Entry{}, ErrNoKeyFound
+	}
+
+	expiration, err := ss.expirationFor(e)
 	if err != nil {
-		return "", err
+		return Entry{}, err
 	}
 
-	return key, nil
+	e.ExpiresAt = expiration
+	e.LastAccessedAt = time.Now()
+	e.Rotations++
+	return e, nil
 }
 
-// Get retrieves the session and generates a new key for it.
+// rotate consumes the key, storing its entry under a newly generated key.
+func (ss *SessionStorage) rotate(ctx context.Context, key string) (Entry, string, error) {
+	renew := func(e Entry) (Entry, error) {
+		if err := checkClient(ctx, e); err != nil {
+			return Entry{}, err
+		}
+
+		ne, err := ss.renew(e)
+		if err == ErrKeyWasExpired {
+			ss.emit(EventExpired, key, "", e)
+		}
+
+		return ne, err
+	}
+
+	if r, ok := ss.storage.(rotator); ok {
+		p := ss.currentPolicy()
+		keyLength := p.keyLength
+		if p.isElevated != nil {
+			e, err := ss.storage.Get(ctx, ss.stored(key))
+			if err != nil {
+				return Entry{}, "", err
+			}
+
+			keyLength = p.keyLengthFor(e.Data)
+		}
+
+		for {
+			newKey, err := p.rkg(keyLength)
+			if err != nil {
+				return Entry{}, "", err
+			}
+
+			e, err := r.rotate(ctx, ss.stored(key), ss.stored(newKey), renew)
+			if err == ErrKeyExists {
+				continue
+			} else if err != nil {
+				return Entry{}, "", err
+			}
+
+			return e, newKey, nil
+		}
+	}
+
+	ss.journal.retry(context.WithoutCancel(ctx), ss.storage)
+
+	old, err := ss.storage.Remove(ctx, ss.stored(key))
+	if err == ErrNoKeyFound {
+		var ok bool
+		if old, ok = ss.journal.take(ss.stored(key)); ok {
+			err = nil
+		}
+	}
+
+	if err != nil {
+		return Entry{}, "", err
+	}
+
+	e, err := renew(old)
+	if err == ErrFingerprintMismatch || err == ErrChannelMismatch {
+		ss.restore(ctx, key, old)
+		return Entry{}, "", err
+	} else if err != nil {
+		return Entry{}, "", err
+	}
+
+	newKey, err := ss.insert(ctx, e)
+	if err != nil {
+		ss.restore(ctx, key, old)
+		return Entry{}, "", err
+	}
+
+	if sc, ok := ss.storage.(scratchStorage); ok {
+		if err := sc.scratchMove(ctx, ss.stored(key), ss.stored(newKey)); err != nil {
+			return Entry{}, "", err
+		}
+	}
+
+	return e, newKey, nil
+}
+
+// peek returns the entry stored under key without consuming it.
+func (ss *SessionStorage) peek(ctx context.Context, key string) (Entry, error) {
+	if ss.config.tokens != nil {
+		e, err := ss.openToken(key)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		if err := checkClient(ctx, e); err != nil {
+			return Entry{}, err
+		}
+
+		return e, nil
+	}
+
+	e, err := ss.storage.Get(ctx, ss.stored(key))
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if time.Until(e.ExpiresAt) <= 0 {
+		return Entry{}, ErrKeyWasExpired
+	}
+
+	if ss.revoked(e) {
+		return Entry{}, ErrNoKeyFound
+	}
+
+	if err := checkClient(ctx, e); err != nil {
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+// Set assigns the session and returns a key for it. Options, such as WithTTL,
+// customize this session only.
+func (ss *SessionStorage) Set(session any, opts ...SetOption) (string, error) {
+	return ss.SetCtx(ss.ctx, session, opts...)
+}
+
+// SetCtx is like Set, but the storage operations run on the given context, so
+// per-request deadlines and cancellation propagate to the backend.
+func (ss *SessionStorage) SetCtx(ctx context.Context, session any, opts ...SetOption) (string, error) {
+	_, key, err := ss.set(ctx, session, opts)
+	return key, err
+}
+
+// set stores the session under a newly generated key, returning its entry.
+func (ss *SessionStorage) set(ctx context.Context, session any, opts []SetOption) (Entry, string, error) {
+	if ss.config.readOnly {
+		return Entry{}, "", ErrReadOnly
+	}
+
+	if session == nil {
+		return Entry{}, "", ErrNilSession
+	}
+
+	sc, err := newSetConfig(opts)
+	if err != nil {
+		return Entry{}, "", err
+	}
+
+	limiter := ss.currentPolicy().limiter
+	if source, ok := sourceFrom(ctx); ok && limiter != nil && !limiter.allow(source, time.Now()) {
+		return Entry{}, "", ErrIssueRateLimited
+	}
+
+	if err := ss.begin(true); err != nil {
+		return Entry{}, "", err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	e, err := ss.newEntry(session, sc)
+	if err != nil {
+		return Entry{}, "", err
+	}
+
+	e.Fingerprint, _ = fingerprintOf(ctx)
+	e.Channel, _ = channelOf(ctx)
+
+	if t := ss.config.tokens; t != nil {
+		key, err := t.issue(e)
+		if err != nil {
+			return Entry{}, "", err
+		}
+
+		ss.emit(EventCreated, key, "", e)
+		return e, key, nil
+	}
+
+	if err := ss.enforceSessionLimit(ctx, e.Owner, 1); err != nil {
+		return Entry{}, "", err
+	}
+
+	// No lock is needed, as nobody else knows the key before it is returned.
+	key, err := ss.insert(ctx, e)
+	if err != nil {
+		return Entry{}, "", err
+	}
+
+	if err := ss.issued(ctx, key, e); err != nil {
+		return Entry{}, "", err
+	}
+
+	return e, key, nil
+}
+
+// newEntry builds the entry of a new session.
+func (ss *SessionStorage) newEntry(session any, sc setConfig) (Entry, error) {
+	if err := ss.checkSize(session); err != nil {
+		return Entry{}, err
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{
+		Data:      session,
+		ID:        id,
+		CreatedAt: time.Now(),
+		TTL:       sc.ttl,
+		Owner:     sc.owner,
+		Epoch:     ss.epoch.Load() + 1,
+		ClientIP:  sc.clientIP,
+		UserAgent: sc.userAgent,
+	}
+	e.LastAccessedAt = e.CreatedAt
+	if e.Owner == "" {
+		e.Owner = ss.ownerOf(session)
+	}
+
+	if d := ss.currentPolicy().absoluteExpiration; d > 0 {
+		e.Deadline = time.Now().Add(d)
+	}
+
+	e.ExpiresAt, err = ss.expirationFor(e)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+// issued escrows and indexes the key issued for the entry of a new session.
+func (ss *SessionStorage) issued(ctx context.Context, key string, e Entry) error {
+	if err := ss.escrow(ctx, key, e, false); err != nil {
+		return err
+	}
+
+	ss.indexIssued(key, e)
+	ss.familyIssued(key, e)
+	ss.sampleIssued(key, e)
+	ss.emit(EventCreated, key, "", e)
+	return nil
+}
+
+// Get retrieves the session and generates a new key for it. In read-only
+// session storages, and the ones created with WithoutKeyRotation, the key is
+// not rotated and is returned back instead.
 func (ss *SessionStorage) Get(key string) (any, string, error) {
-	ss.mu.Lock()
-	defer ss.mu.Unlock()
-	session, newKey, err := ss.storage.get(key)
+	return ss.GetCtx(ss.ctx, key)
+}
+
+// GetCtx is like Get, but the storage operations run on the given context, so
+// per-request deadlines and cancellation propagate to the backend.
+func (ss *SessionStorage) GetCtx(ctx context.Context, key string) (any, string, error) {
+	if err := ss.begin(false); err != nil {
+		return nil, "", err
+	}
+	defer ss.end()
+
+	e, newKey, err := ss.get(ctx, key)
 	if err != nil {
 		return struct{}{}, "", err
 	}
 
-	return session, newKey, nil
+	return e.Data, newKey, nil
+}
+
+// get retrieves the entry stored under key, rotating the key unless the session
+// storage doesn't.
+func (ss *SessionStorage) get(ctx context.Context, key string) (e Entry, newKey string, err error) {
+	if err := ss.checkLookups(ctx); err != nil {
+		return Entry{}, "", err
+	}
+	defer func() { ss.lookedUp(ctx, err) }()
+
+	if !ss.currentPolicy().accepts(key) {
+		return Entry{}, "", ErrForeignKey
+	}
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	if err := ss.checkLockdown(ctx, key); err != nil {
+		return Entry{}, "", err
+	}
+
+	if ss.config.readOnly || ss.config.withoutKeyRotation || ss.config.tokens != nil {
+		e, err := ss.peek(ctx, key)
+		if err != nil {
+			return Entry{}, "", err
+		}
+
+		if e.stale && !ss.config.readOnly {
+			ss.reseal(ctx, key)
+		}
+
+		ss.emit(EventRetrieved, key, "", e)
+		return e, key, nil
+	}
+
+	unlock := ss.locks.lock(key)
+	e, newKey, err = ss.rotate(ctx, key)
+	if err == ErrNoKeyFound {
+		unlock()
+		return ss.consumedEntry(ctx, key)
+	}
+	defer unlock()
+
+	if err != nil {
+		return Entry{}, "", err
+	}
+
+	if err := ss.rotated(ctx, key, newKey, e); err != nil {
+		return Entry{}, "", err
+	}
+
+	return e, newKey, nil
+}
+
+// rotated escrows and indexes the key the entry was rotated to from key.
+func (ss *SessionStorage) rotated(ctx context.Context, key, newKey string, e Entry) error {
+	ss.indexRemoved(key)
+	ss.trackConsumed(key, newKey)
+
+	if err := ss.escrow(ctx, newKey, e, true); err != nil {
+		return err
+	}
+
+	ss.indexIssued(newKey, e)
+	ss.familyIssued(newKey, e)
+	ss.sampleRotated(key, newKey, e)
+	ss.emit(EventRotated, key, newKey, e)
+	return nil
+}
+
+// Peek retrieves the session without consuming nor rotating the key, such as
+// for logging or authorization checks that must leave the key usable.
+func (ss *SessionStorage) Peek(key string) (any, error) {
+	return ss.PeekCtx(ss.ctx, key)
+}
+
+// PeekCtx is like Peek, but the storage operations run on the given context, so
+// per-request deadlines and cancellation propagate to the backend.
+func (ss *SessionStorage) PeekCtx(ctx context.Context, key string) (any, error) {
+	if err := ss.begin(false); err != nil {
+		return nil, err
+	}
+	defer ss.end()
+
+	if err := ss.checkLookups(ctx); err != nil {
+		return nil, err
+	}
+
+	if !ss.currentPolicy().accepts(key) {
+		ss.lookedUp(ctx, ErrForeignKey)
+		return nil, ErrForeignKey
+	}
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	if err := ss.checkLockdown(ctx, key); err != nil {
+		return nil, err
+	}
+
+	e, err := ss.peek(ctx, key)
+	if err != nil {
+		ss.lookedUp(ctx, err)
+		return nil, err
+	}
+
+	return e.Data, nil
 }
 
 // Remove deletes the specified key and its associated value.
 func (ss *SessionStorage) Remove(key string) error {
-	ss.mu.Lock()
-	defer ss.mu.Unlock()
-	err := ss.storage.remove(key)
+	return ss.RemoveCtx(ss.ctx, key)
+}
+
+// RemoveCtx is like Remove, but the storage operations run on the given
+// context, so per-request deadlines and cancellation propagate to the backend.
+// Sessions bound to a client other than the one of the context, as with
+// FingerprintContext, are left as they are.
+func (ss *SessionStorage) RemoveCtx(ctx context.Context, key string) error {
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	if ss.config.tokens != nil {
+		return ErrStatelessToken
+	}
+
+	// Foreign keys can't be stored, so there's nothing to remove, as with keys
+	// not found.
+	if !ss.currentPolicy().accepts(key) {
+		return nil
+	}
+
+	if err := ss.begin(false); err != nil {
+		return err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	_, err := ss.take(ctx, key)
+	if err == ErrNoKeyFound {
+		return nil
+	}
+
+	return err
+}
+
+// Pop removes the session stored under key and returns it, such as for
+// auditing a logout, so nobody else can retrieve it in between.
+func (ss *SessionStorage) Pop(key string) (any, error) {
+	return ss.PopCtx(ss.ctx, key)
+}
+
+// PopCtx is like Pop, but the storage operations run on the given context, so
+// per-request deadlines and cancellation propagate to the backend. Sessions
+// bound to a client other than the one of the context, as with
+// FingerprintContext, are left as they are.
+func (ss *SessionStorage) PopCtx(ctx context.Context, key string) (any, error) {
+	if ss.config.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	if ss.config.tokens != nil {
+		return nil, ErrStatelessToken
+	}
+
+	if !ss.currentPolicy().accepts(key) {
+		return nil, ErrForeignKey
+	}
+
+	if err := ss.begin(false); err != nil {
+		return nil, err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	e, err := ss.take(ctx, key)
 	if err != nil {
+		return nil, err
+	}
+
+	// Expired and revoked sessions are removed all the same, but not returned.
+	if time.Until(e.ExpiresAt) <= 0 {
+		return nil, ErrKeyWasExpired
+	}
+
+	if ss.revoked(e) {
+		return nil, ErrNoKeyFound
+	}
+
+	return e.Data, nil
+}
+
+// take removes the session stored under key and returns it. Logging out with a
+// key consumed during its grace period ends the session it was rotated to.
+func (ss *SessionStorage) take(ctx context.Context, key string) (Entry, error) {
+	e, err := ss.removeBound(ctx, key)
+	if err != ErrNoKeyFound {
+		return e, err
+	}
+
+	if _, newKey, ok := ss.graced(ctx, key); ok {
+		return ss.removeBound(ctx, newKey)
+	}
+
+	return Entry{}, ErrNoKeyFound
+}
+
+// removeBound removes the session stored under key, unless it is bound to a
+// client other than the one of ctx. Bindings never change, so the session is
+// only read beforehand when ctx carries one.
+func (ss *SessionStorage) removeBound(ctx context.Context, key string) (Entry, error) {
+	if err := ss.checkBound(ctx, key); err != nil {
+		return Entry{}, err
+	}
+
+	return ss.remove(ctx, key)
+}
+
+// checkBound fails when the session stored under key, if any, is bound to a
+// client other than the one of ctx.
+func (ss *SessionStorage) checkBound(ctx context.Context, key string) error {
+	if !bound(ctx) {
+		return nil
+	}
+
+	e, err := ss.storage.Get(ctx, ss.stored(key))
+	if err == ErrNoKeyFound {
+		return nil
+	} else if err != nil {
 		return err
 	}
 
+	return checkClient(ctx, e)
+}
+
+// remove removes the session stored under key, along with its scratch space,
+// and returns it, failing with ErrNoKeyFound when there's none.
+func (ss *SessionStorage) remove(ctx context.Context, key string) (Entry, error) {
+	defer ss.locks.lock(key)()
+	e, err := ss.storage.Remove(ctx, ss.stored(key))
+	if err != nil && err != ErrNoKeyFound {
+		return Entry{}, err
+	}
+
+	if err := ss.removed(ctx, key, e); err != nil {
+		return Entry{}, err
+	}
+
+	if err == nil {
+		ss.emit(EventRemoved, key, "", e)
+	}
+
+	return e, err
+}
+
+// removed forgets the key of the removed entry, dropping its scratch space.
+func (ss *SessionStorage) removed(ctx context.Context, key string, e Entry) error {
+	ss.indexRemoved(key)
+	ss.families.removed(e.ID, ss.stored(key))
+	ss.sampleRemoved(key)
+
+	if sc, ok := ss.storage.(scratchStorage); ok {
+		return sc.scratchDrop(ctx, ss.stored(key))
+	}
+
 	return nil
 }
 
 // ClearExpired removes all expired keys. For Redis, this function is a no-op
 // as Redis handles expiration automatically. To enable similar behavior for
-// the default syncMap, start the SessionStorage with the
-// WithAutoClearExpiredKeys option.
+// the default in-memory storage, start the SessionStorage with the
+// WithAutoClearExpiredKeys option. Use ClearExpiredCtx to sweep large storages
+// incrementally.
 func (ss *SessionStorage) ClearExpired() error {
-	ss.mu.Lock()
-	defer ss.mu.Unlock()
-	err := ss.storage.clearExpired()
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := ss.begin(false); err != nil {
+		return err
+	}
+	defer ss.end()
+
+	err := ss.storage.ClearExpired(ss.ctx)
 	if err != nil {
 		return err
 	}
 
+	ss.owners.prune(time.Now())
 	return nil
 }
@@ -1,8 +1,16 @@
 package suk
 
 import (
+	"context"
+	"errors"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 func TestSyncMapStorage(t *testing.T) {
@@ -22,11 +30,7 @@ func TestSyncMapStorage(t *testing.T) {
 			ss.Set(i)
 		}
 
-		got := 0
-		ss.storage.(*syncMap).Range(func(key, value any) bool {
-			got++
-			return true
-		})
+		got := ss.storage.(*shardedMap).len()
 
 		expected := 5
 
@@ -53,11 +57,7 @@ func TestSyncMapStorage(t *testing.T) {
 			t.Errorf("got %d expected %d", gotGet, 10)
 		}
 
-		got := 0
-		ss.storage.(*syncMap).Range(func(key, value any) bool {
-			got++
-			return true
-		})
+		got := ss.storage.(*shardedMap).len()
 
 		expected := 1
 
@@ -81,11 +81,7 @@ func TestSyncMapStorage(t *testing.T) {
 
 		wg.Wait()
 
-		got := 0
-		ss.storage.(*syncMap).Range(func(key, value any) bool {
-			got++
-			return true
-		})
+		got := ss.storage.(*shardedMap).len()
 
 		expected := 10
 
@@ -109,11 +105,7 @@ func TestSyncMapStorage(t *testing.T) {
 
 		wg.Wait()
 
-		got := 0
-		ss.storage.(*syncMap).Range(func(key, value any) bool {
-			got++
-			return true
-		})
+		got := ss.storage.(*shardedMap).len()
 
 		expected := 1_000
 
@@ -122,3 +114,474 @@ func TestSyncMapStorage(t *testing.T) {
 		}
 	})
 }
+
+func TestDrain(t *testing.T) {
+	t.Run("Set is refused after draining", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		if err := ss.Drain(context.Background()); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		_, err := ss.Set(20)
+		if err != ErrDraining {
+			t.Errorf("got %v expected %v", err, ErrDraining)
+		}
+
+		got, _, err := ss.Get(key)
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if got != 10 {
+			t.Errorf("got %d expected %d", got, 10)
+		}
+	})
+
+	t.Run("Drain returns the context error when operations don't finish", func(t *testing.T) {
+		ss, _ := New()
+		ss.begin(false)
+		defer ss.end()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := ss.Drain(ctx)
+		if err != context.DeadlineExceeded {
+			t.Errorf("got %v expected %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestKeyDurationUntil(t *testing.T) {
+	t.Run("Key expires at the computed time", func(t *testing.T) {
+		expiration := time.Now().Add(time.Hour).Truncate(time.Hour)
+		ss, _ := New(WithKeyDurationUntil(func(now time.Time) time.Time {
+			return expiration
+		}))
+
+		key, _ := ss.Set(10)
+		e, _ := ss.storage.(*shardedMap).load(lookupKey(key))
+
+		if got := e.ExpiresAt; !got.Equal(expiration) {
+			t.Errorf("got %s expected %s", got, expiration)
+		}
+	})
+
+	t.Run("Key expiring in the past is refused", func(t *testing.T) {
+		ss, _ := New(WithKeyDurationUntil(func(now time.Time) time.Time {
+			return now
+		}))
+
+		_, err := ss.Set(10)
+		if err != ErrPastExpiration {
+			t.Errorf("got %v expected %v", err, ErrPastExpiration)
+		}
+	})
+
+	t.Run("Can't be used along with WithKeyDuration", func(t *testing.T) {
+		_, err := New(
+			WithKeyDuration(time.Minute),
+			WithKeyDurationUntil(func(now time.Time) time.Time { return now }),
+		)
+
+		if !errors.Is(err, ErrCustomKeyDurationAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrCustomKeyDurationAlreadySet)
+		}
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	t.Run("Read-only storage refuses mutations", func(t *testing.T) {
+		ss, _ := NewReadOnly()
+
+		if _, err := ss.Set(10); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+
+		if err := ss.Remove("key"); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+
+		if err := ss.ClearExpired(); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+	})
+
+	t.Run("Read-only storage gets without rotating", func(t *testing.T) {
+		ss, _ := NewReadOnly()
+		ss.storage.(*shardedMap).store(lookupKey("key"), Entry{Data: 10, ExpiresAt: time.Now().Add(time.Minute)})
+
+		for range 2 {
+			got, newKey, err := ss.Get("key")
+			if err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if got != 10 {
+				t.Errorf("got %d expected %d", got, 10)
+			}
+
+			if newKey != "key" {
+				t.Errorf("got %q expected %q", newKey, "key")
+			}
+		}
+	})
+}
+
+func TestWithoutKeyRotation(t *testing.T) {
+	t.Run("Keys stay valid until they expire", func(t *testing.T) {
+		ss, _ := New(WithoutKeyRotation(), WithKeyDuration(20*time.Millisecond))
+		key, _ := ss.Set(10)
+
+		for range 3 {
+			got, newKey, err := ss.Get(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got != 10 || newKey != key {
+				t.Errorf("got %v and %q expected %d and %q", got, newKey, 10, key)
+			}
+		}
+
+		time.Sleep(30 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); err != ErrKeyWasExpired {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Removed keys are gone", func(t *testing.T) {
+		ss, _ := New(WithoutKeyRotation())
+		key, _ := ss.Set(10)
+		ss.Remove(key)
+
+		if _, _, err := ss.Get(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+}
+
+func TestSyncMapRotation(t *testing.T) {
+	t.Run("Session survives several rotations", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		for range 3 {
+			var (
+				got any
+				err error
+			)
+
+			got, key, err = ss.Get(key)
+			if err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if got != 10 {
+				t.Errorf("got %v expected %d", got, 10)
+			}
+		}
+	})
+}
+
+func TestPeek(t *testing.T) {
+	t.Run("Peeking leaves the key usable", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		for range 2 {
+			got, err := ss.Peek(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got != 10 {
+				t.Errorf("got %v expected %d", got, 10)
+			}
+		}
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Expired sessions can't be peeked", func(t *testing.T) {
+		ss, _ := New(WithKeyDuration(time.Millisecond))
+		key, _ := ss.Set(10)
+		time.Sleep(2 * time.Millisecond)
+
+		if _, err := ss.Peek(key); !errors.Is(err, ErrKeyWasExpired) {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Peeking respects lockdowns", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+		ss.Lockdown(time.Minute)
+
+		if _, err := ss.Peek(key); !errors.Is(err, ErrLockdown) {
+			t.Errorf("got %v expected %v", err, ErrLockdown)
+		}
+	})
+}
+
+func TestPop(t *testing.T) {
+	t.Run("Popping returns the removed session", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		got, err := ss.Pop(key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got != 10 {
+			t.Errorf("got %v expected %d", got, 10)
+		}
+
+		if _, err := ss.Pop(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Expired sessions are removed but not returned", func(t *testing.T) {
+		ss, _ := New(WithKeyDuration(time.Millisecond))
+		key, _ := ss.Set(10)
+		time.Sleep(2 * time.Millisecond)
+
+		if _, err := ss.Pop(key); !errors.Is(err, ErrKeyWasExpired) {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+
+		if _, err := ss.Pop(key); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Sessions are popped once", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(10)
+
+		var (
+			popped atomic.Int32
+			wg     sync.WaitGroup
+		)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := ss.Pop(key); err == nil {
+					popped.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if popped.Load() != 1 {
+			t.Errorf("got %d expected %d", popped.Load(), 1)
+		}
+	})
+
+	t.Run("Popping a graced key pops the rotated session", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+		key, _ := ss.Set(10)
+		_, newKey, _ := ss.Get(key)
+
+		if got, err := ss.Pop(key); err != nil || got != 10 {
+			t.Errorf("got %v and error %v expected %d", got, err, 10)
+		}
+
+		if _, _, err := ss.Get(newKey); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Typed sessions are popped", func(t *testing.T) {
+		ss, _ := New()
+		typed := NewTyped[user](ss)
+		key, _ := typed.Set(user{ID: 42})
+
+		if got, err := typed.Pop(key); err != nil || got.ID != 42 {
+			t.Errorf("got %v and error %v expected %v", got, err, user{ID: 42})
+		}
+	})
+}
+
+// countingStorage is a third-party storage counting the entries set on it.
+type countingStorage struct {
+	*shardedMap
+	sets int
+}
+
+func (c *countingStorage) Set(ctx context.Context, key string, e Entry) error {
+	c.sets++
+	return c.shardedMap.Set(ctx, key, e)
+}
+
+// basicStorage is a third-party storage implementing none of the optional
+// interfaces, such as Ranger.
+type basicStorage struct {
+	Storage
+}
+
+func TestCustomStorage(t *testing.T) {
+	t.Run("Custom storage holds the sessions", func(t *testing.T) {
+		cs := &countingStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(cs))
+
+		key, _ := ss.Set(10)
+		got, _, err := ss.Get(key)
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if got != 10 {
+			t.Errorf("got %v expected %d", got, 10)
+		}
+
+		if cs.sets != 2 {
+			t.Errorf("got %d expected %d", cs.sets, 2)
+		}
+	})
+
+	t.Run("Custom storage can't be used along with Redis", func(t *testing.T) {
+		_, err := New(WithStorage(&countingStorage{}), WithRedis(new(redis.Client), nil))
+
+		if !errors.Is(err, ErrStorageAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrStorageAlreadySet)
+		}
+	})
+
+	t.Run("Key collisions generate another key", func(t *testing.T) {
+		keys := []string{"taken", "taken", "free"}
+		ss, _ := New(WithCustomRandomKeyGenerator(func(uint64) (string, error) {
+			key := keys[0]
+			keys = keys[1:]
+			return key, nil
+		}))
+
+		ss.Set(10)
+		got, _ := ss.Set(20)
+
+		if got != "free" {
+			t.Errorf("got %q expected %q", got, "free")
+		}
+	})
+}
+
+func TestElevatedKeyLength(t *testing.T) {
+	isAdmin := func(session any) bool {
+		return session == "admin"
+	}
+
+	t.Run("Elevated sessions get longer keys", func(t *testing.T) {
+		ss, _ := New(WithKeyLength(16), WithElevatedKeyLength(64, isAdmin))
+
+		userKey, _ := ss.Set("user")
+		adminKey, _ := ss.Set("admin")
+
+		if len(userKey) != 16 {
+			t.Errorf("got %d expected %d", len(userKey), 16)
+		}
+
+		if len(adminKey) != 64 {
+			t.Errorf("got %d expected %d", len(adminKey), 64)
+		}
+
+		_, adminKey, _ = ss.Get(adminKey)
+		if len(adminKey) != 64 {
+			t.Errorf("got %d expected %d", len(adminKey), 64)
+		}
+	})
+
+	t.Run("Elevated key length must be greater than the key length", func(t *testing.T) {
+		_, err := New(WithElevatedKeyLength(16, isAdmin))
+
+		if err != ErrElevatedKeyLengthTooShort {
+			t.Errorf("got %v expected %v", err, ErrElevatedKeyLengthTooShort)
+		}
+	})
+}
+
+func TestContextVariants(t *testing.T) {
+	t.Run("Canceled context reaches Redis", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+		key, _ := ss.Set("session")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := ss.SetCtx(ctx, "session"); !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v expected %v", err, context.Canceled)
+		}
+
+		if _, _, err := ss.GetCtx(ctx, key); !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v expected %v", err, context.Canceled)
+		}
+
+		if err := ss.RemoveCtx(ctx, key); !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v expected %v", err, context.Canceled)
+		}
+
+		if _, _, err := ss.GetCtx(context.Background(), key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+}
+
+// intervalScheduler records the intervals jobs are scheduled at, never running
+// them.
+type intervalScheduler struct {
+	intervals []time.Duration
+}
+
+func (is *intervalScheduler) Schedule(interval time.Duration, _ func()) func() {
+	is.intervals = append(is.intervals, interval)
+	return func() {}
+}
+
+func TestAutoClearInterval(t *testing.T) {
+	t.Run("Non-positive interval", func(t *testing.T) {
+		_, err := New(WithAutoClearInterval(0))
+
+		if !errors.Is(err, ErrNonPositiveAutoClearInterval) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveAutoClearInterval)
+		}
+	})
+
+	t.Run("Interval already set", func(t *testing.T) {
+		_, err := New(WithAutoClearInterval(time.Minute), WithAutoClearInterval(time.Hour))
+
+		if !errors.Is(err, ErrAutoClearIntervalAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrAutoClearIntervalAlreadySet)
+		}
+	})
+
+	t.Run("Interval is independent from the key duration", func(t *testing.T) {
+		sched := new(intervalScheduler)
+		New(WithKeyDuration(24*time.Hour), WithAutoClearInterval(time.Minute), WithScheduler(sched))
+
+		if !slices.Equal(sched.intervals, []time.Duration{time.Minute}) {
+			t.Errorf("got %v expected %v", sched.intervals, []time.Duration{time.Minute})
+		}
+	})
+
+	t.Run("Interval can be changed at runtime", func(t *testing.T) {
+		sched := new(intervalScheduler)
+		ss, _ := New(WithAutoClearExpiredKeys(), WithScheduler(sched))
+
+		if err := ss.Reconfigure(WithAutoClearInterval(time.Second)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		expected := []time.Duration{defaultDurationToExpire, time.Second}
+		if !slices.Equal(sched.intervals, expected) {
+			t.Errorf("got %v expected %v", sched.intervals, expected)
+		}
+	})
+}
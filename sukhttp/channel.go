@@ -0,0 +1,56 @@
+package sukhttp
+
+import (
+	"crypto/sha256"
+	"net/http"
+)
+
+// exporterLabel is the label of the TLS exporter value binding a session to
+// its connection, as set by RFC 9266.
+const exporterLabel = "EXPORTER-Channel-Binding"
+
+// WithChannelBinding binds the sessions to the TLS channel bind tells for the
+// request they were set with, such as ClientCertificate or TLSExporter, and
+// checks it on every request, with suk.ChannelContext. Requests carrying a key
+// bound to another channel are served without a session, and their error is
+// suk.ErrChannelMismatch. Handlers must set sessions with the context of the
+// request for them to be bound.
+func WithChannelBinding(bind func(r *http.Request) []byte) Option {
+	return option(func(c *config) error {
+		if bind == nil {
+			return ErrNilChannelBind
+		}
+
+		c.channel = bind
+		return nil
+	})
+}
+
+// ClientCertificate returns the SHA-256 hash of the certificate the client
+// presented over mutual TLS, binding sessions to it, or nil when it presented
+// none.
+func ClientCertificate(r *http.Request) []byte {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	sum := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+	return sum[:]
+}
+
+// TLSExporter returns the TLS exporter value of the connection the request
+// came over, as set by RFC 9266, binding sessions to the connection itself,
+// or nil when it has none, such as over plain HTTP. Connections must be kept
+// alive for the sessions bound to them to stay usable.
+func TLSExporter(r *http.Request) []byte {
+	if r.TLS == nil {
+		return nil
+	}
+
+	ekm, err := r.TLS.ExportKeyingMaterial(exporterLabel, nil, 32)
+	if err != nil {
+		return nil
+	}
+
+	return ekm
+}
@@ -0,0 +1,112 @@
+package sukhttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+func TestChannelBinding(t *testing.T) {
+	withCertificate := func(key string, raw []byte) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		if raw != nil {
+			r.TLS.PeerCertificates = []*x509.Certificate{{Raw: raw}}
+		}
+
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		return r
+	}
+
+	t.Run("Sessions are bound to the client certificate", func(t *testing.T) {
+		ss, _ := suk.New()
+		bind := WithChannelBinding(ClientCertificate)
+
+		r := withCertificate("", []byte("alice"))
+		key, _ := ss.SetCtx(suk.ChannelContext(context.Background(), ClientCertificate(r)), "alice")
+
+		for name, raw := range map[string][]byte{"another certificate": []byte("mallory"), "no certificate": nil} {
+			if resp := serve(ss, withCertificate(key, raw), bind); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("got %d with %s expected %d", resp.StatusCode, name, http.StatusUnauthorized)
+			}
+		}
+
+		resp := serve(ss, withCertificate(key, []byte("alice")), bind)
+		if body, _ := io.ReadAll(resp.Body); string(body) != "alice" {
+			t.Errorf("got %q expected %q", body, "alice")
+		}
+	})
+
+	t.Run("Requests over another channel are unknown", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.SetCtx(suk.ChannelContext(context.Background(), []byte("alice")), "alice")
+
+		handler := Middleware(ss, WithChannelBinding(ClientCertificate))(RequireSession(
+			status(http.StatusOK),
+			WithUnknownKeyHandler(status(http.StatusNotFound)),
+		))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, withCertificate(key, []byte("mallory")))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("got %d expected %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("TLS exporter values are kept by the connection", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(TLSExporter(r))
+		}))
+		defer srv.Close()
+
+		get := func(client *http.Client) []byte {
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			return body
+		}
+
+		first := get(srv.Client())
+		if len(first) != 32 {
+			t.Fatalf("got %d bytes expected %d", len(first), 32)
+		}
+
+		if again := get(srv.Client()); !bytes.Equal(again, first) {
+			t.Errorf("got %x expected %x", again, first)
+		}
+
+		srv.Client().CloseIdleConnections()
+		if other := get(srv.Client()); bytes.Equal(other, first) {
+			t.Errorf("got %x expected another value", other)
+		}
+	})
+
+	t.Run("Requests without TLS have no binding", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		if ClientCertificate(r) != nil || TLSExporter(r) != nil {
+			t.Errorf("got a binding expected none")
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		defer func() {
+			if err, _ := recover().(error); !errors.Is(err, ErrNilChannelBind) {
+				t.Errorf("got %v expected %v", err, ErrNilChannelBind)
+			}
+		}()
+
+		ss, _ := suk.New()
+		Middleware(ss, WithChannelBinding(nil))
+	})
+}
@@ -0,0 +1,97 @@
+package sukhttp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/ed-henrique/suk"
+)
+
+var ErrNoSession = errors.New("The request has no session.")
+
+type stateContextKey struct{}
+
+// state is the session of a request, as retrieved by the middleware, along
+// with why it couldn't be, if it couldn't.
+type state struct {
+	mu      sync.Mutex
+	session suk.Session
+	err     error
+	w       *ResponseWriter
+
+	// staged tells whether the data of the session was changed with SetData,
+	// so it must be stored once the request is served.
+	staged bool
+}
+
+func stateFrom(ctx context.Context) (*state, bool) {
+	st, ok := ctx.Value(stateContextKey{}).(*state)
+	return st, ok
+}
+
+// FromContext returns the session of the request the context belongs to, as
+// retrieved by the middleware, along with the data staged with SetData, if
+// any.
+func FromContext(ctx context.Context) (suk.Session, bool) {
+	st, ok := stateFrom(ctx)
+	if !ok {
+		return suk.Session{}, false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.err != nil {
+		return suk.Session{}, false
+	}
+
+	return st.session, true
+}
+
+// SetData stages v as the new data of the session of the request the context
+// belongs to. The middleware stores it once the request is served, keeping
+// the key, so handlers down the chain see the staged data right away, and the
+// session is written once however many times it changes.
+func SetData(ctx context.Context, v any) error {
+	if v == nil {
+		return suk.ErrNilSession
+	}
+
+	st, ok := stateFrom(ctx)
+	if !ok {
+		return ErrNoMiddleware
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.err != nil {
+		return ErrNoSession
+	}
+
+	st.session.Data = v
+	st.staged = true
+	return nil
+}
+
+// flush stores the data staged with SetData, if any.
+func (st *state) flush(ctx context.Context, ss *suk.SessionStorage) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if !st.staged {
+		return nil
+	}
+
+	st.staged = false
+	data := st.session.Data
+	return ss.UpdateCtx(ctx, st.session.Key, func(any) any { return data })
+}
+
+// logFlushError logs the error of storing the data staged by the handlers.
+func logFlushError(r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "sukhttp: could not store the staged session data", "error", err)
+}
@@ -0,0 +1,92 @@
+package sukhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+func TestContext(t *testing.T) {
+	t.Run("Staged data is stored once the request is served", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set(1)
+
+		var newKey string
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, ok := FromContext(r.Context())
+			if !ok {
+				t.Fatalf("got %v expected %v", ok, true)
+			}
+			newKey = s.Key
+
+			SetData(r.Context(), s.Data.(int)+1)
+			if s, _ := FromContext(r.Context()); s.Data != 2 {
+				t.Errorf("got %v expected %v", s.Data, 2)
+			}
+
+			// Nothing is stored until the request is served.
+			if got, _ := ss.Peek(newKey); got != 1 {
+				t.Errorf("got %v expected %v", got, 1)
+			}
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if got, _ := ss.Peek(newKey); got != 2 {
+			t.Errorf("got %v expected %v", got, 2)
+		}
+	})
+
+	t.Run("Failures to store the staged data are told", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set(1)
+
+		var got error
+		handler := Middleware(ss, WithFlushErrorHandler(func(_ *http.Request, err error) {
+			got = err
+		}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, _ := FromContext(r.Context())
+			ss.Remove(s.Key)
+			SetData(r.Context(), 2)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if got != suk.ErrNoKeyFound {
+			t.Errorf("got %v expected %v", got, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Requests without a session", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := FromContext(r.Context()); ok {
+				t.Errorf("got %v expected %v", ok, false)
+			}
+
+			if err := SetData(r.Context(), 2); err != ErrNoSession {
+				t.Errorf("got %v expected %v", err, ErrNoSession)
+			}
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("Contexts without the middleware", func(t *testing.T) {
+		if err := SetData(context.Background(), 2); err != ErrNoMiddleware {
+			t.Errorf("got %v expected %v", err, ErrNoMiddleware)
+		}
+
+		if err := SetData(context.Background(), nil); err != suk.ErrNilSession {
+			t.Errorf("got %v expected %v", err, suk.ErrNilSession)
+		}
+	})
+}
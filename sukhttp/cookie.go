@@ -0,0 +1,101 @@
+package sukhttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+// CookieManager writes, reads and clears the cookies carrying the keys, all
+// from the same template, so they always match.
+type CookieManager struct {
+	Name     string
+	Path     string
+	Domain   string
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+
+	// MaxAge is how long the cookies live. When zero, they live as long as the
+	// keys issued by Sessions, or until the browser is closed without it.
+	MaxAge time.Duration
+
+	// Sessions is the session storage issuing the keys.
+	Sessions *suk.SessionStorage
+}
+
+// NewCookieManager returns a cookie manager for the keys issued by ss, with
+// secure defaults: cookies named DefaultCookieName, for the whole site, only
+// sent over HTTPS, hidden from scripts, not sent along cross-site subrequests,
+// and living as long as the keys.
+func NewCookieManager(ss *suk.SessionStorage) *CookieManager {
+	return &CookieManager{
+		Name:     DefaultCookieName,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Sessions: ss,
+	}
+}
+
+// Write sets the cookie carrying the key on the response.
+func (m *CookieManager) Write(w http.ResponseWriter, key string) {
+	maxAge := m.MaxAge
+	if maxAge == 0 && m.Sessions != nil {
+		maxAge = m.Sessions.KeyDuration()
+	}
+
+	cookie := m.cookie(key)
+	if maxAge > 0 {
+		cookie.MaxAge = int(maxAge.Seconds())
+		cookie.Expires = time.Now().Add(maxAge)
+	}
+
+	http.SetCookie(w, cookie)
+}
+
+// WriteSession sets the cookie carrying the key of the session on the
+// response, expiring along with the key, unless MaxAge is set.
+func (m *CookieManager) WriteSession(w http.ResponseWriter, s suk.Session) {
+	if m.MaxAge != 0 || s.ExpiresAt.IsZero() {
+		m.Write(w, s.Key)
+		return
+	}
+
+	cookie := m.cookie(s.Key)
+	cookie.MaxAge = max(int(time.Until(s.ExpiresAt).Seconds()), 1)
+	cookie.Expires = s.ExpiresAt
+	http.SetCookie(w, cookie)
+}
+
+// Read returns the key carried by the cookie of the request, if there's any.
+func (m *CookieManager) Read(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(m.Name)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
+// Clear removes the cookie from the client, such as when logging out.
+func (m *CookieManager) Clear(w http.ResponseWriter) {
+	cookie := m.cookie("")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	http.SetCookie(w, cookie)
+}
+
+func (m *CookieManager) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.Name,
+		Value:    value,
+		Path:     m.Path,
+		Domain:   m.Domain,
+		Secure:   m.Secure,
+		HttpOnly: m.HttpOnly,
+		SameSite: m.SameSite,
+	}
+}
@@ -0,0 +1,83 @@
+package sukhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+func TestCookieManager(t *testing.T) {
+	t.Run("Cookies live as long as the keys", func(t *testing.T) {
+		ss, _ := suk.New(suk.WithKeyDuration(time.Hour))
+		m := NewCookieManager(ss)
+
+		w := httptest.NewRecorder()
+		m.Write(w, "key")
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("got %d cookies expected %d", len(cookies), 1)
+		}
+
+		c := cookies[0]
+		if c.Name != DefaultCookieName || c.Value != "key" || c.Path != "/" || !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+			t.Errorf("got %v expected the default template", c)
+		}
+
+		if c.MaxAge < 3599 || c.MaxAge > 3600 {
+			t.Errorf("got %d expected %d", c.MaxAge, 3600)
+		}
+	})
+
+	t.Run("Cookies live for the given max age", func(t *testing.T) {
+		m := &CookieManager{Name: "session", Domain: "example.com", MaxAge: time.Minute}
+
+		w := httptest.NewRecorder()
+		m.Write(w, "key")
+
+		c := w.Result().Cookies()[0]
+		if c.Name != "session" || c.Domain != "example.com" || c.MaxAge != 60 {
+			t.Errorf("got %v expected a cookie of %q living for %d seconds", c, "example.com", 60)
+		}
+	})
+
+	t.Run("Session cookies expire along with their keys", func(t *testing.T) {
+		ss, _ := suk.New()
+		s, _ := ss.SetSession("a", suk.WithTTL(time.Minute))
+
+		w := httptest.NewRecorder()
+		NewCookieManager(ss).WriteSession(w, s)
+
+		c := w.Result().Cookies()[0]
+		if c.Value != s.Key || c.MaxAge < 59 || c.MaxAge > 60 {
+			t.Errorf("got %v expected %q living for %d seconds", c, s.Key, 60)
+		}
+	})
+
+	t.Run("Keys are read from the cookie", func(t *testing.T) {
+		m := NewCookieManager(nil)
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if _, ok := m.Read(r); ok {
+			t.Errorf("got %v expected %v", ok, false)
+		}
+
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "key"})
+		if key, ok := m.Read(r); !ok || key != "key" {
+			t.Errorf("got %q expected %q", key, "key")
+		}
+	})
+
+	t.Run("Cleared cookies expire right away", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		NewCookieManager(nil).Clear(w)
+
+		c := w.Result().Cookies()[0]
+		if c.Value != "" || c.MaxAge >= 0 {
+			t.Errorf("got %v expected an expired cookie", c)
+		}
+	})
+}
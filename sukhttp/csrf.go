@@ -0,0 +1,152 @@
+package sukhttp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ed-henrique/suk"
+)
+
+const (
+	// DefaultCSRFHeader is the header carrying the CSRF tokens when none is
+	// given with WithCSRFHeader, as sent by scripts.
+	DefaultCSRFHeader = "X-CSRF-Token"
+
+	// DefaultCSRFField is the form field carrying the CSRF tokens when none is
+	// given with WithCSRFField, as sent by HTML forms.
+	DefaultCSRFField = "csrf_token"
+)
+
+var ErrEmptyCSRFField = errors.New("The given CSRF form field must not be empty.")
+
+// CSRFOption customizes CSRF.
+type CSRFOption interface {
+	applyCSRF(*csrfConfig) error
+}
+
+type csrfOption func(*csrfConfig) error
+
+func (o csrfOption) applyCSRF(c *csrfConfig) error {
+	return o(c)
+}
+
+type csrfConfig struct {
+	header    string
+	field     string
+	onFailure http.Handler
+}
+
+// WithCSRFHeader sets the header carrying the CSRF tokens, DefaultCSRFHeader
+// otherwise.
+func WithCSRFHeader(header string) CSRFOption {
+	return csrfOption(func(c *csrfConfig) error {
+		if header == "" {
+			return ErrEmptyHeader
+		}
+
+		c.header = header
+		return nil
+	})
+}
+
+// WithCSRFField sets the form field carrying the CSRF tokens, DefaultCSRFField
+// otherwise.
+func WithCSRFField(field string) CSRFOption {
+	return csrfOption(func(c *csrfConfig) error {
+		if field == "" {
+			return ErrEmptyCSRFField
+		}
+
+		c.field = field
+		return nil
+	})
+}
+
+// WithCSRFFailureHandler sets the handler serving the requests rejected for
+// lacking a valid CSRF token, which are answered with 403 Forbidden otherwise.
+func WithCSRFFailureHandler(h http.Handler) CSRFOption {
+	return csrfOption(func(c *csrfConfig) error {
+		if h == nil {
+			return ErrNilHandler
+		}
+
+		c.onFailure = h
+		return nil
+	})
+}
+
+// safeMethod tells whether the method must not change anything, so requests
+// using it need no CSRF token.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// CSRF returns a middleware implementing the synchronizer token pattern: the
+// requests using unsafe methods, such as POST, are only served when they carry
+// the CSRF token of their session, as issued by CSRFToken, in the header or
+// the form field. It must be behind the middleware, and panics if an option is
+// invalid.
+func CSRF(ss *suk.SessionStorage, opts ...CSRFOption) func(http.Handler) http.Handler {
+	c := csrfConfig{
+		header: DefaultCSRFHeader,
+		field:  DefaultCSRFField,
+		onFailure: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}),
+	}
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.applyCSRF(&c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		panic(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if safeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			s, ok := FromContext(r.Context())
+			if !ok {
+				c.onFailure.ServeHTTP(w, r)
+				return
+			}
+
+			token := r.Header.Get(c.header)
+			if token == "" {
+				token = r.PostFormValue(c.field)
+			}
+
+			if err := ss.ValidateCSRFCtx(r.Context(), s.Key, token); err != nil {
+				c.onFailure.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRFToken returns the CSRF token of the session of the request, as
+// retrieved by the middleware, for the forms and scripts to send it back. It
+// fails with ErrNoSession when the request has no session.
+func CSRFToken(ss *suk.SessionStorage, r *http.Request) (string, error) {
+	s, ok := FromContext(r.Context())
+	if !ok {
+		return "", ErrNoSession
+	}
+
+	return ss.IssueCSRFCtx(r.Context(), s.Key)
+}
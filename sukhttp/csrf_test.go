@@ -0,0 +1,117 @@
+package sukhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+func TestCSRF(t *testing.T) {
+	ss, _ := suk.New()
+
+	var token string
+	handler := Middleware(ss)(CSRF(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			var err error
+			if token, err = CSRFToken(ss, r); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	// serve serves the request with the key, returning the status code and the
+	// key it was rotated to.
+	serve := func(r *http.Request, key string) (int, string) {
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		for _, c := range w.Result().Cookies() {
+			key = c.Value
+		}
+		return w.Code, key
+	}
+
+	t.Run("Safe methods need no token", func(t *testing.T) {
+		key, _ := ss.Set("a")
+
+		if code, _ := serve(httptest.NewRequest(http.MethodGet, "/", nil), key); code != http.StatusNoContent {
+			t.Errorf("got %d expected %d", code, http.StatusNoContent)
+		}
+	})
+
+	t.Run("Tokens are accepted in the header", func(t *testing.T) {
+		key, _ := ss.Set("a")
+		_, key = serve(httptest.NewRequest(http.MethodGet, "/", nil), key)
+
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(DefaultCSRFHeader, token)
+		if code, _ := serve(r, key); code != http.StatusNoContent {
+			t.Errorf("got %d expected %d", code, http.StatusNoContent)
+		}
+	})
+
+	t.Run("Tokens are accepted in the form", func(t *testing.T) {
+		key, _ := ss.Set("a")
+		_, key = serve(httptest.NewRequest(http.MethodGet, "/", nil), key)
+
+		form := url.Values{DefaultCSRFField: {token}}
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if code, _ := serve(r, key); code != http.StatusNoContent {
+			t.Errorf("got %d expected %d", code, http.StatusNoContent)
+		}
+	})
+
+	t.Run("Unsafe requests without a valid token are rejected", func(t *testing.T) {
+		key, _ := ss.Set("a")
+		_, key = serve(httptest.NewRequest(http.MethodGet, "/", nil), key)
+
+		code, key := serve(httptest.NewRequest(http.MethodPost, "/", nil), key)
+		if code != http.StatusForbidden {
+			t.Errorf("got %d expected %d", code, http.StatusForbidden)
+		}
+
+		// Tokens of other sessions are just as wrong.
+		other, _ := ss.Set("b")
+		_, other = serve(httptest.NewRequest(http.MethodGet, "/", nil), other)
+
+		r := httptest.NewRequest(http.MethodDelete, "/", nil)
+		r.Header.Set(DefaultCSRFHeader, token)
+		if code, _ := serve(r, key); code != http.StatusForbidden {
+			t.Errorf("got %d expected %d", code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("Unsafe requests without a session are rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("got %d expected %d", w.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("Requests without a session get no token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if _, err := CSRFToken(ss, r); err != ErrNoSession {
+			t.Errorf("got %v expected %v", err, ErrNoSession)
+		}
+	})
+
+	t.Run("Invalid options panic", func(t *testing.T) {
+		defer func() {
+			if got := recover(); got == nil {
+				t.Errorf("got no panic expected one")
+			}
+		}()
+
+		CSRF(ss, WithCSRFField(""))
+	})
+}
@@ -0,0 +1,105 @@
+package sukhttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ed-henrique/suk"
+)
+
+// RotatedKeyHeader is the response header carrying the rotated key back to
+// the clients sending their key where the response can't answer, such as in
+// the Authorization header or the query string.
+const RotatedKeyHeader = "Suk-Key"
+
+// KeyExtractor extracts the key carried by requests, and hands the key it was
+// rotated to back to the client the same way it was sent, or as close as it
+// gets.
+type KeyExtractor interface {
+	// Extract returns the key carried by the request, if there's any.
+	Extract(r *http.Request) (string, bool)
+
+	// Write hands the session's key back to the client.
+	Write(w http.ResponseWriter, s suk.Session)
+}
+
+type cookieExtractor struct {
+	m *CookieManager
+}
+
+// CookieExtractor extracts the keys from the cookies of the given manager,
+// writing the rotated keys in them.
+func CookieExtractor(m *CookieManager) KeyExtractor {
+	return cookieExtractor{m: m}
+}
+
+func (e cookieExtractor) Extract(r *http.Request) (string, bool) {
+	return e.m.Read(r)
+}
+
+func (e cookieExtractor) Write(w http.ResponseWriter, s suk.Session) {
+	e.m.WriteSession(w, s)
+}
+
+type headerExtractor struct {
+	header string
+}
+
+// HeaderExtractor extracts the keys from the given header, writing the rotated
+// keys in the same header of the response.
+func HeaderExtractor(header string) KeyExtractor {
+	return headerExtractor{header: http.CanonicalHeaderKey(header)}
+}
+
+func (e headerExtractor) Extract(r *http.Request) (string, bool) {
+	key := r.Header.Get(e.header)
+	return key, key != ""
+}
+
+func (e headerExtractor) Write(w http.ResponseWriter, s suk.Session) {
+	w.Header().Set(e.header, s.Key)
+}
+
+type bearerExtractor struct{}
+
+// BearerExtractor extracts the keys sent as bearer tokens in the Authorization
+// header, as single-page applications and mobile clients do, writing the
+// rotated keys in RotatedKeyHeader.
+func BearerExtractor() KeyExtractor {
+	return bearerExtractor{}
+}
+
+func (bearerExtractor) Extract(r *http.Request) (string, bool) {
+	scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	key = strings.TrimSpace(key)
+	return key, key != ""
+}
+
+func (bearerExtractor) Write(w http.ResponseWriter, s suk.Session) {
+	w.Header().Set(RotatedKeyHeader, s.Key)
+}
+
+type queryExtractor struct {
+	param string
+}
+
+// QueryExtractor extracts the keys from the given query parameter, writing the
+// rotated keys in RotatedKeyHeader. Keys in URLs end up in logs and browser
+// histories, so only use it where nothing else works, such as for WebSocket
+// handshakes from browsers.
+func QueryExtractor(param string) KeyExtractor {
+	return queryExtractor{param: param}
+}
+
+func (e queryExtractor) Extract(r *http.Request) (string, bool) {
+	key := r.URL.Query().Get(e.param)
+	return key, key != ""
+}
+
+func (e queryExtractor) Write(w http.ResponseWriter, s suk.Session) {
+	w.Header().Set(RotatedKeyHeader, s.Key)
+}
@@ -0,0 +1,82 @@
+package sukhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+func TestKeyExtractors(t *testing.T) {
+	t.Run("Bearer tokens", func(t *testing.T) {
+		e := BearerExtractor()
+
+		for header, expected := range map[string]string{
+			"Bearer key": "key",
+			"bearer key": "key",
+			"Basic key":  "",
+			"Bearer ":    "",
+			"":           "",
+		} {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", header)
+
+			if key, _ := e.Extract(r); key != expected {
+				t.Errorf("got %q from %q expected %q", key, header, expected)
+			}
+		}
+	})
+
+	t.Run("Query parameters", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/?session=key", nil)
+
+		if key, ok := QueryExtractor("session").Extract(r); !ok || key != "key" {
+			t.Errorf("got %q expected %q", key, "key")
+		}
+	})
+
+	t.Run("Headers", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Session-Key", "key")
+
+		if key, ok := HeaderExtractor("x-session-key").Extract(r); !ok || key != "key" {
+			t.Errorf("got %q expected %q", key, "key")
+		}
+	})
+
+	t.Run("Extractors are tried in order", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("alice")
+
+		r := httptest.NewRequest(http.MethodGet, "/?session=unknown", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		resp := serve(ss, r, WithKeyExtractors(BearerExtractor(), QueryExtractor("session")))
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "alice" {
+			t.Errorf("got %q expected %q", body, "alice")
+		}
+
+		newKey := resp.Header.Get(RotatedKeyHeader)
+		if newKey == "" || newKey == key {
+			t.Errorf("got %q expected a new key", newKey)
+		}
+
+		if len(resp.Cookies()) != 0 {
+			t.Errorf("got %v expected no cookie", resp.Cookies())
+		}
+	})
+
+	t.Run("No extractors", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("got no panic expected %v", ErrNoKeyExtractors)
+			}
+		}()
+
+		ss, _ := suk.New()
+		Middleware(ss, WithKeyExtractors())
+	})
+}
@@ -0,0 +1,145 @@
+package sukhttp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ed-henrique/suk"
+)
+
+var (
+	ErrNilHandler      = errors.New("The given handler must not be nil.")
+	ErrNilErrorHandler = errors.New("The given error handler must not be nil.")
+)
+
+// RequireOption customizes RequireSession.
+type RequireOption interface {
+	applyRequire(*requireConfig) error
+}
+
+type requireOption func(*requireConfig) error
+
+func (o requireOption) applyRequire(c *requireConfig) error {
+	return o(c)
+}
+
+type requireConfig struct {
+	missing http.Handler
+	expired http.Handler
+	unknown http.Handler
+	onError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// WithMissingKeyHandler sets the handler serving the requests carrying no key,
+// such as by redirecting to the login page.
+func WithMissingKeyHandler(h http.Handler) RequireOption {
+	return requireOption(func(c *requireConfig) error {
+		if h == nil {
+			return ErrNilHandler
+		}
+
+		c.missing = h
+		return nil
+	})
+}
+
+// WithExpiredKeyHandler sets the handler serving the requests carrying an
+// expired key, such as by telling the user the session timed out.
+func WithExpiredKeyHandler(h http.Handler) RequireOption {
+	return requireOption(func(c *requireConfig) error {
+		if h == nil {
+			return ErrNilHandler
+		}
+
+		c.expired = h
+		return nil
+	})
+}
+
+// WithUnknownKeyHandler sets the handler serving the requests carrying a key
+// that was never issued, or was already consumed or removed, replayed keys
+// included, along with the keys set for another fingerprint or TLS channel,
+// and the keys of the sources whose lookups are limited.
+func WithUnknownKeyHandler(h http.Handler) RequireOption {
+	return requireOption(func(c *requireConfig) error {
+		if h == nil {
+			return ErrNilHandler
+		}
+
+		c.unknown = h
+		return nil
+	})
+}
+
+// WithErrorHandler sets the function answering the requests whose session
+// couldn't be retrieved for any other reason, such as the backend being
+// unreachable, or the session storage being in lockdown. It answers with 500
+// Internal Server Error otherwise.
+func WithErrorHandler(fn func(w http.ResponseWriter, r *http.Request, err error)) RequireOption {
+	return requireOption(func(c *requireConfig) error {
+		if fn == nil {
+			return ErrNilErrorHandler
+		}
+
+		c.onError = fn
+		return nil
+	})
+}
+
+// unauthorized answers with 401 Unauthorized.
+func unauthorized(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// RequireSession wraps next so it only serves the requests carrying a valid
+// key, as told by the middleware, which must be in front of it. The others are
+// answered with 401 Unauthorized, unless a handler is given for the reason
+// they were rejected. It panics if an option is invalid.
+func RequireSession(next http.Handler, opts ...RequireOption) http.Handler {
+	c := requireConfig{
+		missing: http.HandlerFunc(unauthorized),
+		expired: http.HandlerFunc(unauthorized),
+		unknown: http.HandlerFunc(unauthorized),
+		onError: func(w http.ResponseWriter, _ *http.Request, _ error) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		},
+	}
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.applyRequire(&c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		panic(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st, ok := stateFrom(r.Context())
+		if !ok {
+			c.onError(w, r, ErrNoMiddleware)
+			return
+		}
+
+		st.mu.Lock()
+		err := st.err
+		st.mu.Unlock()
+
+		switch {
+		case err == nil:
+			next.ServeHTTP(w, r)
+		case errors.Is(err, ErrNoKey):
+			c.missing.ServeHTTP(w, r)
+		case errors.Is(err, suk.ErrKeyWasExpired):
+			c.expired.ServeHTTP(w, r)
+		case errors.Is(err, suk.ErrNoKeyFound), errors.Is(err, suk.ErrForeignKey), errors.Is(err, suk.ErrKeyReplayed),
+			errors.Is(err, suk.ErrFingerprintMismatch), errors.Is(err, suk.ErrChannelMismatch),
+			errors.Is(err, suk.ErrLookupRateLimited):
+			c.unknown.ServeHTTP(w, r)
+		default:
+			c.onError(w, r, err)
+		}
+	})
+}
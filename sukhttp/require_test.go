@@ -0,0 +1,92 @@
+package sukhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+// status answers with the given status code.
+func status(code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(code)
+	})
+}
+
+func TestRequireSession(t *testing.T) {
+	ss, _ := suk.New()
+	live, _ := ss.Set("alice")
+	expired, _ := ss.Set("bob", suk.WithTTL(10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	handler := Middleware(ss)(RequireSession(
+		status(http.StatusOK),
+		WithMissingKeyHandler(status(http.StatusSeeOther)),
+		WithExpiredKeyHandler(status(http.StatusForbidden)),
+		WithUnknownKeyHandler(status(http.StatusNotFound)),
+	))
+
+	// The cases run in order, as the first one consumes the live key.
+	for _, tc := range []struct {
+		name     string
+		key      string
+		expected int
+	}{
+		{"Valid keys are served", live, http.StatusOK},
+		{"Requests without a key", "", http.StatusSeeOther},
+		{"Requests with an expired key", expired, http.StatusForbidden},
+		{"Requests with an unknown key", "unknown", http.StatusNotFound},
+		{"Requests with a key used before", live, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.key != "" {
+				r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: tc.key})
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tc.expected {
+				t.Errorf("got %d expected %d", w.Code, tc.expected)
+			}
+		})
+	}
+
+	t.Run("Rejections are unauthorized by default", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		Middleware(ss)(RequireSession(status(http.StatusOK))).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Requests not served through the middleware", func(t *testing.T) {
+		var got error
+		handler := RequireSession(status(http.StatusOK), WithErrorHandler(func(w http.ResponseWriter, _ *http.Request, err error) {
+			got = err
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if !errors.Is(got, ErrNoMiddleware) {
+			t.Errorf("got %v expected %v", got, ErrNoMiddleware)
+		}
+	})
+
+	t.Run("Nil handlers", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("got no panic expected %v", ErrNilHandler)
+			}
+		}()
+
+		RequireSession(status(http.StatusOK), WithMissingKeyHandler(nil))
+	})
+}
@@ -0,0 +1,274 @@
+// Package sukhttp provides a net/http middleware for suk, retrieving the
+// session of each request from the key it carries and handing the rotated key
+// back to the client, so handlers only deal with the session itself:
+//
+//	mux := http.NewServeMux()
+//	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+//		session, ok := sukhttp.Session(r)
+//		if !ok {
+//			http.Error(w, "Log in first", http.StatusUnauthorized)
+//			return
+//		}
+//		fmt.Fprint(w, session)
+//	})
+//
+//	http.ListenAndServe(":8080", sukhttp.Middleware(ss)(mux))
+//
+// Keys are read from a cookie by default, and from anywhere else, such as the
+// Authorization header, with WithKeyExtractors.
+package sukhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/ed-henrique/suk"
+)
+
+// DefaultCookieName is the cookie carrying the key when none is given with
+// WithCookieName.
+const DefaultCookieName = "suk"
+
+var (
+	ErrEmptyCookieName  = errors.New("The given cookie name must not be empty.")
+	ErrNilCookieManager = errors.New("The given cookie manager must not be nil.")
+	ErrEmptyHeader      = errors.New("The given header must not be empty.")
+	ErrNoKeyExtractors  = errors.New("At least one key extractor must be given.")
+	ErrNilFlushHandler  = errors.New("The given flush error handler must not be nil.")
+	ErrNilFingerprint   = errors.New("The given fingerprint function must not be nil.")
+	ErrNilChannelBind   = errors.New("The given channel binding function must not be nil.")
+	ErrNilSource        = errors.New("The given source function must not be nil.")
+
+	ErrNoKey = errors.New("The request carried no key.")
+)
+
+// Option customizes the middleware.
+type Option interface {
+	apply(*config) error
+}
+
+type option func(*config) error
+
+func (o option) apply(c *config) error {
+	return o(c)
+}
+
+type config struct {
+	cookies      CookieManager
+	header       string
+	extractors   []KeyExtractor
+	onFlushError func(r *http.Request, err error)
+	fingerprint  func(r *http.Request) string
+	channel      func(r *http.Request) []byte
+	source       func(r *http.Request) string
+}
+
+// WithCookieName sets the cookie carrying the key, which is DefaultCookieName
+// otherwise.
+func WithCookieName(name string) Option {
+	return option(func(c *config) error {
+		if name == "" {
+			return ErrEmptyCookieName
+		}
+
+		c.cookies.Name = name
+		return nil
+	})
+}
+
+// WithCookieManager sets the template of the cookies carrying the keys, which
+// is the one returned by NewCookieManager otherwise.
+func WithCookieManager(m *CookieManager) Option {
+	return option(func(c *config) error {
+		if m == nil {
+			return ErrNilCookieManager
+		}
+
+		c.cookies = *m
+		return nil
+	})
+}
+
+// WithHeader makes the key be read from the given header, such as for clients
+// not keeping cookies, before trying the key extractors. Keys read from the
+// header are rotated in the same header of the response.
+func WithHeader(header string) Option {
+	return option(func(c *config) error {
+		if header == "" {
+			return ErrEmptyHeader
+		}
+
+		c.header = header
+		return nil
+	})
+}
+
+// WithKeyExtractors sets where keys are extracted from, trying each extractor
+// in order until one finds a key, which is then rotated the same way. Keys are
+// extracted from the cookie described by the cookie manager otherwise.
+func WithKeyExtractors(extractors ...KeyExtractor) Option {
+	return option(func(c *config) error {
+		if len(extractors) == 0 {
+			return ErrNoKeyExtractors
+		}
+
+		c.extractors = extractors
+		return nil
+	})
+}
+
+// WithFlushErrorHandler sets the function told when the data staged with
+// SetData couldn't be stored, once the request was served, which logs it with
+// the default slog logger otherwise.
+func WithFlushErrorHandler(fn func(r *http.Request, err error)) Option {
+	return option(func(c *config) error {
+		if fn == nil {
+			return ErrNilFlushHandler
+		}
+
+		c.onFlushError = fn
+		return nil
+	})
+}
+
+// WithFingerprinting binds the sessions to the fingerprint extract tells for
+// the request they were set with, such as its IP address and User-Agent, and
+// checks it on every request, with suk.FingerprintContext. Requests carrying a
+// key set for another fingerprint, including an empty one, such as when the
+// headers it is made of are stripped, are served without a session, and their
+// error is suk.ErrFingerprintMismatch, so stolen keys can't be replayed from
+// elsewhere. Handlers must set sessions with the context of the request for
+// them to be bound.
+func WithFingerprinting(extract func(r *http.Request) string) Option {
+	return option(func(c *config) error {
+		if extract == nil {
+			return ErrNilFingerprint
+		}
+
+		c.fingerprint = extract
+		return nil
+	})
+}
+
+// WithSource tells the source of each request with source, such as ClientIP,
+// with suk.SourceContext, so the limits set with suk.WithIssueRateLimit and
+// suk.WithLookupRateLimit are enforced for it. Requests whose lookups are
+// limited are served without a session, and their error is
+// suk.ErrLookupRateLimited.
+func WithSource(source func(r *http.Request) string) Option {
+	return option(func(c *config) error {
+		if source == nil {
+			return ErrNilSource
+		}
+
+		c.source = source
+		return nil
+	})
+}
+
+// ClientIP returns the IP address the request came from, as told by its remote
+// address. Behind a reverse proxy, that is the address of the proxy, so the
+// source must be told from the headers it sets instead.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Session returns the data of the session of the request, if it carried a
+// valid key.
+func Session(r *http.Request) (any, bool) {
+	s, ok := FromContext(r.Context())
+	if !ok {
+		return nil, false
+	}
+
+	return s.Data, true
+}
+
+// Middleware returns a middleware retrieving the session of each request from
+// the key it carries, and handing the key it was rotated to back to the
+// client, for the handlers to find the session with Session. Requests without
+// a valid key are served without a session. It panics if an option is
+// invalid, as middlewares are set up once, when the program starts.
+//
+// Handlers are given a ResponseWriter, which hands the key back along with the
+// header, so handlers issuing keys themselves with Issue don't need to care
+// about when the header is written.
+func Middleware(ss *suk.SessionStorage, opts ...Option) func(http.Handler) http.Handler {
+	c := config{cookies: *NewCookieManager(ss), onFlushError: logFlushError}
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.apply(&c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		panic(err)
+	}
+
+	if c.extractors == nil {
+		c.extractors = []KeyExtractor{CookieExtractor(&c.cookies)}
+	}
+
+	if c.header != "" {
+		c.extractors = append([]KeyExtractor{HeaderExtractor(c.header)}, c.extractors...)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c.fingerprint != nil {
+				r = r.WithContext(suk.FingerprintContext(r.Context(), c.fingerprint(r)))
+			}
+
+			if c.channel != nil {
+				r = r.WithContext(suk.ChannelContext(r.Context(), c.channel(r)))
+			}
+
+			if c.source != nil {
+				r = r.WithContext(suk.SourceContext(r.Context(), c.source(r)))
+			}
+
+			rw := &ResponseWriter{ResponseWriter: w, extractor: c.extractors[0]}
+			st := &state{err: ErrNoKey, w: rw}
+
+			if key, extractor := c.key(r); extractor != nil {
+				rw.extractor = extractor
+				st.session, st.err = ss.GetSessionCtx(r.Context(), key)
+				if st.err == nil {
+					rw.issue(st.session)
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), stateContextKey{}, st)
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			// Handlers writing nothing leave the header to be written afterwards.
+			rw.writeKey()
+
+			// The staged data is stored even if the client went away meanwhile.
+			if err := st.flush(context.WithoutCancel(r.Context()), ss); err != nil {
+				c.onFlushError(r, err)
+			}
+		})
+	}
+}
+
+// key returns the key carried by the request, along with the extractor that
+// found it, if any did.
+func (c *config) key(r *http.Request) (string, KeyExtractor) {
+	for _, e := range c.extractors {
+		if key, ok := e.Extract(r); ok {
+			return key, e
+		}
+	}
+
+	return "", nil
+}
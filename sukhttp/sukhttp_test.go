@@ -0,0 +1,165 @@
+package sukhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+// serve sends the request to a handler writing the session of the request,
+// behind the middleware.
+func serve(ss *suk.SessionStorage, r *http.Request, opts ...Option) *http.Response {
+	handler := Middleware(ss, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := Session(r)
+		if !ok {
+			http.Error(w, "no session", http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, session)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Keys carried by cookies are rotated", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("alice")
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		resp := serve(ss, r)
+
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "alice" {
+			t.Errorf("got %q expected %q", body, "alice")
+		}
+
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Name != DefaultCookieName || cookies[0].Value == key {
+			t.Fatalf("got %v expected a cookie with a new key", cookies)
+		}
+
+		if cookies[0].Expires.IsZero() || !cookies[0].HttpOnly {
+			t.Errorf("got %v expected an HTTP-only cookie expiring with its key", cookies[0])
+		}
+
+		if _, _, err := ss.Get(cookies[0].Value); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Keys carried by headers are rotated in the header", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("alice")
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Session-Key", key)
+		resp := serve(ss, r, WithHeader("x-session-key"))
+
+		newKey := resp.Header.Get("X-Session-Key")
+		if newKey == "" || newKey == key {
+			t.Fatalf("got %q expected a new key", newKey)
+		}
+
+		if len(resp.Cookies()) != 0 {
+			t.Errorf("got %v expected no cookie", resp.Cookies())
+		}
+
+		if _, _, err := ss.Get(newKey); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Requests without a valid key have no session", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: "session", Value: "unknown"})
+		resp := serve(ss, r, WithCookieName("session"))
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+
+		if len(resp.Cookies()) != 0 {
+			t.Errorf("got %v expected no cookie", resp.Cookies())
+		}
+	})
+
+	t.Run("Requests without a key have no session", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		resp := serve(ss, httptest.NewRequest(http.MethodGet, "/", nil))
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Keys set for another fingerprint have no session", func(t *testing.T) {
+		ss, _ := suk.New()
+		userAgent := WithFingerprinting(func(r *http.Request) string { return r.UserAgent() })
+
+		ctx := suk.FingerprintContext(context.Background(), "Firefox")
+		key, _ := ss.SetCtx(ctx, "alice")
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("User-Agent", "curl")
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		if resp := serve(ss, r, userAgent); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+
+		r.Header.Del("User-Agent")
+		if resp := serve(ss, r, userAgent); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+
+		r.Header.Set("User-Agent", "Firefox")
+		resp := serve(ss, r, userAgent)
+		if body, _ := io.ReadAll(resp.Body); string(body) != "alice" {
+			t.Errorf("got %q expected %q", body, "alice")
+		}
+	})
+
+	t.Run("Sources guessing keys have no session", func(t *testing.T) {
+		ss, _ := suk.New(suk.WithLookupRateLimit(1, time.Minute, nil))
+		key, _ := ss.Set("alice")
+
+		guess := httptest.NewRequest(http.MethodGet, "/", nil)
+		guess.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "guess"})
+		serve(ss, guess, WithSource(ClientIP))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		if resp := serve(ss, r, WithSource(ClientIP)); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+
+		r.RemoteAddr = "192.0.2.2:1234"
+		resp := serve(ss, r, WithSource(ClientIP))
+		if body, _ := io.ReadAll(resp.Body); string(body) != "alice" {
+			t.Errorf("got %q expected %q", body, "alice")
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("got no panic expected %v", ErrEmptyCookieName)
+			}
+		}()
+
+		ss, _ := suk.New()
+		Middleware(ss, WithCookieName(""))
+	})
+}
@@ -0,0 +1,164 @@
+package sukhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+var (
+	ErrSessionEnded                  = errors.New("The session pinned to the connection ended.")
+	ErrNonPositiveRevalidateInterval = errors.New("The given revalidation interval must be positive.")
+)
+
+// PinOption customizes PinSession.
+type PinOption interface {
+	applyPin(*pinConfig) error
+}
+
+type pinOption func(*pinConfig) error
+
+func (o pinOption) applyPin(c *pinConfig) error {
+	return o(c)
+}
+
+type pinConfig struct {
+	interval time.Duration
+}
+
+// WithRevalidateInterval makes the pinned session be checked every d, ending
+// the connection's context as soon as the session is found to be gone, such
+// as after logging out or being revoked.
+func WithRevalidateInterval(d time.Duration) PinOption {
+	return pinOption(func(c *pinConfig) error {
+		if d <= 0 {
+			return ErrNonPositiveRevalidateInterval
+		}
+
+		c.interval = d
+		return nil
+	})
+}
+
+// PinnedSession is a session pinned to a long-lived connection, such as a
+// WebSocket, whose messages can't rotate the key each.
+type PinnedSession struct {
+	ss      *suk.SessionStorage
+	session suk.Session
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// PinSession pins the session of the request, as retrieved by the middleware,
+// to the connection the request is upgraded to, so it must be called before
+// upgrading, for the requests without a session to be rejected, and for the
+// rotated key to be handed back along with the upgrade. It fails with
+// ErrNoSession when the request has no session, or ErrNoMiddleware when the
+// middleware is not in front of the handler.
+//
+// The connection's context, returned by Context, ends along with the request's
+// or when Close is called, and when the session is found to be gone by
+// Revalidate or the revalidations set with WithRevalidateInterval, with
+// ErrSessionEnded as its cause. The session is found by its ID, so keys
+// rotated by the other requests of the client don't end it. It returns an
+// error if an option is invalid.
+func PinSession(ss *suk.SessionStorage, r *http.Request, opts ...PinOption) (*PinnedSession, error) {
+	var c pinConfig
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.applyPin(&c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if _, ok := stateFrom(r.Context()); !ok {
+		return nil, ErrNoMiddleware
+	}
+
+	s, ok := FromContext(r.Context())
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	p := &PinnedSession{ss: ss, session: s, ctx: ctx, cancel: cancel}
+
+	if c.interval > 0 {
+		go p.watch(c.interval)
+	}
+
+	return p, nil
+}
+
+// Session returns the pinned session, as it was when pinned.
+func (p *PinnedSession) Session() suk.Session {
+	return p.session
+}
+
+// Context returns the context of the connection, which ends when the session
+// is found to be gone, with ErrSessionEnded as its cause, so the connection
+// can be closed.
+func (p *PinnedSession) Context() context.Context {
+	return p.ctx
+}
+
+// Revalidate checks whether the pinned session is still live, ending the
+// connection's context with ErrSessionEnded when it's not. Failures to check
+// it are returned as they are, leaving the connection alive.
+func (p *PinnedSession) Revalidate(ctx context.Context) error {
+	var (
+		ok  bool
+		err error
+	)
+
+	// Third-party storages not keeping the ID of their entries give an empty
+	// one, so the key is checked instead.
+	if id := p.session.Meta.ID; id != "" {
+		ok, err = p.ss.SessionExistsCtx(ctx, id)
+	} else {
+		ok, err = p.ss.ExistsCtx(ctx, p.session.Key)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		p.cancel(ErrSessionEnded)
+		return ErrSessionEnded
+	}
+
+	return nil
+}
+
+// Close stops the revalidations and ends the connection's context, such as
+// once the connection is closed.
+func (p *PinnedSession) Close() {
+	p.cancel(context.Canceled)
+}
+
+// watch revalidates the session every interval, until the connection's
+// context ends. Failures to check the session are tried again on the next
+// tick.
+func (p *PinnedSession) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.Revalidate(p.ctx)
+		}
+	}
+}
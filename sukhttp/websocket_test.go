@@ -0,0 +1,134 @@
+package sukhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+// pin serves a request carrying the key, if any, through the middleware,
+// pinning its session.
+func pin(ss *suk.SessionStorage, key string, opts ...PinOption) (*PinnedSession, error) {
+	var (
+		p   *PinnedSession
+		err error
+	)
+
+	handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err = PinSession(ss, r, opts...)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	if key != "" {
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	return p, err
+}
+
+func TestPinSession(t *testing.T) {
+	t.Run("Sessions are pinned at upgrade time", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		p, err := pin(ss, key)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		defer p.Close()
+
+		if got := p.Session(); got.Data != "a" || got.Key == key {
+			t.Errorf("got %v expected %q under a rotated key", got, "a")
+		}
+	})
+
+	t.Run("Requests without a session are rejected", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		if _, err := pin(ss, "unknown"); err != ErrNoSession {
+			t.Errorf("got %v expected %v", err, ErrNoSession)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if _, err := PinSession(ss, r); err != ErrNoMiddleware {
+			t.Errorf("got %v expected %v", err, ErrNoMiddleware)
+		}
+	})
+
+	t.Run("Sessions survive rotations by other requests", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		p, _ := pin(ss, key)
+		defer p.Close()
+		ss.Get(p.Session().Key)
+
+		if err := p.Revalidate(context.Background()); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if err := p.Context().Err(); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Gone sessions end the connection", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		p, _ := pin(ss, key)
+		ss.Remove(p.Session().Key)
+
+		if err := p.Revalidate(context.Background()); err != ErrSessionEnded {
+			t.Errorf("got %v expected %v", err, ErrSessionEnded)
+		}
+
+		if got := context.Cause(p.Context()); got != ErrSessionEnded {
+			t.Errorf("got %v expected %v", got, ErrSessionEnded)
+		}
+	})
+
+	t.Run("Sessions are revalidated periodically", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		p, _ := pin(ss, key, WithRevalidateInterval(5*time.Millisecond))
+		ss.RevokeSession(p.Session().Meta.ID)
+
+		select {
+		case <-p.Context().Done():
+		case <-time.After(time.Second):
+			t.Fatal("got the connection alive expected it to end")
+		}
+
+		if got := context.Cause(p.Context()); got != ErrSessionEnded {
+			t.Errorf("got %v expected %v", got, ErrSessionEnded)
+		}
+	})
+
+	t.Run("Closing ends the connection", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		p, _ := pin(ss, key, WithRevalidateInterval(time.Hour))
+		p.Close()
+
+		if got := context.Cause(p.Context()); got != context.Canceled {
+			t.Errorf("got %v expected %v", got, context.Canceled)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("a")
+
+		if _, err := pin(ss, key, WithRevalidateInterval(0)); err == nil {
+			t.Errorf("got no error expected %v", ErrNonPositiveRevalidateInterval)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package sukhttp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ed-henrique/suk"
+)
+
+var (
+	ErrNoMiddleware  = errors.New("The request was not served through the sukhttp middleware.")
+	ErrHeaderWritten = errors.New("The response header was already written.")
+)
+
+// ResponseWriter wraps the response writers given to the handlers behind the
+// middleware, holding the key to hand back to the client until the header is
+// written, so keys rotated by the handlers themselves are never lost.
+type ResponseWriter struct {
+	http.ResponseWriter
+
+	extractor KeyExtractor
+	pending   *suk.Session
+	written   bool
+}
+
+// WriteHeader writes the pending key, if any, along with the header.
+func (w *ResponseWriter) WriteHeader(code int) {
+	w.writeKey()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// writeKey sets the pending key, if any, on the header, unless it was already
+// written.
+func (w *ResponseWriter) writeKey() {
+	if w.written {
+		return
+	}
+
+	w.written = true
+	if w.pending != nil {
+		w.extractor.Write(w.ResponseWriter, *w.pending)
+		w.pending = nil
+	}
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush writes the header, if it wasn't already, and flushes the response, if
+// the wrapped writer supports it.
+func (w *ResponseWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// issue makes the key of the session be written along with the header.
+func (w *ResponseWriter) issue(s suk.Session) error {
+	if w.written {
+		return ErrHeaderWritten
+	}
+
+	w.pending = &s
+	return nil
+}
+
+// revoke drops the pending key, so no key is written along with the header.
+func (w *ResponseWriter) revoke() error {
+	if w.written {
+		return ErrHeaderWritten
+	}
+
+	w.pending = nil
+	return nil
+}
+
+// Revoke stops the key of the session of the request from being handed back
+// to the client, such as when logging out, and the request is then served
+// without a session. The session itself must be removed from the session
+// storage, with the key given by FromContext, as the middleware already
+// rotated the one the request carried. It fails with ErrHeaderWritten once the
+// header was written, as the key was already handed back.
+func Revoke(r *http.Request) error {
+	st, ok := stateFrom(r.Context())
+	if !ok {
+		return ErrNoMiddleware
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if err := st.w.revoke(); err != nil {
+		return err
+	}
+
+	// Data staged for the session is dropped along with it.
+	st.session, st.err, st.staged = suk.Session{}, ErrNoSession, false
+	return nil
+}
+
+// Issue makes the key of the session be handed back to the client along with
+// the response, in place of the key the request carried, such as after logging
+// in or after the handler rotated the key itself. The session is also the one
+// of the request from then on, dropping the data staged with SetData. It fails
+// with ErrHeaderWritten once the header was written, as the key can no longer
+// be handed back.
+func Issue(r *http.Request, s suk.Session) error {
+	st, ok := stateFrom(r.Context())
+	if !ok {
+		return ErrNoMiddleware
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if err := st.w.issue(s); err != nil {
+		return err
+	}
+
+	// Data staged for the previous session is dropped along with it.
+	st.session, st.err, st.staged = s, nil, false
+	return nil
+}
@@ -0,0 +1,147 @@
+package sukhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+func TestResponseWriter(t *testing.T) {
+	t.Run("Keys issued by handlers replace the rotated ones", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("alice")
+
+		var issued suk.Session
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			issued, _ = ss.SetSession("bob")
+			if err := Issue(r, issued); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if session, _ := Session(r); session != "bob" {
+				t.Errorf("got %v expected %v", session, "bob")
+			}
+
+			w.Write([]byte("ok"))
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Value != issued.Key {
+			t.Errorf("got %v expected a single cookie with %q", cookies, issued.Key)
+		}
+	})
+
+	t.Run("Keys are handed back when handlers write nothing", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, _ := ss.SetSession("alice")
+			Issue(r, s)
+		}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if cookies := w.Result().Cookies(); len(cookies) != 1 {
+			t.Errorf("got %v expected a single cookie", cookies)
+		}
+	})
+
+	t.Run("Keys can't be issued once the header is written", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+
+			s, _ := ss.SetSession("alice")
+			if err := Issue(r, s); err != ErrHeaderWritten {
+				t.Errorf("got %v expected %v", err, ErrHeaderWritten)
+			}
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("Revoked keys are not handed back", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("alice")
+		cookies := NewCookieManager(ss)
+
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, _ := FromContext(r.Context())
+			ss.Remove(s.Key)
+
+			if err := Revoke(r); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if _, ok := Session(r); ok {
+				t.Errorf("got %v expected %v", ok, false)
+			}
+
+			cookies.Clear(w)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		got := w.Result().Cookies()
+		if len(got) != 1 || got[0].Value != "" {
+			t.Errorf("got %v expected a single cleared cookie", got)
+		}
+
+		if n, _ := ss.Count(); n != 0 {
+			t.Errorf("got %d expected %d", n, 0)
+		}
+	})
+
+	t.Run("Keys can't be revoked once the header is written", func(t *testing.T) {
+		ss, _ := suk.New()
+		key, _ := ss.Set("alice")
+
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+
+			if err := Revoke(r); err != ErrHeaderWritten {
+				t.Errorf("got %v expected %v", err, ErrHeaderWritten)
+			}
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: key})
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	})
+
+	t.Run("Keys can't be issued without the middleware", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		if err := Issue(r, suk.Session{}); err != ErrNoMiddleware {
+			t.Errorf("got %v expected %v", err, ErrNoMiddleware)
+		}
+
+		if err := Revoke(r); err != ErrNoMiddleware {
+			t.Errorf("got %v expected %v", err, ErrNoMiddleware)
+		}
+	})
+
+	t.Run("Wrapped writers are reachable", func(t *testing.T) {
+		ss, _ := suk.New()
+
+		handler := Middleware(ss)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -0,0 +1,163 @@
+package suktest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ed-henrique/suk"
+)
+
+// Conformance runs the behavior every suk backend must share against the
+// session storages created by newSessions, configured with the given options,
+// so third-party backends can be checked along with the built-in ones:
+//
+//	func TestConformance(t *testing.T) {
+//		suktest.Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+//			ss, err := suk.New(append(opts, suk.WithStorage(mystore.New()))...)
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			t.Cleanup(func() { ss.Close(context.Background()) })
+//			return ss
+//		})
+//	}
+//
+// Every session storage created must start out empty. Sessions are strings, so
+// backends encoding them with encoding/gob need no registration.
+func Conformance(t *testing.T, newSessions func(t *testing.T, opts ...suk.Option) *suk.SessionStorage) {
+	t.Run("Sessions survive rotations", func(t *testing.T) {
+		ss := newSessions(t)
+
+		key, err := ss.Set("session")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		for range 3 {
+			var session any
+			session, key, err = ss.Get(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if session != "session" {
+				t.Errorf("got %v expected %v", session, "session")
+			}
+		}
+	})
+
+	t.Run("Keys are single-use", func(t *testing.T) {
+		ss := newSessions(t)
+		key, _ := ss.Set("session")
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Only one of concurrent rotations wins", func(t *testing.T) {
+		ss := newSessions(t)
+		key, _ := ss.Set("session")
+
+		var won atomic.Int64
+		wg := new(sync.WaitGroup)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := ss.Get(key); err == nil {
+					won.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if won.Load() != 1 {
+			t.Errorf("got %d expected %d", won.Load(), 1)
+		}
+	})
+
+	t.Run("Removed sessions are gone", func(t *testing.T) {
+		ss := newSessions(t)
+		key, _ := ss.Set("session")
+
+		if err := ss.Remove(key); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(key); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+
+		if err := ss.Remove(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Unknown keys are not found", func(t *testing.T) {
+		ss := newSessions(t)
+
+		if _, _, err := ss.Get("unknown"); !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v", err, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Peeking leaves the key usable", func(t *testing.T) {
+		ss := newSessions(t)
+		key, _ := ss.Set("session")
+
+		if session, err := ss.Peek(key); err != nil || session != "session" {
+			t.Fatalf("got %v and error %v expected %v", session, err, "session")
+		}
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Expired sessions are refused", func(t *testing.T) {
+		ss := newSessions(t, suk.WithKeyDuration(50*time.Millisecond))
+		key, _ := ss.Set("session")
+		time.Sleep(100 * time.Millisecond)
+
+		// Backends expiring keys by themselves may have dropped the key already.
+		_, _, err := ss.Get(key)
+		if !errors.Is(err, suk.ErrKeyWasExpired) && !errors.Is(err, suk.ErrNoKeyFound) {
+			t.Errorf("got %v expected %v or %v", err, suk.ErrKeyWasExpired, suk.ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Clearing expired sessions keeps live ones", func(t *testing.T) {
+		ss := newSessions(t)
+		key, _ := ss.Set("session")
+
+		if err := ss.ClearExpired(); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if _, _, err := ss.Get(key); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("Operations honor their context", func(t *testing.T) {
+		ss := newSessions(t)
+		key, _ := ss.Set("session")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// In-memory backends never block, so they may succeed anyway.
+		if _, _, err := ss.GetCtx(ctx, key); err != nil && !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v expected %v", err, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+// Package suktest provides a test server fixture for applications using suk,
+// so integration tests of authentication flows take a few lines:
+//
+//	srv := suktest.NewServer(t)
+//	srv.Mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+//		session, _ := suktest.Session(r)
+//		fmt.Fprint(w, session)
+//	})
+//
+//	client := srv.Login("alice")
+//	resp, _ := client.Get("/me")
+//
+// The server keeps the sessions in memory, and its clients follow the key
+// rotations by themselves, as a browser would.
+package suktest
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+// CookieName is the cookie carrying the key between the server and its
+// clients.
+const CookieName = "suk"
+
+type sessionContextKey struct{}
+
+// Session returns the session of the request, if the request carried a valid
+// key.
+func Session(r *http.Request) (any, bool) {
+	session := r.Context().Value(sessionContextKey{})
+	return session, session != nil
+}
+
+// Server is an httptest.Server serving Mux behind a middleware retrieving the
+// session of each request and rotating its key. Requests carrying an invalid
+// key are answered with 401 Unauthorized.
+type Server struct {
+	*httptest.Server
+
+	Mux      *http.ServeMux
+	Sessions *suk.SessionStorage
+
+	t testing.TB
+}
+
+// NewServer starts a server storing the sessions in memory, configured with the
+// given options. It is closed once the test finishes.
+func NewServer(t testing.TB, opts ...suk.Option) *Server {
+	t.Helper()
+
+	ss, err := suk.New(opts...)
+	if err != nil {
+		t.Fatalf("suktest: %s", err.Error())
+	}
+
+	s := &Server{Mux: http.NewServeMux(), Sessions: ss, t: t}
+	s.Server = httptest.NewServer(s.middleware(s.Mux))
+
+	t.Cleanup(func() {
+		s.Server.Close()
+		ss.Close(context.Background())
+	})
+
+	return s
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(CookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, newKey, err := s.Sessions.GetCtx(r.Context(), cookie.Value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{Name: CookieName, Value: newKey, Path: "/", HttpOnly: true})
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session)))
+	})
+}
+
+// Client returns a client without a session.
+func (s *Server) Client() *Client {
+	jar, _ := cookiejar.New(nil)
+	client := s.Server.Client()
+	client.Jar = jar
+
+	return &Client{HTTP: client, server: s}
+}
+
+// Login sets the session and returns a client carrying its key, as if it had
+// just logged in. The test fails if the session can't be set.
+func (s *Server) Login(session any) *Client {
+	s.t.Helper()
+
+	key, err := s.Sessions.Set(session)
+	if err != nil {
+		s.t.Fatalf("suktest: %s", err.Error())
+	}
+
+	c := s.Client()
+	c.SetKey(key)
+	return c
+}
+
+// Client is a client of the test server following the key rotations, so each
+// request carries the key issued by the previous one.
+type Client struct {
+	HTTP *http.Client
+
+	server *Server
+}
+
+func (c *Client) url() *url.URL {
+	u, _ := url.Parse(c.server.URL)
+	return u
+}
+
+// Key returns the key the next request will carry, if there's any.
+func (c *Client) Key() string {
+	for _, cookie := range c.HTTP.Jar.Cookies(c.url()) {
+		if cookie.Name == CookieName {
+			return cookie.Value
+		}
+	}
+
+	return ""
+}
+
+// SetKey makes the next requests carry the given key.
+func (c *Client) SetKey(key string) {
+	c.HTTP.Jar.SetCookies(c.url(), []*http.Cookie{{Name: CookieName, Value: key, Path: "/"}})
+}
+
+// Get issues a GET request to the given path of the test server.
+func (c *Client) Get(path string) (*http.Response, error) {
+	return c.HTTP.Get(c.server.URL + path)
+}
+
+// Do sends the request, which may target the test server by path only.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "" {
+		u := c.url()
+		req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+	}
+
+	return c.HTTP.Do(req)
+}
@@ -0,0 +1,93 @@
+package suktest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ed-henrique/suk"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("Clients follow the key rotations", func(t *testing.T) {
+		srv := NewServer(t)
+		srv.Mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+			session, _ := Session(r)
+			fmt.Fprint(w, session)
+		})
+
+		client := srv.Login("alice")
+
+		for range 3 {
+			key := client.Key()
+
+			resp, err := client.Get("/me")
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if string(body) != "alice" {
+				t.Errorf("got %q expected %q", body, "alice")
+			}
+
+			if client.Key() == key {
+				t.Errorf("got %q expected a new key", client.Key())
+			}
+		}
+	})
+
+	t.Run("Consumed keys are unauthorized", func(t *testing.T) {
+		srv := NewServer(t)
+		client := srv.Login("alice")
+		key := client.Key()
+
+		client.Get("/")
+		client.SetKey(key)
+
+		resp, err := client.Get("/")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Requests without a key have no session", func(t *testing.T) {
+		srv := NewServer(t)
+		srv.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := Session(r); ok {
+				w.WriteHeader(http.StatusTeapot)
+			}
+		})
+
+		resp, err := srv.Client().Get("/")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("got %d expected %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+}
+
+func TestConformance(t *testing.T) {
+	Conformance(t, func(t *testing.T, opts ...suk.Option) *suk.SessionStorage {
+		ss, err := suk.New(opts...)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		t.Cleanup(func() { ss.Close(context.Background()) })
+
+		return ss
+	})
+}
@@ -0,0 +1,118 @@
+package suk
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// SweepBudget bounds the work done by a single call to ClearExpiredCtx. Zero
+// fields are not bounded.
+type SweepBudget struct {
+	// MaxKeys is the most keys checked for expiration.
+	MaxKeys int
+
+	// MaxDuration is the longest the sweep may take.
+	MaxDuration time.Duration
+}
+
+// Sweeper may be implemented by storages able to clear expired entries
+// incrementally, so ClearExpiredCtx respects its budget. Sweep checks the keys
+// in order, after the cursor, until either maxKeys keys were checked or the
+// deadline passes, where zero values are not bounded. It returns the last key
+// checked, to resume from, or an empty cursor once every key was checked.
+type Sweeper interface {
+	Sweep(ctx context.Context, cursor string, maxKeys int, deadline time.Time) (next string, err error)
+}
+
+// ClearExpiredCtx is like ClearExpired, but the sweep stops once the budget
+// is spent, returning a cursor to continue from on the next call, so operators
+// can run incremental sweeps, such as from cron jobs, without ever blocking
+// the storage for a long stretch:
+//
+//	cursor := ""
+//	for {
+//		cursor, err = ss.ClearExpiredCtx(ctx, suk.SweepBudget{MaxKeys: 1000}, cursor)
+//		if err != nil || cursor == "" {
+//			break
+//		}
+//	}
+//
+// An empty cursor starts a new sweep, and is returned once the sweep is over.
+// Storages expiring keys by themselves, such as Redis, and the ones not
+// implementing Sweeper are swept at once, returning an empty cursor.
+func (ss *SessionStorage) ClearExpiredCtx(ctx context.Context, budget SweepBudget, cursor string) (string, error) {
+	if ss.config.readOnly {
+		return "", ErrReadOnly
+	}
+
+	if err := ss.begin(false); err != nil {
+		return "", err
+	}
+	defer ss.end()
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	sw, ok := ss.storage.(Sweeper)
+	if !ok {
+		return "", ss.storage.ClearExpired(ctx)
+	}
+
+	var deadline time.Time
+	if budget.MaxDuration > 0 {
+		deadline = time.Now().Add(budget.MaxDuration)
+	}
+
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	return sw.Sweep(ctx, cursor, budget.MaxKeys, deadline)
+}
+
+// spent reports whether a sweep that checked n keys spent its budget.
+func spent(n, maxKeys int, deadline time.Time) bool {
+	return (maxKeys > 0 && n >= maxKeys) || (!deadline.IsZero() && !time.Now().Before(deadline))
+}
+
+func (m *shardedMap) Sweep(ctx context.Context, cursor string, maxKeys int, deadline time.Time) (string, error) {
+	var keys []string
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		for k := range s.entries {
+			if k > cursor {
+				keys = append(keys, k)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	slices.Sort(keys)
+
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return cursor, err
+		}
+
+		s := m.shardOf(key)
+		s.mu.Lock()
+		e, ok := s.entries[key]
+		expired := ok && time.Until(e.ExpiresAt) <= 0
+		if expired {
+			delete(s.entries, key)
+		}
+		s.mu.Unlock()
+
+		if expired && m.onExpire != nil {
+			m.onExpire(key, e)
+		}
+		cursor = key
+
+		if i+1 < len(keys) && spent(i+1, maxKeys, deadline) {
+			return cursor, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,104 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClearExpiredCtx(t *testing.T) {
+	backends := map[string]func(t *testing.T) Option{
+		"memory": func(t *testing.T) Option {
+			return WithKeyLength(32)
+		},
+		"SQLite": func(t *testing.T) Option {
+			return WithSQLite(filepath.Join(t.TempDir(), "sessions.db"))
+		},
+	}
+
+	for name, backend := range backends {
+		t.Run(fmt.Sprintf("Incremental sweeps clear every expired key on %s", name), func(t *testing.T) {
+			ss, err := New(backend(t))
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+			defer Destroy(ss)
+
+			ctx := context.Background()
+			for i := range 10 {
+				expiresAt := time.Now().Add(time.Hour)
+				if i%2 == 0 {
+					expiresAt = time.Now().Add(-time.Hour)
+				}
+
+				ss.storage.Set(ctx, fmt.Sprintf("k%d", i), Entry{Data: "session", ExpiresAt: expiresAt})
+			}
+
+			calls, cursor := 0, ""
+			for {
+				calls++
+				cursor, err = ss.ClearExpiredCtx(ctx, SweepBudget{MaxKeys: 3}, cursor)
+				if err != nil {
+					t.Fatalf("got error %s", err.Error())
+				}
+
+				if cursor == "" || calls > 10 {
+					break
+				}
+			}
+
+			if calls != 4 {
+				t.Errorf("got %d expected %d", calls, 4)
+			}
+
+			for i := range 10 {
+				_, err := ss.storage.Get(ctx, fmt.Sprintf("k%d", i))
+				if i%2 == 0 && !errors.Is(err, ErrNoKeyFound) {
+					t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+				} else if i%2 == 1 && err != nil {
+					t.Errorf("got error %s", err.Error())
+				}
+			}
+		})
+	}
+
+	t.Run("Spent durations still make progress", func(t *testing.T) {
+		ss, _ := New()
+		ctx := context.Background()
+
+		var first string
+		for i := range 3 {
+			key := fmt.Sprintf("k%d", i)
+			ss.storage.Set(ctx, key, Entry{Data: "session", ExpiresAt: time.Now().Add(-time.Hour)})
+
+			if first == "" || key < first {
+				first = key
+			}
+		}
+
+		cursor, _ := ss.ClearExpiredCtx(ctx, SweepBudget{MaxDuration: time.Nanosecond}, "")
+		if cursor != first {
+			t.Errorf("got %q expected %q", cursor, first)
+		}
+	})
+
+	t.Run("Storages expiring keys by themselves are swept at once", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		ss, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+
+		cursor, err := ss.ClearExpiredCtx(context.Background(), SweepBudget{MaxKeys: 1}, "")
+		if err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+
+		if cursor != "" {
+			t.Errorf("got %q expected %q", cursor, "")
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package suk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+var (
+	ErrInvalidToken   = errors.New("The given key is not a valid stateless token.")
+	ErrStatelessToken = errors.New("Stateless tokens can't be changed nor removed before they expire.")
+)
+
+// tokenHeader starts every PASETO v4.local token.
+const tokenHeader = "v4.local."
+
+const (
+	tokenKeySize   = 32
+	tokenNonceSize = 32
+	tokenTagSize   = 32
+)
+
+// tokenClaims is the payload of a stateless token, holding the session along
+// with what the session storage checks when it comes back.
+type tokenClaims struct {
+	Data        any       `json:"data"`
+	ExpiresAt   time.Time `json:"exp"`
+	IssuedAt    time.Time `json:"iat"`
+	ID          string    `json:"jti"`
+	Owner       string    `json:"sub,omitempty"`
+	Epoch       uint64    `json:"epoch,omitempty"`
+	ClientIP    string    `json:"cip,omitempty"`
+	UserAgent   string    `json:"ua,omitempty"`
+	Fingerprint string    `json:"fp,omitempty"`
+	Channel     string    `json:"ch,omitempty"`
+}
+
+// tokenSealer issues and opens the PASETO v4.local tokens of the session
+// storages set with WithStatelessTokens. Tokens are encrypted with
+// XChaCha20 and authenticated with a keyed BLAKE2b, both keyed from the
+// symmetric key and a random nonce.
+type tokenSealer struct {
+	key [tokenKeySize]byte
+}
+
+// issue returns a token embedding the entry.
+func (t *tokenSealer) issue(e Entry) (string, error) {
+	payload, err := json.Marshal(tokenClaims{
+		Data:        e.Data,
+		ExpiresAt:   e.ExpiresAt,
+		IssuedAt:    e.CreatedAt,
+		ID:          e.ID,
+		Owner:       e.Owner,
+		Epoch:       e.Epoch,
+		ClientIP:    e.ClientIP,
+		UserAgent:   e.UserAgent,
+		Fingerprint: e.Fingerprint,
+		Channel:     e.Channel,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var nonce [tokenNonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", err
+	}
+
+	return t.encrypt(payload, nonce)
+}
+
+// encrypt returns the token of the payload, encrypted with the given nonce.
+func (t *tokenSealer) encrypt(payload []byte, nonce [tokenNonceSize]byte) (string, error) {
+	encKey, streamNonce, authKey := t.subkeys(nonce[:])
+
+	stream, err := chacha20.NewUnauthenticatedCipher(encKey, streamNonce)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := make([]byte, len(payload))
+	stream.XORKeyStream(ciphertext, payload)
+
+	raw := make([]byte, 0, tokenNonceSize+len(ciphertext)+tokenTagSize)
+	raw = append(raw, nonce[:]...)
+	raw = append(raw, ciphertext...)
+	raw = append(raw, t.tag(authKey, nonce[:], ciphertext)...)
+	return tokenHeader + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// open returns the entry embedded in the token, failing with ErrInvalidToken
+// when it wasn't issued with the key or was tampered with.
+func (t *tokenSealer) open(token string) (Entry, error) {
+	payload, err := t.decrypt(token)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Entry{}, ErrInvalidToken
+	}
+
+	return Entry{
+		Data:           claims.Data,
+		ExpiresAt:      claims.ExpiresAt,
+		ID:             claims.ID,
+		CreatedAt:      claims.IssuedAt,
+		LastAccessedAt: claims.IssuedAt,
+		Owner:          claims.Owner,
+		Epoch:          claims.Epoch,
+		ClientIP:       claims.ClientIP,
+		UserAgent:      claims.UserAgent,
+		Fingerprint:    claims.Fingerprint,
+		Channel:        claims.Channel,
+	}, nil
+}
+
+// decrypt returns the payload of the token, checking its tag before
+// decrypting it. Tokens with a footer are never issued, so they are rejected.
+func (t *tokenSealer) decrypt(token string) ([]byte, error) {
+	body, ok := strings.CutPrefix(token, tokenHeader)
+	if !ok || strings.Contains(body, ".") {
+		return nil, ErrInvalidToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil || len(raw) < tokenNonceSize+tokenTagSize {
+		return nil, ErrInvalidToken
+	}
+
+	nonce := raw[:tokenNonceSize]
+	ciphertext := raw[tokenNonceSize : len(raw)-tokenTagSize]
+	encKey, streamNonce, authKey := t.subkeys(nonce)
+
+	if subtle.ConstantTimeCompare(t.tag(authKey, nonce, ciphertext), raw[len(raw)-tokenTagSize:]) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	stream, err := chacha20.NewUnauthenticatedCipher(encKey, streamNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, len(ciphertext))
+	stream.XORKeyStream(payload, ciphertext)
+	return payload, nil
+}
+
+// subkeys derives the encryption key, the XChaCha20 nonce and the
+// authentication key of a token from its nonce.
+func (t *tokenSealer) subkeys(nonce []byte) (encKey, streamNonce, authKey []byte) {
+	tmp := keyedHash(t.key[:], 56, []byte("paseto-encryption-key"), nonce)
+	return tmp[:32], tmp[32:], keyedHash(t.key[:], 32, []byte("paseto-auth-key-for-aead"), nonce)
+}
+
+// tag authenticates the header, the nonce and the ciphertext of a token,
+// which has neither footer nor implicit assertion.
+func (t *tokenSealer) tag(authKey, nonce, ciphertext []byte) []byte {
+	return keyedHash(authKey, tokenTagSize, preAuthEncode([]byte(tokenHeader), nonce, ciphertext, nil, nil))
+}
+
+// keyedHash returns the keyed BLAKE2b hash of the concatenated parts, of the
+// given size.
+func keyedHash(key []byte, size int, parts ...[]byte) []byte {
+	h, err := blake2b.New(size, key)
+	if err != nil {
+		// Sizes and keys are fixed, so this never happens.
+		panic(err)
+	}
+
+	for _, p := range parts {
+		h.Write(p)
+	}
+
+	return h.Sum(nil)
+}
+
+// preAuthEncode encodes the pieces unambiguously, prefixing each of them and
+// their count with their little-endian 64-bit length, as PASETO does.
+func preAuthEncode(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(pieces)))
+	for _, p := range pieces {
+		binary.Write(&buf, binary.LittleEndian, uint64(len(p)))
+		buf.Write(p)
+	}
+
+	return buf.Bytes()
+}
+
+// openToken returns the entry embedded in the token, checking whether it
+// expired or was revoked, as peek does for stored sessions.
+func (ss *SessionStorage) openToken(token string) (Entry, error) {
+	e, err := ss.config.tokens.open(token)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if time.Until(e.ExpiresAt) <= 0 {
+		return Entry{}, ErrKeyWasExpired
+	}
+
+	if ss.revoked(e) {
+		return Entry{}, ErrNoKeyFound
+	}
+
+	return e, nil
+}
@@ -0,0 +1,177 @@
+package suk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatelessTokens(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, tokenKeySize)
+
+	t.Run("Tokens follow the PASETO v4.local test vectors", func(t *testing.T) {
+		k, _ := hex.DecodeString("707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f")
+		ts := &tokenSealer{key: [tokenKeySize]byte(k)}
+		payload := `{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`
+		expected := "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg"
+
+		got, err := ts.encrypt([]byte(payload), [tokenNonceSize]byte{})
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got != expected {
+			t.Errorf("got %s expected %s", got, expected)
+		}
+
+		decrypted, err := ts.decrypt(expected)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if string(decrypted) != payload {
+			t.Errorf("got %s expected %s", decrypted, payload)
+		}
+	})
+
+	t.Run("Sessions skip the backend", func(t *testing.T) {
+		cs := &countingStorage{shardedMap: newShardedMap(1)}
+		ss, _ := New(WithStorage(cs), WithStatelessTokens(key))
+
+		token, err := ss.Set("session")
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		got, newToken, err := ss.Get(token)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got != "session" || newToken != token {
+			t.Errorf("got %v and %s expected %q and %s", got, newToken, "session", token)
+		}
+
+		if cs.sets != 0 {
+			t.Errorf("got %d expected %d", cs.sets, 0)
+		}
+	})
+
+	t.Run("Tokens exist until they expire", func(t *testing.T) {
+		ss, _ := New(WithStatelessTokens(key), WithKeyDuration(time.Hour))
+		token, _ := ss.Set("session")
+
+		if ok, err := ss.Exists(token); err != nil || !ok {
+			t.Errorf("got %v and error %v expected %v", ok, err, true)
+		}
+
+		expiresAt, err := ss.ExpiresAt(token)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if until := time.Until(expiresAt); until <= 59*time.Minute || until > time.Hour {
+			t.Errorf("got %s expected %s", until, time.Hour)
+		}
+
+		tampered := token[:len(token)-2] + string(token[len(token)-2]^1) + token[len(token)-1:]
+
+		if ok, err := ss.Exists(tampered); err != nil || ok {
+			t.Errorf("got %v and error %v expected %v", ok, err, false)
+		}
+
+		if _, err := ss.ExpiresAt(tampered); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("got %v expected %v", err, ErrInvalidToken)
+		}
+	})
+
+	t.Run("Expired tokens don't exist", func(t *testing.T) {
+		ss, _ := New(WithStatelessTokens(key), WithKeyDuration(time.Millisecond))
+		token, _ := ss.Set("session")
+		time.Sleep(5 * time.Millisecond)
+
+		if ok, err := ss.Exists(token); err != nil || ok {
+			t.Errorf("got %v and error %v expected %v", ok, err, false)
+		}
+
+		if _, err := ss.ExpiresAt(token); !errors.Is(err, ErrKeyWasExpired) {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Typed sessions keep their type", func(t *testing.T) {
+		ss, _ := New(WithStatelessTokens(key))
+		typed := NewTyped[user](ss)
+
+		token, _ := typed.Set(user{ID: 42, Name: "Ana"})
+		got, _, err := typed.Get(token)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got.ID != 42 || got.Name != "Ana" {
+			t.Errorf("got %v expected %v", got, user{ID: 42, Name: "Ana"})
+		}
+	})
+
+	t.Run("Tampered tokens", func(t *testing.T) {
+		ss, _ := New(WithStatelessTokens(key))
+		other, _ := New(WithStatelessTokens(bytes.Repeat([]byte{8}, tokenKeySize)))
+
+		token, _ := ss.Set("session")
+		tampered := token[:len(token)-2] + string(token[len(token)-2]^1) + token[len(token)-1:]
+
+		if _, _, err := ss.Get(tampered); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("got %v expected %v", err, ErrInvalidToken)
+		}
+
+		if _, err := other.Peek(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("got %v expected %v", err, ErrInvalidToken)
+		}
+
+		if _, _, err := ss.Get("not a token"); !errors.Is(err, ErrForeignKey) {
+			t.Errorf("got %v expected %v", err, ErrForeignKey)
+		}
+	})
+
+	t.Run("Expired tokens", func(t *testing.T) {
+		ss, _ := New(WithStatelessTokens(key))
+
+		token, _ := ss.Set("session", WithTTL(10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+
+		if _, _, err := ss.Get(token); err != ErrKeyWasExpired {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Tokens can only be invalidated all at once", func(t *testing.T) {
+		ss, _ := New(WithStatelessTokens(key))
+		token, _ := ss.Set("session")
+
+		if err := ss.Remove(token); !errors.Is(err, ErrStatelessToken) {
+			t.Errorf("got %v expected %v", err, ErrStatelessToken)
+		}
+
+		if err := ss.Update(token, func(old any) any { return old }); !errors.Is(err, ErrStatelessToken) {
+			t.Errorf("got %v expected %v", err, ErrStatelessToken)
+		}
+
+		ss.InvalidateAll()
+		if _, _, err := ss.Get(token); err != ErrNoKeyFound {
+			t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+		}
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		if _, err := New(WithStatelessTokens([]byte("short"))); !errors.Is(err, ErrInvalidStatelessTokenKey) {
+			t.Errorf("got %v expected %v", err, ErrInvalidStatelessTokenKey)
+		}
+
+		if _, err := New(WithStatelessTokens(key), WithStatelessTokens(key)); !errors.Is(err, ErrStatelessTokensAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrStatelessTokensAlreadySet)
+		}
+	})
+}
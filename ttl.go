@@ -0,0 +1,84 @@
+package suk
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNonPositiveTTL = errors.New("The given session TTL must be positive.")
+
+// SetOption customizes a single session given to Set.
+type SetOption interface {
+	applySet(*setConfig) error
+}
+
+// setConfig holds the settings of a single session given to Set.
+type setConfig struct {
+	ttl       time.Duration
+	owner     string
+	clientIP  string
+	userAgent string
+}
+
+type setOption func(*setConfig) error
+
+func (o setOption) applySet(c *setConfig) error {
+	return o(c)
+}
+
+func newSetConfig(opts []SetOption) (setConfig, error) {
+	var c setConfig
+
+	errs := make([]error, 0, len(opts))
+	for _, opt := range opts {
+		if err := opt.applySet(&c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return c, errors.Join(errs...)
+}
+
+// WithTTL gives the session its own key duration, replacing the one set with
+// WithKeyDuration or WithKeyDurationUntil, so "remember me" sessions and short
+// admin sessions can share a session storage:
+//
+//	key, _ := ss.Set(session, suk.WithTTL(30*24*time.Hour))
+//
+// The TTL is kept across rotations by every backend, Redis included.
+func WithTTL(d time.Duration) SetOption {
+	return setOption(func(c *setConfig) error {
+		if d <= 0 {
+			return ErrNonPositiveTTL
+		}
+
+		c.ttl = d
+		return nil
+	})
+}
+
+// expirationFor computes the expiration of a key issued right now for the
+// entry, honoring its own TTL and deadline, if any.
+func (ss *SessionStorage) expirationFor(e Entry) (time.Time, error) {
+	expiration := time.Now().Add(e.TTL)
+	if e.TTL == 0 {
+		var err error
+		if expiration, err = ss.expiration(); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if !e.Deadline.IsZero() && e.Deadline.Before(expiration) {
+		expiration = e.Deadline
+	}
+
+	return expiration, nil
+}
+
+// KeyDuration returns how long the keys issued right now live, as set with
+// WithKeyDuration or WithKeyDurationUntil, such as for the Max-Age of the
+// cookies holding them. Sessions given their own TTL with WithTTL, or capped by
+// WithAbsoluteExpiration, may live for less or longer.
+func (ss *SessionStorage) KeyDuration() time.Duration {
+	return max(time.Until(ss.currentPolicy().expiresAt(time.Now())), 0)
+}
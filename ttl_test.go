@@ -0,0 +1,221 @@
+package suk
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// persistentBackends returns the options of the backends storing the sessions
+// out of process, which must keep their metadata along with them. Session
+// storages created with the same option share the backend, as instances do.
+var persistentBackends = map[string]func(t *testing.T) Option{
+	"SQLite": func(t *testing.T) Option {
+		return WithSQLite(filepath.Join(t.TempDir(), "sessions.db"))
+	},
+	"Redis": func(t *testing.T) Option {
+		return WithRedis(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}), nil)
+	},
+	"multi-region Redis": func(t *testing.T) Option {
+		return WithMultiRegionRedis(
+			redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+			redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+			nil,
+		)
+	},
+}
+
+func TestTTL(t *testing.T) {
+	t.Run("Non-positive TTL", func(t *testing.T) {
+		ss, _ := New()
+
+		if _, err := ss.Set(10, WithTTL(0)); !errors.Is(err, ErrNonPositiveTTL) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveTTL)
+		}
+	})
+
+	t.Run("TTL overrides the key duration", func(t *testing.T) {
+		ss, _ := New(WithKeyDuration(time.Minute))
+
+		short, _ := ss.Set(10, WithTTL(10*time.Millisecond))
+		long, _ := ss.Set(20)
+		time.Sleep(20 * time.Millisecond)
+
+		if _, _, err := ss.Get(short); err != ErrKeyWasExpired {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+
+		if _, _, err := ss.Get(long); err != nil {
+			t.Errorf("got error %s", err.Error())
+		}
+	})
+
+	t.Run("TTL is kept across rotations", func(t *testing.T) {
+		ss, _ := New(WithKeyDuration(time.Minute))
+
+		key, _ := ss.Set(10, WithTTL(time.Hour))
+		_, key, _ = ss.Get(key)
+		_, key, _ = ss.Get(key)
+
+		e, _ := ss.storage.Get(ss.ctx, ss.stored(key))
+
+		if remaining := time.Until(e.ExpiresAt); remaining < 59*time.Minute {
+			t.Errorf("got %s expected about %s", remaining, time.Hour)
+		}
+
+		if e.TTL != time.Hour {
+			t.Errorf("got %s expected %s", e.TTL, time.Hour)
+		}
+	})
+
+	for name, backend := range persistentBackends {
+		t.Run("TTL is kept by "+name, func(t *testing.T) {
+			ss, _ := New(backend(t), WithKeyDuration(time.Minute))
+			defer Destroy(ss)
+
+			key, _ := ss.Set(10, WithTTL(time.Hour))
+			_, key, _ = ss.Get(key)
+
+			e, _ := ss.storage.Get(ss.ctx, ss.stored(key))
+
+			if e.TTL != time.Hour {
+				t.Errorf("got %s expected %s", e.TTL, time.Hour)
+			}
+
+			if remaining := time.Until(e.ExpiresAt); remaining < 59*time.Minute {
+				t.Errorf("got %s expected about %s", remaining, time.Hour)
+			}
+		})
+	}
+
+	t.Run("Entries without TTL decode as before", func(t *testing.T) {
+		raw, _ := encodeData(10)
+
+		e, err := decodeEntryData(raw, nil)
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if e.Data != 10 || e.TTL != 0 {
+			t.Errorf("got %v and %s expected %v and %s", e.Data, e.TTL, 10, time.Duration(0))
+		}
+	})
+}
+
+func TestExpirationModes(t *testing.T) {
+	t.Run("Both expiration modes", func(t *testing.T) {
+		_, err := New(WithSlidingExpiration(), WithAbsoluteExpiration(time.Hour))
+
+		if !errors.Is(err, ErrExpirationModeAlreadySet) {
+			t.Errorf("got %v expected %v", err, ErrExpirationModeAlreadySet)
+		}
+	})
+
+	t.Run("Non-positive absolute expiration", func(t *testing.T) {
+		_, err := New(WithAbsoluteExpiration(-time.Hour))
+
+		if !errors.Is(err, ErrNonPositiveAbsoluteExpiration) {
+			t.Errorf("got %v expected %v", err, ErrNonPositiveAbsoluteExpiration)
+		}
+	})
+
+	t.Run("Sliding expiration renews keys", func(t *testing.T) {
+		ss, _ := New(WithSlidingExpiration(), WithKeyDuration(40*time.Millisecond))
+
+		key, _ := ss.Set(10)
+		for range 4 {
+			time.Sleep(20 * time.Millisecond)
+
+			var err error
+			if _, key, err = ss.Get(key); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+		}
+	})
+
+	t.Run("Absolute expiration caps activity", func(t *testing.T) {
+		ss, _ := New(WithAbsoluteExpiration(50*time.Millisecond), WithKeyDuration(40*time.Millisecond))
+
+		key, _ := ss.Set(10)
+		time.Sleep(30 * time.Millisecond)
+		_, key, _ = ss.Get(key)
+
+		e, _ := ss.storage.Get(ss.ctx, ss.stored(key))
+		if !e.ExpiresAt.Equal(e.Deadline) {
+			t.Errorf("got %s expected %s", e.ExpiresAt, e.Deadline)
+		}
+
+		time.Sleep(30 * time.Millisecond)
+
+		if _, _, err := ss.Get(key); err != ErrKeyWasExpired {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Absolute expiration caps sessions with their own TTL", func(t *testing.T) {
+		ss, _ := New(WithAbsoluteExpiration(time.Hour))
+
+		key, _ := ss.Set(10, WithTTL(24*time.Hour))
+		e, _ := ss.storage.Get(ss.ctx, ss.stored(key))
+
+		if remaining := time.Until(e.ExpiresAt); remaining > time.Hour {
+			t.Errorf("got %s expected at most %s", remaining, time.Hour)
+		}
+	})
+
+	for name, backend := range persistentBackends {
+		t.Run("Deadline is kept by "+name, func(t *testing.T) {
+			ss, _ := New(backend(t), WithAbsoluteExpiration(time.Hour))
+			defer Destroy(ss)
+
+			key, _ := ss.Set(10)
+			before, _ := ss.storage.Get(ss.ctx, ss.stored(key))
+			_, key, _ = ss.Get(key)
+			after, _ := ss.storage.Get(ss.ctx, ss.stored(key))
+
+			if before.Deadline.IsZero() || !after.Deadline.Equal(before.Deadline) {
+				t.Errorf("got %s expected %s", after.Deadline, before.Deadline)
+			}
+		})
+	}
+
+	t.Run("Reconfigured absolute expiration applies to new sessions", func(t *testing.T) {
+		ss, _ := New()
+
+		old, _ := ss.Set(10)
+		if err := ss.Reconfigure(WithAbsoluteExpiration(time.Hour)); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+		capped, _ := ss.Set(20)
+
+		oe, _ := ss.storage.Get(ss.ctx, ss.stored(old))
+		ce, _ := ss.storage.Get(ss.ctx, ss.stored(capped))
+
+		if !oe.Deadline.IsZero() || ce.Deadline.IsZero() {
+			t.Errorf("got deadlines %s and %s expected only the second one", oe.Deadline, ce.Deadline)
+		}
+	})
+}
+
+func TestKeyDuration(t *testing.T) {
+	t.Run("Keys live for the key duration", func(t *testing.T) {
+		ss, _ := New(WithKeyDuration(time.Hour))
+
+		if d := ss.KeyDuration(); d <= 59*time.Minute || d > time.Hour {
+			t.Errorf("got %s expected about %s", d, time.Hour)
+		}
+	})
+
+	t.Run("Keys live until the given time", func(t *testing.T) {
+		until := time.Now().Add(time.Hour)
+		ss, _ := New(WithKeyDurationUntil(func(time.Time) time.Time { return until }))
+
+		if d := ss.KeyDuration(); d <= 59*time.Minute || d > time.Hour {
+			t.Errorf("got %s expected about %s", d, time.Hour)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package suk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrUnexpectedSessionType = errors.New("The stored session is not of the expected type.")
+
+// Typed wraps a session storage holding sessions of type T, so Get returns T
+// directly instead of a value that must be type asserted.
+//
+// Sessions are kept as they are in the in-memory storage, and serialized by
+// the codec given to WithCodec or in stateless tokens, if any. For every other
+// backend, such as Redis, they are serialized to JSON, so T must be supported
+// by encoding/json.
+type Typed[T any] struct {
+	ss *SessionStorage
+
+	// encode is set when the sessions must be serialized to survive the
+	// backend.
+	encode bool
+
+	// convert is set when sessions may come back as generic values, such as
+	// the map[string]any of JSONCodec, which are converted to T through JSON.
+	convert bool
+}
+
+// NewTyped wraps the session storage, typing its sessions as T.
+func NewTyped[T any](ss *SessionStorage) *Typed[T] {
+	return &Typed[T]{ss: ss, encode: !ss.keepsValues(), convert: ss.genericValues()}
+}
+
+// Storage returns the wrapped session storage.
+func (t *Typed[T]) Storage() *SessionStorage {
+	return t.ss
+}
+
+func (t *Typed[T]) marshal(session T) (any, error) {
+	if !t.encode {
+		return session, nil
+	}
+
+	return json.Marshal(session)
+}
+
+func (t *Typed[T]) unmarshal(data any) (T, error) {
+	var session T
+
+	// Encoded sessions are always decoded, as T may be the type of the encoded
+	// data itself, such as string.
+	if s, ok := data.(T); ok && !t.encode {
+		return s, nil
+	}
+
+	var raw []byte
+	switch d := data.(type) {
+	case string:
+		raw = []byte(d)
+	case []byte:
+		raw = d
+	default:
+		if !t.convert {
+			return session, fmt.Errorf("%w: got %T", ErrUnexpectedSessionType, data)
+		}
+
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return session, fmt.Errorf("%w: %w", ErrUnexpectedSessionType, err)
+		}
+	}
+
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return session, fmt.Errorf("%w: %w", ErrUnexpectedSessionType, err)
+	}
+
+	return session, nil
+}
+
+// Set assigns the session and returns a key for it. Options, such as WithTTL,
+// customize this session only.
+func (t *Typed[T]) Set(session T, opts ...SetOption) (string, error) {
+	return t.SetCtx(t.ss.ctx, session, opts...)
+}
+
+// SetCtx is like Set, but the storage operations run on the given context.
+func (t *Typed[T]) SetCtx(ctx context.Context, session T, opts ...SetOption) (string, error) {
+	data, err := t.marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	return t.ss.SetCtx(ctx, data, opts...)
+}
+
+// Get retrieves the session and generates a new key for it.
+func (t *Typed[T]) Get(key string) (T, string, error) {
+	return t.GetCtx(t.ss.ctx, key)
+}
+
+// GetCtx is like Get, but the storage operations run on the given context.
+// When the session is not of type T, the key is rotated all the same, so the
+// new one is returned along with ErrUnexpectedSessionType.
+func (t *Typed[T]) GetCtx(ctx context.Context, key string) (T, string, error) {
+	var session T
+
+	data, newKey, err := t.ss.GetCtx(ctx, key)
+	if err != nil {
+		return session, "", err
+	}
+
+	// The key was rotated anyway, so the new one is returned along with the
+	// error, keeping the session reachable.
+	session, err = t.unmarshal(data)
+	if err != nil {
+		return session, newKey, err
+	}
+
+	return session, newKey, nil
+}
+
+// GetWithInfo is like Get, but also describes the session.
+func (t *Typed[T]) GetWithInfo(key string) (T, SessionInfo, string, error) {
+	return t.GetWithInfoCtx(t.ss.ctx, key)
+}
+
+// GetWithInfoCtx is like GetWithInfo, but the storage operations run on the
+// given context.
+func (t *Typed[T]) GetWithInfoCtx(ctx context.Context, key string) (T, SessionInfo, string, error) {
+	var session T
+
+	data, info, newKey, err := t.ss.GetWithInfoCtx(ctx, key)
+	if err != nil {
+		return session, SessionInfo{}, "", err
+	}
+
+	session, err = t.unmarshal(data)
+	if err != nil {
+		return session, info, newKey, err
+	}
+
+	return session, info, newKey, nil
+}
+
+// Remove deletes the specified key and its associated value.
+func (t *Typed[T]) Remove(key string) error {
+	return t.ss.Remove(key)
+}
+
+// RemoveCtx is like Remove, but the storage operations run on the given
+// context.
+func (t *Typed[T]) RemoveCtx(ctx context.Context, key string) error {
+	return t.ss.RemoveCtx(ctx, key)
+}
+
+// Update replaces the session stored under key with the one fn returns given
+// the current one, without consuming nor rotating the key.
+func (t *Typed[T]) Update(key string, fn func(old T) T) error {
+	return t.UpdateCtx(t.ss.ctx, key, fn)
+}
+
+// UpdateCtx is like Update, but the storage operations run on the given
+// context.
+func (t *Typed[T]) UpdateCtx(ctx context.Context, key string, fn func(old T) T) error {
+	return t.ss.update(ctx, key, func(old any) (any, error) {
+		session, err := t.unmarshal(old)
+		if err != nil {
+			return nil, err
+		}
+
+		return t.marshal(fn(session))
+	})
+}
+
+// Pop removes the session stored under key and returns it.
+func (t *Typed[T]) Pop(key string) (T, error) {
+	return t.PopCtx(t.ss.ctx, key)
+}
+
+// PopCtx is like Pop, but the storage operations run on the given context.
+func (t *Typed[T]) PopCtx(ctx context.Context, key string) (T, error) {
+	var session T
+
+	data, err := t.ss.PopCtx(ctx, key)
+	if err != nil {
+		return session, err
+	}
+
+	return t.unmarshal(data)
+}
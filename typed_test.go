@@ -0,0 +1,73 @@
+package suk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type user struct {
+	ID    int
+	Name  string
+	Roles []string
+}
+
+func TestTyped(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" typed storage round-trips structs", func(t *testing.T) {
+			typed := NewTyped[user](ss)
+			expected := user{ID: 42, Name: "Ana", Roles: []string{"admin"}}
+
+			key, err := typed.Set(expected)
+			if err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			got, _, err := typed.Get(key)
+			if err != nil {
+				t.Errorf("got error %s", err.Error())
+			}
+
+			if got.ID != expected.ID || got.Name != expected.Name || len(got.Roles) != 1 {
+				t.Errorf("got %v expected %v", got, expected)
+			}
+		})
+	}
+
+	for name, ss := range storages {
+		t.Run(name+" typed storage round-trips strings", func(t *testing.T) {
+			typed := NewTyped[string](ss)
+
+			key, _ := typed.Set("hello")
+			got, _, err := typed.Get(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got != "hello" {
+				t.Errorf("got %q expected %q", got, "hello")
+			}
+		})
+	}
+
+	t.Run("Typed storage with a session of another type", func(t *testing.T) {
+		key, _ := syncMapStorage.Set(10)
+
+		_, newKey, err := NewTyped[user](syncMapStorage).Get(key)
+		if !errors.Is(err, ErrUnexpectedSessionType) {
+			t.Errorf("got %v expected %v", err, ErrUnexpectedSessionType)
+		}
+
+		if got, _, err := syncMapStorage.Get(newKey); err != nil || got != 10 {
+			t.Errorf("got %v and error %v expected %v", got, err, 10)
+		}
+	})
+}
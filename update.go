@@ -0,0 +1,260 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// updater is implemented by storages that can replace the entry stored under
+// key in place, atomically. Update calls fn with the stored entry and stores
+// the entry it returns instead, failing with ErrNoKeyFound when there's none.
+// Storages not implementing it have their entries removed and set again,
+// which is only atomic within this process.
+type updater interface {
+	update(ctx context.Context, key string, fn func(Entry) (Entry, error)) (Entry, error)
+}
+
+func (m *shardedMap) update(_ context.Context, key string, fn func(Entry) (Entry, error)) (Entry, error) {
+	s := m.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return Entry{}, ErrNoKeyFound
+	}
+
+	e, err := fn(e)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// The expiration is left as it is, so there's no need to track it again.
+	s.entries[key] = e
+	return e, nil
+}
+
+// update watches the key, so the session is only replaced when nobody else
+// changed it meanwhile, trying again otherwise. Its TTL is kept as it is.
+func (r *redisDB) update(ctx context.Context, key string, fn func(Entry) (Entry, error)) (Entry, error) {
+	return r.lookup(key, func(key string) (Entry, error) {
+		for {
+			var updated Entry
+			err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+				pttl := tx.PTTL(ctx, key)
+				if err := pttl.Err(); err != nil {
+					return err
+				}
+
+				get := tx.Get(ctx, key)
+				e, err := r.entry(get, pttl, get.Err())
+				if err != nil {
+					return err
+				}
+
+				if updated, err = fn(e); err != nil {
+					return err
+				}
+
+				data, err := r.payload.encodeEntry(updated)
+				if err != nil {
+					return err
+				}
+
+				_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					pipe.SetArgs(ctx, key, data, redis.SetArgs{Mode: "XX", KeepTTL: true})
+					return nil
+				})
+				return err
+			}, key)
+
+			if errors.Is(err, redis.TxFailedErr) {
+				continue
+			} else if err != nil {
+				return Entry{}, err
+			}
+
+			return updated, nil
+		}
+	})
+}
+
+// update replaces sessions buffered during an outage in the buffer, and every
+// other one in Redis.
+func (b *bufferedStorage) update(ctx context.Context, key string, fn func(Entry) (Entry, error)) (Entry, error) {
+	e, err := b.local.update(ctx, key, fn)
+	if err != ErrNoKeyFound {
+		return e, err
+	}
+
+	return b.redisDB.update(ctx, key, fn)
+}
+
+// update writes the session as a newer version of itself, so it wins over the
+// one it replaces in both regions.
+func (m *multiRegionRedis) update(ctx context.Context, key string, fn func(Entry) (Entry, error)) (Entry, error) {
+	e, rotated, err := m.load(ctx, key)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	ne, err := fn(e)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	payload, err := m.payload.encodeEntry(ne)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if now := time.Now().UnixNano(); rotated < now {
+		rotated = now
+	} else {
+		rotated++
+	}
+
+	le := regionEntry{key: key, rotated: rotated, payload: payload, expires: e.ExpiresAt}
+	if err := m.write(ctx, le); err != nil {
+		return Entry{}, err
+	}
+
+	return ne, nil
+}
+
+// Update replaces the session stored under key with the one fn returns given
+// the current one, without consuming nor rotating the key, so concurrent
+// updates of the same session are never lost. The session keeps its
+// expiration. As fn runs while the session is locked, it must not use the
+// session storage.
+//
+// In memory, Redis and the multi-region Redis, sessions are updated
+// atomically. Elsewhere, they are removed and set again, which is only atomic
+// for the operations of this process.
+func (ss *SessionStorage) Update(key string, fn func(old any) any) error {
+	return ss.UpdateCtx(ss.ctx, key, fn)
+}
+
+// UpdateCtx is like Update, but the storage operations run on the given
+// context, so per-request deadlines and cancellation propagate to the backend.
+func (ss *SessionStorage) UpdateCtx(ctx context.Context, key string, fn func(old any) any) error {
+	return ss.update(ctx, key, func(old any) (any, error) {
+		return fn(old), nil
+	})
+}
+
+// update replaces the session stored under key with the one fn returns. A key
+// consumed during its grace period updates the session it was rotated to.
+func (ss *SessionStorage) update(ctx context.Context, key string, fn func(old any) (any, error)) (err error) {
+	if ss.config.readOnly {
+		return ErrReadOnly
+	}
+
+	if ss.config.tokens != nil {
+		return ErrStatelessToken
+	}
+
+	if err := ss.begin(false); err != nil {
+		return err
+	}
+	defer ss.end()
+
+	if err := ss.checkLookups(ctx); err != nil {
+		return err
+	}
+	defer func() { ss.lookedUp(ctx, err) }()
+
+	if !ss.currentPolicy().accepts(key) {
+		return ErrForeignKey
+	}
+
+	ctx, release := ss.bind(ctx)
+	defer release()
+
+	if err := ss.checkLockdown(ctx, key); err != nil {
+		return err
+	}
+
+	err = ss.updateEntry(ctx, key, fn)
+	if err != ErrNoKeyFound {
+		return err
+	}
+
+	if _, newKey, ok := ss.graced(ctx, key); ok {
+		return ss.updateEntry(ctx, newKey, fn)
+	}
+
+	if err := ss.detectReplay(ctx, key); err != nil {
+		return err
+	}
+
+	return ErrNoKeyFound
+}
+
+// updateEntry replaces the data of the live entry stored under key with the
+// one fn returns.
+func (ss *SessionStorage) updateEntry(ctx context.Context, key string, fn func(old any) (any, error)) error {
+	defer ss.locks.lock(key)()
+
+	replace := func(e Entry) (Entry, error) {
+		if time.Until(e.ExpiresAt) <= 0 {
+			return Entry{}, ErrKeyWasExpired
+		}
+
+		if ss.revoked(e) {
+			return Entry{}, ErrNoKeyFound
+		}
+
+		if err := checkClient(ctx, e); err != nil {
+			return Entry{}, err
+		}
+
+		data, err := fn(e.Data)
+		if err != nil {
+			return Entry{}, err
+		}
+
+		if data == nil {
+			return Entry{}, ErrNilSession
+		}
+
+		if err := ss.checkSize(data); err != nil {
+			return Entry{}, err
+		}
+
+		e.Data = data
+		return e, nil
+	}
+
+	stored := ss.stored(key)
+	if u, ok := ss.storage.(updater); ok {
+		_, err := u.update(ctx, stored, replace)
+		return err
+	}
+
+	ss.journal.retry(context.WithoutCancel(ctx), ss.storage)
+
+	old, err := ss.storage.Get(ctx, stored)
+	if err != nil {
+		return err
+	}
+
+	e, err := replace(old)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ss.storage.Remove(ctx, stored); err != nil {
+		return err
+	}
+
+	if err := ss.storage.Set(ctx, stored, e); err != nil {
+		ss.restore(ctx, key, old)
+		return err
+	}
+
+	return nil
+}
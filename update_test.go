@@ -0,0 +1,161 @@
+package suk
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUpdate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	syncMapStorage, _ := New()
+	redisStorage, _ := New(WithRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil))
+	sqliteStorage, _ := New(WithSQLite(filepath.Join(t.TempDir(), "sessions.db")))
+	defer Destroy(sqliteStorage)
+
+	storages := map[string]*SessionStorage{"Sync map": syncMapStorage, "Redis": redisStorage, "SQLite": sqliteStorage}
+
+	for name, ss := range storages {
+		t.Run(name+" sessions are updated in place", func(t *testing.T) {
+			key, _ := ss.Set("cart")
+
+			if err := ss.Update(key, func(old any) any { return old.(string) + " with items" }); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			got, _, err := ss.Get(key)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got != "cart with items" {
+				t.Errorf("got %v expected %v", got, "cart with items")
+			}
+		})
+
+		t.Run(name+" unknown keys", func(t *testing.T) {
+			err := ss.Update("unknown", func(old any) any { return old })
+
+			if err != ErrNoKeyFound {
+				t.Errorf("got %v expected %v", err, ErrNoKeyFound)
+			}
+		})
+
+		t.Run(name+" nil sessions are refused", func(t *testing.T) {
+			key, _ := ss.Set("cart")
+
+			if err := ss.Update(key, func(any) any { return nil }); err != ErrNilSession {
+				t.Errorf("got %v expected %v", err, ErrNilSession)
+			}
+
+			if got, _ := ss.Peek(key); got != "cart" {
+				t.Errorf("got %v expected %v", got, "cart")
+			}
+		})
+	}
+
+	t.Run("Concurrent updates are not lost", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(0)
+
+		var wg sync.WaitGroup
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ss.Update(key, func(old any) any { return old.(int) + 1 })
+			}()
+		}
+		wg.Wait()
+
+		if got, _ := ss.Peek(key); got != 50 {
+			t.Errorf("got %v expected %v", got, 50)
+		}
+	})
+
+	t.Run("Expiration is kept", func(t *testing.T) {
+		ss, _ := New()
+		key, _ := ss.Set(1, WithTTL(20*time.Millisecond))
+
+		time.Sleep(10 * time.Millisecond)
+		ss.Update(key, func(any) any { return 2 })
+		time.Sleep(15 * time.Millisecond)
+
+		if err := ss.Update(key, func(any) any { return 3 }); err != ErrKeyWasExpired {
+			t.Errorf("got %v expected %v", err, ErrKeyWasExpired)
+		}
+	})
+
+	t.Run("Redis sessions keep their TTL", func(t *testing.T) {
+		key, _ := redisStorage.Set("cart")
+		before := mr.TTL(key)
+
+		redisStorage.Update(key, func(any) any { return "order" })
+
+		if got := mr.TTL(key); got != before {
+			t.Errorf("got %v expected %v", got, before)
+		}
+	})
+
+	t.Run("Multi-region sessions are updated in both regions", func(t *testing.T) {
+		mrA, mrB := miniredis.RunT(t), miniredis.RunT(t)
+		ssA, regionA := newTestRegion(t, mrA, mrB)
+		ssB, regionB := newTestRegion(t, mrB, mrA)
+
+		key, _ := ssA.Set("cart")
+		drain(regionA, regionB)
+
+		ssA.Update(key, func(any) any { return "order" })
+		drain(regionA, regionB)
+
+		if got, _ := ssB.Peek(key); got != "order" {
+			t.Errorf("got %v expected %v", got, "order")
+		}
+	})
+
+	t.Run("Graced keys update the rotated session", func(t *testing.T) {
+		ss, _ := New(WithRotationGracePeriod(time.Minute))
+
+		key, _ := ss.Set(1)
+		_, newKey, _ := ss.Get(key)
+
+		if err := ss.Update(key, func(any) any { return 2 }); err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		if got, _ := ss.Peek(newKey); got != 2 {
+			t.Errorf("got %v expected %v", got, 2)
+		}
+	})
+
+	t.Run("Read-only storage", func(t *testing.T) {
+		ss, _ := NewReadOnly(WithStorage(newShardedMap(1)))
+
+		if err := ss.Update("key", func(old any) any { return old }); err != ErrReadOnly {
+			t.Errorf("got %v expected %v", err, ErrReadOnly)
+		}
+	})
+
+	t.Run("Typed sessions are updated", func(t *testing.T) {
+		typed := NewTyped[user](redisStorage)
+		key, _ := typed.Set(user{ID: 42, Name: "Ana"})
+
+		err := typed.UpdateCtx(context.Background(), key, func(old user) user {
+			old.Roles = append(old.Roles, "admin")
+			return old
+		})
+		if err != nil {
+			t.Fatalf("got error %s", err.Error())
+		}
+
+		got, _, _ := typed.Get(key)
+		if got.Name != "Ana" || len(got.Roles) != 1 {
+			t.Errorf("got %v expected %v", got, user{ID: 42, Name: "Ana", Roles: []string{"admin"}})
+		}
+	})
+}
@@ -0,0 +1,263 @@
+package suk
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// usageReservoirSize is how many values each distribution keeps to estimate
+	// its percentiles.
+	usageReservoirSize = 1024
+
+	// maxTrackedSessions bounds how many sampled sessions are followed across
+	// their rotations at once.
+	maxTrackedSessions = 4096
+)
+
+// UsageStats reports the distributions measured over the sampled sessions,
+// meant for capacity planning, such as sizing a Redis deployment. Only sizes,
+// durations and counts are recorded, never the keys nor the sessions.
+type UsageStats struct {
+	// SampledSessions is the amount of issued sessions that were sampled.
+	SampledSessions uint64
+
+	// PayloadBytes is the size, in bytes, of the sampled sessions once encoded
+	// with encoding/gob, as most backends store them. Sessions that can't be
+	// encoded are not measured.
+	PayloadBytes Distribution
+
+	// RotationInterval is the time, in seconds, between the issuance of a key of
+	// a sampled session and its rotation.
+	RotationInterval Distribution
+
+	// TTLUsed is the fraction of their lifetime keys of sampled sessions had
+	// used up when rotated, from 0 to 1. Values close to 1 mean sessions are
+	// close to expiring between requests.
+	TTLUsed Distribution
+
+	// Rotations is the amount of times sampled sessions were rotated before
+	// being removed or expiring.
+	Rotations Distribution
+}
+
+// Distribution summarizes the values recorded for a measure. Percentiles are
+// estimated from a uniform sample of at most 1024 of the values.
+type Distribution struct {
+	Count uint64
+	Min   float64
+	Max   float64
+	Mean  float64
+	P50   float64
+	P90   float64
+	P99   float64
+}
+
+// reservoir records values, keeping a uniform sample of them.
+type reservoir struct {
+	count    uint64
+	min, max float64
+	sum      float64
+	values   []float64
+}
+
+func (r *reservoir) add(v float64) {
+	if r.count == 0 || v < r.min {
+		r.min = v
+	}
+
+	if r.count == 0 || v > r.max {
+		r.max = v
+	}
+
+	r.count++
+	r.sum += v
+
+	if len(r.values) < usageReservoirSize {
+		r.values = append(r.values, v)
+	} else if i := rand.Uint64N(r.count); i < usageReservoirSize {
+		r.values[i] = v
+	}
+}
+
+func (r *reservoir) distribution() Distribution {
+	if r.count == 0 {
+		return Distribution{}
+	}
+
+	values := slices.Clone(r.values)
+	slices.Sort(values)
+
+	percentile := func(p float64) float64 {
+		return values[int(math.Ceil(p*float64(len(values))))-1]
+	}
+
+	return Distribution{
+		Count: r.count,
+		Min:   r.min,
+		Max:   r.max,
+		Mean:  r.sum / float64(r.count),
+		P50:   percentile(0.5),
+		P90:   percentile(0.9),
+		P99:   percentile(0.99),
+	}
+}
+
+// trackedSession is a sampled session followed across its rotations.
+type trackedSession struct {
+	issuedAt  time.Time
+	expiresAt time.Time
+	rotations int
+}
+
+// usageSampler samples one of every few issued sessions, following them
+// across rotations. Sessions are tracked under the lookup key of their current
+// key, so no usable key is kept.
+type usageSampler struct {
+	mu sync.Mutex
+
+	every   uint64
+	issued  uint64
+	sampled uint64
+	tracked map[string]trackedSession
+
+	payloadBytes     reservoir
+	rotationInterval reservoir
+	ttlUsed          reservoir
+	rotations        reservoir
+}
+
+func newUsageSampler(every uint64) *usageSampler {
+	return &usageSampler{every: every, tracked: make(map[string]trackedSession)}
+}
+
+// observeIssued samples the session issued under key, if its turn came.
+func (us *usageSampler) observeIssued(key string, e Entry) {
+	us.mu.Lock()
+	us.issued++
+	sample := (us.issued-1)%us.every == 0
+	us.mu.Unlock()
+
+	if !sample {
+		return
+	}
+
+	// Encoding is the expensive part, so it's done without holding the lock.
+	data, err := encodeData(e.Data)
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	us.sampled++
+	if err == nil {
+		us.payloadBytes.add(float64(len(data)))
+	}
+
+	now := time.Now()
+	if len(us.tracked) >= maxTrackedSessions {
+		us.pruneExpired(now)
+	}
+
+	if len(us.tracked) < maxTrackedSessions {
+		us.tracked[lookupKey(key)] = trackedSession{issuedAt: now, expiresAt: e.ExpiresAt}
+	}
+}
+
+// observeRotated follows a sampled session from key to newKey.
+func (us *usageSampler) observeRotated(key, newKey string, e Entry) {
+	id := lookupKey(key)
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	ts, ok := us.tracked[id]
+	if !ok {
+		return
+	}
+	delete(us.tracked, id)
+
+	now := time.Now()
+	interval := now.Sub(ts.issuedAt)
+	us.rotationInterval.add(interval.Seconds())
+
+	if lifetime := ts.expiresAt.Sub(ts.issuedAt); lifetime > 0 {
+		us.ttlUsed.add(min(1, float64(interval)/float64(lifetime)))
+	}
+
+	us.tracked[lookupKey(newKey)] = trackedSession{
+		issuedAt:  now,
+		expiresAt: e.ExpiresAt,
+		rotations: ts.rotations + 1,
+	}
+}
+
+// observeRemoved stops following the sampled session under key, if any.
+func (us *usageSampler) observeRemoved(key string) {
+	id := lookupKey(key)
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	if ts, ok := us.tracked[id]; ok {
+		delete(us.tracked, id)
+		us.rotations.add(float64(ts.rotations))
+	}
+}
+
+// pruneExpired stops following the sampled sessions that expired. It must be
+// called with mu held.
+func (us *usageSampler) pruneExpired(now time.Time) {
+	for id, ts := range us.tracked {
+		if !now.Before(ts.expiresAt) {
+			delete(us.tracked, id)
+			us.rotations.add(float64(ts.rotations))
+		}
+	}
+}
+
+// reset stops following every sampled session, keeping the samples taken so
+// far.
+func (us *usageSampler) reset() {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	us.tracked = make(map[string]trackedSession)
+}
+
+func (us *usageSampler) snapshot() UsageStats {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	us.pruneExpired(time.Now())
+
+	return UsageStats{
+		SampledSessions:  us.sampled,
+		PayloadBytes:     us.payloadBytes.distribution(),
+		RotationInterval: us.rotationInterval.distribution(),
+		TTLUsed:          us.ttlUsed.distribution(),
+		Rotations:        us.rotations.distribution(),
+	}
+}
+
+// sampleIssued, sampleRotated and sampleRemoved feed the usage sampler, when
+// WithUsageSampling is set.
+func (ss *SessionStorage) sampleIssued(key string, e Entry) {
+	if ss.usage != nil {
+		ss.usage.observeIssued(key, e)
+	}
+}
+
+func (ss *SessionStorage) sampleRotated(key, newKey string, e Entry) {
+	if ss.usage != nil {
+		ss.usage.observeRotated(key, newKey, e)
+	}
+}
+
+func (ss *SessionStorage) sampleRemoved(key string) {
+	if ss.usage != nil {
+		ss.usage.observeRemoved(key)
+	}
+}
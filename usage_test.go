@@ -0,0 +1,144 @@
+package suk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageSampling(t *testing.T) {
+	t.Run("Stats without usage sampling", func(t *testing.T) {
+		ss, _ := New()
+
+		if got := ss.Stats().Usage; got != nil {
+			t.Errorf("got %v expected nil", got)
+		}
+	})
+
+	t.Run("Zero sampling rate", func(t *testing.T) {
+		_, err := New(WithUsageSampling(0))
+
+		if !errors.Is(err, ErrZeroUsageSamplingRate) {
+			t.Errorf("got %v expected %v", err, ErrZeroUsageSamplingRate)
+		}
+	})
+
+	t.Run("Usage sampling samples every other session", func(t *testing.T) {
+		ss, _ := New(WithUsageSampling(2))
+
+		for i := range 10 {
+			ss.Set(i)
+		}
+
+		usage := ss.Stats().Usage
+		var expected uint64 = 5
+
+		if usage.SampledSessions != expected {
+			t.Errorf("got %d expected %d", usage.SampledSessions, expected)
+		}
+
+		if usage.PayloadBytes.Count != expected {
+			t.Errorf("got %d expected %d", usage.PayloadBytes.Count, expected)
+		}
+	})
+
+	t.Run("Payload sizes are measured", func(t *testing.T) {
+		ss, _ := New(WithUsageSampling(1))
+
+		ss.Set("small")
+		ss.Set(strings.Repeat("large", 100))
+
+		got := ss.Stats().Usage.PayloadBytes
+
+		if got.Max-got.Min < 400 {
+			t.Errorf("got sizes from %f to %f expected them about 495 bytes apart", got.Min, got.Max)
+		}
+
+		if got.P99 != got.Max {
+			t.Errorf("got %f expected %f", got.P99, got.Max)
+		}
+	})
+
+	t.Run("Sessions are followed across rotations", func(t *testing.T) {
+		ss, _ := New(WithUsageSampling(1), WithKeyDuration(time.Second))
+
+		key, _ := ss.Set("session")
+		time.Sleep(100 * time.Millisecond)
+
+		for range 3 {
+			_, key, _ = ss.Get(key)
+		}
+		ss.Remove(key)
+
+		usage := ss.Stats().Usage
+
+		if usage.RotationInterval.Count != 3 {
+			t.Errorf("got %d expected %d", usage.RotationInterval.Count, 3)
+		}
+
+		if usage.RotationInterval.Max < 0.1 {
+			t.Errorf("got %f expected at least %f", usage.RotationInterval.Max, 0.1)
+		}
+
+		if usage.TTLUsed.Max < 0.1 || usage.TTLUsed.Max > 1 {
+			t.Errorf("got %f expected between %f and %f", usage.TTLUsed.Max, 0.1, 1.0)
+		}
+
+		if usage.Rotations.Count != 1 || usage.Rotations.Max != 3 {
+			t.Errorf("got %d sessions with %f rotations expected %d with %d", usage.Rotations.Count, usage.Rotations.Max, 1, 3)
+		}
+	})
+
+	t.Run("Expired sessions are counted", func(t *testing.T) {
+		ss, _ := New(WithUsageSampling(1), WithKeyDuration(10*time.Millisecond))
+
+		ss.Set("session")
+		time.Sleep(20 * time.Millisecond)
+
+		got := ss.Stats().Usage.Rotations
+
+		if got.Count != 1 || got.Max != 0 {
+			t.Errorf("got %d sessions with %f rotations expected %d with %d", got.Count, got.Max, 1, 0)
+		}
+	})
+
+	t.Run("Usage sampling can't be reconfigured", func(t *testing.T) {
+		ss, _ := New()
+
+		if err := ss.Reconfigure(WithUsageSampling(1)); err != ErrNotReconfigurable {
+			t.Errorf("got %v expected %v", err, ErrNotReconfigurable)
+		}
+	})
+}
+
+func TestReservoir(t *testing.T) {
+	t.Run("Percentiles of a uniform distribution", func(t *testing.T) {
+		var r reservoir
+		for i := 1; i <= 100; i++ {
+			r.add(float64(i))
+		}
+
+		got := r.distribution()
+		expected := Distribution{Count: 100, Min: 1, Max: 100, Mean: 50.5, P50: 50, P90: 90, P99: 99}
+
+		if got != expected {
+			t.Errorf("got %+v expected %+v", got, expected)
+		}
+	})
+
+	t.Run("Values kept are bounded", func(t *testing.T) {
+		var r reservoir
+		for i := range 10 * usageReservoirSize {
+			r.add(float64(i))
+		}
+
+		if len(r.values) != usageReservoirSize {
+			t.Errorf("got %d expected %d", len(r.values), usageReservoirSize)
+		}
+
+		if got := r.distribution().Count; got != 10*usageReservoirSize {
+			t.Errorf("got %d expected %d", got, 10*usageReservoirSize)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package suk
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrWatermarkUnsupported = errors.New("The session storage backend can't keep watermarks.")
+
+// watermarkRefreshInterval is how often the watermarks are read back from the
+// storage, so the revocations made by other instances apply here too.
+const watermarkRefreshInterval = time.Second
+
+// The names of the watermarks, holding the epoch bumped by InvalidateAll and
+// the time given to RevokeCreatedBefore, in Unix nanoseconds.
+const (
+	epochWatermark         = "epoch"
+	revokedBeforeWatermark = "revoked_before"
+)
+
+// Watermarker may be implemented by storages able to keep the watermarks of
+// the revocations, such as the epoch bumped by InvalidateAll, so they survive
+// restarts and are shared by every instance using the storage. Storages not
+// implementing it only keep them in the process.
+type Watermarker interface {
+	// RaiseWatermark raises the watermark with the given name to value,
+	// unless it is already higher, and returns it. Watermarks never raised
+	// are zero.
+	RaiseWatermark(ctx context.Context, name string, value int64) (int64, error)
+}
+
+// watermarkKey returns the Redis key of the watermark with the given name.
+func watermarkKey(name string) string {
+	return "watermark:" + name
+}
+
+// RaiseWatermark keeps the watermarks under the prefix, outside of the
+// segments of the epochs. While the prefix is being migrated, the watermarks
+// under the previous one are taken into account too.
+func (r *redisDB) RaiseWatermark(ctx context.Context, name string, value int64) (int64, error) {
+	p := r.prefixes.Load()
+
+	keys := []string{p.current + watermarkKey(name)}
+	if p.migrating {
+		keys = append(keys, p.previous+watermarkKey(name))
+	}
+
+	return raiseRedisWatermark(ctx, r.client, keys, value)
+}
+
+// RaiseWatermark raises the watermark in both regions, so the instances of
+// each share it, and returns the highest one.
+func (m *multiRegionRedis) RaiseWatermark(ctx context.Context, name string, value int64) (int64, error) {
+	key := []string{watermarkKey(name)}
+
+	raised, err := raiseRedisWatermark(ctx, m.other, key, value)
+	if err != nil {
+		return 0, err
+	}
+
+	return raiseRedisWatermark(ctx, m.nearest, key, raised)
+}
+
+// raiseRedisWatermark raises the watermark held by the first of the keys to
+// the highest of value and the ones held by every key, watching them so
+// concurrent raises are never lost. Watermarks are held by hashes without
+// data, which the scans over the sessions skip.
+func raiseRedisWatermark(ctx context.Context, client *redis.Client, keys []string, value int64) (int64, error) {
+	for {
+		raised := value
+		err := client.Watch(ctx, func(tx *redis.Tx) error {
+			var current int64
+			for i, key := range keys {
+				stored, err := tx.HGet(ctx, key, "value").Int64()
+				if err != nil && err != redis.Nil {
+					return err
+				}
+
+				if i == 0 {
+					current = stored
+				}
+				raised = max(raised, stored)
+			}
+
+			if raised <= current {
+				return nil
+			}
+
+			_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, keys[0], "value", raised)
+				return nil
+			})
+			return err
+		}, keys...)
+
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		return raised, nil
+	}
+}
+
+func (s *sqliteDB) RaiseWatermark(ctx context.Context, name string, value int64) (int64, error) {
+	var raised int64
+	err := s.db.QueryRowContext(
+		ctx,
+		`INSERT INTO suk_watermarks (name, value) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = max(value, excluded.value)
+		RETURNING value`,
+		name,
+		value,
+	).Scan(&raised)
+
+	return raised, err
+}
+
+func (s *sealedStorage) RaiseWatermark(ctx context.Context, name string, value int64) (int64, error) {
+	w, ok := s.Storage.(Watermarker)
+	if !ok {
+		return 0, ErrWatermarkUnsupported
+	}
+
+	return w.RaiseWatermark(ctx, name, value)
+}
+
+// raiseWatermark raises the watermark with the given name in the storage and
+// returns it, or returns value as it is when the storage can't keep it.
+func (ss *SessionStorage) raiseWatermark(ctx context.Context, name string, value int64) (int64, error) {
+	w, ok := ss.storage.(Watermarker)
+	if !ok {
+		return value, nil
+	}
+
+	raised, err := w.RaiseWatermark(ctx, name, value)
+	if err == ErrWatermarkUnsupported {
+		return value, nil
+	}
+
+	return raised, err
+}
+
+// refreshWatermarks reads the watermarks kept by the storage, so the
+// revocations made before a restart, or by other instances, apply here too.
+func (ss *SessionStorage) refreshWatermarks(ctx context.Context) error {
+	epoch, err := ss.raiseWatermark(ctx, epochWatermark, 0)
+	if err != nil {
+		return err
+	}
+
+	ss.advanceEpoch(uint64(epoch))
+
+	before, err := ss.raiseWatermark(ctx, revokedBeforeWatermark, 0)
+	if err != nil {
+		return err
+	}
+
+	ss.revokeBefore(before)
+	return nil
+}
+
+// scheduleWatermarkRefresh loads the watermarks kept by the storage, and reads
+// them back periodically, if it keeps any. Failures to load them are logged,
+// as the backend may be unreachable for now, such as during a Redis outage.
+func (ss *SessionStorage) scheduleWatermarkRefresh() {
+	if _, ok := ss.storage.(Watermarker); !ok {
+		return
+	}
+
+	if err := ss.refreshWatermarks(ss.ctx); err != nil {
+		ss.config.logger.Error("suk: failed to load the watermarks", "error", err)
+	}
+
+	ss.stops = append(ss.stops, ss.schedule("watermark refresh", watermarkRefreshInterval, func() {
+		ss.refreshWatermarks(ss.ctx)
+	}))
+}
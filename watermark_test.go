@@ -0,0 +1,64 @@
+package suk
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWatermark(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range persistentBackends {
+		t.Run(name+" watermarks only move forward", func(t *testing.T) {
+			ss, _ := New(backend(t))
+			defer Destroy(ss)
+
+			w := ss.storage.(Watermarker)
+
+			if got, err := w.RaiseWatermark(ctx, "test", 0); err != nil || got != 0 {
+				t.Errorf("got %d and error %v expected %d", got, err, 0)
+			}
+
+			if got, err := w.RaiseWatermark(ctx, "test", 1<<62); err != nil || got != 1<<62 {
+				t.Errorf("got %d and error %v expected %d", got, err, int64(1<<62))
+			}
+
+			if got, err := w.RaiseWatermark(ctx, "test", 3); err != nil || got != 1<<62 {
+				t.Errorf("got %d and error %v expected %d", got, err, int64(1<<62))
+			}
+		})
+
+		t.Run(name+" watermarks are not sessions", func(t *testing.T) {
+			ss, _ := New(backend(t))
+			defer Destroy(ss)
+
+			ss.InvalidateAll()
+			ss.Set("session")
+
+			if n, err := ss.Count(); err != nil || n != 1 {
+				t.Errorf("got %d and error %v expected %d", n, err, 1)
+			}
+
+			if err := ss.Clear(); err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+
+			if got, _ := ss.raiseWatermark(ctx, epochWatermark, 0); got != 1 {
+				t.Errorf("got %d expected %d", got, 1)
+			}
+		})
+	}
+
+	t.Run("Watermarks of storages not keeping them stay in the process", func(t *testing.T) {
+		ss, _ := New(WithStorage(basicStorage{newShardedMap(1)}), WithEncryption(make([]byte, 32)))
+		ss.InvalidateAll()
+
+		if _, err := ss.storage.(Watermarker).RaiseWatermark(ctx, epochWatermark, 0); err != ErrWatermarkUnsupported {
+			t.Errorf("got %v expected %v", err, ErrWatermarkUnsupported)
+		}
+
+		if got := ss.epoch.Load(); got != 1 {
+			t.Errorf("got %d expected %d", got, 1)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package suk
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// The bounds of the delay before restarting a worker that panicked, doubled
+// at each panic in a row, so a worker panicking right away doesn't spin.
+const (
+	minWorkerRestartDelay = 10 * time.Millisecond
+	maxWorkerRestartDelay = 30 * time.Second
+)
+
+// runWorker runs work in a new goroutine, restarting it whenever it panics, so
+// a panic in a background worker, such as the auto clear, is reported through
+// the logger instead of silently stopping the worker forever. Restarts back
+// off exponentially, starting over once the worker ran for longer than the
+// longest delay.
+func (ss *SessionStorage) runWorker(name string, work func()) {
+	go func() {
+		delay := minWorkerRestartDelay
+		for {
+			started := time.Now()
+			if ss.safeRun(name, work) {
+				return
+			}
+
+			if time.Since(started) > maxWorkerRestartDelay {
+				delay = minWorkerRestartDelay
+			}
+
+			time.Sleep(delay)
+			delay = min(2*delay, maxWorkerRestartDelay)
+		}
+	}()
+}
+
+// safeRun runs work, recovering from any panic. It reports whether work
+// finished without panicking.
+func (ss *SessionStorage) safeRun(name string, work func()) (finished bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			ss.config.logger.Error(
+				"suk: background worker panicked, restarting it",
+				"worker", name,
+				"panic", v,
+				"stack", string(debug.Stack()),
+			)
+		}
+	}()
+
+	work()
+	return true
+}
@@ -0,0 +1,66 @@
+package suk
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunWorker(t *testing.T) {
+	t.Run("Worker is restarted after panicking", func(t *testing.T) {
+		var (
+			buf bytes.Buffer
+			mu  sync.Mutex
+		)
+
+		ss, _ := New(WithLogger(slog.New(slog.NewTextHandler(&lockedWriter{&mu, &buf}, nil))))
+
+		var starts []time.Time
+		done := make(chan struct{})
+		ss.runWorker("test", func() {
+			starts = append(starts, time.Now())
+			if len(starts) < 3 {
+				panic("boom")
+			}
+			close(done)
+		})
+
+		<-done
+
+		if len(starts) != 3 {
+			t.Fatalf("got %d expected %d", len(starts), 3)
+		}
+
+		// The second restart waits twice as long as the first one.
+		if got := starts[2].Sub(starts[1]); got < 2*minWorkerRestartDelay {
+			t.Errorf("got %v expected at least %v", got, 2*minWorkerRestartDelay)
+		}
+
+		if got := starts[1].Sub(starts[0]); got < minWorkerRestartDelay {
+			t.Errorf("got %v expected at least %v", got, minWorkerRestartDelay)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if got := strings.Count(buf.String(), "worker=test panic=boom"); got != 2 {
+			t.Errorf("got %d panics logged expected %d", got, 2)
+		}
+	})
+}
+
+// lockedWriter serializes writes to an underlying buffer, so it can be read
+// safely by the test.
+type lockedWriter struct {
+	mu  *sync.Mutex
+	buf *bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}